@@ -133,6 +133,40 @@ func (c *Client) GetStyle(id string, draft bool) (style.Style, error) {
 	return s, nil
 }
 
+// LoadStyle fetches the style behind a mapbox://styles/{owner}/{id} URL,
+// so that a Client can be used as a style.StyleLoader for imports.
+func (c *Client) LoadStyle(styleURL string) (*style.Style, error) {
+	const prefix = "mapbox://styles/"
+	if !strings.HasPrefix(styleURL, prefix) {
+		return nil, errors.Errorf("unsupported style url %q", styleURL)
+	}
+	parts := strings.Split(strings.TrimPrefix(styleURL, prefix), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid style url %q", styleURL)
+	}
+
+	url := c.baseURL
+	url.Path = path.Join(url.Path, "styles/v1/", parts[0], parts[1])
+	if len(parts) > 2 && parts[2] == "draft" {
+		url.Path = path.Join(url.Path, "draft")
+	}
+
+	var s style.Style
+
+	_, err := c.do("GET", url, nil, &s)
+	if err != nil {
+		return nil, errors.Wrap(err, "making request")
+	}
+
+	return &s, nil
+}
+
+// URLResolver returns a style.URLResolver for the client's base URL and
+// access token.
+func (c *Client) URLResolver() *style.URLResolver {
+	return style.NewURLResolver(c.baseURL.String(), c.token)
+}
+
 func (c *Client) ListTilesets(params tilejson.ListTilesetsParams) ([]tilejson.Tileset, error) {
 	url := c.baseURL
 	url.Path = path.Join(url.Path, "tilesets/v1/", c.username)
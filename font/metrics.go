@@ -0,0 +1,26 @@
+package fonts
+
+import "github.com/flywave/go-mapbox/symbol"
+
+// Metrics returns the glyph metrics of all font stacks for symbol layout.
+// Glyphs of font stacks that span several ranges are merged by name.
+func (g *Glyphs) Metrics() symbol.GlyphSet {
+	set := symbol.GlyphSet{}
+	for _, stack := range g.Stacks {
+		glyphs, ok := set[stack.Name]
+		if !ok {
+			glyphs = map[rune]symbol.GlyphMetrics{}
+			set[stack.Name] = glyphs
+		}
+		for _, glyph := range stack.Glyphs {
+			glyphs[rune(glyph.ID)] = symbol.GlyphMetrics{
+				Width:   glyph.Width,
+				Height:  glyph.Height,
+				Left:    glyph.Left,
+				Top:     glyph.Top,
+				Advance: glyph.Advance,
+			}
+		}
+	}
+	return set
+}
@@ -18,6 +18,7 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/image v0.28.0
+	golang.org/x/text v0.26.0
 )
 
 require (
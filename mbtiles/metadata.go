@@ -127,6 +127,20 @@ type Attribute struct {
 	Values []interface{} `json:"values"`
 }
 
+// Attributes returns the attribute names of each layer, keyed by layer
+// name, in the form style.LintSource expects.
+func (t *TileStats) Attributes() map[string][]string {
+	out := make(map[string][]string, len(t.Layers))
+	for _, l := range t.Layers {
+		names := make([]string, 0, len(l.Attributes))
+		for _, a := range l.Attributes {
+			names = append(names, a.Name)
+		}
+		out[l.Name] = names
+	}
+	return out
+}
+
 func StringToTileFormat(s string) TileFormat {
 	for i, k := range formatStrings {
 		if k == s {
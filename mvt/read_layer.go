@@ -1,6 +1,9 @@
 package mvt
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/flywave/go-pbf"
 )
 
@@ -19,71 +22,118 @@ type Layer struct {
 	Proto           Proto
 }
 
-func (tile *Tile) NewLayer(endpos int, pt ProtoType) {
+// NewLayer reads the layer message ending at endpos, starting at the
+// current position of the tile buffer, and adds it to the tile.
+func (tile *Tile) NewLayer(endpos int, pt ProtoType) error {
 	proto := getProto(pt)
+	if endpos > tile.Buf.Length {
+		return errTruncated
+	}
 	layer := &Layer{StartPos: tile.Buf.Pos, EndPos: endpos, Proto: proto}
-	key, val := tile.Buf.ReadTag()
 	for tile.Buf.Pos < layer.EndPos {
-		if key == proto.Layer.Name && val == pbf.Bytes {
+		key, val := tile.Buf.ReadTag()
+		var err error
+		switch {
+		case key == proto.Layer.Name && val == pbf.Bytes:
 			layer.Name = tile.Buf.ReadString()
-			tile.Layers = append(tile.Layers, layer.Name)
-			key, val = tile.Buf.ReadTag()
-		}
-		for key == proto.Layer.Features && val == pbf.Bytes {
+		case key == proto.Layer.Features && val == pbf.Bytes:
 			layer.features = append(layer.features, tile.Buf.Pos)
-			feat_size := tile.Buf.ReadVarint()
-
-			tile.Buf.Pos += feat_size
-			key, val = tile.Buf.ReadTag()
-		}
-		for key == proto.Layer.Keys && val == pbf.Bytes {
+			err = skipField(tile.Buf, val, layer.EndPos)
+		case key == proto.Layer.Keys && val == pbf.Bytes:
 			layer.Keys = append(layer.Keys, tile.Buf.ReadString())
-			key, val = tile.Buf.ReadTag()
-		}
-		for key == proto.Layer.Values && val == pbf.Bytes {
-			tile.Buf.ReadVarint()
-			newkey, _ := tile.Buf.ReadTag()
-			switch newkey {
-			case proto.Value.StringValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadString())
-			case proto.Value.FloatValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadFloat())
-			case proto.Value.DoubleValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadDouble())
-			case proto.Value.IntValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadInt64())
-			case proto.Value.UIntValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadUInt64())
-			case proto.Value.SIntValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadUInt64())
-			case proto.Value.BoolIntValue:
-				layer.Values = append(layer.Values, tile.Buf.ReadBool())
-			}
-			key, val = tile.Buf.ReadTag()
-		}
-		if key == proto.Layer.Extent && val == pbf.Varint {
+		case key == proto.Layer.Values && val == pbf.Bytes:
+			var value interface{}
+			value, err = tile.readValue(proto, layer.EndPos)
+			layer.Values = append(layer.Values, value)
+		case key == proto.Layer.Extent && val == pbf.Varint:
 			layer.Extent = int(tile.Buf.ReadVarint())
-			key, val = tile.Buf.ReadTag()
-		}
-		if key == proto.Layer.Version && val == pbf.Varint {
+		case key == proto.Layer.Version && val == pbf.Varint:
 			layer.Version = int(tile.Buf.ReadVarint())
-			key, val = tile.Buf.ReadTag()
+		default:
+			err = skipField(tile.Buf, val, layer.EndPos)
 		}
-		if tile.Buf.Pos < layer.EndPos && !(key == proto.Layer.Name || key == proto.Layer.Features ||
-			key == proto.Layer.Keys || key == proto.Layer.Values ||
-			key == proto.Layer.Extent || key == proto.Layer.Version) {
-			key, val = tile.Buf.ReadTag()
+		if err != nil {
+			return err
 		}
 	}
+	if tile.Buf.Pos > layer.EndPos {
+		return errTruncated
+	}
 
 	if layer.Extent == 0 {
 		layer.Extent = 4096
 	}
 
 	layer.Number_Features = len(layer.features)
+	tile.Layers = append(tile.Layers, layer.Name)
 	tile.LayerMap[layer.Name] = layer
 	tile.Buf.Pos = endpos
 	layer.Buf = tile.Buf
+	return nil
+}
+
+// readValue reads a length delimited value message of the layer value
+// table, which must end by endpos.
+func (tile *Tile) readValue(proto Proto, endpos int) (value interface{}, err error) {
+	end := tile.Buf.ReadVarint()
+	end += tile.Buf.Pos
+	if end > endpos {
+		return nil, errTruncated
+	}
+	for tile.Buf.Pos < end {
+		key, val := tile.Buf.ReadTag()
+		switch key {
+		case proto.Value.StringValue:
+			value = tile.Buf.ReadString()
+		case proto.Value.FloatValue:
+			value = tile.Buf.ReadFloat()
+		case proto.Value.DoubleValue:
+			value = tile.Buf.ReadDouble()
+		case proto.Value.IntValue:
+			value = tile.Buf.ReadInt64()
+		case proto.Value.UIntValue:
+			value = tile.Buf.ReadUInt64()
+		case proto.Value.SIntValue:
+			value = int64(tile.Buf.ReadSVarint())
+		case proto.Value.BoolIntValue:
+			value = tile.Buf.ReadBool()
+		default:
+			if err := skipField(tile.Buf, val, end); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if tile.Buf.Pos > end {
+		return nil, errTruncated
+	}
+	return value, nil
+}
+
+var errTruncated = errors.New("truncated vector tile")
+
+// skipField moves buf past the value of a field of the given wire type,
+// which must end by endpos.
+func skipField(buf *pbf.Reader, val pbf.WireType, endpos int) error {
+	switch val {
+	case pbf.Varint:
+		buf.ReadVarint()
+	case pbf.Bytes:
+		size := buf.ReadVarint()
+		if size < 0 || size > endpos-buf.Pos {
+			return errTruncated
+		}
+		buf.Pos += size
+	case pbf.Fixed32:
+		buf.Pos += 4
+	case pbf.Fixed64:
+		buf.Pos += 8
+	default:
+		return fmt.Errorf("unsupported wire type %d", val)
+	}
+	if buf.Pos > endpos {
+		return errTruncated
+	}
+	return nil
 }
 
 func (layer *Layer) Next() bool {
@@ -0,0 +1,34 @@
+package mvt
+
+import "testing"
+
+func TestNewTileMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		// Field 1, wire type 3: a group, which vector tiles never use.
+		{"group wire type", []byte{0x0b, 0x00}},
+		// A layer of 10 bytes with only 3 present.
+		{"truncated layer", []byte{0x1a, 0x0a, 0x0a, 0x01, 0x61}},
+		// A layer of 4 bytes holding a feature of 9 bytes.
+		{"feature past layer", []byte{0x1a, 0x04, 0x12, 0x09, 0x00, 0x00, 0x0a, 0x01, 0x61}},
+		// A layer whose value message ends past the layer.
+		{"value past layer", []byte{0x1a, 0x04, 0x22, 0x05, 0x0a, 0x01, 0x61, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		tile, err := NewTile(tt.data, PROTO_MAPBOX)
+		if err == nil {
+			t.Errorf("%s: expected error, got %+v", tt.name, tile)
+		}
+	}
+
+	// name "a", extent 512, version 2 last.
+	tile, err := NewTile([]byte{0x1a, 0x08, 0x0a, 0x01, 0x61, 0x28, 0x80, 0x04, 0x78, 0x02}, PROTO_MAPBOX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := tile.LayerMap["a"]; l == nil || l.Extent != 512 || l.Version != 2 {
+		t.Errorf("layer = %+v", tile.LayerMap["a"])
+	}
+}
@@ -37,9 +37,12 @@ func NewTile(bytevals []byte, pt ProtoType) (tile *Tile, err error) {
 		if key == proto.Layers && val == pbf.Bytes {
 			size := tile.Buf.ReadVarint()
 			if size != 0 {
-				tile.NewLayer(tile.Buf.Pos+size, pt)
+				if err := tile.NewLayer(tile.Buf.Pos+size, pt); err != nil {
+					return nil, err
+				}
 			}
-
+		} else if err := skipField(tile.Buf, val, tile.Buf.Length); err != nil {
+			return nil, err
 		}
 	}
 	return tile, err
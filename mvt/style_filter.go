@@ -0,0 +1,64 @@
+package mvt
+
+import (
+	"github.com/flywave/go-geom"
+	"github.com/flywave/go-mapbox/style"
+)
+
+// StyleFeature returns the feature as seen by style filters and expressions.
+// A zero ID, which the tile format uses for features without one, has no
+// id, as in ReadTile.
+func (feature *Feature) StyleFeature() *style.Feature {
+	f := &style.Feature{
+		Type:       feature.Type,
+		Properties: feature.Properties,
+	}
+	if feature.ID != 0 {
+		f.ID = feature.ID
+	}
+	return f
+}
+
+// StyleFeatureFromGeom adapts a decoded GeoJSON feature, as returned by
+// ReadTile, for style filter evaluation.
+func StyleFeatureFromGeom(feature *geom.Feature) *style.Feature {
+	return &style.Feature{
+		ID:         feature.ID,
+		Type:       feature.GeometryData.Type,
+		Properties: feature.Properties,
+	}
+}
+
+// CountLayerMatches counts, for every style layer reading from this tile,
+// which belongs to the style source with the given id, the features of its
+// source layer that pass the layer filter at zoom. The result is keyed by
+// style layer id.
+func (tile *Tile) CountLayerMatches(source string, layers []*style.Layer, zoom float64) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, l := range layers {
+		if l == nil || l.Source == nil || *l.Source != source || l.SourceLayer == nil {
+			continue
+		}
+		counts[l.ID] = 0
+		layer, ok := tile.LayerMap[*l.SourceLayer]
+		if !ok {
+			continue
+		}
+		layer.Reset()
+		for layer.Next() {
+			feature, err := layer.Feature()
+			if err != nil {
+				return nil, err
+			}
+			match, err := l.Matches(feature.StyleFeature(), zoom)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				counts[l.ID]++
+			}
+		}
+		layer.Reset()
+	}
+	return counts, nil
+}
@@ -0,0 +1,55 @@
+package mvt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/flywave/go-mapbox/style"
+)
+
+// message prefixes b with the key of a length delimited field and its
+// length.
+func message(key byte, b ...byte) []byte {
+	return append([]byte{key, byte(len(b))}, b...)
+}
+
+func TestCountLayerMatches(t *testing.T) {
+	withID := []byte{0x08, 0x07, 0x18, 0x01, 0x22, 0x03, 0x09, 0x02, 0x02}
+	withoutID := []byte{0x18, 0x01, 0x22, 0x03, 0x09, 0x04, 0x04}
+	layer := message(0x0a, []byte("roads")...)
+	layer = append(layer, message(0x12, withID...)...)
+	layer = append(layer, message(0x12, withoutID...)...)
+	tile, err := NewTile(message(0x1a, layer...), PROTO_MAPBOX)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var layers []*style.Layer
+	if err := json.Unmarshal([]byte(`[
+		{"id": "all", "type": "line", "source": "streets", "source-layer": "roads"},
+		{"id": "with-id", "type": "line", "source": "streets", "source-layer": "roads", "filter": ["has", "$id"]},
+		{"id": "other-source", "type": "line", "source": "satellite", "source-layer": "roads"}
+	]`), &layers); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := tile.CountLayerMatches("streets", append(layers, nil), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts["all"] != 2 || counts["with-id"] != 1 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	l := tile.LayerMap["roads"]
+	var ids []interface{}
+	for l.Next() {
+		f, err := l.Feature()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, f.StyleFeature().ID)
+	}
+	if len(ids) != 2 || ids[0] != 7 || ids[1] != nil {
+		t.Errorf("ids = %v", ids)
+	}
+}
@@ -0,0 +1,285 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/flywave/go-mapbox/sprite"
+	"github.com/flywave/go-mapbox/style"
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// LegendOptions control the look of a rendered legend. Zero values use the
+// defaults of DefaultLegendOptions.
+type LegendOptions struct {
+	Width      int
+	SwatchSize int
+	Padding    int
+	Background color.Color
+	TextColor  color.Color
+	Face       font.Face
+	// Atlas and Icons are a sprite sheet and its index, used for icon
+	// swatches.
+	Atlas image.Image
+	Icons map[string]*sprite.TextureSprite
+}
+
+// DefaultLegendOptions returns a 240px wide legend on white.
+func DefaultLegendOptions() LegendOptions {
+	return LegendOptions{
+		Width:      240,
+		SwatchSize: 20,
+		Padding:    8,
+		Background: color.White,
+		TextColor:  color.Black,
+		Face:       basicfont.Face7x13,
+	}
+}
+
+func (o *LegendOptions) setDefaults() {
+	def := DefaultLegendOptions()
+	if o.Width <= 0 {
+		o.Width = def.Width
+	}
+	if o.SwatchSize <= 0 {
+		o.SwatchSize = def.SwatchSize
+	}
+	if o.Padding <= 0 {
+		o.Padding = def.Padding
+	}
+	if o.Background == nil {
+		o.Background = def.Background
+	}
+	if o.TextColor == nil {
+		o.TextColor = def.TextColor
+	}
+	if o.Face == nil {
+		o.Face = def.Face
+	}
+}
+
+// RenderLegend draws a legend as a list of swatches with labels. A layer
+// with several entries gets a heading; ramps are drawn as a gradient bar
+// with the stop values below it.
+func RenderLegend(legend *style.Legend, opts LegendOptions) (*image.RGBA, error) {
+	if legend == nil {
+		return nil, errors.New("nil legend")
+	}
+	opts.setDefaults()
+	row := opts.SwatchSize + opts.Padding/2
+	height := opts.Padding
+	for _, l := range legend.Layers {
+		switch {
+		case l.Ramp:
+			height += 3 * row
+		case len(l.Entries) > 1:
+			height += (len(l.Entries) + 1) * row
+		default:
+			height += len(l.Entries) * row
+		}
+	}
+	height += opts.Padding
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	lr := &legendRenderer{img: img, opts: opts, row: row, raster: vector.NewRasterizer(0, 0)}
+	y := opts.Padding
+	for _, l := range legend.Layers {
+		if l.Ramp || len(l.Entries) > 1 {
+			lr.text(l.Label, opts.Padding, y)
+			y += row
+		}
+		if l.Ramp {
+			lr.ramp(l, y)
+			y += 2 * row
+			continue
+		}
+		for _, e := range l.Entries {
+			rect := image.Rect(opts.Padding, y, opts.Padding+opts.SwatchSize, y+opts.SwatchSize)
+			lr.swatch(l.Swatch, e, rect)
+			lr.text(e.Label, rect.Max.X+opts.Padding, y)
+			y += row
+		}
+	}
+	return img, nil
+}
+
+type legendRenderer struct {
+	img    *image.RGBA
+	opts   LegendOptions
+	row    int
+	raster *vector.Rasterizer
+}
+
+// text draws s vertically centered in the row starting at y.
+func (r *legendRenderer) text(s string, x, y int) {
+	m := r.opts.Face.Metrics()
+	baseline := y + (r.opts.SwatchSize+(m.Ascent-m.Descent).Ceil())/2
+	d := &font.Drawer{
+		Dst:  r.img,
+		Src:  image.NewUniform(r.opts.TextColor),
+		Face: r.opts.Face,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(s)
+}
+
+func (r *legendRenderer) fill(rect image.Rectangle, c color.Color, build func()) {
+	if c == nil {
+		return
+	}
+	r.raster.Reset(rect.Dx(), rect.Dy())
+	build()
+	r.raster.Draw(r.img, rect, image.NewUniform(c), image.Point{})
+}
+
+func (r *legendRenderer) swatch(t style.SwatchType, e *style.LegendEntry, rect image.Rectangle) {
+	size := float32(r.opts.SwatchSize)
+	switch t {
+	case style.SwatchFill:
+		draw.Draw(r.img, rect, image.NewUniform(withOpacity(e.Color, e.Opacity)), image.Point{}, draw.Over)
+		if e.OutlineColor != nil {
+			r.fill(rect, withOpacity(e.OutlineColor, e.Opacity), func() {
+				r.rect(0, 0, size, size)
+				r.reverseRect(1, 1, size-1, size-1)
+			})
+		}
+	case style.SwatchLine:
+		width := float32(math.Max(1, math.Min(e.LineWidth, float64(size))))
+		r.fill(rect, withOpacity(e.Color, e.Opacity), func() {
+			for _, dash := range dashes(float64(size), e.LineDash, float64(width)) {
+				r.rect(float32(dash[0]), (size-width)/2, float32(dash[1]), (size+width)/2)
+			}
+		})
+	case style.SwatchCircle:
+		radius := float32(math.Max(1, math.Min(e.Radius, float64(size)/2)))
+		stroke := float32(math.Min(e.LineWidth, float64(radius)))
+		inner := radius - stroke
+		r.fill(rect, withOpacity(e.Color, e.Opacity), func() {
+			r.circle(size/2, size/2, inner, false)
+		})
+		if stroke > 0 {
+			r.fill(rect, withOpacity(e.OutlineColor, e.Opacity), func() {
+				r.circle(size/2, size/2, radius, false)
+				r.circle(size/2, size/2, inner, true)
+			})
+		}
+	case style.SwatchIcon:
+		icon, ok := r.opts.Icons[e.Icon]
+		if !ok || r.opts.Atlas == nil {
+			return
+		}
+		src := image.Rect(icon.X, icon.Y, icon.X+icon.Width, icon.Y+icon.Height)
+		scale := math.Min(1, math.Min(float64(rect.Dx())/float64(src.Dx()), float64(rect.Dy())/float64(src.Dy())))
+		w, h := int(float64(src.Dx())*scale), int(float64(src.Dy())*scale)
+		dst := image.Rect(0, 0, w, h).Add(rect.Min).Add(image.Pt((rect.Dx()-w)/2, (rect.Dy()-h)/2))
+		xdraw.ApproxBiLinear.Scale(r.img, dst, r.opts.Atlas, src, xdraw.Over, nil)
+	case style.SwatchText:
+		c := e.Color
+		if c == nil {
+			c = color.Black
+		}
+		saved := r.opts.TextColor
+		r.opts.TextColor = withOpacity(c, e.Opacity)
+		r.text("Aa", rect.Min.X+2, rect.Min.Y)
+		r.opts.TextColor = saved
+	case style.SwatchRaster:
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			v := uint8(64 + 128*(x-rect.Min.X)/rect.Dx())
+			draw.Draw(r.img, image.Rect(x, rect.Min.Y, x+1, rect.Max.Y), image.NewUniform(color.Gray{v}), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// ramp draws the entries of a ramp as a gradient bar with the first, middle
+// and last stop labels below it.
+func (r *legendRenderer) ramp(l *style.LegendLayer, y int) {
+	if len(l.Entries) == 0 {
+		return
+	}
+	left, right := r.opts.Padding, r.opts.Width-r.opts.Padding
+	n := len(l.Entries)
+	for x := left; x < right; x++ {
+		pos := 0.0
+		if n > 1 {
+			pos = float64(x-left) / float64(right-left-1) * float64(n-1)
+		}
+		i := min(int(pos), n-2)
+		var c color.Color
+		if n == 1 {
+			c = withOpacity(l.Entries[0].Color, l.Entries[0].Opacity)
+		} else {
+			from, to := l.Entries[i], l.Entries[i+1]
+			c = mixColors(withOpacity(from.Color, from.Opacity), withOpacity(to.Color, to.Opacity), pos-float64(i))
+		}
+		draw.Draw(r.img, image.Rect(x, y, x+1, y+r.opts.SwatchSize), image.NewUniform(c), image.Point{}, draw.Over)
+	}
+	labelY := y + r.row
+	r.text(l.Entries[0].Label, left, labelY)
+	if n > 2 {
+		mid := l.Entries[n/2].Label
+		w := font.MeasureString(r.opts.Face, mid).Ceil()
+		r.text(mid, left+(right-left)*(n/2)/(n-1)-w/2, labelY)
+	}
+	if n > 1 {
+		last := l.Entries[n-1].Label
+		r.text(last, right-font.MeasureString(r.opts.Face, last).Ceil(), labelY)
+	}
+}
+
+func (r *legendRenderer) rect(x0, y0, x1, y1 float32) {
+	r.raster.MoveTo(x0, y0)
+	r.raster.LineTo(x1, y0)
+	r.raster.LineTo(x1, y1)
+	r.raster.LineTo(x0, y1)
+	r.raster.ClosePath()
+}
+
+func (r *legendRenderer) reverseRect(x0, y0, x1, y1 float32) {
+	r.raster.MoveTo(x0, y0)
+	r.raster.LineTo(x0, y1)
+	r.raster.LineTo(x1, y1)
+	r.raster.LineTo(x1, y0)
+	r.raster.ClosePath()
+}
+
+func (r *legendRenderer) circle(cx, cy, radius float32, reverse bool) {
+	f := frame{raster: r.raster}
+	f.addCircle(cx, cy, radius, reverse)
+}
+
+// dashes splits a line of the given length into [start, end] dashes.
+// Dash lengths are in line widths, as in line-dasharray.
+func dashes(length float64, pattern []float64, width float64) [][2]float64 {
+	total := 0.0
+	for _, d := range pattern {
+		total += d
+	}
+	if len(pattern) < 2 || total <= 0 {
+		return [][2]float64{{0, length}}
+	}
+	var out [][2]float64
+	for x, i := 0.0, 0; x < length; i = (i + 1) % len(pattern) {
+		end := math.Min(length, x+pattern[i]*width)
+		if i%2 == 0 && end > x {
+			out = append(out, [2]float64{x, end})
+		}
+		x = end
+	}
+	return out
+}
+
+// mixColors blends two colors linearly.
+func mixColors(a, b color.Color, t float64) color.Color {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	mix := func(x, y uint32) uint16 { return uint16(float64(x)*(1-t) + float64(y)*t) }
+	return color.RGBA64{mix(r1, r2), mix(g1, g2), mix(b1, b2), mix(a1, a2)}
+}
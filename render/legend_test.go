@@ -0,0 +1,83 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/flywave/go-mapbox/sprite"
+	"github.com/flywave/go-mapbox/style"
+)
+
+func TestRenderLegend(t *testing.T) {
+	atlas := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			atlas.Set(x, y, color.RGBA{255, 0, 255, 255})
+		}
+	}
+	legend := &style.Legend{Layers: []*style.LegendLayer{
+		{ID: "water", Label: "Water", Swatch: style.SwatchFill, Entries: []*style.LegendEntry{
+			{Label: "Water", Color: color.RGBA{0, 0, 255, 255}, Opacity: 1},
+		}},
+		{ID: "roads", Label: "Roads", Swatch: style.SwatchLine, Entries: []*style.LegendEntry{
+			{Label: "minor", Color: color.RGBA{255, 0, 0, 255}, Opacity: 1, LineWidth: 4},
+			{Label: "major", Color: color.RGBA{0, 255, 0, 255}, Opacity: 1, LineWidth: 8},
+		}},
+		{ID: "pois", Label: "POIs", Swatch: style.SwatchIcon, Entries: []*style.LegendEntry{
+			{Label: "shop", Icon: "shop", Opacity: 1},
+		}},
+		{ID: "density", Label: "Density", Swatch: style.SwatchCircle, Ramp: true, Entries: []*style.LegendEntry{
+			{Label: "0", Color: color.Black, Opacity: 1},
+			{Label: "100", Color: color.White, Opacity: 1},
+		}},
+	}}
+	opts := DefaultLegendOptions()
+	opts.Atlas = atlas
+	opts.Icons = map[string]*sprite.TextureSprite{
+		"shop": {TextureMeta: &sprite.TextureMeta{Width: 10, Height: 10, PixelRatio: 1}},
+	}
+	img, err := RenderLegend(legend, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Padding 8 and rows of 24px: water, roads heading, minor, major, pois,
+	// density heading, ramp bar and ramp labels.
+	if b := img.Bounds(); b.Dx() != 240 || b.Dy() != 8+8*24+8 {
+		t.Fatalf("bounds = %v", b)
+	}
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"fill swatch", 18, 18, color.RGBA{0, 0, 255, 255}},
+		{"line swatch", 18, 8 + 2*24 + 10, color.RGBA{255, 0, 0, 255}},
+		{"above thin line", 18, 8 + 2*24 + 4, color.RGBA{255, 255, 255, 255}},
+		{"thick line", 18, 8 + 3*24 + 7, color.RGBA{0, 255, 0, 255}},
+		{"icon", 18, 8 + 4*24 + 10, color.RGBA{255, 0, 255, 255}},
+		{"ramp start", 8, 8 + 6*24 + 10, color.RGBA{0, 0, 0, 255}},
+		{"ramp end", 231, 8 + 6*24 + 10, color.RGBA{255, 255, 255, 255}},
+	}
+	for _, tt := range tests {
+		if got := pixel(t, img, tt.x, tt.y); !near(got, tt.want) {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+	if got := pixel(t, img, 120, 8+6*24+10); got.R < 100 || got.R > 155 {
+		t.Errorf("ramp middle = %v", got)
+	}
+}
+
+func TestDashes(t *testing.T) {
+	got := dashes(20, []float64{2, 1}, 2)
+	want := [][2]float64{{0, 4}, {6, 10}, {12, 16}, {18, 20}}
+	if len(got) != len(want) {
+		t.Fatalf("dashes = %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("dash %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,487 @@
+// Package render draws styles to images without a GPU. It supports
+// background, fill, line and circle layers and is meant for thumbnails,
+// static previews and visual regression tests rather than full fidelity.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/flywave/go-mapbox/style"
+	"github.com/pkg/errors"
+	"golang.org/x/image/vector"
+)
+
+// TileSize is the size in pixels a vector tile covers, as in Mapbox GL.
+const TileSize = 512
+
+const maxTileZoom = 22
+
+// View is the camera of a rendered image.
+type View struct {
+	// Center is the longitude and latitude of the image center.
+	Center     [2]float64
+	Zoom       float64
+	Width      int
+	Height     int
+	PixelRatio float64
+}
+
+// Renderer draws a style using tiles from Sources, keyed by style source
+// id. Layers of sources without a TileSource are skipped.
+type Renderer struct {
+	Style   *style.Style
+	Sources map[string]TileSource
+	// Theme is applied to the colors of every layer that does not opt out.
+	// When nil, each layer uses the color theme of the style or import it
+	// came from, see style.Style.ColorThemeFor.
+	Theme *style.ColorLUT
+	// State holds the feature states read by feature-state expressions,
+	// such as hover or selection. Features have no state when nil.
+	State *style.FeatureStateStore
+}
+
+// NewRenderer returns a renderer for s.
+func NewRenderer(s *style.Style, sources map[string]TileSource) *Renderer {
+	return &Renderer{Style: s, Sources: sources}
+}
+
+// Render draws the visible layers of the style for view. Paint properties
+// and filters are evaluated per feature; patterns, dashes, blur, offsets
+// and translations are ignored.
+func (r *Renderer) Render(view View) (*image.RGBA, error) {
+	if view.Width <= 0 || view.Height <= 0 {
+		return nil, errors.Errorf("invalid image size %dx%d", view.Width, view.Height)
+	}
+	if view.PixelRatio <= 0 {
+		view.PixelRatio = 1
+	}
+	w := int(math.Round(float64(view.Width) * view.PixelRatio))
+	h := int(math.Round(float64(view.Height) * view.PixelRatio))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if _, err := r.Style.Config(); err != nil {
+		return nil, err
+	}
+	themes, err := r.themes()
+	if err != nil {
+		return nil, err
+	}
+
+	cx, cy := project(view.Center[0], view.Center[1])
+	worldSize := TileSize * math.Exp2(view.Zoom) * view.PixelRatio
+	f := &frame{
+		img:    img,
+		zoom:   view.Zoom,
+		ratio:  view.PixelRatio,
+		world:  worldSize,
+		left:   cx*worldSize - float64(w)/2,
+		top:    cy*worldSize - float64(h)/2,
+		raster: vector.NewRasterizer(0, 0),
+		tiles:  map[string][]*placedTile{},
+		themes: themes,
+		state:  r.State,
+	}
+
+	for _, l := range r.Style.Layers {
+		if l == nil || !l.VisibleAtZoom(view.Zoom) {
+			continue
+		}
+		if l.Layout != nil && l.Layout.Visibility == "none" {
+			continue
+		}
+		if l.Type == style.LayerTypeBackground {
+			f.drawBackground(l)
+			continue
+		}
+		switch l.Type {
+		case style.LayerTypeFill, style.LayerTypeLine, style.LayerTypeCircle:
+		default:
+			continue
+		}
+		if l.Source == nil || l.SourceLayer == nil {
+			continue
+		}
+		tiles, err := r.tilesFor(f, *l.Source)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tiles {
+			layer, ok := t.tile.Layers[*l.SourceLayer]
+			if !ok {
+				continue
+			}
+			f.drawLayer(l, t, layer)
+		}
+	}
+	return img, nil
+}
+
+// project returns the Web Mercator position of a coordinate, with the
+// world spanning 0 to 1.
+func project(lng, lat float64) (float64, float64) {
+	lat = math.Max(-85.051129, math.Min(85.051129, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := (lng + 180) / 360
+	y := 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+	return x, y
+}
+
+// frame is the state of one Render call.
+type frame struct {
+	img         *image.RGBA
+	zoom, ratio float64
+	world       float64
+	left, top   float64
+	raster      *vector.Rasterizer
+	tiles       map[string][]*placedTile
+	themes      map[*style.Layer]*style.ColorLUT
+	state       *style.FeatureStateStore
+}
+
+// themes returns the color lookup table of every layer, decoding each
+// color theme once.
+func (r *Renderer) themes() (map[*style.Layer]*style.ColorLUT, error) {
+	out := make(map[*style.Layer]*style.ColorLUT, len(r.Style.Layers))
+	luts := map[*style.ColorTheme]*style.ColorLUT{}
+	for _, l := range r.Style.Layers {
+		if r.Theme != nil {
+			out[l] = r.Theme
+			continue
+		}
+		theme := r.Style.ColorThemeFor(l)
+		lut, ok := luts[theme]
+		if !ok {
+			var err error
+			if lut, err = theme.LUT(); err != nil {
+				return nil, errors.Wrapf(err, "layer %q color theme", l.ID)
+			}
+			luts[theme] = lut
+		}
+		out[l] = lut
+	}
+	return out, nil
+}
+
+// placedTile is a tile with the screen position of its top-left corner and
+// its size in pixels.
+type placedTile struct {
+	tile       *Tile
+	x, y, size float64
+}
+
+// tilesFor loads the tiles of a source covering the image, once per
+// render. Missing tiles are replaced by the nearest existing ancestor.
+func (r *Renderer) tilesFor(f *frame, source string) ([]*placedTile, error) {
+	if tiles, ok := f.tiles[source]; ok {
+		return tiles, nil
+	}
+	src, ok := r.Sources[source]
+	if !ok {
+		f.tiles[source] = nil
+		return nil, nil
+	}
+	minZoom, maxZoom := 0, maxTileZoom
+	if s, ok := r.Style.Sources[source]; ok && s != nil {
+		if s.MinZoom != nil {
+			minZoom = int(*s.MinZoom)
+		}
+		if s.MaxZoom != nil {
+			maxZoom = int(*s.MaxZoom)
+		}
+	}
+	z := int(math.Floor(f.zoom))
+	if z > maxZoom {
+		z = maxZoom
+	}
+	if z < minZoom {
+		f.tiles[source] = nil
+		return nil, nil
+	}
+
+	n := 1 << uint(z)
+	size := f.world / float64(n)
+	w, h := float64(f.img.Bounds().Dx()), float64(f.img.Bounds().Dy())
+	x0, x1 := int(math.Floor(f.left/size)), int(math.Floor((f.left+w)/size))
+	y0, y1 := int(math.Floor(f.top/size)), int(math.Floor((f.top+h)/size))
+
+	type key struct{ z, x, y, wrap int }
+	seen := map[key]bool{}
+	var tiles []*placedTile
+	for ty := max(y0, 0); ty <= min(y1, n-1); ty++ {
+		for tx := x0; tx <= x1; tx++ {
+			wrap := int(math.Floor(float64(tx) / float64(n)))
+			x := tx - wrap*n
+			// Walk up to the first ancestor that exists.
+			for tz, ax, ay := z, x, ty; tz >= minZoom; tz, ax, ay = tz-1, ax/2, ay/2 {
+				k := key{tz, ax, ay, wrap}
+				if seen[k] {
+					break
+				}
+				tile, err := src.Tile(tz, ax, ay)
+				if err != nil {
+					return nil, errors.Wrapf(err, "source %q", source)
+				}
+				if tile == nil {
+					continue
+				}
+				seen[k] = true
+				tsize := f.world / float64(int(1)<<uint(tz))
+				tiles = append(tiles, &placedTile{
+					tile: tile,
+					x:    float64(ax)*tsize + float64(wrap)*f.world - f.left,
+					y:    float64(ay)*tsize - f.top,
+					size: tsize,
+				})
+				break
+			}
+		}
+	}
+	f.tiles[source] = tiles
+	return tiles, nil
+}
+
+func (f *frame) drawBackground(l *style.Layer) {
+	opacity, _ := l.Paint.BackgroundOpacityAt(f.zoom, nil)
+	var c color.Color
+	if l.Paint != nil {
+		c = f.color(l, "background-color", l.Paint.BackgroundColor, nil)
+	}
+	draw.Draw(f.img, f.img.Bounds(), image.NewUniform(withOpacity(c, opacity)), image.Point{}, draw.Over)
+}
+
+// drawLayer draws the features of a tile layer that pass the filter of l.
+// Features whose filter fails are skipped, and paint properties that fail
+// to evaluate take their spec defaults, as the accessors return them.
+func (f *frame) drawLayer(l *style.Layer, t *placedTile, layer *Layer) {
+	extent := float64(layer.Extent)
+	if extent <= 0 {
+		extent = defaultExtent
+	}
+	scale := t.size / extent
+	clip := image.Rect(
+		int(math.Floor(t.x)), int(math.Floor(t.y)),
+		int(math.Ceil(t.x+t.size)), int(math.Ceil(t.y+t.size)),
+	).Intersect(f.img.Bounds())
+	if clip.Empty() {
+		return
+	}
+	toScreen := func(p Point) (float32, float32) {
+		return float32(t.x + p.X*scale - float64(clip.Min.X)), float32(t.y + p.Y*scale - float64(clip.Min.Y))
+	}
+
+	for _, feature := range layer.Features {
+		sf := &style.Feature{ID: feature.ID, Type: feature.Type, Properties: feature.Properties}
+		sf = f.state.WithState(*l.Source, *l.SourceLayer, sf)
+		if ok, err := l.Matches(sf, f.zoom); err != nil || !ok {
+			continue
+		}
+		switch l.Type {
+		case style.LayerTypeFill:
+			if feature.Type != GeometryPolygon {
+				continue
+			}
+			c := f.color(l, "fill-color", paint(l).FillColor, sf)
+			opacity, _ := l.Paint.FillOpacityAt(f.zoom, sf)
+			f.begin(clip)
+			for _, ring := range feature.Geometry {
+				f.addRing(ring, toScreen)
+			}
+			f.end(clip, withOpacity(c, opacity))
+			if outline := f.color(l, "fill-outline-color", paint(l).FillOutlineColor, sf); outline != nil {
+				f.begin(clip)
+				for _, ring := range feature.Geometry {
+					f.addLine(ring, toScreen, f.ratio, false)
+				}
+				f.end(clip, withOpacity(outline, opacity))
+			}
+		case style.LayerTypeLine:
+			if feature.Type == GeometryPoint {
+				continue
+			}
+			c := f.color(l, "line-color", paint(l).LineColor, sf)
+			opacity, _ := l.Paint.LineOpacityAt(f.zoom, sf)
+			width, _ := l.Paint.LineWidthAt(f.zoom, sf)
+			roundCap := l.Layout != nil && l.Layout.LineCap == "round"
+			f.begin(clip)
+			for _, line := range feature.Geometry {
+				f.addLine(line, toScreen, width*f.ratio, roundCap)
+			}
+			f.end(clip, withOpacity(c, opacity))
+		case style.LayerTypeCircle:
+			if feature.Type != GeometryPoint {
+				continue
+			}
+			f.drawCircles(l, sf, feature, toScreen, clip)
+		}
+	}
+}
+
+func (f *frame) drawCircles(l *style.Layer, sf *style.Feature, feature *Feature, toScreen func(Point) (float32, float32), clip image.Rectangle) {
+	radius, _ := l.Paint.CircleRadiusAt(f.zoom, sf)
+	c := f.color(l, "circle-color", paint(l).CircleColor, sf)
+	opacity, _ := l.Paint.CircleOpacityAt(f.zoom, sf)
+	strokeWidth, _ := l.Paint.CircleStrokeWidthAt(f.zoom, sf)
+	radius *= f.ratio
+	strokeWidth *= f.ratio
+
+	if strokeWidth > 0 {
+		sc := f.color(l, "circle-stroke-color", paint(l).CircleStrokeColor, sf)
+		sOpacity, _ := l.Paint.CircleStrokeOpacityAt(f.zoom, sf)
+		f.begin(clip)
+		for _, pts := range feature.Geometry {
+			for _, p := range pts {
+				x, y := toScreen(p)
+				f.addCircle(x, y, float32(radius+strokeWidth), false)
+				f.addCircle(x, y, float32(radius), true)
+			}
+		}
+		f.end(clip, withOpacity(sc, sOpacity))
+	}
+	f.begin(clip)
+	for _, pts := range feature.Geometry {
+		for _, p := range pts {
+			x, y := toScreen(p)
+			f.addCircle(x, y, float32(radius), false)
+		}
+	}
+	f.end(clip, withOpacity(c, opacity))
+}
+
+func (f *frame) begin(clip image.Rectangle) {
+	f.raster.Reset(clip.Dx(), clip.Dy())
+}
+
+func (f *frame) end(clip image.Rectangle, c color.Color) {
+	if c == nil {
+		return
+	}
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return
+	}
+	f.raster.Draw(f.img, clip, image.NewUniform(c), image.Point{})
+}
+
+func (f *frame) addRing(ring []Point, toScreen func(Point) (float32, float32)) {
+	if len(ring) < 3 {
+		return
+	}
+	x, y := toScreen(ring[0])
+	f.raster.MoveTo(x, y)
+	for _, p := range ring[1:] {
+		x, y := toScreen(p)
+		f.raster.LineTo(x, y)
+	}
+	f.raster.ClosePath()
+}
+
+// addLine adds a stroke of the given width along line as one quad per
+// segment, with round joins and optionally round caps. The rasterizer
+// saturates overlapping coverage, so the pieces union cleanly.
+func (f *frame) addLine(line []Point, toScreen func(Point) (float32, float32), width float64, roundCap bool) {
+	if len(line) < 2 || width <= 0 {
+		return
+	}
+	half := float32(width / 2)
+	pts := make([][2]float32, len(line))
+	for i, p := range line {
+		pts[i][0], pts[i][1] = toScreen(p)
+	}
+	for i := 0; i+1 < len(pts); i++ {
+		a, b := pts[i], pts[i+1]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		length := float32(math.Hypot(float64(dx), float64(dy)))
+		if length == 0 {
+			continue
+		}
+		// Wound like addCircle, so joins and caps add to the quads'
+		// coverage under the nonzero rule rather than cancel it.
+		nx, ny := -dy/length*half, dx/length*half
+		f.raster.MoveTo(a[0]-nx, a[1]-ny)
+		f.raster.LineTo(b[0]-nx, b[1]-ny)
+		f.raster.LineTo(b[0]+nx, b[1]+ny)
+		f.raster.LineTo(a[0]+nx, a[1]+ny)
+		f.raster.ClosePath()
+	}
+	if half < 1 {
+		return
+	}
+	for i, p := range pts {
+		end := i == 0 || i == len(pts)-1
+		if !end || roundCap {
+			f.addCircle(p[0], p[1], half, false)
+		}
+	}
+}
+
+// addCircle adds a circle as a polygon; reverse winds it the other way to
+// cut a hole.
+func (f *frame) addCircle(cx, cy, r float32, reverse bool) {
+	if r <= 0 {
+		return
+	}
+	segments := int(math.Max(12, math.Min(64, float64(r)*2)))
+	step := 2 * math.Pi / float64(segments)
+	if reverse {
+		step = -step
+	}
+	f.raster.MoveTo(cx+r, cy)
+	for i := 1; i < segments; i++ {
+		a := float64(i) * step
+		f.raster.LineTo(cx+r*float32(math.Cos(a)), cy+r*float32(math.Sin(a)))
+	}
+	f.raster.ClosePath()
+}
+
+// paint returns the paint properties of l, empty if it has none.
+func paint(l *style.Layer) *style.Paint {
+	if l.Paint == nil {
+		return &style.Paint{}
+	}
+	return l.Paint
+}
+
+// evalColor evaluates a color property, returning nil when it is unset or
+// fails to evaluate so the spec default applies.
+func evalColor(c *style.ColorType, zoom float64, feature *style.Feature) color.Color {
+	col, err := c.Evaluate(zoom, feature)
+	if err != nil {
+		return nil
+	}
+	return col
+}
+
+// color evaluates a color paint property of l and applies the color theme
+// unless the property opts out. Unset properties stay nil.
+func (f *frame) color(l *style.Layer, property string, c *style.ColorType, feature *style.Feature) color.Color {
+	col := evalColor(c, f.zoom, feature)
+	if themed, err := l.ThemeColor(f.themes[l], property, col, f.zoom, feature); err == nil {
+		col = themed
+	}
+	return col
+}
+
+// withOpacity returns c with its alpha scaled by opacity. A nil color is
+// the spec default, black.
+func withOpacity(c color.Color, opacity float64) color.Color {
+	if c == nil {
+		c = color.Black
+	}
+	opacity = math.Max(0, math.Min(1, opacity))
+	n := straightAlpha(c)
+	n.A = uint16(math.Round(float64(n.A) * opacity))
+	return n
+}
+
+// straightAlpha returns c without premultiplied alpha. The style package
+// keeps straight alpha in color.RGBA, so those are taken as they are.
+func straightAlpha(c color.Color) color.NRGBA64 {
+	if rgba, ok := c.(color.RGBA); ok {
+		return color.NRGBA64{
+			R: uint16(rgba.R) * 0x101, G: uint16(rgba.G) * 0x101,
+			B: uint16(rgba.B) * 0x101, A: uint16(rgba.A) * 0x101,
+		}
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
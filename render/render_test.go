@@ -0,0 +1,471 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/flywave/go-mapbox/style"
+)
+
+func decodeStyle(t *testing.T, raw string) *style.Style {
+	t.Helper()
+	var s style.Style
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// singleTile serves tile at zoom 0 only.
+func singleTile(tile *Tile) TileSource {
+	return TileSourceFunc(func(z, x, y int) (*Tile, error) {
+		if z == 0 && x == 0 && y == 0 {
+			return tile, nil
+		}
+		return nil, nil
+	})
+}
+
+func pixel(t *testing.T, img interface{ At(x, y int) color.Color }, x, y int) color.RGBA {
+	t.Helper()
+	r, g, b, a := img.At(x, y).RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+func near(a, b color.RGBA) bool {
+	d := func(x, y uint8) bool { return math.Abs(float64(x)-float64(y)) <= 2 }
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && d(a.A, b.A)
+}
+
+const testStyle = `{
+	"version": 8,
+	"sources": {"v": {"type": "vector"}},
+	"layers": [
+		{"id": "bg", "type": "background", "paint": {"background-color": "#ffffff"}},
+		{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+		 "filter": ["==", ["get", "kind"], "lake"],
+		 "paint": {"fill-color": "#0000ff"}},
+		{"id": "roads", "type": "line", "source": "v", "source-layer": "roads",
+		 "paint": {"line-color": ["match", ["get", "class"], "major", "#ff0000", "#00ff00"], "line-width": 6}},
+		{"id": "pois", "type": "circle", "source": "v", "source-layer": "pois",
+		 "paint": {"circle-color": "#000000", "circle-radius": 8}}
+	]
+}`
+
+func testTile() *Tile {
+	square := func(x0, y0, x1, y1 float64) [][]Point {
+		return [][]Point{{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}}}
+	}
+	return &Tile{Layers: map[string]*Layer{
+		"water": {Name: "water", Extent: 4096, Features: []*Feature{
+			{Type: GeometryPolygon, Properties: map[string]interface{}{"kind": "lake"}, Geometry: square(0, 0, 2048, 2048)},
+			{Type: GeometryPolygon, Properties: map[string]interface{}{"kind": "sea"}, Geometry: square(2048, 2048, 4096, 4096)},
+		}},
+		"roads": {Name: "roads", Extent: 4096, Features: []*Feature{
+			{Type: GeometryLineString, Properties: map[string]interface{}{"class": "major"}, Geometry: [][]Point{{{2048, 3000}, {4096, 3000}}}},
+			{Type: GeometryLineString, Properties: map[string]interface{}{"class": "minor"}, Geometry: [][]Point{{{3000, 0}, {3000, 2048}}}},
+		}},
+		"pois": {Name: "pois", Extent: 4096, Features: []*Feature{
+			{Type: GeometryPoint, Geometry: [][]Point{{{1024, 3072}}}},
+		}},
+	}}
+}
+
+func TestRender(t *testing.T) {
+	s := decodeStyle(t, testStyle)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := color.RGBA{255, 255, 255, 255}
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"background", 400, 100, white},
+		{"matching fill", 100, 100, color.RGBA{0, 0, 255, 255}},
+		{"filtered fill", 400, 400, white},
+		{"major road", 450, 375, color.RGBA{255, 0, 0, 255}},
+		{"minor road", 375, 100, color.RGBA{0, 255, 0, 255}},
+		{"beside road", 450, 365, white},
+		{"circle", 128, 384, color.RGBA{0, 0, 0, 255}},
+		{"beside circle", 140, 384, white},
+	}
+	for _, tt := range tests {
+		if got := pixel(t, img, tt.x, tt.y); !near(got, tt.want) {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLineJoin(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#ffffff"}},
+			{"id": "roads", "type": "line", "source": "v", "source-layer": "roads",
+			 "paint": {"line-color": "#ff0000", "line-width": 20}}
+		]
+	}`)
+	tile := &Tile{Layers: map[string]*Layer{"roads": {Name: "roads", Extent: 4096, Features: []*Feature{
+		{Type: GeometryLineString, Geometry: [][]Point{{{1000, 1000}, {3000, 1000}, {3000, 3000}}}},
+	}}}}
+	img, err := NewRenderer(s, map[string]TileSource{"v": singleTile(tile)}).Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The join is at (375, 125) with a radius of 10 pixels.
+	red, white := color.RGBA{255, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"join and second segment", 380, 130, red},
+		{"join and first segment", 370, 120, red},
+		{"join only", 378, 118, red},
+		{"outside join", 385, 115, white},
+	}
+	for _, tt := range tests {
+		if got := pixel(t, img, tt.x, tt.y); !near(got, tt.want) {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestRenderOpacityAndVisibility(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#000000"}},
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "paint": {"fill-color": "#ffffff", "fill-opacity": 0.5}},
+			{"id": "hidden", "type": "circle", "source": "v", "source-layer": "pois",
+			 "layout": {"visibility": "none"}, "paint": {"circle-color": "#ff0000", "circle-radius": 50}},
+			{"id": "zoomed", "type": "circle", "source": "v", "source-layer": "pois", "minzoom": 5,
+			 "paint": {"circle-color": "#ff0000", "circle-radius": 50}}
+		]
+	}`)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(t, img, 100, 100); !near(got, color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("half transparent fill = %v", got)
+	}
+	if got := pixel(t, img, 128, 384); !near(got, color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("hidden circles drawn: %v", got)
+	}
+}
+
+func TestRenderTranslucentColorAndFailures(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#000000"}},
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "filter": ["==", ["get", "kind"], "lake"],
+			 "paint": {"fill-color": "rgba(255, 255, 255, 0.5)"}},
+			{"id": "sea", "type": "fill", "source": "v", "source-layer": "water",
+			 "filter": ["==", ["get", "kind"], "sea"],
+			 "paint": {"fill-color": "#ffffff", "fill-opacity": ["number", ["get", "missing"]]}},
+			{"id": "malformed", "type": "circle", "source": "v", "source-layer": "pois",
+			 "filter": "park", "paint": {"circle-color": "#ff0000", "circle-radius": 50}}
+		]
+	}`)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(t, img, 100, 100); !near(got, color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("half transparent color over black = %v, want grey 128", got)
+	}
+	if got := pixel(t, img, 400, 400); !near(got, color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("fill-opacity failing to evaluate = %v, want the default opacity 1", got)
+	}
+	if got := pixel(t, img, 128, 300); !near(got, color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("layer with a malformed filter drawn: %v", got)
+	}
+}
+
+func TestRenderOverzoomedAndPixelRatio(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector", "maxzoom": 0}},
+		"layers": [
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "filter": ["==", ["get", "kind"], "lake"], "paint": {"fill-color": "#0000ff"}}
+		]
+	}`)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	// At zoom 2 centered on lng -90, lat 0 the view spans the world tile's
+	// x range 0.125..0.375, the left half of which is the lake.
+	img, err := r.Render(View{Center: [2]float64{-90, 0}, Zoom: 2, Width: 256, Height: 256, PixelRatio: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 512 || b.Dy() != 512 {
+		t.Fatalf("bounds = %v", b)
+	}
+	if got := pixel(t, img, 256, 100); !near(got, color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("lake pixel = %v", got)
+	}
+	if got := pixel(t, img, 256, 400); got.A != 0 {
+		t.Errorf("below lake pixel = %v", got)
+	}
+}
+
+// invertStrip returns a 2x2x2 color lookup table that inverts colors.
+func invertStrip() image.Image {
+	strip := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for b := 0; b < 2; b++ {
+		for g := 0; g < 2; g++ {
+			for r := 0; r < 2; r++ {
+				strip.Set(b*2+r, g, color.NRGBA{uint8(255 * (1 - r)), uint8(255 * (1 - g)), uint8(255 * (1 - b)), 255})
+			}
+		}
+	}
+	return strip
+}
+
+func TestRenderColorTheme(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#000000"}},
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "paint": {"fill-color": "#0000ff", "fill-color-use-theme": "none"}}
+		]
+	}`)
+	lut, err := style.NewColorLUT(invertStrip())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	r.Theme = lut
+	img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(t, img, 128, 384); !near(got, color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("themed background = %v", got)
+	}
+	if got := pixel(t, img, 100, 100); !near(got, color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("opted out fill = %v", got)
+	}
+}
+
+func TestRenderImportColorTheme(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, invertStrip()); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeStyle(t, `{
+		"version": 8,
+		"imports": [{"id": "base", "url": "", "data": {"version": 8, "sources": {},
+			"layers": [{"id": "bg", "type": "background", "paint": {"background-color": "#000000"}}]}}],
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "paint": {"fill-color": "#0000ff"}}
+		]
+	}`)
+	root.Imports[0].ColorTheme = &style.ColorTheme{Data: base64.StdEncoding.EncodeToString(buf.Bytes())}
+	s, err := style.ResolveImports(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())}).Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(t, img, 400, 100); !near(got, color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("imported background = %v, want the import's theme applied", got)
+	}
+	if got := pixel(t, img, 100, 100); !near(got, color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("root fill = %v, want no theme", got)
+	}
+}
+
+func TestRenderFeatureState(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"sources": {"v": {"type": "vector", "promoteId": {"water": "kind"}}},
+		"layers": [
+			{"id": "water", "type": "fill", "source": "v", "source-layer": "water",
+			 "paint": {"fill-color": ["case", ["boolean", ["feature-state", "hover"], false], "#ff0000", "#0000ff"]}}
+		]
+	}`)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	r.State = style.NewFeatureStateStore(s.Sources)
+	r.State.Set(style.FeatureRef{Source: "v", SourceLayer: "water", ID: "sea"}, map[string]interface{}{"hover": true})
+	img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(t, img, 100, 100); !near(got, color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("lake = %v", got)
+	}
+	if got := pixel(t, img, 400, 400); !near(got, color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("hovered sea = %v", got)
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	s := decodeStyle(t, `{
+		"version": 8,
+		"schema": {
+			"showPOIs": {"default": false, "type": "boolean"},
+			"poiColor": {"default": "#ff0000", "type": "color"}
+		},
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#ffffff"}},
+			{"id": "pois", "type": "circle", "source": "v", "source-layer": "pois",
+			 "filter": ["config", "showPOIs"],
+			 "paint": {"circle-color": ["config", "poiColor"], "circle-radius": 8}}
+		]
+	}`)
+	r := NewRenderer(s, map[string]TileSource{"v": singleTile(testTile())})
+	render := func() color.RGBA {
+		t.Helper()
+		img, err := r.Render(View{Zoom: 0, Width: 512, Height: 512})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pixel(t, img, 128, 384)
+	}
+	if got := render(); !near(got, color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("pois drawn with showPOIs defaulting to false: %v", got)
+	}
+	c, err := s.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("showPOIs", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := render(); !near(got, color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("poi with showPOIs set = %v, want the schema default color", got)
+	}
+}
+
+func TestRenderInvalidSize(t *testing.T) {
+	r := NewRenderer(decodeStyle(t, `{"version": 8, "sources": {}, "layers": []}`), nil)
+	if _, err := r.Render(View{Width: 0, Height: 10}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// Wire types of the protocol buffer format.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// pbfWriter encodes the parts of the protocol buffer format tests need.
+type pbfWriter []byte
+
+func (w *pbfWriter) varint(field int, v uint64) {
+	*w = binary.AppendUvarint(*w, uint64(field<<3|wireVarint))
+	*w = binary.AppendUvarint(*w, v)
+}
+
+func (w *pbfWriter) bytes(field int, b []byte) {
+	*w = binary.AppendUvarint(*w, uint64(field<<3|wireBytes))
+	*w = binary.AppendUvarint(*w, uint64(len(b)))
+	*w = append(*w, b...)
+}
+
+func (w *pbfWriter) packed(field int, vs ...uint32) {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	w.bytes(field, b)
+}
+
+func zz(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+func TestDecodeTile(t *testing.T) {
+	var value, feature, layer, tile pbfWriter
+	value.bytes(1, []byte("lake"))
+
+	feature.varint(1, 7)
+	feature.packed(2, 0, 0)
+	feature.varint(3, 3)
+	feature.packed(4, 1<<3|1, zz(10), zz(10), 3<<3|2, zz(20), 0, 0, zz(20), zz(-20), 0, 1<<3|7)
+
+	layer.varint(15, 2)
+	layer.bytes(1, []byte("water"))
+	layer.bytes(2, feature)
+	layer.bytes(3, []byte("kind"))
+	layer.bytes(4, value)
+	layer.varint(5, 512)
+	tile.bytes(3, layer)
+
+	got, err := DecodeTile(tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := got.Layers["water"]
+	if l == nil || l.Extent != 512 || len(l.Features) != 1 {
+		t.Fatalf("layer = %+v", l)
+	}
+	f := l.Features[0]
+	if f.ID != 7.0 || f.Type != GeometryPolygon || f.Properties["kind"] != "lake" {
+		t.Errorf("feature = %+v", f)
+	}
+	want := []Point{{10, 10}, {30, 10}, {30, 30}, {10, 30}, {10, 10}}
+	if len(f.Geometry) != 1 || len(f.Geometry[0]) != len(want) {
+		t.Fatalf("geometry = %v", f.Geometry)
+	}
+	for i, p := range want {
+		if f.Geometry[0][i] != p {
+			t.Errorf("point %d = %v, want %v", i, f.Geometry[0][i], p)
+		}
+	}
+
+	if _, err := DecodeTile(tile[:len(tile)-3]); err == nil {
+		t.Error("expected error for truncated tile")
+	}
+}
+
+type memReader map[[3]uint64][]byte
+
+func (m memReader) ReadTile(z uint8, x uint64, y uint64, data *[]byte) error {
+	*data = m[[3]uint64{uint64(z), x, y}]
+	return nil
+}
+
+func TestTileReaderSource(t *testing.T) {
+	var layer, tile pbfWriter
+	layer.bytes(1, []byte("roads"))
+	tile.bytes(3, layer)
+	// Row 0 in TMS is the bottom row, y 1 at zoom 1.
+	src := NewTileReaderSource(memReader{{1, 0, 0}: tile})
+	got, err := src.Tile(1, 0, 1)
+	if err != nil || got == nil || got.Layers["roads"] == nil {
+		t.Fatalf("tile = %v, %v", got, err)
+	}
+	if got, err := src.Tile(1, 0, 0); got != nil || err != nil {
+		t.Errorf("missing tile = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,202 @@
+package render
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/flywave/go-geom"
+	"github.com/flywave/go-mapbox/mvt"
+	"github.com/pkg/errors"
+)
+
+// Geometry types of decoded features, as seen by the geometry-type
+// expression.
+const (
+	GeometryPoint      = "Point"
+	GeometryLineString = "LineString"
+	GeometryPolygon    = "Polygon"
+)
+
+const defaultExtent = 4096
+
+// Point is a position in tile coordinates, from 0 to the layer extent.
+type Point struct {
+	X, Y float64
+}
+
+// Feature is a decoded vector tile feature. Geometry holds one ring per
+// polygon ring, one line per line string and one single point slice per
+// point.
+type Feature struct {
+	ID         interface{}
+	Type       string
+	Properties map[string]interface{}
+	Geometry   [][]Point
+}
+
+// Layer is a decoded vector tile layer.
+type Layer struct {
+	Name     string
+	Extent   int
+	Features []*Feature
+}
+
+// Tile is a decoded vector tile, keyed by layer name.
+type Tile struct {
+	Layers map[string]*Layer
+}
+
+// TileSource provides the tiles of one style source. A nil tile with a nil
+// error means the tile does not exist.
+type TileSource interface {
+	Tile(z, x, y int) (*Tile, error)
+}
+
+// TileSourceFunc adapts a function to the TileSource interface.
+type TileSourceFunc func(z, x, y int) (*Tile, error)
+
+func (f TileSourceFunc) Tile(z, x, y int) (*Tile, error) {
+	return f(z, x, y)
+}
+
+// TileReader reads encoded tiles addressed in the TMS scheme, as
+// *mbtiles.DB does.
+type TileReader interface {
+	ReadTile(z uint8, x uint64, y uint64, data *[]byte) error
+}
+
+// NewTileReaderSource returns a TileSource decoding the Mapbox Vector Tiles
+// of r, such as an MBTiles database.
+func NewTileReaderSource(r TileReader) TileSource {
+	return TileSourceFunc(func(z, x, y int) (*Tile, error) {
+		var data []byte
+		row := (1 << uint(z)) - 1 - y
+		if err := r.ReadTile(uint8(z), uint64(x), uint64(row), &data); err != nil {
+			return nil, errors.Wrapf(err, "reading tile %d/%d/%d", z, x, y)
+		}
+		if len(data) == 0 {
+			return nil, nil
+		}
+		return DecodeTile(data)
+	})
+}
+
+// DecodeTile decodes a Mapbox Vector Tile, gzip compressed or not.
+func DecodeTile(data []byte) (*Tile, error) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing tile")
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, errors.Wrap(err, "decompressing tile")
+		}
+	}
+	t, err := mvt.NewTile(data, mvt.PROTO_MAPBOX)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding tile")
+	}
+	tile := &Tile{Layers: map[string]*Layer{}}
+	for _, name := range t.Layers {
+		layer, err := decodeLayer(t.LayerMap[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "layer %q", name)
+		}
+		tile.Layers[name] = layer
+	}
+	return tile, nil
+}
+
+func decodeLayer(l *mvt.Layer) (*Layer, error) {
+	layer := &Layer{Name: l.Name, Extent: l.Extent}
+	if layer.Extent == 0 {
+		layer.Extent = defaultExtent
+	}
+	for l.Next() {
+		f, err := l.Feature()
+		if err != nil {
+			return nil, err
+		}
+		feature := &Feature{Type: f.Type, Properties: make(map[string]interface{}, len(f.Properties))}
+		if f.ID != 0 {
+			feature.ID = float64(f.ID)
+		}
+		for k, v := range f.Properties {
+			feature.Properties[k] = propertyValue(v)
+		}
+		if f.Type != "" {
+			g, err := f.LoadGeometry()
+			if err != nil {
+				return nil, err
+			}
+			feature.Geometry = geometryPoints(g)
+		}
+		layer.Features = append(layer.Features, feature)
+	}
+	return layer, nil
+}
+
+// propertyValue returns numbers as float64, the number type of style
+// expressions.
+func propertyValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return v
+}
+
+// geometryPoints flattens a decoded geometry to the lines, rings or single
+// point slices of Feature.Geometry.
+func geometryPoints(g *geom.GeometryData) [][]Point {
+	if g == nil {
+		return nil
+	}
+	line := func(coords [][]float64) []Point {
+		out := make([]Point, 0, len(coords))
+		for _, c := range coords {
+			if len(c) >= 2 {
+				out = append(out, Point{c[0], c[1]})
+			}
+		}
+		return out
+	}
+	switch g.Type {
+	case GeometryPoint:
+		return [][]Point{line([][]float64{g.Point})}
+	case "MultiPoint":
+		out := make([][]Point, 0, len(g.MultiPoint))
+		for _, p := range g.MultiPoint {
+			out = append(out, line([][]float64{p}))
+		}
+		return out
+	case GeometryLineString:
+		return [][]Point{line(g.LineString)}
+	case "MultiLineString":
+		out := make([][]Point, 0, len(g.MultiLineString))
+		for _, l := range g.MultiLineString {
+			out = append(out, line(l))
+		}
+		return out
+	case GeometryPolygon:
+		out := make([][]Point, 0, len(g.Polygon))
+		for _, ring := range g.Polygon {
+			out = append(out, line(ring))
+		}
+		return out
+	case "MultiPolygon":
+		var out [][]Point
+		for _, polygon := range g.MultiPolygon {
+			for _, ring := range polygon {
+				out = append(out, line(ring))
+			}
+		}
+		return out
+	}
+	return nil
+}
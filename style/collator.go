@@ -0,0 +1,132 @@
+package style
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the locale of number-format and collator expressions
+// when neither the expression nor EvalContext.Locale sets one.
+const DefaultLocale = "en"
+
+// collateMatcher matches locales to the supported collations. The default
+// locale comes first, so locales without a match fall back to it.
+var collateMatcher = language.NewMatcher(append([]language.Tag{language.Make(DefaultLocale)}, collate.Supported()...))
+
+// Collator is the value of a collator expression, used by the comparison
+// operators to compare strings with the rules of a locale.
+type Collator struct {
+	CaseSensitive      bool
+	DiacriticSensitive bool
+	// Locale is the requested BCP 47 locale, DefaultLocale when empty.
+	Locale string
+
+	c *collate.Collator
+}
+
+// NewCollator returns a collator for a locale.
+func NewCollator(caseSensitive, diacriticSensitive bool, locale string) *Collator {
+	return &Collator{CaseSensitive: caseSensitive, DiacriticSensitive: diacriticSensitive, Locale: locale}
+}
+
+// Compare returns an integer comparing a and b, as strings.Compare does. A
+// Collator is not safe for concurrent use.
+func (c *Collator) Compare(a, b string) int {
+	if c.c == nil {
+		var opts []collate.Option
+		if !c.CaseSensitive {
+			opts = append(opts, collate.IgnoreCase)
+		}
+		if !c.DiacriticSensitive {
+			opts = append(opts, collate.IgnoreDiacritics)
+		}
+		c.c = collate.New(c.tag(), opts...)
+	}
+	return c.c.CompareString(a, b)
+}
+
+// ResolvedLocale returns the locale the collator uses, as the
+// resolved-locale expression does: the supported locale the requested one
+// resolves to, DefaultLocale when none matches.
+func (c *Collator) ResolvedLocale() string {
+	return c.tag().String()
+}
+
+func (c *Collator) tag() language.Tag {
+	locale := c.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Make(DefaultLocale)
+	}
+	matched, _, conf := collateMatcher.Match(tag)
+	if conf == language.No {
+		return language.Make(DefaultLocale)
+	}
+	// The matcher may add extensions, such as the region of the request;
+	// the resolved locale is the language, script and region it matched.
+	base, script, region := matched.Raw()
+	resolved, err := language.Compose(base, script, region)
+	if err != nil || resolved == language.Und {
+		return language.Make(DefaultLocale)
+	}
+	return resolved
+}
+
+func (ev *evaluator) evalCollator(e *Expression) (interface{}, error) {
+	c := &Collator{Locale: ev.ctx.Locale}
+	if len(e.Args) == 0 {
+		return c, nil
+	}
+	opts, ok := expressionValue(e.Args[0]).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("%q expects an options object", e.Operator)
+	}
+	for _, key := range sortedKeys(opts) {
+		v, err := ev.evalOption(opts[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q: %s", e.Operator, key)
+		}
+		if v == nil {
+			continue
+		}
+		switch key {
+		case "case-sensitive", "diacritic-sensitive":
+			b, ok := v.(bool)
+			if !ok {
+				return nil, errors.Errorf("%q: %s: expected boolean but found %s", e.Operator, key, typeOf(v))
+			}
+			if key == "case-sensitive" {
+				c.CaseSensitive = b
+			} else {
+				c.DiacriticSensitive = b
+			}
+		case "locale":
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.Errorf("%q: locale: expected string but found %s", e.Operator, typeOf(v))
+			}
+			c.Locale = s
+		}
+	}
+	return c, nil
+}
+
+// evalCollatorArg evaluates the optional collator argument of a comparison.
+func (ev *evaluator) evalCollatorArg(e *Expression, i int) (*Collator, error) {
+	if i >= len(e.Args) {
+		return nil, nil
+	}
+	v, err := ev.evalArg(e, i)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := v.(*Collator)
+	if !ok {
+		return nil, errors.Errorf("%q: expected collator for argument %d but found %s", e.Operator, i, typeOf(v))
+	}
+	return c, nil
+}
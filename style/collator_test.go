@@ -0,0 +1,38 @@
+package style
+
+import "testing"
+
+func TestEvaluateCollator(t *testing.T) {
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{`["==", "Ecole", "école", ["collator", {}]]`, true},
+		{`["==", "Ecole", "école", ["collator", {"case-sensitive": true}]]`, false},
+		{`["==", "ecole", "école", ["collator", {"diacritic-sensitive": true}]]`, false},
+		{`["!=", "Straße", "STRASSE", ["collator", {"locale": "de"}]]`, false},
+		{`["<", "a", "B"]`, false},
+		{`["<", "a", "B", ["collator", {}]]`, true},
+		{`["<", "é", "f", ["collator", {}]]`, true},
+		{`[">=", "b", "B", ["collator", {"case-sensitive": false}]]`, true},
+		{`["resolved-locale", ["collator", {"locale": "fr-CA"}]]`, "fr-CA"},
+		{`["resolved-locale", ["collator", {"locale": "zz-unknown"}]]`, DefaultLocale},
+		{`["resolved-locale", ["collator", {"locale": "tlh"}]]`, DefaultLocale},
+		{`["resolved-locale", ["collator", {"locale": "en-GB"}]]`, "en"},
+		{`["typeof", ["collator", {}]]`, "collator"},
+	}
+	for _, tt := range tests {
+		got, err := mustExpr(t, tt.expr).Evaluate(EvalContext{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, err := mustExpr(t, `["==", "a", "b", "not a collator"]`).Evaluate(EvalContext{}); err == nil {
+		t.Error("expected error for a non-collator argument")
+	}
+}
@@ -3,6 +3,7 @@ package style
 import (
 	"encoding/json"
 	"image/color"
+	"math"
 )
 
 type colorStop struct {
@@ -38,8 +39,9 @@ func (c *colorStop) MarshalJSON() ([]byte, error) {
 }
 
 type ColorStopsType struct {
-	Stops []*colorStop `json:"stops"`
-	Base  *float64     `json:"base"`
+	Stops      []*colorStop `json:"stops"`
+	Base       *float64     `json:"base"`
+	ColorSpace string       `json:"colorSpace,omitempty"`
 }
 
 func (c *ColorStopsType) GetValueAtZoomLevel(zoomLevel ZoomLevel) color.Color {
@@ -75,24 +77,11 @@ func (c *ColorStopsType) GetValueAtZoomLevel(zoomLevel ZoomLevel) color.Color {
 		thisColor := thisStop.Color.(color.RGBA)
 		nextColor := nextStop.Color.(color.RGBA)
 
-		value := color.RGBA{
-			R: getColorValueBetweenStops(percentageThrough, thisColor.R, nextColor.R),
-			G: getColorValueBetweenStops(percentageThrough, thisColor.G, nextColor.G),
-			B: getColorValueBetweenStops(percentageThrough, thisColor.B, nextColor.B),
-			A: getColorValueBetweenStops(percentageThrough, thisColor.A, nextColor.A),
+		if c.ColorSpace == ColorSpaceLab || c.ColorSpace == ColorSpaceHCL {
+			return interpolateColor(thisColor, nextColor, percentageThrough, c.ColorSpace)
 		}
-
-		return value
+		return blendPremultiplied(thisColor, nextColor, percentageThrough, math.Floor)
 	}
 
 	panic("shouldn't get here!")
 }
-
-func getColorValueBetweenStops(percentageThrough float64, this, next uint8) uint8 {
-	thisFloat64 := float64(this)
-	nextFloat64 := float64(next)
-
-	value := getValueThroughStop(thisFloat64, nextFloat64, percentageThrough)
-
-	return uint8(value)
-}
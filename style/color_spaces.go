@@ -0,0 +1,188 @@
+package style
+
+import (
+	"image/color"
+	"math"
+)
+
+// Color interpolation spaces.
+const (
+	ColorSpaceRGB = "rgb"
+	ColorSpaceLab = "lab"
+	ColorSpaceHCL = "hcl"
+)
+
+// CIE Lab constants for the D50 white point, matching GL JS.
+const (
+	labXn = 0.96422
+	labYn = 1
+	labZn = 0.82521
+	labT0 = 4.0 / 29
+	labT1 = 6.0 / 29
+	labT2 = 3 * labT1 * labT1
+	labT3 = labT1 * labT1 * labT1
+)
+
+type labColor struct {
+	L, A, B, Alpha float64
+}
+
+type hclColor struct {
+	H, C, L, Alpha float64
+}
+
+func xyzToLab(t float64) float64 {
+	if t > labT3 {
+		return math.Cbrt(t)
+	}
+	return t/labT2 + labT0
+}
+
+func labToXYZ(t float64) float64 {
+	if t > labT1 {
+		return t * t * t
+	}
+	return labT2 * (t - labT0)
+}
+
+func srgbToLinear(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(x float64) float64 {
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+func rgbToLab(c color.RGBA) labColor {
+	r := srgbToLinear(float64(c.R) / 255)
+	g := srgbToLinear(float64(c.G) / 255)
+	b := srgbToLinear(float64(c.B) / 255)
+	y := xyzToLab((0.2225045*r + 0.7168786*g + 0.0606169*b) / labYn)
+	x, z := y, y
+	if r != g || g != b {
+		x = xyzToLab((0.4360747*r + 0.3850649*g + 0.1430804*b) / labXn)
+		z = xyzToLab((0.0139322*r + 0.0971045*g + 0.7141733*b) / labZn)
+	}
+	return labColor{L: 116*y - 16, A: 500 * (x - y), B: 200 * (y - z), Alpha: float64(c.A) / 255}
+}
+
+func labToRGB(lab labColor) color.RGBA {
+	y := (lab.L + 16) / 116
+	x := y
+	if !math.IsNaN(lab.A) {
+		x = y + lab.A/500
+	}
+	z := y
+	if !math.IsNaN(lab.B) {
+		z = y - lab.B/200
+	}
+	y = labYn * labToXYZ(y)
+	x = labXn * labToXYZ(x)
+	z = labZn * labToXYZ(z)
+	return color.RGBA{
+		R: unitToChannel(linearToSRGB(3.1338561*x - 1.6168667*y - 0.4906146*z)),
+		G: unitToChannel(linearToSRGB(-0.9787684*x + 1.9161415*y + 0.0334540*z)),
+		B: unitToChannel(linearToSRGB(0.0719453*x - 0.2289914*y + 1.4052427*z)),
+		A: unitToChannel(lab.Alpha),
+	}
+}
+
+// rgbToHCL converts to polar Lab. Achromatic colors get a NaN hue so that
+// interpolation takes the hue of the other color.
+func rgbToHCL(c color.RGBA) hclColor {
+	lab := rgbToLab(c)
+	chroma := math.Hypot(lab.A, lab.B)
+	hue := math.NaN()
+	if math.Round(chroma*10000) != 0 {
+		hue = math.Atan2(lab.B, lab.A) * 180 / math.Pi
+		if hue < 0 {
+			hue += 360
+		}
+	}
+	return hclColor{H: hue, C: chroma, L: lab.L, Alpha: lab.Alpha}
+}
+
+func hclToRGB(hcl hclColor) color.RGBA {
+	h := hcl.H
+	if math.IsNaN(h) {
+		h = 0
+	}
+	h = h * math.Pi / 180
+	return labToRGB(labColor{L: hcl.L, A: math.Cos(h) * hcl.C, B: math.Sin(h) * hcl.C, Alpha: hcl.Alpha})
+}
+
+func unitToChannel(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// interpolateHue takes the shortest way around the hue circle.
+func interpolateHue(a, b, t float64) float64 {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return math.NaN()
+	case math.IsNaN(a):
+		return b
+	case math.IsNaN(b):
+		return a
+	}
+	d := b - a
+	if d > 180 || d < -180 {
+		d -= 360 * math.Round(d/360)
+	}
+	return a + t*d
+}
+
+// interpolateColor blends two non-premultiplied colors in the given color
+// space. RGB blending is done on premultiplied components, as GL JS does, so
+// a transparent stop does not tint the result.
+func interpolateColor(from, to color.RGBA, t float64, space string) color.RGBA {
+	switch space {
+	case ColorSpaceLab:
+		a, b := rgbToLab(from), rgbToLab(to)
+		return labToRGB(labColor{
+			L:     lerp(a.L, b.L, t),
+			A:     lerp(a.A, b.A, t),
+			B:     lerp(a.B, b.B, t),
+			Alpha: lerp(a.Alpha, b.Alpha, t),
+		})
+	case ColorSpaceHCL:
+		a, b := rgbToHCL(from), rgbToHCL(to)
+		return hclToRGB(hclColor{
+			H:     interpolateHue(a.H, b.H, t),
+			C:     lerp(a.C, b.C, t),
+			L:     lerp(a.L, b.L, t),
+			Alpha: lerp(a.Alpha, b.Alpha, t),
+		})
+	}
+	return blendPremultiplied(from, to, t, math.Round)
+}
+
+// blendPremultiplied linearly blends premultiplied channels and returns the
+// non-premultiplied result, using snap to turn channel values into integers.
+func blendPremultiplied(from, to color.RGBA, t float64, snap func(float64) float64) color.RGBA {
+	fa, ta := float64(from.A)/255, float64(to.A)/255
+	alpha := lerp(fa, ta, t)
+	channel := func(a, b uint8) uint8 {
+		if alpha == 0 {
+			return 0
+		}
+		v := lerp(float64(a)*fa, float64(b)*ta, t) / alpha
+		return uint8(math.Max(0, math.Min(255, snap(v))))
+	}
+	return color.RGBA{
+		R: channel(from.R, to.R),
+		G: channel(from.G, to.G),
+		B: channel(from.B, to.B),
+		A: uint8(math.Max(0, math.Min(255, snap(alpha*255)))),
+	}
+}
@@ -0,0 +1,92 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestInterpolateColorSpaces(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	tests := []struct {
+		name     string
+		from, to color.RGBA
+		space    string
+		want     color.RGBA
+	}{
+		{"rgb", red, blue, ColorSpaceRGB, color.RGBA{R: 128, B: 128, A: 255}},
+		{"lab_gray", color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}, ColorSpaceLab, color.RGBA{R: 119, G: 119, B: 119, A: 255}},
+		{"lab", red, blue, ColorSpaceLab, color.RGBA{R: 193, B: 136, A: 255}},
+		{"hcl", red, blue, ColorSpaceHCL, color.RGBA{R: 245, B: 134, A: 255}},
+		{"premultiplied", color.RGBA{R: 255}, blue, ColorSpaceRGB, color.RGBA{B: 255, A: 128}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := interpolateColor(tc.from, tc.to, 0.5, tc.space); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLabRoundTrip(t *testing.T) {
+	lab := rgbToLab(color.RGBA{R: 255, A: 255})
+	if math.Abs(lab.L-54.29) > 0.01 || math.Abs(lab.A-80.81) > 0.01 || math.Abs(lab.B-69.89) > 0.01 {
+		t.Fatalf("unexpected lab value %+v", lab)
+	}
+	c := color.RGBA{R: 12, G: 200, B: 77, A: 255}
+	if got := labToRGB(rgbToLab(c)); got != c {
+		t.Fatalf("round trip: expected %v, got %v", c, got)
+	}
+}
+
+func TestUnitBezier(t *testing.T) {
+	ease := newUnitBezier(0.42, 0, 0.58, 1)
+	if got := ease.solve(0.5); math.Abs(got-0.5) > 1e-6 {
+		t.Fatalf("expected 0.5, got %v", got)
+	}
+	if got := ease.solve(0.25); math.Abs(got-0.1292) > 1e-4 {
+		t.Fatalf("expected 0.1292, got %v", got)
+	}
+}
+
+func TestEvaluateInterpolateFamily(t *testing.T) {
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{`["interpolate",["cubic-bezier",0.42,0,0.58,1],["zoom"],0,0,10,100]`, 50.0},
+		{`["interpolate-lab",["linear"],["zoom"],0,"black",10,"white"]`, color.RGBA{R: 119, G: 119, B: 119, A: 255}},
+		{`["interpolate-hcl",["linear"],["zoom"],0,"red",10,"blue"]`, color.RGBA{R: 245, B: 134, A: 255}},
+		{`["interpolate",["linear"],["zoom"],0,"#ff000000",10,"blue"]`, color.RGBA{B: 255, A: 128}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := mustExpr(t, tc.expr).Evaluate(EvalContext{Zoom: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, ok := got.(float64); ok {
+				if math.Abs(n-tc.want.(float64)) > 1e-6 {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+				return
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestColorStopsColorSpace(t *testing.T) {
+	var c ColorType
+	if err := json.Unmarshal([]byte(`{"stops":[[0,"black"],[10,"white"]],"colorSpace":"lab"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.GetColorAtZoomLevel(5); got != (color.RGBA{R: 119, G: 119, B: 119, A: 255}) {
+		t.Fatalf("unexpected color %v", got)
+	}
+}
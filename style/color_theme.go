@@ -0,0 +1,178 @@
+package style
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the "<color property>-use-theme" paint properties.
+const (
+	UseThemeDefault = "default"
+	UseThemeNone    = "none"
+)
+
+// ColorLUT is a 3D color lookup table decoded from a color theme.
+type ColorLUT struct {
+	// Size is the number of entries along each axis.
+	Size int
+	// data holds the output colors as RGB in [0, 1], red varying fastest
+	// and blue slowest.
+	data [][3]float64
+}
+
+// LUT decodes the theme's lookup table. It returns nil for a nil theme or
+// one without data.
+func (t *ColorTheme) LUT() (*ColorLUT, error) {
+	if t == nil || t.Data == "" {
+		return nil, nil
+	}
+	return DecodeColorLUT(t.Data)
+}
+
+// DecodeColorLUT decodes a base64 encoded PNG lookup table strip, as used
+// by color-theme. A table of size N is an N*N pixels wide and N pixels high
+// image of N slices side by side: x within a slice is red, y is green and
+// the slice is blue. A data URI prefix is accepted.
+func DecodeColorLUT(data string) (*ColorLUT, error) {
+	if i := strings.Index(data, "base64,"); strings.HasPrefix(data, "data:") && i >= 0 {
+		data = data[i+len("base64,"):]
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding color theme")
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding color theme image")
+	}
+	return NewColorLUT(img)
+}
+
+// NewColorLUT reads a lookup table from an image strip laid out as
+// described for DecodeColorLUT.
+func NewColorLUT(img image.Image) (*ColorLUT, error) {
+	b := img.Bounds()
+	n := b.Dy()
+	if n < 2 || b.Dx() != n*n {
+		return nil, errors.Errorf("color theme image is %dx%d, expected a strip of N*N x N pixels", b.Dx(), b.Dy())
+	}
+	lut := &ColorLUT{Size: n, data: make([][3]float64, n*n*n)}
+	for blue := 0; blue < n; blue++ {
+		for green := 0; green < n; green++ {
+			for red := 0; red < n; red++ {
+				c := color.NRGBAModel.Convert(img.At(b.Min.X+blue*n+red, b.Min.Y+green)).(color.NRGBA)
+				lut.data[(blue*n+green)*n+red] = [3]float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+			}
+		}
+	}
+	return lut, nil
+}
+
+func (l *ColorLUT) at(r, g, b int) [3]float64 {
+	return l.data[(b*l.Size+g)*l.Size+r]
+}
+
+// Apply maps c through the table with trilinear interpolation. Alpha is
+// kept. A nil table or color is returned unchanged.
+func (l *ColorLUT) Apply(c color.Color) color.Color {
+	if l == nil || c == nil {
+		return c
+	}
+	in := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if in.A == 0 {
+		return c
+	}
+	last := float64(l.Size - 1)
+	var lo, hi [3]int
+	var frac [3]float64
+	for i, v := range []uint8{in.R, in.G, in.B} {
+		pos := float64(v) / 255 * last
+		lo[i] = int(math.Floor(pos))
+		hi[i] = min(lo[i]+1, l.Size-1)
+		frac[i] = pos - float64(lo[i])
+	}
+	var out [3]float64
+	for corner := 0; corner < 8; corner++ {
+		idx, w := [3]int{}, 1.0
+		for axis := 0; axis < 3; axis++ {
+			if corner&(1<<axis) != 0 {
+				idx[axis], w = hi[axis], w*frac[axis]
+			} else {
+				idx[axis], w = lo[axis], w*(1-frac[axis])
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		v := l.at(idx[0], idx[1], idx[2])
+		for i := range out {
+			out[i] += v[i] * w
+		}
+	}
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return color.NRGBA{channel(out[0]), channel(out[1]), channel(out[2]), in.A}
+}
+
+// UsesThemeAt reports whether the color theme applies to a color paint
+// property, such as "fill-color". A property opts out when its
+// "<property>-use-theme" property evaluates to "none".
+func (p *Paint) UsesThemeAt(property string, zoom float64, feature *Feature) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	i, ok := useThemeFields()[property]
+	if !ok {
+		return false, errors.Errorf("%q is not a themeable color property", property)
+	}
+	raw := reflect.ValueOf(p).Elem().Field(i).Interface()
+	v, err := propertyAt[string](&p.cache, p.config, property+"-use-theme", raw, zoom, feature, UseThemeDefault)
+	if err != nil {
+		return true, err
+	}
+	return v != UseThemeNone, nil
+}
+
+// ThemeColor applies lut to c, a value of the layer's color paint property,
+// unless the layer opts that property out of the theme.
+func (l *Layer) ThemeColor(lut *ColorLUT, property string, c color.Color, zoom float64, feature *Feature) (color.Color, error) {
+	if lut == nil || c == nil {
+		return c, nil
+	}
+	use, err := l.Paint.UsesThemeAt(property, zoom, feature)
+	if err != nil || !use {
+		return c, err
+	}
+	return lut.Apply(c), nil
+}
+
+var (
+	useThemeOnce  sync.Once
+	useThemeIndex map[string]int
+)
+
+// useThemeFields maps color property names to the index of their
+// "-use-theme" field in Paint.
+func useThemeFields() map[string]int {
+	useThemeOnce.Do(func() {
+		useThemeIndex = map[string]int{}
+		t := reflect.TypeOf(Paint{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if prop, ok := strings.CutSuffix(name, "-use-theme"); ok {
+				useThemeIndex[prop] = i
+			}
+		}
+	})
+	return useThemeIndex
+}
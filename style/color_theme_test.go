@@ -0,0 +1,140 @@
+package style
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// invertingLUT returns a base64 PNG strip of size n that inverts colors.
+func invertingLUT(t *testing.T, n int) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, n*n, n))
+	for b := 0; b < n; b++ {
+		for g := 0; g < n; g++ {
+			for r := 0; r < n; r++ {
+				v := func(i int) uint8 { return uint8(255 - i*255/(n-1)) }
+				img.Set(b*n+r, g, color.NRGBA{v(r), v(g), v(b), 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestColorLUT(t *testing.T) {
+	lut, err := DecodeColorLUT("data:image/png;base64," + invertingLUT(t, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Size != 4 {
+		t.Fatalf("size = %d", lut.Size)
+	}
+	tests := []struct {
+		in, want color.NRGBA
+	}{
+		{color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}},
+		{color.NRGBA{255, 0, 255, 128}, color.NRGBA{0, 255, 0, 128}},
+		// Between table entries the result is interpolated.
+		{color.NRGBA{100, 200, 51, 255}, color.NRGBA{155, 55, 204, 255}},
+	}
+	for _, tt := range tests {
+		if got := lut.Apply(tt.in); got != tt.want {
+			t.Errorf("Apply(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if lut.Apply(nil) != nil || (*ColorLUT)(nil).Apply(color.Black) != color.Black {
+		t.Error("nil colors and tables must pass through")
+	}
+
+	if _, err := NewColorLUT(image.NewNRGBA(image.Rect(0, 0, 10, 4))); err == nil {
+		t.Error("expected error for a non-strip image")
+	}
+	if lut, err := (&ColorTheme{}).LUT(); lut != nil || err != nil {
+		t.Errorf("empty theme = %v, %v", lut, err)
+	}
+}
+
+func TestLayerThemeColor(t *testing.T) {
+	s := parseStyle(t, `{
+		"version": 8,
+		"sources": {},
+		"layers": [
+			{"id": "a", "type": "fill", "paint": {"fill-color": "#000000"}},
+			{"id": "b", "type": "fill", "paint": {"fill-color": "#000000", "fill-color-use-theme": "none"}},
+			{"id": "c", "type": "fill", "paint": {"fill-color": "#000000",
+				"fill-color-use-theme": ["match", ["get", "kind"], "brand", "none", "default"]}}
+		]
+	}`)
+	lut, err := DecodeColorLUT(invertingLUT(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := color.NRGBA{255, 255, 255, 255}
+	tests := []struct {
+		layer   int
+		feature *Feature
+		want    color.Color
+	}{
+		{0, nil, white},
+		{1, nil, color.Black},
+		{2, &Feature{Properties: map[string]interface{}{"kind": "brand"}}, color.Black},
+		{2, &Feature{Properties: map[string]interface{}{"kind": "park"}}, white},
+	}
+	for _, tt := range tests {
+		got, err := s.Layers[tt.layer].ThemeColor(lut, "fill-color", color.Black, 0, tt.feature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("layer %d: color = %v, want %v", tt.layer, got, tt.want)
+		}
+	}
+	if _, err := s.Layers[0].ThemeColor(lut, "fill-opacity", color.Black, 0, nil); err == nil {
+		t.Error("expected error for a non-color property")
+	}
+	if errs := s.ValidateLayers(); len(errs) != 0 {
+		t.Errorf("use-theme properties rejected: %v", errs)
+	}
+}
+
+func TestResolveImportsColorTheme(t *testing.T) {
+	background := func(id string) *Layer { return &Layer{ID: id, Type: LayerTypeBackground} }
+	inner := &Style{Version: 8, ColorTheme: &ColorTheme{Data: "inner"}, Layers: []*Layer{background("c")}}
+	plain := &Style{Version: 8, Layers: []*Layer{background("d")}}
+	child := &Style{Version: 8, ColorTheme: &ColorTheme{Data: "child"}, Layers: []*Layer{background("b")},
+		Imports: []Import{{ID: "inner", Data: inner}, {ID: "plain", Data: plain}}}
+	parent := &Style{Version: 8, ColorTheme: &ColorTheme{Data: "parent"}, Layers: []*Layer{background("a")},
+		Imports: []Import{{ID: "base", Data: child, ColorTheme: &ColorTheme{Data: "import"}}}}
+	out, err := ResolveImports(parent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"base/inner/c": "inner", "base/plain/d": "", "base/b": "import", "a": "parent"}
+	if len(out.Layers) != len(want) {
+		t.Fatalf("layers = %d", len(out.Layers))
+	}
+	for _, l := range out.Layers {
+		got := ""
+		if theme := out.ColorThemeFor(l); theme != nil {
+			got = theme.Data
+		}
+		if got != want[l.ID] {
+			t.Errorf("layer %q: color theme = %q, want %q", l.ID, got, want[l.ID])
+		}
+	}
+	if out.ColorTheme == nil || out.ColorTheme.Data != "parent" {
+		t.Errorf("style color theme = %+v, want the parent's", out.ColorTheme)
+	}
+
+	parent.ColorTheme = nil
+	if out, _ = ResolveImports(parent, nil); out.ColorTheme != nil {
+		t.Errorf("import color theme flattened onto the style: %+v", out.ColorTheme)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"image/color"
 	"math"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -16,6 +15,14 @@ const defaultColorAlpha = 0xff
 
 type ColorType struct {
 	internalType internalColorType
+	// config is the scope config expressions read, see Style.BindConfig.
+	config *ConfigScope
+}
+
+// NewColorType returns a ColorType holding a constant color, serialized in
+// its canonical CSS form.
+func NewColorType(c color.Color) *ColorType {
+	return &ColorType{internalType: plainColorType{Color: c, raw: ColorToCSS(c)}}
 }
 
 func (c *ColorType) GetColorAtZoomLevel(zoomLevel ZoomLevel) color.Color {
@@ -25,6 +32,28 @@ func (c *ColorType) GetColorAtZoomLevel(zoomLevel ZoomLevel) color.Color {
 	return c.internalType.GetValueAtZoomLevel(zoomLevel)
 }
 
+// Evaluate returns the color for a zoom level and feature. Unlike
+// GetColorAtZoomLevel it gives expressions access to feature data. An unset
+// color evaluates to nil.
+func (c *ColorType) Evaluate(zoom float64, feature *Feature) (color.Color, error) {
+	if c == nil || c.internalType == nil {
+		return nil, nil
+	}
+	e, ok := c.internalType.(*expressionColorType)
+	if !ok {
+		return c.internalType.GetValueAtZoomLevel(ZoomLevel(zoom)), nil
+	}
+	v, err := e.Expr.Evaluate(EvalContext{Zoom: zoom, Feature: feature, Config: c.config})
+	if err != nil {
+		return nil, err
+	}
+	col, ok := valueToColor(v)
+	if !ok {
+		return nil, errors.Errorf("expected color but found %s", typeOf(v))
+	}
+	return col, nil
+}
+
 func (c *ColorType) UnmarshalJSON(data []byte) error {
 	var i interface{}
 	err := json.Unmarshal(data, &i)
@@ -41,6 +70,20 @@ func (c *ColorType) UnmarshalJSON(data []byte) error {
 
 		c.internalType = plainColorType{Color: colorValue, raw: val}
 	case map[string]interface{}:
+		if _, ok := val["property"]; ok {
+			// Property and composite functions are evaluated as their
+			// expression equivalent but keep their original form.
+			raw, err := convertFunction(val, propertySpec{Type: specColor}, false)
+			if err != nil {
+				return err
+			}
+			expr := &Expression{}
+			if err := expr.decode(raw); err != nil {
+				return err
+			}
+			c.internalType = &expressionColorType{Expr: expr, function: val}
+			return nil
+		}
 		var colorStops ColorStopsType
 		err = json.Unmarshal(data, &colorStops)
 		if err != nil {
@@ -48,6 +91,13 @@ func (c *ColorType) UnmarshalJSON(data []byte) error {
 		}
 
 		c.internalType = &colorStops
+	case []interface{}:
+		expr := &Expression{}
+		if err := expr.decode(val); err != nil {
+			return err
+		}
+
+		c.internalType = &expressionColorType{Expr: expr}
 	default:
 		panic(fmt.Sprintf("couldn't understand: %T :: %s", i, string(data)))
 	}
@@ -64,6 +114,11 @@ func (c *ColorType) MarshalJSON() ([]byte, error) {
 		return json.Marshal(v.raw)
 	case *ColorStopsType:
 		return json.Marshal(v)
+	case *expressionColorType:
+		if v.function != nil {
+			return json.Marshal(v.function)
+		}
+		return v.Expr.MarshalJSON()
 	default:
 		return json.Marshal(nil)
 	}
@@ -82,12 +137,27 @@ func (p plainColorType) GetValueAtZoomLevel(zoomLevel ZoomLevel) color.Color {
 	return p.Color
 }
 
-var (
-	strColorHSLRegexp  = regexp.MustCompile(`hsl\(\s*(\d+)\s*,\s*(\d+)%\s*,\s*(\d+)%\s*\)`)
-	strColorHSLARegexp = regexp.MustCompile(`hsla\(\s*(\d+)\s*,\s*(\d+)%\s*,\s*(\d+)%\s*,\s*(\d*\.?\d*)\s*\)`)
-	strColorRGBRegexp  = regexp.MustCompile(`rgb\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)`)
-	strColorRGBARegexp = regexp.MustCompile(`rgba\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d*\.?\d*)\s*\)`)
+// expressionColorType is a color given as an expression, or as a legacy
+// property function converted to one. Only camera inputs are available when
+// it is evaluated through GetValueAtZoomLevel.
+type expressionColorType struct {
+	Expr     *Expression
+	function map[string]interface{}
+}
 
+func (e *expressionColorType) GetValueAtZoomLevel(zoomLevel ZoomLevel) color.Color {
+	v, err := e.Expr.Evaluate(EvalContext{Zoom: float64(zoomLevel)})
+	if err != nil {
+		return nil
+	}
+	c, ok := valueToColor(v)
+	if !ok {
+		return nil
+	}
+	return c
+}
+
+var (
 	namedColors = map[string]string{
 		"black": "#000000", "silver": "#c0c0c0", "gray": "#808080",
 		"white": "#ffffff", "maroon": "#800000", "red": "#ff0000",
@@ -137,7 +207,8 @@ var (
 		"snow": "#fffafa", "springgreen": "#00ff7f", "steelblue": "#4682b4",
 		"tan": "#d2b48c", "thistle": "#d8bfd8", "tomato": "#ff6347",
 		"turquoise": "#40e0d0", "violet": "#ee82ee", "wheat": "#f5deb3",
-		"whitesmoke": "#f5f5f5", "yellowgreen": "#9acd32",
+		"whitesmoke": "#f5f5f5", "yellowgreen": "#9acd32", "darkslategrey": "#2f4f4f",
+		"rebeccapurple": "#663399",
 	}
 )
 
@@ -146,35 +217,24 @@ func strToColor(str string, defaultAlpha uint8) (color.Color, error) {
 		return nil, nil
 	}
 
-	switch strings.ToLower(str) {
+	lower := strings.ToLower(strings.TrimSpace(str))
+	switch lower {
 	case "transparent":
 		return color.RGBA{A: 0}, nil
 	case "none":
 		return nil, nil
 	}
 
-	if strings.HasPrefix(str, "#") {
-		return getHexColor(str, defaultAlpha)
+	if strings.HasPrefix(lower, "#") {
+		return getHexColor(lower, defaultAlpha)
 	}
 
-	if hex, ok := namedColors[strings.ToLower(str)]; ok {
+	if hex, ok := namedColors[lower]; ok {
 		return getHexColor(hex, defaultAlpha)
 	}
 
-	if strColorHSLRegexp.MatchString(str) {
-		return getHSLColor(str, defaultAlpha)
-	}
-
-	if strColorHSLARegexp.MatchString(str) {
-		return getHSLAColor(str)
-	}
-
-	if strColorRGBRegexp.MatchString(str) {
-		return getRGBColor(str, defaultAlpha)
-	}
-
-	if strColorRGBARegexp.MatchString(str) {
-		return getRGBAColor(str)
+	if open := strings.IndexByte(lower, '('); open > 0 && strings.HasSuffix(lower, ")") {
+		return getFunctionalColor(lower[:open], lower[open+1:len(lower)-1], defaultAlpha)
 	}
 
 	return nil, errors.Errorf("unimplemented color handing: %q", str)
@@ -194,6 +254,13 @@ func getHexColor(str string, defaultAlpha uint8) (color.Color, error) {
 		defaultAlpha = uint8(alphaVal)
 	case 7:
 		hex6Char = str[1:7]
+	case 5:
+		alphaVal, err := strconv.ParseUint(str[4:5], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		defaultAlpha = uint8(alphaVal * 17)
+		fallthrough
 	case 4:
 		for _, ch := range str[1:4] {
 			hex6Char += (string(ch) + string(ch))
@@ -219,6 +286,135 @@ func getHexColor(str string, defaultAlpha uint8) (color.Color, error) {
 	}, nil
 }
 
+// splitColorArgs splits the arguments of a CSS color function, accepting
+// both the legacy comma syntax and the CSS Color 4 space syntax with an
+// optional "/ alpha".
+func splitColorArgs(args string) ([]string, error) {
+	var parts []string
+	alpha := ""
+	if slash := strings.IndexByte(args, '/'); slash >= 0 {
+		alpha = strings.TrimSpace(args[slash+1:])
+		args = args[:slash]
+		if alpha == "" || strings.ContainsAny(alpha, ", ") || strings.Contains(args, ",") {
+			return nil, errors.Errorf("invalid alpha in color arguments %q", args)
+		}
+	}
+	if strings.Contains(args, ",") {
+		for _, p := range strings.Split(args, ",") {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				return nil, errors.Errorf("empty color argument in %q", args)
+			}
+			parts = append(parts, p)
+		}
+	} else {
+		parts = strings.Fields(args)
+	}
+	if alpha != "" {
+		parts = append(parts, alpha)
+	}
+	return parts, nil
+}
+
+// parseColorNumber parses a number or percentage. Percentages are scaled so
+// that 100% equals scale.
+func parseColorNumber(s string, scale float64) (float64, error) {
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, errors.Errorf("invalid percentage %q", s)
+		}
+		return v / 100 * scale, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
+
+// parseHue parses a hue in degrees, accepting the deg, rad, grad and turn
+// units.
+func parseHue(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"deg", 1}, {"grad", 0.9}, {"rad", 180 / math.Pi}, {"turn", 360},
+	}
+	factor := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, factor = strings.TrimSuffix(s, u.suffix), u.factor
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid hue %q", s)
+	}
+	h := math.Mod(v*factor, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h, nil
+}
+
+func parseAlpha(s string) (uint8, error) {
+	a, err := parseColorNumber(s, 1)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(math.Round(clamp01(a) * 255)), nil
+}
+
+func getFunctionalColor(name, args string, defaultAlpha uint8) (color.Color, error) {
+	parts, err := splitColorArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, errors.Errorf("%s() requires 3 or 4 arguments, found %d", name, len(parts))
+	}
+	alpha := defaultAlpha
+	if len(parts) == 4 {
+		if alpha, err = parseAlpha(parts[3]); err != nil {
+			return nil, err
+		}
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		var ch [3]uint8
+		for i, p := range parts[:3] {
+			v, err := parseColorNumber(p, 255)
+			if err != nil {
+				return nil, err
+			}
+			ch[i] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+		}
+		return color.RGBA{R: ch[0], G: ch[1], B: ch[2], A: alpha}, nil
+	case "hsl", "hsla", "hwb":
+		h, err := parseHue(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		var v [2]float64
+		for i, p := range parts[1:3] {
+			n, err := parseColorNumber(p, 100)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = clamp01(n / 100)
+		}
+		if name == "hwb" {
+			return hwbToRGBA(h, v[0], v[1], alpha), nil
+		}
+		return hslToRGBA(h, v[0], v[1], alpha), nil
+	}
+	return nil, errors.Errorf("unknown color function %q", name)
+}
+
 // hslToRGBA converts HSL values (hue 0-360, saturation 0-1, lightness 0-1) to RGBA.
 func hslToRGBA(h, s, l float64, a uint8) color.RGBA {
 	if s == 0 {
@@ -265,86 +461,30 @@ func hueToRGB(p, q, t float64) float64 {
 	return p
 }
 
-func getHSLColor(str string, defaultAlpha uint8) (color.Color, error) {
-	matches := strColorHSLRegexp.FindStringSubmatch(str)
-	colorFragments := [3]float64{}
-	for i, match := range matches[1:4] {
-		c, err := strconv.ParseFloat(match, 64)
-		if err != nil {
-			return nil, err
-		}
-		colorFragments[i] = c
+// hwbToRGBA converts HWB values (hue 0-360, whiteness 0-1, blackness 0-1)
+// to RGBA.
+func hwbToRGBA(h, w, b float64, a uint8) color.RGBA {
+	if w+b >= 1 {
+		v := uint8(math.Round(w / (w + b) * 255))
+		return color.RGBA{R: v, G: v, B: v, A: a}
 	}
-
-	if colorFragments[0] < 0 || colorFragments[0] > 360 ||
-		colorFragments[1] < 0 || colorFragments[1] > 100 ||
-		colorFragments[2] < 0 || colorFragments[2] > 100 {
-		return nil, errors.Errorf("hsl value out of range: h=%f s=%f l=%f", colorFragments[0], colorFragments[1], colorFragments[2])
+	c := hslToRGBA(h, 1, 0.5, a)
+	scale := func(ch uint8) uint8 {
+		return uint8(math.Round((float64(ch)/255*(1-w-b) + w) * 255))
 	}
-
-	return hslToRGBA(colorFragments[0], colorFragments[1]/100, colorFragments[2]/100, defaultAlpha), nil
+	return color.RGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: a}
 }
 
-func getHSLAColor(str string) (color.Color, error) {
-	matches := strColorHSLARegexp.FindStringSubmatch(str)
-	colorFragments := [4]float64{}
-	for i, match := range matches[1:5] {
-		c, err := strconv.ParseFloat(match, 64)
-		if err != nil {
-			return nil, err
-		}
-		colorFragments[i] = c
+// ColorToCSS returns the canonical CSS form of a color: "#rrggbb" for opaque
+// colors and "rgba(r, g, b, a)" otherwise.
+func ColorToCSS(c color.Color) string {
+	if c == nil {
+		return "transparent"
 	}
-
-	if colorFragments[0] < 0 || colorFragments[0] > 360 ||
-		colorFragments[1] < 0 || colorFragments[1] > 100 ||
-		colorFragments[2] < 0 || colorFragments[2] > 100 ||
-		colorFragments[3] < 0 || colorFragments[3] > 1 {
-		return nil, errors.Errorf("hsla value out of range")
+	rgba, _ := valueToColor(c)
+	if rgba.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
 	}
-
-	return hslToRGBA(colorFragments[0], colorFragments[1]/100, colorFragments[2]/100,
-		uint8(math.Round(colorFragments[3]*256)-1)), nil
-}
-
-func getRGBAColor(str string) (color.Color, error) {
-	matches := strColorRGBARegexp.FindStringSubmatch(str)
-	colorFragments := [3]uint8{}
-	for i, match := range matches[1:4] {
-		c, err := strconv.ParseUint(match, 10, 8)
-		if err != nil {
-			return nil, err
-		}
-		colorFragments[i] = uint8(c)
-	}
-	alpha, err := strconv.ParseFloat(matches[4], 64)
-	if err != nil {
-		return nil, err
-	}
-
-	return color.RGBA{
-		R: colorFragments[0],
-		G: colorFragments[1],
-		B: colorFragments[2],
-		A: uint8(math.Round(alpha*256) - 1),
-	}, nil
-}
-
-func getRGBColor(str string, defaultAlpha uint8) (color.Color, error) {
-	matches := strColorRGBRegexp.FindStringSubmatch(str)
-	colorFragments := [3]uint8{}
-	for i, match := range matches[1:4] {
-		c, err := strconv.ParseUint(match, 10, 8)
-		if err != nil {
-			return nil, err
-		}
-		colorFragments[i] = uint8(c)
-	}
-
-	return color.RGBA{
-		R: colorFragments[0],
-		G: colorFragments[1],
-		B: colorFragments[2],
-		A: defaultAlpha,
-	}, nil
+	alpha := strconv.FormatFloat(math.Round(float64(rgba.A)/255*1000)/1000, 'f', -1, 64)
+	return fmt.Sprintf("rgba(%d, %d, %d, %s)", rgba.R, rgba.G, rgba.B, alpha)
 }
@@ -0,0 +1,105 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+)
+
+func TestStrToColorCSS4(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"rgb(255 0 0)", color.RGBA{R: 255, A: 255}},
+		{"rgb(255 0 0 / 50%)", color.RGBA{R: 255, A: 128}},
+		{"rgba(255, 0, 0, 0)", color.RGBA{R: 255}},
+		{"rgba(255,0,0,0.5)", color.RGBA{R: 255, A: 128}},
+		{"rgb(100%, 50%, 0%)", color.RGBA{R: 255, G: 128, A: 255}},
+		{"hsl(120deg 100% 25%)", color.RGBA{G: 128, A: 255}},
+		{"hsl(0.5turn, 100%, 50%)", color.RGBA{G: 255, B: 255, A: 255}},
+		{"hsla(240, 100%, 50%, .25)", color.RGBA{B: 255, A: 64}},
+		{"hwb(0 0% 0%)", color.RGBA{R: 255, A: 255}},
+		{"hwb(120 20% 20%)", color.RGBA{R: 51, G: 204, B: 51, A: 255}},
+		{"hwb(0 60% 60%)", color.RGBA{R: 128, G: 128, B: 128, A: 255}},
+		{"#F00A", color.RGBA{R: 255, A: 170}},
+		{"RebeccaPurple", color.RGBA{R: 102, G: 51, B: 153, A: 255}},
+		{"TRANSPARENT", color.RGBA{}},
+		{"RGB(1, 2, 3)", color.RGBA{R: 1, G: 2, B: 3, A: 255}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			c, err := strToColor(tc.in, defaultColorAlpha)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := valueToColor(c); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStrToColorInvalid(t *testing.T) {
+	for _, in := range []string{"rgb(1, 2)", "rgb(1 2 3 4 5)", "hsl(x, 1%, 1%)", "foo(1, 2, 3)", "rgb(1, 2 / 3)", "notacolor"} {
+		if _, err := strToColor(in, defaultColorAlpha); err == nil {
+			t.Fatalf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestColorToCSS(t *testing.T) {
+	tests := []struct {
+		in   color.Color
+		want string
+	}{
+		{color.RGBA{R: 255, A: 255}, "#ff0000"},
+		{color.RGBA{R: 255, G: 128, A: 128}, "rgba(255, 128, 0, 0.502)"},
+		{color.RGBA{}, "rgba(0, 0, 0, 0)"},
+		{nil, "transparent"},
+	}
+	for _, tc := range tests {
+		if got := ColorToCSS(tc.in); got != tc.want {
+			t.Fatalf("expected %q, got %q", tc.want, got)
+		}
+		if tc.in == nil {
+			continue
+		}
+		back, err := strToColor(tc.want, defaultColorAlpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := valueToColor(back); got != tc.in {
+			t.Fatalf("%q parsed back as %v", tc.want, got)
+		}
+	}
+}
+
+func TestColorTypeMarshalFidelity(t *testing.T) {
+	for _, raw := range []string{
+		`"hsl(120deg 100% 25%)"`,
+		`["match",["get","class"],["park","forest"],"green",["rgba",0,0,0,0.5]]`,
+		`["interpolate-hcl",["linear"],["zoom"],0,"red",10,["to-color",["get","c"]]]`,
+		`["case",["boolean",["feature-state","hover"],false],"#fff","hwb(0 0% 0%)"]`,
+	} {
+		var c ColorType
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(&c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(string(out), raw) {
+			t.Fatalf("round trip:\n  in:  %s\n  out: %s", raw, out)
+		}
+	}
+
+	out, err := json.Marshal(NewColorType(color.RGBA{B: 255, A: 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `"#0000ff"` {
+		t.Fatalf("unexpected %s", out)
+	}
+}
@@ -0,0 +1,330 @@
+package style
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Schema option value types.
+const (
+	SchemaTypeString  = "string"
+	SchemaTypeNumber  = "number"
+	SchemaTypeBoolean = "boolean"
+	SchemaTypeColor   = "color"
+)
+
+// ConfigScope binds the values of a style's config options: the schema
+// defaults, overridden by import config and runtime values. Imported
+// styles get scopes of their own, reachable by import id.
+type ConfigScope struct {
+	options map[string]SchemaOption
+	values  map[string]interface{}
+	imports map[string]*ConfigScope
+}
+
+// NewConfigScope returns a scope holding the defaults of schema.
+func NewConfigScope(schema map[string]SchemaOption) *ConfigScope {
+	c := &ConfigScope{
+		options: map[string]SchemaOption{},
+		values:  map[string]interface{}{},
+		imports: map[string]*ConfigScope{},
+	}
+	for name, opt := range schema {
+		c.options[name] = opt
+		c.values[name] = opt.Default
+	}
+	return c
+}
+
+// ConfigScope builds the config scope of the style and of the imports that
+// carry inline data, applying each import's config overrides.
+func (s *Style) ConfigScope() (*ConfigScope, error) {
+	c := NewConfigScope(s.Schema)
+	for _, imp := range s.Imports {
+		if imp.Data == nil {
+			continue
+		}
+		child, err := imp.Data.ConfigScope()
+		if err != nil {
+			return nil, errors.Wrapf(err, "import %q", imp.ID)
+		}
+		if err := child.Apply(imp.Config); err != nil {
+			return nil, errors.Wrapf(err, "import %q", imp.ID)
+		}
+		c.imports[imp.ID] = child
+	}
+	return c, nil
+}
+
+// configBinding holds the scope a style is bound to. It is shared by
+// copies of the Style so they stay bound to the same scope.
+type configBinding struct {
+	mu    sync.Mutex
+	scope *ConfigScope
+	// bound is set when the scope came from BindConfig rather than from
+	// the schema defaults.
+	bound bool
+}
+
+// Config returns the config scope that the style's filters and property
+// accessors read config expressions from. Unless BindConfig was called it
+// holds the schema defaults. Layers added or replaced since the scope was
+// bound, by Diff for example, are bound again. It fails when the config of
+// an import does not satisfy the imported style's schema.
+func (s *Style) Config() (*ConfigScope, error) {
+	if s.config == nil {
+		s.config = &configBinding{}
+	}
+	b := s.config
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.scope == nil {
+		c, err := s.ConfigScope()
+		if err != nil {
+			return nil, err
+		}
+		b.scope = c
+	}
+	for _, l := range s.Layers {
+		if l != nil && !l.boundTo(b.scope) {
+			l.bindConfig(b.scope)
+		}
+	}
+	return b.scope, nil
+}
+
+// BindConfig makes the style evaluate config expressions against c, so
+// runtime changes through c.Set apply to the next evaluation. A nil c
+// restores the schema defaults, which fails like Config.
+func (s *Style) BindConfig(c *ConfigScope) error {
+	if s.config == nil {
+		s.config = &configBinding{}
+	}
+	s.config.mu.Lock()
+	s.config.scope, s.config.bound = c, c != nil
+	s.config.mu.Unlock()
+	_, err := s.Config()
+	return err
+}
+
+// boundTo reports whether the layer and its paint and layout are bound to c.
+func (l *Layer) boundTo(c *ConfigScope) bool {
+	return l.config == c && (l.Paint == nil || l.Paint.config == c) && (l.Layout == nil || l.Layout.config == c)
+}
+
+func (l *Layer) bindConfig(c *ConfigScope) {
+	l.config = c
+	if l.Layout != nil {
+		l.Layout.config = c
+	}
+	if l.Paint != nil {
+		l.Paint.config = c
+		v := reflect.ValueOf(l.Paint).Elem()
+		for _, i := range colorFields() {
+			if col, ok := v.Field(i).Interface().(*ColorType); ok && col != nil {
+				col.config = c
+			}
+		}
+	}
+}
+
+var (
+	colorFieldsOnce  sync.Once
+	colorFieldsIndex []int
+)
+
+// colorFields returns the indexes of the *ColorType fields of Paint.
+func colorFields() []int {
+	colorFieldsOnce.Do(func() {
+		t := reflect.TypeOf(Paint{})
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Type == reflect.TypeOf((*ColorType)(nil)) {
+				colorFieldsIndex = append(colorFieldsIndex, i)
+			}
+		}
+	})
+	return colorFieldsIndex
+}
+
+// ValidateConfig checks user supplied config values against the style
+// schema without applying them.
+func (s *Style) ValidateConfig(config map[string]interface{}) ValidationErrors {
+	return NewConfigScope(s.Schema).Validate(config)
+}
+
+// Get returns the current value of an option.
+func (c *ConfigScope) Get(name string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	v, ok := c.values[name]
+	return v, ok
+}
+
+// Set overrides an option after checking the value against its schema.
+func (c *ConfigScope) Set(name string, value interface{}) error {
+	opt, ok := c.options[name]
+	if !ok {
+		return errors.Errorf("unknown config option %q", name)
+	}
+	if err := opt.Check(value); err != nil {
+		return errors.Wrapf(err, "config option %q", name)
+	}
+	c.values[name] = normalizeValue(value)
+	return nil
+}
+
+// Reset restores the schema default of an option.
+func (c *ConfigScope) Reset(name string) {
+	if opt, ok := c.options[name]; ok {
+		c.values[name] = opt.Default
+	}
+}
+
+// Validate reports every config value that is unknown or does not satisfy
+// its schema option.
+func (c *ConfigScope) Validate(config map[string]interface{}) ValidationErrors {
+	var errs ValidationErrors
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := "config." + name
+		opt, ok := c.options[name]
+		if !ok {
+			errs.add(path, "unknown config option")
+			continue
+		}
+		if err := opt.Check(config[name]); err != nil {
+			errs.add(path, "%v", err)
+		}
+	}
+	return errs
+}
+
+// Apply validates config and, if every value is valid, sets them all.
+func (c *ConfigScope) Apply(config map[string]interface{}) error {
+	if err := c.Validate(config).Err(); err != nil {
+		return err
+	}
+	for name, v := range config {
+		c.values[name] = normalizeValue(v)
+	}
+	return nil
+}
+
+// Import returns the scope of an imported style, or nil.
+func (c *ConfigScope) Import(id string) *ConfigScope {
+	if c == nil {
+		return nil
+	}
+	return c.imports[id]
+}
+
+// SetImport attaches the scope of an imported style, for imports that are
+// loaded from a URL.
+func (c *ConfigScope) SetImport(id string, scope *ConfigScope) {
+	c.imports[id] = scope
+}
+
+// Check reports whether value is acceptable for the option: it must have
+// the option's type, lie within minValue and maxValue and, when values is
+// set, be one of them. Array options check every item.
+func (o SchemaOption) Check(value interface{}) error {
+	value = normalizeValue(value)
+	if o.Array != nil && *o.Array {
+		arr, ok := value.([]interface{})
+		if !ok {
+			return errors.Errorf("expected array but found %s", typeOf(value))
+		}
+		for i, item := range arr {
+			if err := o.checkItem(item); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		return nil
+	}
+	return o.checkItem(value)
+}
+
+func (o SchemaOption) checkItem(value interface{}) error {
+	switch o.Type {
+	case SchemaTypeString:
+		if _, ok := value.(string); !ok {
+			return errors.Errorf("expected string but found %s", typeOf(value))
+		}
+	case SchemaTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return errors.Errorf("expected boolean but found %s", typeOf(value))
+		}
+	case SchemaTypeColor:
+		if _, ok := valueToColor(value); !ok {
+			return errors.Errorf("expected color but found %s", jsonString(value))
+		}
+	case SchemaTypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return errors.Errorf("expected number but found %s", typeOf(value))
+		}
+		if o.MinValue != nil && n < *o.MinValue {
+			return errors.Errorf("%s is less than the minimum value %s", formatNumber(n), formatNumber(*o.MinValue))
+		}
+		if o.MaxValue != nil && n > *o.MaxValue {
+			return errors.Errorf("%s is greater than the maximum value %s", formatNumber(n), formatNumber(*o.MaxValue))
+		}
+		if o.StepValue != nil && *o.StepValue > 0 {
+			base := 0.0
+			if o.MinValue != nil {
+				base = *o.MinValue
+			}
+			steps := (n - base) / *o.StepValue
+			if math.Abs(steps-math.Round(steps)) > 1e-9 {
+				return errors.Errorf("%s is not a multiple of the step %s", formatNumber(n), formatNumber(*o.StepValue))
+			}
+		}
+	}
+	if len(o.Values) > 0 {
+		for _, allowed := range o.Values {
+			if valuesEqual(allowed, value) {
+				return nil
+			}
+		}
+		return errors.Errorf("expected one of %s, %s found", jsonString(o.Values), jsonString(value))
+	}
+	return nil
+}
+
+func (ev *evaluator) evalConfig(e *Expression) (interface{}, error) {
+	name, err := ev.evalStringArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	scope := ev.ctx.Config
+	if len(e.Args) > 1 {
+		id, err := ev.evalStringArg(e, 1)
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			scope = scope.Import(id)
+		}
+	}
+	v, ok := scope.Get(name)
+	if !ok {
+		return nil, nil
+	}
+	// Defaults may themselves be expressions, such as ["rgba", ...].
+	if expr, err := propertyExpression(v); err == nil && !expr.IsLiteral {
+		if expr.Operator == ExpConfig {
+			return nil, errors.Errorf("config option %q refers to another config option", name)
+		}
+		return ev.eval(expr)
+	}
+	return normalizeValue(v), nil
+}
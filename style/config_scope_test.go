@@ -0,0 +1,171 @@
+package style
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+const configStyle = `{
+	"version": 8,
+	"schema": {
+		"showPOIs": {"default": true, "type": "boolean"},
+		"lightPreset": {"default": "day", "type": "string", "values": ["dawn", "day", "dusk", "night"]},
+		"density": {"default": 3, "type": "number", "minValue": 1, "maxValue": 5, "stepValue": 1},
+		"accent": {"default": ["rgba", 255, 0, 0, 1], "type": "color"},
+		"languages": {"default": ["en"], "type": "string", "array": true}
+	},
+	"imports": [{"id": "basemap", "url": "", "config": {"theme": "faded"},
+		"data": {"version": 8, "sources": {}, "layers": [],
+			"schema": {"theme": {"default": "default", "type": "string"}}}}],
+	"sources": {},
+	"layers": []
+}`
+
+func TestConfigScopeEvaluate(t *testing.T) {
+	scope, err := parseStyle(t, configStyle).ConfigScope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{`["config", "showPOIs"]`, true},
+		{`["config", "density"]`, 3.0},
+		{`["config", "accent"]`, color.RGBA{R: 255, A: 255}},
+		{`["config", "theme", "basemap"]`, "faded"},
+		{`["config", "missing"]`, nil},
+		{`["case", ["config", "showPOIs"], 1, 0]`, 1.0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := mustExpr(t, tc.expr).Evaluate(EvalContext{Config: scope})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valuesEqual(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if err := scope.Set("lightPreset", "night"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := mustExpr(t, `["config", "lightPreset"]`).Evaluate(EvalContext{Config: scope})
+	if got != "night" {
+		t.Fatalf("runtime override not applied, got %v", got)
+	}
+	scope.Reset("lightPreset")
+	if v, _ := scope.Get("lightPreset"); v != "day" {
+		t.Fatalf("expected default after reset, got %v", v)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	s := parseStyle(t, configStyle)
+	errs := s.ValidateConfig(map[string]interface{}{
+		"showPOIs":    "yes",
+		"lightPreset": "noon",
+		"density":     2.5,
+		"languages":   []interface{}{"en", 1},
+		"accent":      "not-a-color",
+		"unknown":     1,
+	})
+	want := []string{
+		`config.accent: expected color but found "not-a-color"`,
+		"config.density: 2.5 is not a multiple of the step 1",
+		"config.languages: item 1: expected string but found number",
+		`config.lightPreset: expected one of ["dawn","day","dusk","night"], "noon" found`,
+		"config.showPOIs: expected boolean but found string",
+		"config.unknown: unknown config option",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Fatalf("error %d: expected %q, got %q", i, w, errs[i].Error())
+		}
+	}
+
+	if errs := s.ValidateConfig(map[string]interface{}{"density": 5, "lightPreset": "dusk"}); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	scope, _ := s.ConfigScope()
+	if err := scope.Set("density", 9); err == nil {
+		t.Fatal("expected a range error")
+	}
+}
+
+func TestStyleConfig(t *testing.T) {
+	s, err := Parse(strings.NewReader(`{
+		"version": 8,
+		"schema": {
+			"showPOIs": {"default": true, "type": "boolean"},
+			"poiSize": {"default": 4, "type": "number"}
+		},
+		"sources": {"v": {"type": "vector"}},
+		"layers": [
+			{"id": "pois", "type": "circle", "source": "v", "source-layer": "poi",
+			 "filter": ["config", "showPOIs"],
+			 "paint": {"circle-radius": ["*", 2, ["config", "poiSize"]], "circle-color": ["case", ["config", "showPOIs"], "#ff0000", "#0000ff"]}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := s.style.Layers[0]
+	f := &Feature{Type: "Point"}
+	if ok, err := l.Matches(f, 10); err != nil || !ok {
+		t.Errorf("Matches with schema default = %v, %v, want true", ok, err)
+	}
+	if r, err := l.Paint.CircleRadiusAt(10, f); err != nil || r != 8 {
+		t.Errorf("CircleRadiusAt = %v, %v, want 8", r, err)
+	}
+	if c, err := l.Paint.CircleColor.Evaluate(10, f); err != nil || !valuesEqual(c, color.RGBA{R: 255, A: 255}) {
+		t.Errorf("circle-color = %v, %v, want red", c, err)
+	}
+
+	scope := NewConfigScope(s.style.Schema)
+	if err := scope.Set("showPOIs", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.style.BindConfig(scope); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := l.Matches(f, 10); ok {
+		t.Error("Matches after hiding POIs")
+	}
+	scope.Reset("showPOIs")
+	if ok, _ := l.Matches(f, 10); !ok {
+		t.Error("Matches after resetting showPOIs")
+	}
+
+	// Layers replaced through Apply are bound to the same scope.
+	if err := s.style.Apply([]Command{{Command: CommandSetPaintProperty, Args: []interface{}{"pois", "circle-radius", []interface{}{"config", "poiSize"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Set("poiSize", 5); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := l.Paint.CircleRadiusAt(10, f); err != nil || r != 5 {
+		t.Errorf("CircleRadiusAt after setPaintProperty = %v, %v, want 5", r, err)
+	}
+}
+
+func TestStyleConfigInvalidImport(t *testing.T) {
+	doc := strings.Replace(configStyle, `{"theme": "faded"}`, `{"theme": 1}`, 1)
+	if _, err := Parse(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), `import "basemap"`) {
+		t.Errorf("Parse err = %v, want an import config error", err)
+	}
+
+	s := parseStyle(t, doc)
+	if _, err := s.Config(); err == nil {
+		t.Error("Config accepted an invalid import config")
+	}
+	if _, err := s.Legend(10); err == nil {
+		t.Error("Legend accepted an invalid import config")
+	}
+}
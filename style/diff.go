@@ -0,0 +1,581 @@
+package style
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Style mutation commands, named after the mapbox-gl Map methods that
+// perform them.
+const (
+	CommandSetStyle             = "setStyle"
+	CommandAddLayer             = "addLayer"
+	CommandRemoveLayer          = "removeLayer"
+	CommandSetPaintProperty     = "setPaintProperty"
+	CommandSetLayoutProperty    = "setLayoutProperty"
+	CommandSetFilter            = "setFilter"
+	CommandAddSource            = "addSource"
+	CommandRemoveSource         = "removeSource"
+	CommandSetLayerZoomRange    = "setLayerZoomRange"
+	CommandSetGeoJSONSourceData = "setGeoJSONSourceData"
+	CommandSetSprite            = "setSprite"
+	CommandSetGlyphs            = "setGlyphs"
+	CommandSetTerrain           = "setTerrain"
+	CommandSetFog               = "setFog"
+	CommandSetCenter            = "setCenter"
+	CommandSetZoom              = "setZoom"
+	CommandSetBearing           = "setBearing"
+	CommandSetPitch             = "setPitch"
+)
+
+// Command is a single style mutation. It serializes to the
+// {"command": ..., "args": [...]} form used by mapbox-gl's diffStyles.
+type Command struct {
+	Command string        `json:"command"`
+	Args    []interface{} `json:"args"`
+}
+
+// diffedStyleKeys are the top-level style keys that have dedicated
+// commands. A change to any other key falls back to setStyle.
+var diffedStyleKeys = map[string]bool{
+	"version": true, "sources": true, "layers": true,
+	"sprite": true, "glyphs": true, "terrain": true, "fog": true,
+	"center": true, "zoom": true, "bearing": true, "pitch": true,
+}
+
+// DiffStyles returns the commands that turn before into after. When a
+// change cannot be expressed incrementally, a single setStyle command is
+// returned.
+func DiffStyles(before, after *Style) ([]Command, error) {
+	if before == nil || after == nil || before.Version != after.Version {
+		return []Command{{Command: CommandSetStyle, Args: []interface{}{after}}}, nil
+	}
+
+	beforeMap, err := propertyMap(before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := propertyMap(after)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for k := range beforeMap {
+		keys[k] = true
+	}
+	for k := range afterMap {
+		keys[k] = true
+	}
+	for k := range keys {
+		if !diffedStyleKeys[k] && !jsonValuesEqual(beforeMap[k], afterMap[k]) {
+			return []Command{{Command: CommandSetStyle, Args: []interface{}{after}}}, nil
+		}
+	}
+
+	var commands []Command
+	push := func(command string, args ...interface{}) {
+		commands = append(commands, Command{Command: command, Args: args})
+	}
+
+	if !jsonValuesEqual(before.Center, after.Center) {
+		push(CommandSetCenter, after.Center)
+	}
+	if before.Zoom != after.Zoom {
+		push(CommandSetZoom, after.Zoom)
+	}
+	if before.Bearing != after.Bearing {
+		push(CommandSetBearing, after.Bearing)
+	}
+	if before.Pitch != after.Pitch {
+		push(CommandSetPitch, after.Pitch)
+	}
+	if before.Sprite != after.Sprite {
+		push(CommandSetSprite, after.Sprite)
+	}
+	if before.Glyphs != after.Glyphs {
+		push(CommandSetGlyphs, after.Glyphs)
+	}
+
+	// Layers using a source that is replaced must be re-added, so sources
+	// are diffed first. Removing a source only works once no layer uses it,
+	// so the removals are emitted after the layer commands.
+	var sourceRemovals []Command
+	replaced := map[string]bool{}
+	for _, id := range sortedSourceIDs(before.Sources) {
+		prev := before.Sources[id]
+		next, ok := after.Sources[id]
+		switch {
+		case !ok:
+			sourceRemovals = append(sourceRemovals, Command{Command: CommandRemoveSource, Args: []interface{}{id}})
+		case jsonValuesEqual(prev, next):
+		case canUpdateGeoJSON(prev, next):
+			push(CommandSetGeoJSONSourceData, id, next.Data)
+		default:
+			replaced[id] = true
+		}
+	}
+	for _, id := range sortedSourceIDs(after.Sources) {
+		if _, ok := before.Sources[id]; !ok {
+			push(CommandAddSource, id, after.Sources[id])
+		}
+	}
+
+	beforeLayers := before.Layers
+	if len(replaced) > 0 {
+		// Remove the layers of replaced sources up front, so the source
+		// can be swapped before any of its layers are added back.
+		var kept []*Layer
+		for _, l := range beforeLayers {
+			if l.Source != nil && replaced[*l.Source] {
+				push(CommandRemoveLayer, l.ID)
+				continue
+			}
+			kept = append(kept, l)
+		}
+		for _, id := range sortedSourceIDs(after.Sources) {
+			if replaced[id] {
+				push(CommandRemoveSource, id)
+				push(CommandAddSource, id, after.Sources[id])
+			}
+		}
+		beforeLayers = kept
+	}
+
+	layerCommands, err := diffLayers(beforeLayers, after.Layers)
+	if err != nil {
+		return nil, err
+	}
+	commands = append(commands, layerCommands...)
+	commands = append(commands, sourceRemovals...)
+
+	if !jsonValuesEqual(before.Terrain, after.Terrain) {
+		push(CommandSetTerrain, after.Terrain)
+	}
+	if !jsonValuesEqual(before.Fog, after.Fog) {
+		push(CommandSetFog, after.Fog)
+	}
+	return commands, nil
+}
+
+func sortedSourceIDs(sources Sources) []string {
+	ids := make([]string, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// canUpdateGeoJSON reports whether two GeoJSON sources differ only in
+// their data.
+func canUpdateGeoJSON(prev, next *Source) bool {
+	if prev == nil || next == nil || prev.Type != "geojson" || next.Type != "geojson" {
+		return false
+	}
+	a, b := *prev, *next
+	a.Data, b.Data = nil, nil
+	return jsonValuesEqual(&a, &b)
+}
+
+func diffLayers(before, after []*Layer) ([]Command, error) {
+	var commands []Command
+	push := func(command string, args ...interface{}) {
+		commands = append(commands, Command{Command: command, Args: args})
+	}
+
+	beforeIndex := map[string]*Layer{}
+	for _, l := range before {
+		beforeIndex[l.ID] = l
+	}
+	afterIndex := map[string]*Layer{}
+	afterOrder := make([]string, len(after))
+	for i, l := range after {
+		afterIndex[l.ID] = l
+		afterOrder[i] = l.ID
+	}
+
+	// tracker mirrors the layer order as the commands are applied.
+	var tracker []string
+	for _, l := range before {
+		if _, ok := afterIndex[l.ID]; !ok {
+			push(CommandRemoveLayer, l.ID)
+			continue
+		}
+		tracker = append(tracker, l.ID)
+	}
+
+	// Walk the target order backwards, moving or adding every layer that
+	// is not already in place.
+	clean := map[string]bool{}
+	for i := len(afterOrder) - 1; i >= 0; i-- {
+		id := afterOrder[i]
+		pos := len(tracker) - (len(afterOrder) - 1 - i) - 1
+		if pos >= 0 && tracker[pos] == id {
+			continue
+		}
+		if _, ok := beforeIndex[id]; ok {
+			push(CommandRemoveLayer, id)
+			tracker = removeString(tracker, id)
+		}
+		insertAt := len(tracker) - (len(afterOrder) - 1 - i)
+		var beforeID interface{}
+		if insertAt < len(tracker) {
+			beforeID = tracker[insertAt]
+		}
+		push(CommandAddLayer, afterIndex[id], beforeID)
+		tracker = append(tracker[:insertAt], append([]string{id}, tracker[insertAt:]...)...)
+		clean[id] = true
+	}
+
+	for i, id := range afterOrder {
+		if clean[id] {
+			continue
+		}
+		prev, next := beforeIndex[id], afterIndex[id]
+		if jsonValuesEqual(prev, next) {
+			continue
+		}
+
+		prevMap, err := propertyMap(prev)
+		if err != nil {
+			return nil, err
+		}
+		nextMap, err := propertyMap(next)
+		if err != nil {
+			return nil, err
+		}
+		if layerNeedsReplace(prevMap, nextMap) {
+			var beforeID interface{}
+			if i+1 < len(afterOrder) {
+				beforeID = afterOrder[i+1]
+			}
+			push(CommandRemoveLayer, id)
+			push(CommandAddLayer, next, beforeID)
+			continue
+		}
+
+		diffProperties(prevMap["layout"], nextMap["layout"], func(name string, v interface{}) {
+			push(CommandSetLayoutProperty, id, name, v)
+		})
+		diffProperties(prevMap["paint"], nextMap["paint"], func(name string, v interface{}) {
+			push(CommandSetPaintProperty, id, name, v)
+		})
+		if !jsonValuesEqual(prevMap["filter"], nextMap["filter"]) {
+			push(CommandSetFilter, id, next.Filter)
+		}
+		if !jsonValuesEqual(prevMap["minzoom"], nextMap["minzoom"]) || !jsonValuesEqual(prevMap["maxzoom"], nextMap["maxzoom"]) {
+			push(CommandSetLayerZoomRange, id, next.MinZoom, next.MaxZoom)
+		}
+	}
+	return commands, nil
+}
+
+// layerNeedsReplace reports whether a layer changed in a way that no
+// property command covers.
+func layerNeedsReplace(prev, next map[string]interface{}) bool {
+	keys := map[string]bool{}
+	for k := range prev {
+		keys[k] = true
+	}
+	for k := range next {
+		keys[k] = true
+	}
+	for k := range keys {
+		switch k {
+		case "layout", "paint", "filter", "minzoom", "maxzoom":
+			continue
+		}
+		if !jsonValuesEqual(prev[k], next[k]) {
+			return true
+		}
+	}
+	return false
+}
+
+func diffProperties(prev, next interface{}, set func(name string, v interface{})) {
+	a, _ := prev.(map[string]interface{})
+	b, _ := next.(map[string]interface{})
+	names := map[string]bool{}
+	for k := range a {
+		names[k] = true
+	}
+	for k := range b {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		if !jsonValuesEqual(a[k], b[k]) {
+			set(k, b[k])
+		}
+	}
+}
+
+func removeString(list []string, s string) []string {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+func jsonValuesEqual(a, b interface{}) bool {
+	da, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(da, db)
+}
+
+// Apply replays commands onto the style. Arguments may be typed values, as
+// produced by DiffStyles, or generic values decoded from JSON.
+func (s *Style) Apply(commands []Command) error {
+	for i, c := range commands {
+		if err := s.apply(c); err != nil {
+			// Bind the layers of the commands that were applied.
+			s.Config()
+			return errors.Wrapf(err, "command %d (%s)", i, c.Command)
+		}
+	}
+	_, err := s.Config()
+	return err
+}
+
+func (s *Style) apply(c Command) error {
+	switch c.Command {
+	case CommandSetStyle:
+		var next Style
+		if err := c.decodeArgs(&next); err != nil {
+			return err
+		}
+		if s.config != nil && s.config.bound {
+			// Keep a scope set with BindConfig; default scopes are
+			// rebuilt from the new schema.
+			next.config = s.config
+		}
+		*s = next
+	case CommandAddLayer:
+		var layer Layer
+		var beforeID *string
+		if err := c.decodeArgs(&layer, &beforeID); err != nil {
+			return err
+		}
+		return s.addLayer(&layer, beforeID)
+	case CommandRemoveLayer:
+		var id string
+		if err := c.decodeArgs(&id); err != nil {
+			return err
+		}
+		i := s.layerIndex(id)
+		if i < 0 {
+			return errors.Errorf("layer %q does not exist", id)
+		}
+		s.Layers = append(s.Layers[:i], s.Layers[i+1:]...)
+	case CommandSetPaintProperty, CommandSetLayoutProperty:
+		var id, name string
+		var value interface{}
+		if err := c.decodeArgs(&id, &name, &value); err != nil {
+			return err
+		}
+		l, err := s.layer(id)
+		if err != nil {
+			return err
+		}
+		if c.Command == CommandSetPaintProperty {
+			return setProperty(&l.Paint, name, value)
+		}
+		return setProperty(&l.Layout, name, value)
+	case CommandSetFilter:
+		var id string
+		var filter *FilterContainer
+		if err := c.decodeArgs(&id, &filter); err != nil {
+			return err
+		}
+		l, err := s.layer(id)
+		if err != nil {
+			return err
+		}
+		l.Filter = filter
+	case CommandSetLayerZoomRange:
+		var id string
+		var minZoom, maxZoom *float64
+		if err := c.decodeArgs(&id, &minZoom, &maxZoom); err != nil {
+			return err
+		}
+		l, err := s.layer(id)
+		if err != nil {
+			return err
+		}
+		l.MinZoom, l.MaxZoom = minZoom, maxZoom
+	case CommandAddSource:
+		var id string
+		var source Source
+		if err := c.decodeArgs(&id, &source); err != nil {
+			return err
+		}
+		if _, ok := s.Sources[id]; ok {
+			return errors.Errorf("source %q already exists", id)
+		}
+		if s.Sources == nil {
+			s.Sources = Sources{}
+		}
+		s.Sources[id] = &source
+	case CommandRemoveSource:
+		var id string
+		if err := c.decodeArgs(&id); err != nil {
+			return err
+		}
+		if _, ok := s.Sources[id]; !ok {
+			return errors.Errorf("source %q does not exist", id)
+		}
+		for _, l := range s.Layers {
+			if l.Source != nil && *l.Source == id {
+				return errors.Errorf("source %q cannot be removed while layer %q is using it", id, l.ID)
+			}
+		}
+		delete(s.Sources, id)
+	case CommandSetGeoJSONSourceData:
+		var id string
+		var data interface{}
+		if err := c.decodeArgs(&id, &data); err != nil {
+			return err
+		}
+		src, ok := s.Sources[id]
+		if !ok {
+			return errors.Errorf("source %q does not exist", id)
+		}
+		if src.Type != "geojson" {
+			return errors.Errorf("source %q is not a geojson source", id)
+		}
+		src.Data = data
+	case CommandSetSprite:
+		return c.decodeArgs(&s.Sprite)
+	case CommandSetGlyphs:
+		return c.decodeArgs(&s.Glyphs)
+	case CommandSetTerrain:
+		var terrain *Terrain
+		if err := c.decodeArgs(&terrain); err != nil {
+			return err
+		}
+		s.Terrain = terrain
+	case CommandSetFog:
+		var fog *Fog
+		if err := c.decodeArgs(&fog); err != nil {
+			return err
+		}
+		s.Fog = fog
+	case CommandSetCenter:
+		var center []float64
+		if err := c.decodeArgs(&center); err != nil {
+			return err
+		}
+		s.Center = center
+	case CommandSetZoom:
+		return c.decodeArgs(&s.Zoom)
+	case CommandSetBearing:
+		return c.decodeArgs(&s.Bearing)
+	case CommandSetPitch:
+		return c.decodeArgs(&s.Pitch)
+	default:
+		return errors.Errorf("unknown command %q", c.Command)
+	}
+	return nil
+}
+
+// decodeArgs copies the command arguments into dst through JSON, which
+// accepts both typed and generic arguments and never aliases them.
+func (c Command) decodeArgs(dst ...interface{}) error {
+	if len(c.Args) < len(dst) {
+		return errors.Errorf("expected %d arguments, found %d", len(dst), len(c.Args))
+	}
+	for i, d := range dst {
+		data, err := json.Marshal(c.Args[i])
+		if err != nil {
+			return errors.Wrapf(err, "argument %d", i)
+		}
+		if err := json.Unmarshal(data, d); err != nil {
+			return errors.Wrapf(err, "argument %d", i)
+		}
+	}
+	return nil
+}
+
+func (s *Style) layerIndex(id string) int {
+	for i, l := range s.Layers {
+		if l.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Style) layer(id string) (*Layer, error) {
+	i := s.layerIndex(id)
+	if i < 0 {
+		return nil, errors.Errorf("layer %q does not exist", id)
+	}
+	return s.Layers[i], nil
+}
+
+func (s *Style) addLayer(l *Layer, beforeID *string) error {
+	if s.layerIndex(l.ID) >= 0 {
+		return errors.Errorf("layer %q already exists", l.ID)
+	}
+	if beforeID == nil {
+		s.Layers = append(s.Layers, l)
+		return nil
+	}
+	i := s.layerIndex(*beforeID)
+	if i < 0 {
+		return errors.Errorf("layer %q does not exist", *beforeID)
+	}
+	s.Layers = append(s.Layers[:i], append([]*Layer{l}, s.Layers[i:]...)...)
+	return nil
+}
+
+// setProperty sets or, for a nil value, removes a property of a Paint or
+// Layout by round-tripping it through a JSON object.
+func setProperty[T any](target **T, name string, value interface{}) error {
+	props, err := propertyMap(*target)
+	if err != nil {
+		return err
+	}
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	if value == nil {
+		delete(props, name)
+	} else {
+		props[name] = value
+	}
+	data, err := json.Marshal(props)
+	if err != nil {
+		return err
+	}
+	next := new(T)
+	if err := json.Unmarshal(data, next); err != nil {
+		return errors.Wrapf(err, "property %q", name)
+	}
+	if value != nil {
+		check, err := propertyMap(next)
+		if err != nil {
+			return err
+		}
+		if _, ok := check[name]; !ok {
+			return errors.Errorf("unknown property %q", name)
+		}
+	}
+	*target = next
+	return nil
+}
@@ -0,0 +1,165 @@
+package style
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const diffBaseStyle = `{
+	"version": 8,
+	"sprite": "mapbox://sprites/a",
+	"sources": {
+		"streets": {"type": "vector", "url": "mapbox://mapbox.streets"},
+		"points": {"type": "geojson", "data": {"type": "FeatureCollection", "features": []}}
+	},
+	"layers": [
+		{"id": "bg", "type": "background", "paint": {"background-color": "#fff"}},
+		{"id": "water", "type": "fill", "source": "streets", "source-layer": "water", "paint": {"fill-color": "blue"}},
+		{"id": "roads", "type": "line", "source": "streets", "source-layer": "road",
+			"filter": ["==", ["get", "class"], "street"], "paint": {"line-width": 2}},
+		{"id": "pois", "type": "circle", "source": "points"}
+	]
+}`
+
+func parseStyle(t *testing.T, raw string) *Style {
+	t.Helper()
+	var s Style
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// modifyStyle applies edit to a generic copy of raw and parses the result.
+func modifyStyle(t *testing.T, raw string, edit func(m map[string]interface{})) *Style {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		t.Fatal(err)
+	}
+	edit(m)
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseStyle(t, string(data))
+}
+
+func styleLayers(m map[string]interface{}) []interface{} {
+	return m["layers"].([]interface{})
+}
+
+func layerMap(m map[string]interface{}, i int) map[string]interface{} {
+	return styleLayers(m)[i].(map[string]interface{})
+}
+
+func TestDiffStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(m map[string]interface{})
+		commands []string
+	}{
+		{"unchanged", func(m map[string]interface{}) {}, nil},
+		{"paint", func(m map[string]interface{}) {
+			layerMap(m, 2)["paint"] = map[string]interface{}{"line-width": 4, "line-opacity": 0.5}
+		}, []string{CommandSetPaintProperty, CommandSetPaintProperty}},
+		{"layout", func(m map[string]interface{}) {
+			layerMap(m, 2)["layout"] = map[string]interface{}{"line-cap": "round"}
+		}, []string{CommandSetLayoutProperty}},
+		{"filter_and_zoom", func(m map[string]interface{}) {
+			layerMap(m, 2)["filter"] = []interface{}{"==", []interface{}{"get", "class"}, "primary"}
+			layerMap(m, 2)["minzoom"] = 10
+		}, []string{CommandSetFilter, CommandSetLayerZoomRange}},
+		{"remove_layer", func(m map[string]interface{}) {
+			m["layers"] = append(styleLayers(m)[:1], styleLayers(m)[2:]...)
+		}, []string{CommandRemoveLayer}},
+		{"add_layer", func(m map[string]interface{}) {
+			l := map[string]interface{}{"id": "parks", "type": "fill", "source": "streets", "source-layer": "park"}
+			m["layers"] = append(styleLayers(m)[:2], append([]interface{}{l}, styleLayers(m)[2:]...)...)
+		}, []string{CommandAddLayer}},
+		{"reorder", func(m map[string]interface{}) {
+			ls := styleLayers(m)
+			ls[1], ls[2] = ls[2], ls[1]
+		}, []string{CommandRemoveLayer, CommandAddLayer}},
+		{"layer_type", func(m map[string]interface{}) {
+			layerMap(m, 3)["type"] = "heatmap"
+		}, []string{CommandRemoveLayer, CommandAddLayer}},
+		{"geojson_data", func(m map[string]interface{}) {
+			m["sources"].(map[string]interface{})["points"].(map[string]interface{})["data"] = "https://example.com/points.geojson"
+		}, []string{CommandSetGeoJSONSourceData}},
+		{"replace_source", func(m map[string]interface{}) {
+			m["sources"].(map[string]interface{})["streets"] = map[string]interface{}{"type": "vector", "url": "mapbox://mapbox.streets-v8"}
+		}, []string{CommandRemoveLayer, CommandRemoveLayer, CommandRemoveSource, CommandAddSource, CommandAddLayer, CommandAddLayer}},
+		{"remove_source", func(m map[string]interface{}) {
+			delete(m["sources"].(map[string]interface{}), "points")
+			m["layers"] = styleLayers(m)[:3]
+		}, []string{CommandRemoveLayer, CommandRemoveSource}},
+		{"sprite_glyphs_terrain", func(m map[string]interface{}) {
+			m["sprite"] = "mapbox://sprites/b"
+			m["glyphs"] = "mapbox://fonts/{fontstack}/{range}.pbf"
+			m["terrain"] = map[string]interface{}{"source": "dem"}
+		}, []string{CommandSetSprite, CommandSetGlyphs, CommandSetTerrain}},
+		{"unsupported", func(m map[string]interface{}) {
+			m["name"] = "renamed"
+		}, []string{CommandSetStyle}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := parseStyle(t, diffBaseStyle)
+			after := modifyStyle(t, diffBaseStyle, tc.edit)
+			commands, err := DiffStyles(before, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commands) != len(tc.commands) {
+				t.Fatalf("expected %v, got %+v", tc.commands, commands)
+			}
+			for i, c := range commands {
+				if c.Command != tc.commands[i] {
+					t.Fatalf("command %d: expected %s, got %s", i, tc.commands[i], c.Command)
+				}
+			}
+
+			// Replaying the commands, also after a JSON round trip, must
+			// reproduce the target style.
+			data, err := json.Marshal(commands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded []Command
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			for _, cmds := range [][]Command{commands, decoded} {
+				s := parseStyle(t, diffBaseStyle)
+				if err := s.Apply(cmds); err != nil {
+					t.Fatal(err)
+				}
+				if !jsonValuesEqual(s, after) {
+					got, _ := json.Marshal(s)
+					want, _ := json.Marshal(after)
+					t.Fatalf("apply mismatch:\n  got:  %s\n  want: %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStyleApplyErrors(t *testing.T) {
+	tests := []Command{
+		{Command: CommandRemoveLayer, Args: []interface{}{"missing"}},
+		{Command: CommandAddLayer, Args: []interface{}{map[string]interface{}{"id": "bg", "type": "background"}, nil}},
+		{Command: CommandRemoveSource, Args: []interface{}{"streets"}},
+		{Command: CommandSetPaintProperty, Args: []interface{}{"roads", "line-widht", 3}},
+		{Command: CommandSetGeoJSONSourceData, Args: []interface{}{"streets", nil}},
+		{Command: "flyTo", Args: nil},
+	}
+	for _, c := range tests {
+		t.Run(c.Command, func(t *testing.T) {
+			s := parseStyle(t, diffBaseStyle)
+			if err := s.Apply([]Command{c}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
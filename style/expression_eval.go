@@ -0,0 +1,1338 @@
+package style
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Feature is the view of a map feature that expressions can observe.
+// Type is the GeoJSON geometry type ("Point", "LineString", "Polygon" or
+// one of their Multi* variants). State is the feature state read by
+// ["feature-state", key], see FeatureStateStore.
+type Feature struct {
+	ID         interface{}
+	Type       string
+	Properties map[string]interface{}
+	State      map[string]interface{}
+}
+
+// EvalContext holds the camera and feature inputs an expression is
+// evaluated against.
+type EvalContext struct {
+	Zoom               float64
+	Pitch              float64
+	DistanceFromCenter float64
+	HeatmapDensity     float64
+	LineProgress       float64
+	Worldview          string
+	// Locale is the default locale of number-format and collator
+	// expressions, DefaultLocale when empty.
+	Locale  string
+	Feature *Feature
+	Config  *ConfigScope
+}
+
+// Evaluate computes the value of the expression for the given context.
+// Numbers are returned as float64, colors as color.RGBA, arrays as
+// []interface{} and objects as map[string]interface{}.
+func (e *Expression) Evaluate(ctx EvalContext) (interface{}, error) {
+	ev := &evaluator{ctx: ctx}
+	return ev.eval(e)
+}
+
+type evalScope struct {
+	parent *evalScope
+	vars   map[string]interface{}
+}
+
+func (s *evalScope) get(name string) (interface{}, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if v, ok := sc.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type evaluator struct {
+	ctx   EvalContext
+	scope *evalScope
+}
+
+// expressionValue reconstructs the plain JSON value an expression was
+// decoded from. Literal arrays whose first element is a string are decoded
+// as compound expressions, so match labels and literal arguments need this
+// to recover their original form.
+func expressionValue(e *Expression) interface{} {
+	if e == nil {
+		return nil
+	}
+	if e.IsLiteral {
+		return e.Value
+	}
+	arr := make([]interface{}, 0, len(e.Args)+1)
+	arr = append(arr, e.Operator)
+	for _, arg := range e.Args {
+		arr = append(arr, expressionValue(arg))
+	}
+	return arr
+}
+
+func (ev *evaluator) eval(e *Expression) (interface{}, error) {
+	if e == nil {
+		return nil, nil
+	}
+	if e.IsLiteral {
+		return normalizeValue(e.Value), nil
+	}
+
+	switch e.Operator {
+	// Types
+	case ExpLiteral:
+		if len(e.Args) != 1 {
+			return nil, errors.Errorf("%q requires 1 argument, got %d", e.Operator, len(e.Args))
+		}
+		return normalizeValue(expressionValue(e.Args[0])), nil
+	case ExpArray:
+		return ev.evalArrayAssertion(e)
+	case ExpBoolean, ExpNumber, ExpString, ExpObject:
+		return ev.evalTypeAssertion(e)
+	case ExpToBool:
+		v, err := ev.evalArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(v), nil
+	case ExpToNumber:
+		return ev.evalToNumber(e)
+	case ExpToString:
+		v, err := ev.evalArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return valueToString(v), nil
+	case ExpToColor:
+		return ev.evalToColor(e)
+	case ExpTypeOf:
+		v, err := ev.evalArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return typeOf(v), nil
+	case ExpCollator:
+		return ev.evalCollator(e)
+	case ExpFormat:
+		return ev.evalFormat(e)
+	case ExpImage:
+		return ev.evalImage(e)
+	case ExpNumberFmt:
+		return ev.evalNumberFormat(e)
+	case ExpToRGBA:
+		c, err := ev.evalColorArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{float64(c.R), float64(c.G), float64(c.B), float64(c.A) / 255}, nil
+
+	// Feature data
+	case ExpGeometryType:
+		if ev.ctx.Feature == nil {
+			return nil, nil
+		}
+		return ev.ctx.Feature.Type, nil
+	case ExpID:
+		if ev.ctx.Feature == nil {
+			return nil, nil
+		}
+		return normalizeValue(ev.ctx.Feature.ID), nil
+	case ExpProperties:
+		return ev.featureProperties(), nil
+	case ExpFeatureState:
+		key, err := ev.evalStringArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		if ev.ctx.Feature == nil {
+			return nil, nil
+		}
+		return normalizeValue(ev.ctx.Feature.State[key]), nil
+	case ExpLineProgress:
+		return ev.ctx.LineProgress, nil
+	case ExpHeatmapDensity:
+		return ev.ctx.HeatmapDensity, nil
+
+	// Lookup
+	case ExpGet:
+		return ev.evalGet(e)
+	case ExpHas:
+		return ev.evalHas(e)
+	case ExpAt:
+		return ev.evalAt(e)
+	case ExpIn:
+		return ev.evalIn(e)
+	case ExpIndexOf:
+		return ev.evalIndexOf(e)
+	case ExpLength:
+		v, err := ev.evalArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		switch t := v.(type) {
+		case string:
+			return float64(len([]rune(t))), nil
+		case []interface{}:
+			return float64(len(t)), nil
+		}
+		return nil, errors.Errorf("%q expects a string or array, got %s", e.Operator, typeOf(v))
+	case ExpSlice:
+		return ev.evalSlice(e)
+	case ExpSplit:
+		s, err := ev.evalStringArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := ev.evalStringArg(e, 1)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(s, sep)
+		out := make([]interface{}, len(parts))
+		for i, p := range parts {
+			out[i] = p
+		}
+		return out, nil
+	case ExpWorldview:
+		return ev.ctx.Worldview, nil
+
+	// Decision
+	case ExpNot:
+		b, err := ev.evalBoolArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return !b, nil
+	case ExpEQ, ExpNEq:
+		return ev.evalEquality(e)
+	case ExpLT, ExpLTE, ExpGT, ExpGTE:
+		return ev.evalComparison(e)
+	case ExpAll:
+		for i := range e.Args {
+			b, err := ev.evalBoolArg(e, i)
+			if err != nil {
+				return nil, err
+			}
+			if !b {
+				return false, nil
+			}
+		}
+		return true, nil
+	case ExpAny:
+		for i := range e.Args {
+			b, err := ev.evalBoolArg(e, i)
+			if err != nil {
+				return nil, err
+			}
+			if b {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ExpCase:
+		return ev.evalCase(e)
+	case ExpCoalesce:
+		return ev.evalCoalesce(e)
+	case ExpMatch:
+		return ev.evalMatch(e)
+
+	// Ramps, scales, curves
+	case ExpStep:
+		return ev.evalStep(e)
+	case ExpInterpolate, ExpInterpolateHCL, ExpInterpolateLab:
+		return ev.evalInterpolate(e)
+
+	// Variable binding
+	case ExpLet:
+		return ev.evalLet(e)
+	case ExpVar:
+		name, ok := literalString(e.Args, 0)
+		if !ok {
+			return nil, errors.Errorf("%q requires a variable name", e.Operator)
+		}
+		v, ok := ev.scope.get(name)
+		if !ok {
+			return nil, errors.Errorf("unknown variable %q", name)
+		}
+		return v, nil
+
+	// String
+	case ExpConcat:
+		var sb strings.Builder
+		for i := range e.Args {
+			v, err := ev.evalArg(e, i)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(valueToString(v))
+		}
+		return sb.String(), nil
+	case ExpDowncase:
+		s, err := ev.evalStringArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToLower(s), nil
+	case ExpUpcase:
+		s, err := ev.evalStringArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(s), nil
+	case ExpIsSupportedScript:
+		if _, err := ev.evalStringArg(e, 0); err != nil {
+			return nil, err
+		}
+		return true, nil
+	case ExpResolvedLocale:
+		c, err := ev.evalCollatorArg(e, 0)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, errors.Errorf("%q requires a collator", e.Operator)
+		}
+		return c.ResolvedLocale(), nil
+
+	// Color
+	case ExpRGB, ExpRGBA:
+		return ev.evalRGBA(e)
+	case ExpHSL, ExpHSLA:
+		return ev.evalHSLA(e)
+
+	// Math
+	case ExpAdd, ExpMul, ExpSub, ExpDiv, ExpMod, ExpPow, ExpMax, ExpMin:
+		return ev.evalArithmetic(e)
+	case ExpAbs, ExpAcos, ExpAsin, ExpAtan, ExpCeil, ExpCos, ExpFloor,
+		ExpLn, ExpLog10, ExpLog2, ExpRound, ExpSin, ExpSqrt, ExpTan:
+		return ev.evalUnaryMath(e)
+	case ExpE:
+		return math.E, nil
+	case ExpLn2:
+		return math.Ln2, nil
+	case ExpPI:
+		return math.Pi, nil
+
+	case ExpConfig:
+		return ev.evalConfig(e)
+
+	// Camera
+	case ExpZoom:
+		return ev.ctx.Zoom, nil
+	case ExpPitch:
+		return ev.ctx.Pitch, nil
+	case ExpDistFromCenter:
+		return ev.ctx.DistanceFromCenter, nil
+	}
+
+	if !IsKnownOperator(e.Operator) {
+		return nil, errors.Errorf("unknown expression operator: %q", e.Operator)
+	}
+	return nil, errors.Errorf("expression %q cannot be evaluated", e.Operator)
+}
+
+func (ev *evaluator) evalArg(e *Expression, i int) (interface{}, error) {
+	if i >= len(e.Args) {
+		return nil, errors.Errorf("%q: missing argument %d", e.Operator, i)
+	}
+	return ev.eval(e.Args[i])
+}
+
+func (ev *evaluator) evalNumberArg(e *Expression, i int) (float64, error) {
+	v, err := ev.evalArg(e, i)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, errors.Errorf("%q: expected number for argument %d but found %s", e.Operator, i, typeOf(v))
+	}
+	return n, nil
+}
+
+func (ev *evaluator) evalStringArg(e *Expression, i int) (string, error) {
+	v, err := ev.evalArg(e, i)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("%q: expected string for argument %d but found %s", e.Operator, i, typeOf(v))
+	}
+	return s, nil
+}
+
+func (ev *evaluator) evalBoolArg(e *Expression, i int) (bool, error) {
+	v, err := ev.evalArg(e, i)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.Errorf("%q: expected boolean for argument %d but found %s", e.Operator, i, typeOf(v))
+	}
+	return b, nil
+}
+
+func (ev *evaluator) evalColorArg(e *Expression, i int) (color.RGBA, error) {
+	v, err := ev.evalArg(e, i)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	c, ok := valueToColor(v)
+	if !ok {
+		return color.RGBA{}, errors.Errorf("%q: expected color for argument %d but found %s", e.Operator, i, typeOf(v))
+	}
+	return c, nil
+}
+
+func literalString(args []*Expression, i int) (string, bool) {
+	if i >= len(args) || args[i] == nil || !args[i].IsLiteral {
+		return "", false
+	}
+	s, ok := args[i].Value.(string)
+	return s, ok
+}
+
+func (ev *evaluator) featureProperties() map[string]interface{} {
+	if ev.ctx.Feature == nil || ev.ctx.Feature.Properties == nil {
+		return map[string]interface{}{}
+	}
+	return ev.ctx.Feature.Properties
+}
+
+func (ev *evaluator) evalGet(e *Expression) (interface{}, error) {
+	key, err := ev.evalStringArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Args) > 1 {
+		obj, err := ev.evalArg(e, 1)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return normalizeValue(m[key]), nil
+	}
+	return normalizeValue(ev.featureProperties()[key]), nil
+}
+
+func (ev *evaluator) evalHas(e *Expression) (interface{}, error) {
+	key, err := ev.evalStringArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Args) > 1 {
+		obj, err := ev.evalArg(e, 1)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		_, ok = m[key]
+		return ok, nil
+	}
+	_, ok := ev.featureProperties()[key]
+	return ok, nil
+}
+
+func (ev *evaluator) evalAt(e *Expression) (interface{}, error) {
+	idx, err := ev.evalNumberArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ev.evalArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("%q: expected array but found %s", e.Operator, typeOf(v))
+	}
+	if idx < 0 || idx != math.Floor(idx) || int(idx) >= len(arr) {
+		return nil, errors.Errorf("%q: array index %v out of bounds (length %d)", e.Operator, idx, len(arr))
+	}
+	return arr[int(idx)], nil
+}
+
+func (ev *evaluator) evalIn(e *Expression) (interface{}, error) {
+	needle, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	haystack, err := ev.evalArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch h := haystack.(type) {
+	case string:
+		s, ok := needle.(string)
+		if !ok {
+			return false, nil
+		}
+		return strings.Contains(h, s), nil
+	case []interface{}:
+		for _, item := range h {
+			if valuesEqual(item, needle) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case nil:
+		return false, nil
+	}
+	return nil, errors.Errorf("%q: expected string or array but found %s", e.Operator, typeOf(haystack))
+}
+
+func (ev *evaluator) evalIndexOf(e *Expression) (interface{}, error) {
+	needle, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	haystack, err := ev.evalArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	from := 0
+	if len(e.Args) > 2 {
+		n, err := ev.evalNumberArg(e, 2)
+		if err != nil {
+			return nil, err
+		}
+		from = int(n)
+	}
+	switch h := haystack.(type) {
+	case string:
+		s, ok := needle.(string)
+		if !ok {
+			return float64(-1), nil
+		}
+		runes, sub := []rune(h), []rune(s)
+		if from < 0 {
+			from = 0
+		}
+		for i := from; i+len(sub) <= len(runes); i++ {
+			if string(runes[i:i+len(sub)]) == s {
+				return float64(i), nil
+			}
+		}
+		return float64(-1), nil
+	case []interface{}:
+		if from < 0 {
+			from = 0
+		}
+		for i := from; i < len(h); i++ {
+			if valuesEqual(h[i], needle) {
+				return float64(i), nil
+			}
+		}
+		return float64(-1), nil
+	}
+	return nil, errors.Errorf("%q: expected string or array but found %s", e.Operator, typeOf(haystack))
+}
+
+func (ev *evaluator) evalSlice(e *Expression) (interface{}, error) {
+	v, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := ev.evalNumberArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	switch t := v.(type) {
+	case string:
+		length = len([]rune(t))
+	case []interface{}:
+		length = len(t)
+	default:
+		return nil, errors.Errorf("%q: expected string or array but found %s", e.Operator, typeOf(v))
+	}
+	end := float64(length)
+	if len(e.Args) > 2 {
+		if end, err = ev.evalNumberArg(e, 2); err != nil {
+			return nil, err
+		}
+	}
+	from, to := clampSliceIndex(start, length), clampSliceIndex(end, length)
+	if to < from {
+		to = from
+	}
+	switch t := v.(type) {
+	case string:
+		return string([]rune(t)[from:to]), nil
+	default:
+		arr := t.([]interface{})
+		out := make([]interface{}, to-from)
+		copy(out, arr[from:to])
+		return out, nil
+	}
+}
+
+// clampSliceIndex mirrors Array.prototype.slice index handling, where
+// negative indices count back from the end.
+func clampSliceIndex(i float64, length int) int {
+	n := int(i)
+	if n < 0 {
+		n += length
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > length {
+		return length
+	}
+	return n
+}
+
+func (ev *evaluator) evalEquality(e *Expression) (interface{}, error) {
+	a, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ev.evalArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ev.evalCollatorArg(e, 2)
+	if err != nil {
+		return nil, err
+	}
+	eq := valuesEqual(a, b)
+	if as, ok := a.(string); ok && c != nil {
+		bs, ok := b.(string)
+		eq = ok && c.Compare(as, bs) == 0
+	}
+	if e.Operator == ExpNEq {
+		return !eq, nil
+	}
+	return eq, nil
+}
+
+func (ev *evaluator) evalComparison(e *Expression) (interface{}, error) {
+	a, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ev.evalArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+	var cmp int
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return nil, errors.Errorf("%q: cannot compare number with %s", e.Operator, typeOf(b))
+		}
+		switch {
+		case av < bv:
+			cmp = -1
+		case av > bv:
+			cmp = 1
+		}
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return nil, errors.Errorf("%q: cannot compare string with %s", e.Operator, typeOf(b))
+		}
+		c, err := ev.evalCollatorArg(e, 2)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			cmp = c.Compare(av, bv)
+		} else {
+			cmp = strings.Compare(av, bv)
+		}
+	default:
+		return nil, errors.Errorf("%q: expected number or string but found %s", e.Operator, typeOf(a))
+	}
+	switch e.Operator {
+	case ExpLT:
+		return cmp < 0, nil
+	case ExpLTE:
+		return cmp <= 0, nil
+	case ExpGT:
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func (ev *evaluator) evalCase(e *Expression) (interface{}, error) {
+	if len(e.Args) < 3 || len(e.Args)%2 == 0 {
+		return nil, errors.Errorf("%q requires condition/output pairs and a fallback", e.Operator)
+	}
+	for i := 0; i+1 < len(e.Args); i += 2 {
+		b, err := ev.evalBoolArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return ev.eval(e.Args[i+1])
+		}
+	}
+	return ev.eval(e.Args[len(e.Args)-1])
+}
+
+func (ev *evaluator) evalCoalesce(e *Expression) (interface{}, error) {
+	var last interface{}
+	for _, arg := range e.Args {
+		v, err := ev.eval(arg)
+		if err != nil {
+			// Mirror GL, where a failing branch is treated as null.
+			continue
+		}
+		last = v
+		if v != nil {
+			return v, nil
+		}
+	}
+	return last, nil
+}
+
+func (ev *evaluator) evalMatch(e *Expression) (interface{}, error) {
+	if len(e.Args) < 3 || len(e.Args)%2 != 0 {
+		return nil, errors.Errorf("%q requires an input, label/output pairs and a fallback", e.Operator)
+	}
+	input, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i+1 < len(e.Args); i += 2 {
+		label := normalizeValue(expressionValue(e.Args[i]))
+		if labels, ok := label.([]interface{}); ok {
+			for _, l := range labels {
+				if valuesEqual(l, input) {
+					return ev.eval(e.Args[i+1])
+				}
+			}
+			continue
+		}
+		if valuesEqual(label, input) {
+			return ev.eval(e.Args[i+1])
+		}
+	}
+	return ev.eval(e.Args[len(e.Args)-1])
+}
+
+func (ev *evaluator) evalStep(e *Expression) (interface{}, error) {
+	if len(e.Args) < 2 || len(e.Args)%2 != 0 {
+		return nil, errors.Errorf("%q requires an input, a default output and stop/output pairs", e.Operator)
+	}
+	input, err := ev.evalNumberArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := e.Args[1]
+	for i := 2; i+1 < len(e.Args); i += 2 {
+		stop, err := ev.evalNumberArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		if input < stop {
+			break
+		}
+		out = e.Args[i+1]
+	}
+	return ev.eval(out)
+}
+
+func (ev *evaluator) evalInterpolate(e *Expression) (interface{}, error) {
+	if len(e.Args) < 4 || len(e.Args)%2 != 0 {
+		return nil, errors.Errorf("%q requires an interpolation type, an input and stop/output pairs", e.Operator)
+	}
+	interp := e.Args[0]
+	if interp == nil || interp.IsLiteral {
+		return nil, errors.Errorf("%q: first argument must be an interpolation type", e.Operator)
+	}
+	base := 1.0
+	var bezier *unitBezier
+	switch interp.Operator {
+	case ExpLinear:
+	case ExpExponential:
+		b, err := ev.evalNumberArg(interp, 0)
+		if err != nil {
+			return nil, err
+		}
+		base = b
+	case ExpCubicBezier:
+		var p [4]float64
+		for i := range p {
+			v, err := ev.evalNumberArg(interp, i)
+			if err != nil {
+				return nil, err
+			}
+			p[i] = v
+		}
+		u := newUnitBezier(p[0], p[1], p[2], p[3])
+		bezier = &u
+	default:
+		return nil, errors.Errorf("%q: unsupported interpolation type %q", e.Operator, interp.Operator)
+	}
+
+	input, err := ev.evalNumberArg(e, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	n := (len(e.Args) - 2) / 2
+	stops := make([]float64, n)
+	for i := 0; i < n; i++ {
+		if stops[i], err = ev.evalNumberArg(e, 2+2*i); err != nil {
+			return nil, err
+		}
+	}
+
+	if input <= stops[0] {
+		return ev.eval(e.Args[3])
+	}
+	if input >= stops[n-1] {
+		return ev.eval(e.Args[len(e.Args)-1])
+	}
+	idx := sort.Search(n, func(i int) bool { return stops[i] > input }) - 1
+	lower, upper := stops[idx], stops[idx+1]
+	t := getExponentialPercentage(ZoomLevel(input), ZoomLevel(lower), ZoomLevel(upper), base)
+	if bezier != nil {
+		t = bezier.solve(t)
+	}
+
+	from, err := ev.eval(e.Args[3+2*idx])
+	if err != nil {
+		return nil, err
+	}
+	to, err := ev.eval(e.Args[5+2*idx])
+	if err != nil {
+		return nil, err
+	}
+	space := ColorSpaceRGB
+	switch e.Operator {
+	case ExpInterpolateHCL:
+		space = ColorSpaceHCL
+	case ExpInterpolateLab:
+		space = ColorSpaceLab
+	}
+	if space != ColorSpaceRGB {
+		if _, ok := valueToColor(from); !ok {
+			return nil, errors.Errorf("%q requires color outputs, found %s", e.Operator, typeOf(from))
+		}
+	}
+	return interpolateValue(from, to, t, space)
+}
+
+// interpolateValue blends numbers, arrays of numbers and colors. Colors are
+// blended in the given color space.
+func interpolateValue(from, to interface{}, t float64, space string) (interface{}, error) {
+	switch a := from.(type) {
+	case float64:
+		b, ok := to.(float64)
+		if !ok {
+			return nil, errors.Errorf("cannot interpolate number with %s", typeOf(to))
+		}
+		return a + (b-a)*t, nil
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok || len(a) != len(b) {
+			return nil, errors.Errorf("cannot interpolate %s with %s", typeOf(from), typeOf(to))
+		}
+		out := make([]interface{}, len(a))
+		for i := range a {
+			v, err := interpolateValue(a[i], b[i], t, space)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	ca, ok := valueToColor(from)
+	if !ok {
+		return nil, errors.Errorf("cannot interpolate %s", typeOf(from))
+	}
+	cb, ok := valueToColor(to)
+	if !ok {
+		return nil, errors.Errorf("cannot interpolate color with %s", typeOf(to))
+	}
+	return interpolateColor(ca, cb, t, space), nil
+}
+
+func (ev *evaluator) evalLet(e *Expression) (interface{}, error) {
+	if len(e.Args) < 3 || len(e.Args)%2 == 0 {
+		return nil, errors.Errorf("%q requires name/value pairs and a result expression", e.Operator)
+	}
+	scope := &evalScope{parent: ev.scope, vars: map[string]interface{}{}}
+	for i := 0; i+1 < len(e.Args); i += 2 {
+		name, ok := literalString(e.Args, i)
+		if !ok {
+			return nil, errors.Errorf("%q: variable name at argument %d must be a string literal", e.Operator, i)
+		}
+		v, err := ev.eval(e.Args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		scope.vars[name] = v
+	}
+	prev := ev.scope
+	ev.scope = scope
+	defer func() { ev.scope = prev }()
+	return ev.eval(e.Args[len(e.Args)-1])
+}
+
+func (ev *evaluator) evalArrayAssertion(e *Expression) (interface{}, error) {
+	if len(e.Args) == 0 {
+		return nil, errors.Errorf("%q requires at least 1 argument", e.Operator)
+	}
+	itemType := ""
+	length := -1
+	if len(e.Args) > 1 {
+		itemType, _ = literalString(e.Args, 0)
+	}
+	if len(e.Args) > 2 && e.Args[1] != nil && e.Args[1].IsLiteral {
+		if n, ok := e.Args[1].Value.(float64); ok {
+			length = int(n)
+		}
+	}
+	v, err := ev.eval(e.Args[len(e.Args)-1])
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("expected array but found %s", typeOf(v))
+	}
+	if length >= 0 && len(arr) != length {
+		return nil, errors.Errorf("expected array of length %d but found length %d", length, len(arr))
+	}
+	if itemType != "" && itemType != "value" {
+		for _, item := range arr {
+			if typeOf(item) != itemType {
+				return nil, errors.Errorf("expected array<%s> but found %s", itemType, typeOf(v))
+			}
+		}
+	}
+	return arr, nil
+}
+
+// evalTypeAssertion implements boolean, number, string and object, which
+// return the first argument of the asserted type.
+func (ev *evaluator) evalTypeAssertion(e *Expression) (interface{}, error) {
+	var last interface{}
+	for i := range e.Args {
+		v, err := ev.evalArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		if typeOf(v) == e.Operator {
+			return v, nil
+		}
+		last = v
+	}
+	return nil, errors.Errorf("expected %s but found %s", e.Operator, typeOf(last))
+}
+
+func (ev *evaluator) evalToNumber(e *Expression) (interface{}, error) {
+	var last interface{}
+	for i := range e.Args {
+		v, err := ev.evalArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		last = v
+		switch t := v.(type) {
+		case nil:
+			return float64(0), nil
+		case bool:
+			if t {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		case float64:
+			return t, nil
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+				return n, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("could not convert %s to number", valueToString(last))
+}
+
+func (ev *evaluator) evalToColor(e *Expression) (interface{}, error) {
+	var last interface{}
+	for i := range e.Args {
+		v, err := ev.evalArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		last = v
+		if c, ok := valueToColor(v); ok {
+			return c, nil
+		}
+	}
+	return nil, errors.Errorf("could not parse color from value %s", valueToString(last))
+}
+
+func (ev *evaluator) evalRGBA(e *Expression) (interface{}, error) {
+	want := 3
+	if e.Operator == ExpRGBA {
+		want = 4
+	}
+	if len(e.Args) != want {
+		return nil, errors.Errorf("%q requires %d arguments, got %d", e.Operator, want, len(e.Args))
+	}
+	var ch [4]float64
+	ch[3] = 1
+	for i := 0; i < want; i++ {
+		n, err := ev.evalNumberArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		ch[i] = n
+	}
+	for i := 0; i < 3; i++ {
+		if ch[i] < 0 || ch[i] > 255 {
+			return nil, errors.Errorf("invalid rgba value %v: channels must be between 0 and 255", ch)
+		}
+	}
+	if ch[3] < 0 || ch[3] > 1 {
+		return nil, errors.Errorf("invalid rgba value %v: alpha must be between 0 and 1", ch)
+	}
+	return color.RGBA{
+		R: uint8(math.Round(ch[0])),
+		G: uint8(math.Round(ch[1])),
+		B: uint8(math.Round(ch[2])),
+		A: uint8(math.Round(ch[3] * 255)),
+	}, nil
+}
+
+func (ev *evaluator) evalHSLA(e *Expression) (interface{}, error) {
+	want := 3
+	if e.Operator == ExpHSLA {
+		want = 4
+	}
+	if len(e.Args) != want {
+		return nil, errors.Errorf("%q requires %d arguments, got %d", e.Operator, want, len(e.Args))
+	}
+	var ch [4]float64
+	ch[3] = 1
+	for i := 0; i < want; i++ {
+		n, err := ev.evalNumberArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		ch[i] = n
+	}
+	h := math.Mod(ch[0], 360)
+	if h < 0 {
+		h += 360
+	}
+	return hslToRGBA(h, clamp01(ch[1]/100), clamp01(ch[2]/100), uint8(math.Round(clamp01(ch[3])*255))), nil
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func (ev *evaluator) evalArithmetic(e *Expression) (interface{}, error) {
+	if len(e.Args) == 0 {
+		return nil, errors.Errorf("%q requires at least 1 argument", e.Operator)
+	}
+	nums := make([]float64, len(e.Args))
+	for i := range e.Args {
+		n, err := ev.evalNumberArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	switch e.Operator {
+	case ExpSub:
+		if len(nums) == 1 {
+			return -nums[0], nil
+		}
+	case ExpMod, ExpPow, ExpDiv:
+		if len(nums) != 2 {
+			return nil, errors.Errorf("%q requires 2 arguments, got %d", e.Operator, len(nums))
+		}
+	}
+	acc := nums[0]
+	for _, n := range nums[1:] {
+		switch e.Operator {
+		case ExpAdd:
+			acc += n
+		case ExpMul:
+			acc *= n
+		case ExpSub:
+			acc -= n
+		case ExpDiv:
+			acc /= n
+		case ExpMod:
+			acc = math.Mod(acc, n)
+		case ExpPow:
+			acc = math.Pow(acc, n)
+		case ExpMax:
+			acc = math.Max(acc, n)
+		case ExpMin:
+			acc = math.Min(acc, n)
+		}
+	}
+	return acc, nil
+}
+
+func (ev *evaluator) evalUnaryMath(e *Expression) (interface{}, error) {
+	n, err := ev.evalNumberArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Operator {
+	case ExpAbs:
+		return math.Abs(n), nil
+	case ExpAcos:
+		return math.Acos(n), nil
+	case ExpAsin:
+		return math.Asin(n), nil
+	case ExpAtan:
+		return math.Atan(n), nil
+	case ExpCeil:
+		return math.Ceil(n), nil
+	case ExpCos:
+		return math.Cos(n), nil
+	case ExpFloor:
+		return math.Floor(n), nil
+	case ExpLn:
+		return math.Log(n), nil
+	case ExpLog10:
+		return math.Log10(n), nil
+	case ExpLog2:
+		return math.Log2(n), nil
+	case ExpRound:
+		// Halfway values round away from zero, as in GL.
+		return math.Round(n), nil
+	case ExpSin:
+		return math.Sin(n), nil
+	case ExpSqrt:
+		return math.Sqrt(n), nil
+	default:
+		return math.Tan(n), nil
+	}
+}
+
+// normalizeValue converts decoded feature and JSON values into the small set
+// of types the evaluator works with.
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case []float64:
+		out := make([]interface{}, len(t))
+		for i, n := range t {
+			out[i] = n
+		}
+		return out
+	case []string:
+		out := make([]interface{}, len(t))
+		for i, s := range t {
+			out[i] = s
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case color.Color:
+		c, _ := valueToColor(t)
+		return c
+	}
+	return v
+}
+
+func valuesEqual(a, b interface{}) bool {
+	a, b = normalizeValue(a), normalizeValue(b)
+	if typeOf(a) != typeOf(b) {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toBoolean(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0 && !math.IsNaN(t)
+	case string:
+		return t != ""
+	}
+	return true
+}
+
+// valueToColor accepts colors and CSS color strings.
+func valueToColor(v interface{}) (color.RGBA, bool) {
+	switch t := v.(type) {
+	case color.RGBA:
+		return t, true
+	case color.NRGBA:
+		return color.RGBA{R: t.R, G: t.G, B: t.B, A: t.A}, true
+	case color.Color:
+		r, g, b, a := t.RGBA()
+		return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}, true
+	case string:
+		c, err := strToColor(t, defaultColorAlpha)
+		if err != nil || c == nil {
+			return color.RGBA{}, false
+		}
+		return valueToColor(c)
+	}
+	return color.RGBA{}, false
+}
+
+// typeOf returns the expression type name of a runtime value, as reported by
+// the typeof operator.
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case color.RGBA:
+		return "color"
+	case Formatted:
+		return "formatted"
+	case ResolvedImage:
+		return "resolvedImage"
+	case *Collator:
+		return "collator"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		itemType := ""
+		for _, item := range t {
+			it := typeOf(item)
+			if itemType == "" {
+				itemType = it
+			} else if itemType != it {
+				itemType = "value"
+				break
+			}
+		}
+		if itemType == "" {
+			itemType = "value"
+		}
+		return fmt.Sprintf("array<%s, %d>", itemType, len(t))
+	}
+	return "value"
+}
+
+func valueToString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return formatNumber(t)
+	case string:
+		return t
+	case color.RGBA:
+		return fmt.Sprintf("rgba(%d,%d,%d,%s)", t.R, t.G, t.B, formatNumber(float64(t.A)/255))
+	case Formatted:
+		return t.String()
+	case ResolvedImage:
+		return t.Name
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, item := range t {
+			parts[i] = jsonString(item)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case map[string]interface{}:
+		return jsonString(t)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func jsonString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strconv.Quote(t)
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = strconv.Quote(k) + ":" + jsonString(t[k])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return valueToString(v)
+}
+
+// formatNumber formats a number the way JavaScript's Number#toString does
+// for the values that appear in styles.
+func formatNumber(n float64) string {
+	if math.IsInf(n, 1) {
+		return "Infinity"
+	}
+	if math.IsInf(n, -1) {
+		return "-Infinity"
+	}
+	if math.IsNaN(n) {
+		return "NaN"
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
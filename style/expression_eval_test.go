@@ -0,0 +1,143 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func mustExpr(t *testing.T, raw string) *Expression {
+	t.Helper()
+	var e Expression
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	return &e
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	feature := &Feature{
+		ID:   int64(42),
+		Type: "Polygon",
+		Properties: map[string]interface{}{
+			"class":  "park",
+			"rank":   int64(3),
+			"height": float32(12.5),
+			"name":   "Central",
+		},
+	}
+	ctx := EvalContext{Zoom: 10, Feature: feature}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{"get", `["get","class"]`, "park"},
+		{"get_int_normalised", `["get","rank"]`, float64(3)},
+		{"get_missing", `["get","nope"]`, nil},
+		{"get_object", `["get","a",["literal",{"a":1}]]`, float64(1)},
+		{"has", `["has","name"]`, true},
+		{"id", `["id"]`, float64(42)},
+		{"geometry_type", `["geometry-type"]`, "Polygon"},
+		{"zoom", `["zoom"]`, float64(10)},
+		{"eq", `["==",["get","class"],"park"]`, true},
+		{"neq_types", `["!=",["get","rank"],"3"]`, true},
+		{"lt", `["<",["get","rank"],5]`, true},
+		{"all", `["all",true,["==",1,1]]`, true},
+		{"any", `["any",false,["==",1,2]]`, false},
+		{"not", `["!",["has","missing"]]`, true},
+		{"case", `["case",["==",["get","class"],"water"],"blue",["==",["get","class"],"park"],"green","grey"]`, "green"},
+		{"match", `["match",["get","class"],"water","blue",["park","forest"],"green","grey"]`, "green"},
+		{"match_fallback", `["match",["get","class"],"water","blue","grey"]`, "grey"},
+		{"coalesce", `["coalesce",["get","missing"],["get","name"]]`, "Central"},
+		{"step", `["step",["zoom"],1,5,2,10,3,15,4]`, float64(3)},
+		{"interpolate_linear", `["interpolate",["linear"],["zoom"],0,0,20,100]`, float64(50)},
+		{"interpolate_below", `["interpolate",["linear"],["zoom"],12,1,20,2]`, float64(1)},
+		{"interpolate_above", `["interpolate",["linear"],["zoom"],0,1,5,2]`, float64(2)},
+		{"let_var", `["let","x",2,["*",["var","x"],["var","x"]]]`, float64(4)},
+		{"math", `["+",1,["*",2,3],["-",4]]`, float64(3)},
+		{"round_half", `["round",-1.5]`, float64(-2)},
+		{"concat", `["concat",["get","name"]," ",["to-string",["get","rank"]]]`, "Central 3"},
+		{"upcase", `["upcase","abc"]`, "ABC"},
+		{"in_array", `["in","b",["literal",["a","b"]]]`, true},
+		{"in_string", `["in","ent",["get","name"]]`, true},
+		{"index_of", `["index-of","t",["get","name"]]`, float64(3)},
+		{"slice", `["slice",["get","name"],1,3]`, "en"},
+		{"length", `["length",["get","name"]]`, float64(7)},
+		{"at", `["at",1,["literal",[10,20,30]]]`, float64(20)},
+		{"to_number", `["to-number","12.5"]`, float64(12.5)},
+		{"to_boolean", `["to-boolean",""]`, false},
+		{"typeof", `["typeof",["get","name"]]`, "string"},
+		{"typeof_array", `["typeof",["literal",[1,2]]]`, "array<number, 2>"},
+		{"number_assertion", `["number",["get","name"],["get","height"]]`, float64(12.5)},
+		{"properties", `["get","class",["properties"]]`, "park"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mustExpr(t, tc.expr).Evaluate(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonDeepEqual(got, tc.want) {
+				t.Fatalf("expected %v (%T), got %v (%T)", tc.want, tc.want, got, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpressionColors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want color.RGBA
+	}{
+		{"rgb", `["rgb",255,0,0]`, color.RGBA{R: 255, A: 255}},
+		{"rgba", `["rgba",0,0,255,0.5]`, color.RGBA{B: 255, A: 128}},
+		{"hsl", `["hsl",120,100,50]`, color.RGBA{G: 255, A: 255}},
+		{"to_color", `["to-color","#00ff00"]`, color.RGBA{G: 255, A: 255}},
+		{"interpolate", `["interpolate",["linear"],["zoom"],0,"#000000",20,"#ffffff"]`, color.RGBA{R: 128, G: 128, B: 128, A: 255}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mustExpr(t, tc.expr).Evaluate(EvalContext{Zoom: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateExponentialInterpolation(t *testing.T) {
+	e := mustExpr(t, `["interpolate",["exponential",2],["zoom"],0,0,2,30]`)
+	got, err := e.Evaluate(EvalContext{Zoom: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (2^1 - 1) / (2^2 - 1) = 1/3
+	if math.Abs(got.(float64)-10) > 1e-9 {
+		t.Fatalf("expected 10, got %v", got)
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	tests := []string{
+		`["unknown-op"]`,
+		`["var","undefined"]`,
+		`["<",1,"a"]`,
+		`["at",5,["literal",[1]]]`,
+		`["number","abc"]`,
+		`["rgb",300,0,0]`,
+	}
+	for _, raw := range tests {
+		t.Run(raw, func(t *testing.T) {
+			if _, err := mustExpr(t, raw).Evaluate(EvalContext{}); err == nil {
+				t.Fatalf("expected error for %s", raw)
+			}
+		})
+	}
+}
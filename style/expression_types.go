@@ -0,0 +1,893 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Expression type kinds.
+const (
+	KindNull          = "null"
+	KindNumber        = "number"
+	KindString        = "string"
+	KindBoolean       = "boolean"
+	KindColor         = "color"
+	KindObject        = "object"
+	KindValue         = "value"
+	KindArray         = "array"
+	KindFormatted     = "formatted"
+	KindResolvedImage = "resolvedImage"
+	KindCollator      = "collator"
+	KindPadding       = "padding"
+)
+
+// ExpressionType is the static type of an expression. Item and Length are
+// only set for arrays; a Length of 0 means the length is not known.
+type ExpressionType struct {
+	Kind   string
+	Item   *ExpressionType
+	Length int
+}
+
+var (
+	TypeNull          = ExpressionType{Kind: KindNull}
+	TypeNumber        = ExpressionType{Kind: KindNumber}
+	TypeString        = ExpressionType{Kind: KindString}
+	TypeBoolean       = ExpressionType{Kind: KindBoolean}
+	TypeColor         = ExpressionType{Kind: KindColor}
+	TypeObject        = ExpressionType{Kind: KindObject}
+	TypeValue         = ExpressionType{Kind: KindValue}
+	TypeFormatted     = ExpressionType{Kind: KindFormatted}
+	TypeResolvedImage = ExpressionType{Kind: KindResolvedImage}
+	TypeCollator      = ExpressionType{Kind: KindCollator}
+	TypePadding       = ExpressionType{Kind: KindPadding}
+)
+
+// ArrayOf returns the type array<item, length>. Pass 0 for arrays of
+// unknown length.
+func ArrayOf(item ExpressionType, length int) ExpressionType {
+	return ExpressionType{Kind: KindArray, Item: &item, Length: length}
+}
+
+func (t ExpressionType) String() string {
+	if t.Kind != KindArray {
+		return t.Kind
+	}
+	item := TypeValue
+	if t.Item != nil {
+		item = *t.Item
+	}
+	if t.Length > 0 {
+		return fmt.Sprintf("array<%s, %d>", item, t.Length)
+	}
+	if item.Kind == KindValue {
+		return "array"
+	}
+	return fmt.Sprintf("array<%s>", item)
+}
+
+func (t ExpressionType) itemType() ExpressionType {
+	if t.Item == nil {
+		return TypeValue
+	}
+	return *t.Item
+}
+
+// accepts reports whether a value of type actual may be used where t is
+// expected. Like GL, it allows values of type "value" wherever a runtime
+// assertion can be inserted, and strings wherever a runtime coercion to
+// color, formatted or resolvedImage can be inserted.
+func (t ExpressionType) accepts(actual ExpressionType) bool {
+	if t.Kind == KindValue {
+		return true
+	}
+	switch actual.Kind {
+	case KindValue:
+		switch t.Kind {
+		case KindString, KindNumber, KindBoolean, KindObject, KindArray,
+			KindColor, KindFormatted, KindResolvedImage, KindPadding:
+			return true
+		}
+	case KindString:
+		switch t.Kind {
+		case KindColor, KindFormatted, KindResolvedImage:
+			return true
+		}
+	}
+	switch t.Kind {
+	case KindPadding:
+		return actual.Kind == KindNumber || (actual.Kind == KindArray && actual.itemType().Kind == KindNumber)
+	case KindArray:
+		if actual.Kind != KindArray {
+			return false
+		}
+		if t.Length > 0 && actual.Length > 0 && t.Length != actual.Length {
+			return false
+		}
+		expectedItem, actualItem := t.itemType(), actual.itemType()
+		return expectedItem.Kind == KindValue || expectedItem.Kind == actualItem.Kind
+	}
+	return t.Kind == actual.Kind
+}
+
+type typeScope struct {
+	parent *typeScope
+	vars   map[string]ExpressionType
+}
+
+func (s *typeScope) get(name string) (ExpressionType, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if t, ok := sc.vars[name]; ok {
+			return t, true
+		}
+	}
+	return TypeValue, false
+}
+
+type typeChecker struct {
+	errs  ValidationErrors
+	scope *typeScope
+}
+
+// TypeCheckExpression infers the type of an expression and reports every
+// node whose type does not fit where it is used. Pass TypeValue as expected
+// when any result type is acceptable. Errors are reported relative to path.
+func TypeCheckExpression(e *Expression, expected ExpressionType, path string) (ExpressionType, ValidationErrors) {
+	tc := &typeChecker{}
+	t := tc.check(e, expected, path)
+	return t, tc.errs
+}
+
+func argPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i+1)
+}
+
+// check infers the type of e and reports a mismatch against expected.
+func (tc *typeChecker) check(e *Expression, expected ExpressionType, path string) ExpressionType {
+	errCount := len(tc.errs)
+	t := tc.infer(e, expected, path)
+	if len(tc.errs) == errCount && !expected.accepts(t) {
+		tc.errs.add(path, "expected %s but found %s", expected, t)
+	}
+	return t
+}
+
+func (tc *typeChecker) checkArgs(e *Expression, path string, expected ExpressionType, from int) {
+	for i := from; i < len(e.Args); i++ {
+		tc.check(e.Args[i], expected, argPath(path, i))
+	}
+}
+
+func (tc *typeChecker) arity(e *Expression, path string, min, max int) bool {
+	if len(e.Args) < min || (max >= 0 && len(e.Args) > max) {
+		switch {
+		case min == max:
+			tc.errs.add(path, "%q expects %d arguments, but found %d", e.Operator, min, len(e.Args))
+		case max < 0:
+			tc.errs.add(path, "%q expects at least %d arguments, but found %d", e.Operator, min, len(e.Args))
+		default:
+			tc.errs.add(path, "%q expects %d to %d arguments, but found %d", e.Operator, min, max, len(e.Args))
+		}
+		return false
+	}
+	return true
+}
+
+func literalType(v interface{}, expected ExpressionType) ExpressionType {
+	switch t := v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case float64:
+		return TypeNumber
+	case string:
+		if expected.Kind == KindColor {
+			if _, ok := valueToColor(t); ok {
+				return TypeColor
+			}
+		}
+		return TypeString
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		item := ExpressionType{}
+		expectedItem := TypeValue
+		if expected.Kind == KindArray {
+			expectedItem = expected.itemType()
+		}
+		for _, v := range t {
+			it := literalType(normalizeValue(v), expectedItem)
+			if item.Kind == "" {
+				item = it
+			} else if item.Kind != it.Kind {
+				item = TypeValue
+			}
+		}
+		if item.Kind == "" {
+			item = TypeValue
+		}
+		return ArrayOf(item, len(t))
+	}
+	return TypeValue
+}
+
+func (tc *typeChecker) infer(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if e == nil {
+		return TypeNull
+	}
+	if e.IsLiteral {
+		t := literalType(normalizeValue(e.Value), expected)
+		if expected.Kind == KindColor && t.Kind == KindString {
+			tc.errs.add(path, "could not parse color from value %q", e.Value)
+		}
+		return t
+	}
+
+	switch e.Operator {
+	// Types
+	case ExpLiteral:
+		if !tc.arity(e, path, 1, 1) {
+			return TypeValue
+		}
+		return literalType(normalizeValue(expressionValue(e.Args[0])), expected)
+	case ExpArray:
+		return tc.inferArrayAssertion(e, path)
+	case ExpBoolean, ExpNumber, ExpString, ExpObject:
+		if tc.arity(e, path, 1, -1) {
+			tc.checkArgs(e, path, TypeValue, 0)
+		}
+		return ExpressionType{Kind: e.Operator}
+	case ExpToBool, ExpToString, ExpTypeOf:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeValue, 0)
+		}
+		if e.Operator == ExpToBool {
+			return TypeBoolean
+		}
+		return TypeString
+	case ExpToNumber, ExpToColor:
+		if tc.arity(e, path, 1, -1) {
+			tc.checkArgs(e, path, TypeValue, 0)
+		}
+		if e.Operator == ExpToNumber {
+			return TypeNumber
+		}
+		return TypeColor
+	case ExpToRGBA, ExpToHSLA:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeColor, 0)
+		}
+		return ArrayOf(TypeNumber, 4)
+	case ExpCollator:
+		if tc.arity(e, path, 0, 1) {
+			tc.checkArgs(e, path, TypeObject, 0)
+		}
+		return TypeCollator
+	case ExpFormat:
+		if tc.arity(e, path, 1, -1) {
+			for i, arg := range e.Args {
+				if arg != nil && arg.IsLiteral {
+					if _, ok := arg.Value.(map[string]interface{}); ok {
+						continue
+					}
+				}
+				tc.check(arg, TypeValue, argPath(path, i))
+			}
+		}
+		return TypeFormatted
+	case ExpImage:
+		if tc.arity(e, path, 1, 2) {
+			tc.check(e.Args[0], TypeString, argPath(path, 0))
+		}
+		return TypeResolvedImage
+	case ExpNumberFmt:
+		if tc.arity(e, path, 2, 2) {
+			tc.check(e.Args[0], TypeNumber, argPath(path, 0))
+			tc.check(e.Args[1], TypeObject, argPath(path, 1))
+		}
+		return TypeString
+
+	// Feature data
+	case ExpAccumulated, ExpLineProgress, ExpHeatmapDensity:
+		tc.arity(e, path, 0, 0)
+		return TypeNumber
+	case ExpFeatureState:
+		if tc.arity(e, path, 1, 1) {
+			tc.check(e.Args[0], TypeString, argPath(path, 0))
+		}
+		return TypeValue
+	case ExpGeometryType:
+		tc.arity(e, path, 0, 0)
+		return TypeString
+	case ExpID:
+		tc.arity(e, path, 0, 0)
+		return TypeValue
+	case ExpProperties:
+		tc.arity(e, path, 0, 0)
+		return TypeObject
+
+	// Lookup
+	case ExpGet, ExpHas:
+		if tc.arity(e, path, 1, 2) {
+			tc.check(e.Args[0], TypeString, argPath(path, 0))
+			if len(e.Args) > 1 {
+				tc.check(e.Args[1], TypeObject, argPath(path, 1))
+			}
+		}
+		if e.Operator == ExpHas {
+			return TypeBoolean
+		}
+		return TypeValue
+	case ExpConfig:
+		if tc.arity(e, path, 1, 2) {
+			tc.checkArgs(e, path, TypeString, 0)
+		}
+		return TypeValue
+	case ExpAt:
+		if !tc.arity(e, path, 2, 2) {
+			return TypeValue
+		}
+		tc.check(e.Args[0], TypeNumber, argPath(path, 0))
+		arr := tc.check(e.Args[1], ArrayOf(TypeValue, 0), argPath(path, 1))
+		if arr.Kind == KindArray {
+			return arr.itemType()
+		}
+		return TypeValue
+	case ExpAtInterpolated:
+		if tc.arity(e, path, 2, 2) {
+			tc.check(e.Args[0], TypeNumber, argPath(path, 0))
+			tc.check(e.Args[1], ArrayOf(TypeValue, 0), argPath(path, 1))
+		}
+		return TypeValue
+	case ExpIn, ExpIndexOf:
+		if tc.arity(e, path, 2, 3) {
+			needle := tc.check(e.Args[0], TypeValue, argPath(path, 0))
+			tc.checkNeedle(needle, argPath(path, 0))
+			tc.checkStringOrArray(e.Args[1], argPath(path, 1))
+			if len(e.Args) > 2 {
+				tc.check(e.Args[2], TypeNumber, argPath(path, 2))
+			}
+		}
+		if e.Operator == ExpIn {
+			return TypeBoolean
+		}
+		return TypeNumber
+	case ExpLength:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkStringOrArray(e.Args[0], argPath(path, 0))
+		}
+		return TypeNumber
+	case ExpSlice:
+		if !tc.arity(e, path, 2, 3) {
+			return TypeValue
+		}
+		t := tc.checkStringOrArray(e.Args[0], argPath(path, 0))
+		tc.checkArgs(e, path, TypeNumber, 1)
+		return t
+	case ExpSplit:
+		if tc.arity(e, path, 2, 2) {
+			tc.checkArgs(e, path, TypeString, 0)
+		}
+		return ArrayOf(TypeString, 0)
+	case ExpMeasureLight:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeString, 0)
+		}
+		return TypeNumber
+	case ExpWorldview:
+		tc.arity(e, path, 0, 0)
+		return TypeString
+
+	// Decision
+	case ExpNot:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeBoolean, 0)
+		}
+		return TypeBoolean
+	case ExpEQ, ExpNEq, ExpLT, ExpLTE, ExpGT, ExpGTE:
+		tc.inferComparison(e, path)
+		return TypeBoolean
+	case ExpAll, ExpAny:
+		tc.checkArgs(e, path, TypeBoolean, 0)
+		return TypeBoolean
+	case ExpCase:
+		return tc.inferCase(e, expected, path)
+	case ExpCoalesce:
+		if !tc.arity(e, path, 1, -1) {
+			return TypeValue
+		}
+		return tc.unify(e, expected, path, 0, 1)
+	case ExpMatch:
+		return tc.inferMatch(e, expected, path)
+	case ExpWithin:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeObject, 0)
+		}
+		return TypeBoolean
+
+	// Ramps, scales, curves
+	case ExpStep:
+		return tc.inferStep(e, expected, path)
+	case ExpInterpolate, ExpInterpolateHCL, ExpInterpolateLab:
+		return tc.inferInterpolate(e, expected, path)
+
+	// Variable binding
+	case ExpLet:
+		return tc.inferLet(e, expected, path)
+	case ExpVar:
+		if !tc.arity(e, path, 1, 1) {
+			return TypeValue
+		}
+		name, ok := literalString(e.Args, 0)
+		if !ok {
+			tc.errs.add(argPath(path, 0), "variable name must be a string literal")
+			return TypeValue
+		}
+		t, ok := tc.scope.get(name)
+		if !ok {
+			tc.errs.add(argPath(path, 0), "unknown variable %q; make sure %q has been bound in an enclosing \"let\" expression", name, name)
+		}
+		return t
+
+	// String
+	case ExpConcat:
+		if tc.arity(e, path, 1, -1) {
+			tc.checkArgs(e, path, TypeValue, 0)
+		}
+		return TypeString
+	case ExpDowncase, ExpUpcase:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeString, 0)
+		}
+		return TypeString
+	case ExpIsSupportedScript:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeString, 0)
+		}
+		return TypeBoolean
+	case ExpResolvedLocale:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeCollator, 0)
+		}
+		return TypeString
+
+	// Color
+	case ExpRGB, ExpHSL:
+		if tc.arity(e, path, 3, 3) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeColor
+	case ExpRGBA, ExpHSLA:
+		if tc.arity(e, path, 4, 4) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeColor
+
+	// Math
+	case ExpSub:
+		if tc.arity(e, path, 1, 2) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeNumber
+	case ExpDiv, ExpMod, ExpPow:
+		if tc.arity(e, path, 2, 2) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeNumber
+	case ExpAdd, ExpMul, ExpMax, ExpMin:
+		if tc.arity(e, path, 1, -1) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeNumber
+	case ExpAbs, ExpAcos, ExpAsin, ExpAtan, ExpCeil, ExpCos, ExpFloor,
+		ExpLn, ExpLog10, ExpLog2, ExpRound, ExpSin, ExpSqrt, ExpTan:
+		if tc.arity(e, path, 1, 1) {
+			tc.checkArgs(e, path, TypeNumber, 0)
+		}
+		return TypeNumber
+	case ExpRand:
+		if tc.arity(e, path, 2, 3) {
+			tc.check(e.Args[0], TypeNumber, argPath(path, 0))
+			tc.check(e.Args[1], TypeNumber, argPath(path, 1))
+		}
+		return TypeNumber
+	case ExpDist:
+		if tc.arity(e, path, 1, 2) {
+			tc.check(e.Args[0], TypeObject, argPath(path, 0))
+		}
+		return TypeNumber
+	case ExpE, ExpLn2, ExpPI, ExpZoom, ExpPitch, ExpDistFromCenter:
+		tc.arity(e, path, 0, 0)
+		return TypeNumber
+	}
+
+	if IsKnownOperator(e.Operator) {
+		tc.errs.add(path, "%q is not allowed here", e.Operator)
+	} else {
+		tc.errs.add(path, "unknown expression operator %q", e.Operator)
+	}
+	return TypeValue
+}
+
+func (tc *typeChecker) checkStringOrArray(e *Expression, path string) ExpressionType {
+	t := tc.check(e, TypeValue, path)
+	switch t.Kind {
+	case KindString, KindArray, KindValue:
+	default:
+		tc.errs.add(path, "expected string or array but found %s", t)
+	}
+	return t
+}
+
+func (tc *typeChecker) checkNeedle(t ExpressionType, path string) {
+	switch t.Kind {
+	case KindBoolean, KindString, KindNumber, KindNull, KindValue:
+	default:
+		tc.errs.add(path, "expected boolean, string, number or null but found %s", t)
+	}
+}
+
+func (tc *typeChecker) inferArrayAssertion(e *Expression, path string) ExpressionType {
+	if !tc.arity(e, path, 1, 3) {
+		return ArrayOf(TypeValue, 0)
+	}
+	item := TypeValue
+	length := 0
+	if len(e.Args) > 1 {
+		name, ok := literalString(e.Args, 0)
+		switch {
+		case !ok:
+			tc.errs.add(argPath(path, 0), "the item type argument of \"array\" must be a string literal")
+		case name == KindString || name == KindNumber || name == KindBoolean:
+			item = ExpressionType{Kind: name}
+		default:
+			tc.errs.add(argPath(path, 0), "the item type argument of \"array\" must be one of string, number, boolean")
+		}
+	}
+	if len(e.Args) > 2 {
+		n, ok := literalNumber(e.Args[1])
+		if !ok || n < 0 || n != float64(int(n)) {
+			tc.errs.add(argPath(path, 1), "the length argument of \"array\" must be a non-negative integer literal")
+		} else {
+			length = int(n)
+		}
+	}
+	tc.check(e.Args[len(e.Args)-1], TypeValue, argPath(path, len(e.Args)-1))
+	return ArrayOf(item, length)
+}
+
+func (tc *typeChecker) inferComparison(e *Expression, path string) {
+	if !tc.arity(e, path, 2, 3) {
+		return
+	}
+	lhs := tc.check(e.Args[0], TypeValue, argPath(path, 0))
+	rhs := tc.check(e.Args[1], TypeValue, argPath(path, 1))
+	if len(e.Args) == 3 {
+		tc.check(e.Args[2], TypeCollator, argPath(path, 2))
+	}
+	ordering := e.Operator != ExpEQ && e.Operator != ExpNEq
+	for i, t := range []ExpressionType{lhs, rhs} {
+		switch t.Kind {
+		case KindString, KindNumber, KindValue:
+		case KindBoolean, KindNull:
+			if ordering {
+				tc.errs.add(argPath(path, i), "%q comparisons are not supported for type %s", e.Operator, t)
+				return
+			}
+		default:
+			tc.errs.add(argPath(path, i), "%q comparisons are not supported for type %s", e.Operator, t)
+			return
+		}
+	}
+	if lhs.Kind != rhs.Kind && lhs.Kind != KindValue && rhs.Kind != KindValue {
+		tc.errs.add(path, "cannot compare types %s and %s", lhs, rhs)
+	}
+}
+
+// unify checks the output expressions at positions from, from+step, ... and
+// returns their common type. The first output determines the type the
+// others must match unless a specific type is expected.
+func (tc *typeChecker) unify(e *Expression, expected ExpressionType, path string, from, step int) ExpressionType {
+	out := expected
+	for i := from; i < len(e.Args); i += step {
+		t := tc.check(e.Args[i], out, argPath(path, i))
+		if out.Kind == KindValue && i == from {
+			out = t
+		}
+	}
+	return out
+}
+
+func (tc *typeChecker) inferCase(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if !tc.arity(e, path, 3, -1) {
+		return TypeValue
+	}
+	if len(e.Args)%2 == 0 {
+		tc.errs.add(path, "expected an odd number of arguments")
+		return TypeValue
+	}
+	for i := 0; i+1 < len(e.Args); i += 2 {
+		tc.check(e.Args[i], TypeBoolean, argPath(path, i))
+	}
+	out := expected
+	for i := 1; i < len(e.Args); i += 2 {
+		t := tc.check(e.Args[i], out, argPath(path, i))
+		if out.Kind == KindValue && i == 1 {
+			out = t
+		}
+	}
+	tc.check(e.Args[len(e.Args)-1], out, argPath(path, len(e.Args)-1))
+	return out
+}
+
+func (tc *typeChecker) inferMatch(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if !tc.arity(e, path, 3, -1) {
+		return TypeValue
+	}
+	if len(e.Args)%2 != 0 {
+		tc.errs.add(path, "expected an even number of arguments")
+		return TypeValue
+	}
+	input := tc.check(e.Args[0], TypeValue, argPath(path, 0))
+
+	labelKind := ""
+	for i := 1; i+1 < len(e.Args); i += 2 {
+		label := normalizeValue(expressionValue(e.Args[i]))
+		labels, ok := label.([]interface{})
+		if !ok {
+			labels = []interface{}{label}
+		} else if len(labels) == 0 {
+			tc.errs.add(argPath(path, i), "expected at least one branch label")
+		}
+		for _, l := range labels {
+			var kind string
+			switch v := l.(type) {
+			case string:
+				kind = KindString
+			case float64:
+				kind = KindNumber
+				if v != float64(int64(v)) {
+					tc.errs.add(argPath(path, i), "numeric branch labels must be integer values")
+				}
+			default:
+				tc.errs.add(argPath(path, i), "branch labels must be numbers or strings")
+				continue
+			}
+			if labelKind == "" {
+				labelKind = kind
+			} else if kind != labelKind {
+				tc.errs.add(argPath(path, i), "expected %s but found %s", labelKind, kind)
+			}
+		}
+	}
+	if labelKind != "" && input.Kind != KindValue && input.Kind != labelKind {
+		tc.errs.add(argPath(path, 0), "expected %s but found %s", labelKind, input)
+	}
+
+	out := expected
+	for i := 2; i < len(e.Args); i += 2 {
+		t := tc.check(e.Args[i], out, argPath(path, i))
+		if out.Kind == KindValue && i == 2 {
+			out = t
+		}
+	}
+	tc.check(e.Args[len(e.Args)-1], out, argPath(path, len(e.Args)-1))
+	return out
+}
+
+func (tc *typeChecker) checkStops(e *Expression, path string, first int) {
+	prev := 0.0
+	for i := first; i < len(e.Args); i += 2 {
+		arg := e.Args[i]
+		if arg == nil || !arg.IsLiteral {
+			tc.errs.add(argPath(path, i), "input/output pairs must use literal numbers as inputs")
+			continue
+		}
+		n, ok := arg.Value.(float64)
+		if !ok {
+			tc.errs.add(argPath(path, i), "input/output pairs must use literal numbers as inputs")
+			continue
+		}
+		if i > first && n <= prev {
+			tc.errs.add(argPath(path, i), "input/output pairs must be arranged with input values in strictly ascending order")
+		}
+		prev = n
+	}
+}
+
+func (tc *typeChecker) inferStep(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if !tc.arity(e, path, 2, -1) {
+		return TypeValue
+	}
+	if len(e.Args)%2 != 0 {
+		tc.errs.add(path, "expected an even number of arguments")
+		return TypeValue
+	}
+	tc.check(e.Args[0], TypeNumber, argPath(path, 0))
+	tc.checkStops(e, path, 2)
+	return tc.unify(e, expected, path, 1, 2)
+}
+
+func (tc *typeChecker) inferInterpolate(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if !tc.arity(e, path, 4, -1) {
+		return TypeValue
+	}
+	if len(e.Args)%2 != 0 {
+		tc.errs.add(path, "expected an even number of arguments")
+		return TypeValue
+	}
+	interp := e.Args[0]
+	switch {
+	case interp == nil || interp.IsLiteral:
+		tc.errs.add(argPath(path, 0), "expected an interpolation type expression")
+	case interp.Operator == ExpLinear:
+		tc.arity(interp, argPath(path, 0), 0, 0)
+	case interp.Operator == ExpExponential:
+		if tc.arity(interp, argPath(path, 0), 1, 1) {
+			if _, ok := literalNumber(interp.Args[0]); !ok {
+				tc.errs.add(argPath(argPath(path, 0), 0), "exponential interpolation requires a numeric base")
+			}
+		}
+	case interp.Operator == ExpCubicBezier:
+		if tc.arity(interp, argPath(path, 0), 4, 4) {
+			for i, arg := range interp.Args {
+				n, ok := literalNumber(arg)
+				if !ok || ((i == 0 || i == 2) && (n < 0 || n > 1)) {
+					tc.errs.add(argPath(path, 0), "cubic bezier interpolation requires four numeric arguments with values between 0 and 1")
+					break
+				}
+			}
+		}
+	default:
+		tc.errs.add(argPath(path, 0), "unknown interpolation type %q", interp.Operator)
+	}
+	tc.check(e.Args[1], TypeNumber, argPath(path, 1))
+	tc.checkStops(e, path, 2)
+
+	out := expected
+	if e.Operator != ExpInterpolate {
+		out = TypeColor
+	}
+	out = tc.unify(e, out, path, 3, 2)
+	switch {
+	case out.Kind == KindNumber, out.Kind == KindColor, out.Kind == KindValue:
+	case out.Kind == KindArray && out.itemType().Kind == KindNumber && out.Length > 0:
+	default:
+		tc.errs.add(path, "type %s is not interpolatable", out)
+	}
+	return out
+}
+
+func literalNumber(e *Expression) (float64, bool) {
+	if e == nil || !e.IsLiteral {
+		return 0, false
+	}
+	n, ok := e.Value.(float64)
+	return n, ok
+}
+
+func (tc *typeChecker) inferLet(e *Expression, expected ExpressionType, path string) ExpressionType {
+	if !tc.arity(e, path, 3, -1) {
+		return TypeValue
+	}
+	if len(e.Args)%2 == 0 {
+		tc.errs.add(path, "expected an odd number of arguments")
+		return TypeValue
+	}
+	scope := &typeScope{parent: tc.scope, vars: map[string]ExpressionType{}}
+	for i := 0; i+1 < len(e.Args); i += 2 {
+		name, ok := literalString(e.Args, i)
+		if !ok {
+			tc.errs.add(argPath(path, i), "variable names must be string literals")
+			continue
+		}
+		scope.vars[name] = tc.check(e.Args[i+1], TypeValue, argPath(path, i+1))
+	}
+	prev := tc.scope
+	tc.scope = scope
+	defer func() { tc.scope = prev }()
+	return tc.check(e.Args[len(e.Args)-1], expected, argPath(path, len(e.Args)-1))
+}
+
+// propertyExpression decodes a raw property value. Arrays whose first
+// element is not a known operator, such as "text-font" stacks, are plain
+// array values rather than expressions.
+func propertyExpression(raw interface{}) (*Expression, error) {
+	e := &Expression{}
+	if err := e.decode(raw); err != nil {
+		return nil, err
+	}
+	if !e.IsLiteral && !IsKnownOperator(e.Operator) {
+		return &Expression{IsLiteral: true, Value: raw}, nil
+	}
+	return e, nil
+}
+
+// isLegacyFunction reports whether a raw property value is a legacy
+// function object such as {"stops": [[0, 1], [10, 2]]}.
+func isLegacyFunction(raw interface{}) bool {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, hasStops := m["stops"]
+	_, hasType := m["type"]
+	return hasStops || hasType
+}
+
+func (tc *typeChecker) checkProperty(raw interface{}, expected ExpressionType, path string) {
+	if isLegacyFunction(raw) {
+		stops, _ := raw.(map[string]interface{})["stops"].([]interface{})
+		for i, stop := range stops {
+			pair, ok := stop.([]interface{})
+			if !ok || len(pair) != 2 {
+				tc.errs.add(fmt.Sprintf("%s.stops[%d]", path, i), "expected a [input, output] pair")
+				continue
+			}
+			tc.checkProperty(pair[1], expected, fmt.Sprintf("%s.stops[%d][1]", path, i))
+		}
+		return
+	}
+	e, err := propertyExpression(raw)
+	if err != nil {
+		tc.errs.add(path, "%v", err)
+		return
+	}
+	tc.check(e, expected, path)
+}
+
+// propertyMap returns the properties set on a Paint or Layout as raw JSON
+// values keyed by property name.
+func propertyMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	props := map[string]interface{}{}
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// TypeCheck infers the type of every layer filter and paint and layout
+// property expression and reports the values whose type does not match
+// the style specification.
+func (s *Style) TypeCheck() ValidationErrors {
+	tc := &typeChecker{}
+	for i, l := range s.Layers {
+		if l == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("layers[%d]", i)
+		if l.Filter != nil && l.Filter.Expr != nil && !IsLegacyFilter(l.Filter.Expr) {
+			tc.check(l.Filter.Expr, TypeBoolean, prefix+".filter")
+		}
+		tc.checkLayerProperties(l, l.Paint, true, prefix+".paint")
+		tc.checkLayerProperties(l, l.Layout, false, prefix+".layout")
+	}
+	return tc.errs
+}
+
+func (tc *typeChecker) checkLayerProperties(l *Layer, v interface{}, paint bool, path string) {
+	props, err := propertyMap(v)
+	if err != nil {
+		tc.errs.add(path, "%v", err)
+		return
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw := props[name]
+		spec, ok := propertySpecFor(l.Type, name, paint)
+		if !ok {
+			continue
+		}
+		tc.checkProperty(raw, spec.expressionType(), path+"."+name)
+	}
+}
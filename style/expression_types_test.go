@@ -0,0 +1,115 @@
+package style
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTypeCheckExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected ExpressionType
+		want     string
+	}{
+		{"number", `["+",1,["get","x"]]`, TypeValue, "number"},
+		{"color_literal", `"red"`, TypeColor, "color"},
+		{"rgb", `["rgb",1,2,3]`, TypeValue, "color"},
+		{"case_unify", `["case",["has","a"],1,2]`, TypeValue, "number"},
+		{"match_unify", `["match",["get","c"],["a","b"],"x","y"]`, TypeValue, "string"},
+		{"coalesce_value", `["coalesce",["get","a"],"x"]`, TypeValue, "value"},
+		{"coalesce_expected", `["coalesce",["get","a"],"red"]`, TypeColor, "color"},
+		{"interpolate_color", `["interpolate",["linear"],["zoom"],0,"red",10,"blue"]`, TypeColor, "color"},
+		{"interpolate_hcl", `["interpolate-hcl",["linear"],["zoom"],0,"red",10,"blue"]`, TypeValue, "color"},
+		{"step", `["step",["zoom"],"a",5,"b"]`, TypeValue, "string"},
+		{"let", `["let","v",2,["*",["var","v"],3]]`, TypeValue, "number"},
+		{"to_number", `["to-number",["get","x"]]`, TypeNumber, "number"},
+		{"array_assert", `["array","number",2,["get","x"]]`, TypeValue, "array<number, 2>"},
+		{"at", `["at",0,["array","string",["get","x"]]]`, TypeValue, "string"},
+		{"literal_array", `["literal",[1,2]]`, TypeValue, "array<number, 2>"},
+		{"format", `["format",["get","name"],{"font-scale":1.2}]`, TypeFormatted, "formatted"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, errs := TypeCheckExpression(mustExpr(t, tc.expr), tc.expected, "x")
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if got.String() != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTypeCheckExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected ExpressionType
+		want     string
+	}{
+		{"number_for_color", `["+",1,2]`, TypeColor, "p: expected color but found number"},
+		{"math_string_arg", `["+",1,"a"]`, TypeNumber, "p[2]: expected number but found string"},
+		{"case_condition", `["case",1,"a","b"]`, TypeValue, "p[1]: expected boolean but found number"},
+		{"case_branch", `["case",true,1,"b"]`, TypeValue, "p[3]: expected number but found string"},
+		{"match_branch", `["match",["get","c"],"a",1,"b"]`, TypeValue, "p[4]: expected number but found string"},
+		{"match_labels", `["match",["get","c"],"a",1,2,2,0]`, TypeValue, "p[4]: expected string but found number"},
+		{"compare", `["<",1,"a"]`, TypeValue, "p: cannot compare types number and string"},
+		{"bad_color", `"notacolor"`, TypeColor, `p: could not parse color from value "notacolor"`},
+		{"unbound_var", `["var","nope"]`, TypeValue, `p[1]: unknown variable "nope"`},
+		{"arity", `["rgb",1,2]`, TypeValue, `p: "rgb" expects 3 arguments, but found 2`},
+		{"stops_order", `["step",["zoom"],0,5,1,3,2]`, TypeValue, "p[5]: input/output pairs must be arranged"},
+		{"interpolate_string", `["interpolate",["linear"],["zoom"],0,"a",1,"b"]`, TypeValue, "p: type string is not interpolatable"},
+		{"to_rgba", `["to-rgba",1]`, TypeValue, "p[1]: expected color but found number"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := TypeCheckExpression(mustExpr(t, tc.expr), tc.expected, "p")
+			if len(errs) == 0 {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(errs[0].Error(), tc.want) {
+				t.Fatalf("expected %q, got %q", tc.want, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestStyleTypeCheck(t *testing.T) {
+	raw := `{
+		"version": 8,
+		"sources": {},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#fff"}},
+			{"id": "roads", "type": "line",
+				"filter": ["==", ["get", "class"], "road"],
+				"paint": {"line-width": ["interpolate", ["linear"], ["zoom"], 5, 1, 10, 4]},
+				"layout": {"line-cap": "round"}},
+			{"id": "labels", "type": "symbol",
+				"layout": {"text-field": ["get", "name"], "text-font": ["Open Sans Regular"], "text-offset": [0, 1]}},
+			{"id": "water", "type": "fill",
+				"filter": ["length", ["get", "class"]],
+				"paint": {"fill-color": ["+", 1, 2], "fill-opacity": {"stops": [[0, 0.5], [10, "x"]]}}}
+		]
+	}`
+	var s Style
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatal(err)
+	}
+	errs := s.TypeCheck()
+	want := []string{
+		"layers[3].filter: expected boolean but found number",
+		"layers[3].paint.fill-color: expected color but found number",
+		"layers[3].paint.fill-opacity.stops[1][1]: expected number but found string",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Fatalf("error %d: expected %q, got %q", i, w, errs[i].Error())
+		}
+	}
+}
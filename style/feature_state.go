@@ -0,0 +1,234 @@
+package style
+
+import (
+	"sort"
+	"sync"
+)
+
+// FeatureRef identifies a feature for its state, as the target of
+// setFeatureState in GL JS. SourceLayer is empty for GeoJSON sources. ID is
+// the feature id, or the value of the property named by the source's
+// promoteId.
+type FeatureRef struct {
+	Source      string      `json:"source"`
+	SourceLayer string      `json:"sourceLayer,omitempty"`
+	ID          interface{} `json:"id"`
+}
+
+// FeatureState is the state of one feature in a snapshot.
+type FeatureState struct {
+	FeatureRef
+	State map[string]interface{} `json:"state"`
+}
+
+type featureKey struct {
+	source, sourceLayer, id string
+}
+
+// FeatureStateStore holds the state of features, keyed by source, source
+// layer and feature id, for ["feature-state", key] expressions to read. It
+// is safe for concurrent use. A nil store holds no state and ignores
+// changes.
+type FeatureStateStore struct {
+	sources Sources
+
+	mu     sync.RWMutex
+	states map[featureKey]FeatureState
+}
+
+// NewFeatureStateStore returns an empty store for the features of the given
+// sources, whose promoteId decides which property identifies a feature.
+func NewFeatureStateStore(sources Sources) *FeatureStateStore {
+	return &FeatureStateStore{sources: sources, states: map[featureKey]FeatureState{}}
+}
+
+// keyOf returns the map key of a feature. Ids are compared by their JSON
+// encoding after number normalization, so 1 and 1.0 are the same feature but
+// "1" is not.
+func keyOf(ref FeatureRef) featureKey {
+	return featureKey{ref.Source, ref.SourceLayer, jsonString(normalizeValue(ref.ID))}
+}
+
+// Set merges state into the state of a feature, like setFeatureState.
+// Setting a feature without an id is a no-op.
+func (st *FeatureStateStore) Set(ref FeatureRef, state map[string]interface{}) {
+	if st == nil || ref.ID == nil || len(state) == 0 {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.set(ref, state)
+}
+
+func (st *FeatureStateStore) set(ref FeatureRef, state map[string]interface{}) {
+	k := keyOf(ref)
+	cur, ok := st.states[k]
+	if !ok {
+		cur = FeatureState{FeatureRef: ref, State: map[string]interface{}{}}
+	}
+	for key, v := range state {
+		cur.State[key] = copyValue(normalizeValue(v))
+	}
+	st.states[k] = cur
+}
+
+// Remove removes the key from the state of a feature, like
+// removeFeatureState. An empty key removes the whole state of the feature,
+// and a nil ID the state of every feature of the source layer.
+func (st *FeatureStateStore) Remove(ref FeatureRef, key string) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if ref.ID == nil {
+		for k := range st.states {
+			if k.source == ref.Source && k.sourceLayer == ref.SourceLayer {
+				delete(st.states, k)
+			}
+		}
+		return
+	}
+	k := keyOf(ref)
+	if key == "" {
+		delete(st.states, k)
+		return
+	}
+	if cur, ok := st.states[k]; ok {
+		delete(cur.State, key)
+		if len(cur.State) == 0 {
+			delete(st.states, k)
+		}
+	}
+}
+
+// Get returns a copy of the state of a feature, or nil if it has none.
+func (st *FeatureStateStore) Get(ref FeatureRef) map[string]interface{} {
+	if st == nil || ref.ID == nil {
+		return nil
+	}
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	cur, ok := st.states[keyOf(ref)]
+	if !ok {
+		return nil
+	}
+	return copyState(cur.State)
+}
+
+// FeatureID returns the id the state of f is keyed by: the property named by
+// the promoteId of the source, or the feature id if the source promotes none.
+func (st *FeatureStateStore) FeatureID(source, sourceLayer string, f *Feature) interface{} {
+	if f == nil {
+		return nil
+	}
+	if st == nil {
+		return f.ID
+	}
+	if src := st.sources[source]; src != nil {
+		if prop, ok := promotedProperty(src.PromoteID, sourceLayer); ok {
+			return f.Properties[prop]
+		}
+	}
+	return f.ID
+}
+
+// promotedProperty returns the property promoteId names for a source layer.
+// promoteId is either a property name or an object of names by source layer.
+func promotedProperty(promoteID interface{}, sourceLayer string) (string, bool) {
+	switch t := promoteID.(type) {
+	case string:
+		return t, true
+	case map[string]interface{}:
+		prop, ok := t[sourceLayer].(string)
+		return prop, ok
+	case map[string]string:
+		prop, ok := t[sourceLayer]
+		return prop, ok
+	}
+	return "", false
+}
+
+// WithState returns a copy of f carrying its state, for evaluating
+// expressions against it. f is returned unchanged when it has no state.
+func (st *FeatureStateStore) WithState(source, sourceLayer string, f *Feature) *Feature {
+	if st == nil || f == nil {
+		return f
+	}
+	state := st.Get(FeatureRef{Source: source, SourceLayer: sourceLayer, ID: st.FeatureID(source, sourceLayer, f)})
+	if state == nil {
+		return f
+	}
+	out := *f
+	out.State = state
+	return &out
+}
+
+// Snapshot returns a copy of every feature state, ordered by source, source
+// layer and id.
+func (st *FeatureStateStore) Snapshot() []FeatureState {
+	if st == nil {
+		return nil
+	}
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	keys := make([]featureKey, 0, len(st.states))
+	for k := range st.states {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		if a.sourceLayer != b.sourceLayer {
+			return a.sourceLayer < b.sourceLayer
+		}
+		return a.id < b.id
+	})
+	out := make([]FeatureState, len(keys))
+	for i, k := range keys {
+		cur := st.states[k]
+		out[i] = FeatureState{FeatureRef: cur.FeatureRef, State: copyState(cur.State)}
+	}
+	return out
+}
+
+// Restore replaces every feature state with those of a snapshot.
+func (st *FeatureStateStore) Restore(snapshot []FeatureState) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.states = map[featureKey]FeatureState{}
+	for _, fs := range snapshot {
+		if fs.ID != nil && len(fs.State) > 0 {
+			st.set(fs.FeatureRef, fs.State)
+		}
+	}
+}
+
+func copyState(state map[string]interface{}) map[string]interface{} {
+	return copyValue(state).(map[string]interface{})
+}
+
+// copyValue deep copies a decoded JSON value, so callers cannot change
+// stored state through the maps and slices they get back.
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = copyValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
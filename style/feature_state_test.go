@@ -0,0 +1,160 @@
+package style
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureStateStore(t *testing.T) {
+	st := NewFeatureStateStore(nil)
+	ref := FeatureRef{Source: "v", SourceLayer: "poi", ID: 1}
+	st.Set(ref, map[string]interface{}{"hover": true})
+	st.Set(FeatureRef{Source: "v", SourceLayer: "poi", ID: 1.0}, map[string]interface{}{"rank": 2})
+
+	want := map[string]interface{}{"hover": true, "rank": float64(2)}
+	if got := st.Get(ref); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged state = %v, want %v", got, want)
+	}
+	if got := st.Get(FeatureRef{Source: "v", SourceLayer: "poi", ID: "1"}); got != nil {
+		t.Errorf("string id shares state with numeric id: %v", got)
+	}
+
+	st.Get(ref)["hover"] = false
+	if got := st.Get(ref)["hover"]; got != true {
+		t.Errorf("state changed through Get: hover = %v", got)
+	}
+
+	st.Remove(ref, "hover")
+	if got := st.Get(ref); !reflect.DeepEqual(got, map[string]interface{}{"rank": float64(2)}) {
+		t.Errorf("after removing hover = %v", got)
+	}
+	st.Remove(ref, "")
+	if got := st.Get(ref); got != nil {
+		t.Errorf("after removing state = %v", got)
+	}
+
+	st.Set(FeatureRef{Source: "v", SourceLayer: "poi", ID: 1}, map[string]interface{}{"a": 1})
+	st.Set(FeatureRef{Source: "v", SourceLayer: "poi", ID: 2}, map[string]interface{}{"a": 1})
+	st.Set(FeatureRef{Source: "v", SourceLayer: "road", ID: 1}, map[string]interface{}{"a": 1})
+	st.Remove(FeatureRef{Source: "v", SourceLayer: "poi"}, "")
+	if got := len(st.Snapshot()); got != 1 {
+		t.Errorf("after clearing source layer, %d states remain, want 1", got)
+	}
+}
+
+func TestFeatureStateSnapshot(t *testing.T) {
+	st := NewFeatureStateStore(nil)
+	st.Set(FeatureRef{Source: "b", ID: "x"}, map[string]interface{}{"selected": true})
+	st.Set(FeatureRef{Source: "a", SourceLayer: "l", ID: 7}, map[string]interface{}{"tags": []interface{}{"t"}})
+
+	snap := st.Snapshot()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"source":"a","sourceLayer":"l","id":7,"state":{"tags":["t"]}},{"source":"b","id":"x","state":{"selected":true}}]`
+	if string(data) != want {
+		t.Errorf("snapshot = %s, want %s", data, want)
+	}
+
+	snap[0].State["tags"].([]interface{})[0] = "changed"
+	st.Set(FeatureRef{Source: "b", ID: "x"}, map[string]interface{}{"selected": false})
+	if got := st.Get(FeatureRef{Source: "a", SourceLayer: "l", ID: 7})["tags"]; !reflect.DeepEqual(got, []interface{}{"t"}) {
+		t.Errorf("store changed through snapshot: tags = %v", got)
+	}
+
+	st.Restore(st.Snapshot()[:1])
+	st.Restore(snap)
+	if got := st.Get(FeatureRef{Source: "b", ID: "x"})["selected"]; got != true {
+		t.Errorf("restored selected = %v, want true", got)
+	}
+	if got := len(st.Snapshot()); got != 2 {
+		t.Errorf("restored %d states, want 2", got)
+	}
+}
+
+func TestFeatureStatePromoteID(t *testing.T) {
+	sources := Sources{
+		"all":    {Type: "geojson", PromoteID: "code"},
+		"layers": {Type: "vector", PromoteID: map[string]interface{}{"poi": "poi_id"}},
+		"plain":  {Type: "vector"},
+	}
+	st := NewFeatureStateStore(sources)
+	f := &Feature{ID: 1, Properties: map[string]interface{}{"code": "FR", "poi_id": 99}}
+
+	tests := []struct {
+		source, sourceLayer string
+		want                interface{}
+	}{
+		{"all", "", "FR"},
+		{"layers", "poi", 99},
+		{"layers", "road", 1},
+		{"plain", "poi", 1},
+		{"unknown", "", 1},
+	}
+	for _, tt := range tests {
+		if got := st.FeatureID(tt.source, tt.sourceLayer, f); got != tt.want {
+			t.Errorf("FeatureID(%q, %q) = %v, want %v", tt.source, tt.sourceLayer, got, tt.want)
+		}
+	}
+
+	st.Set(FeatureRef{Source: "all", ID: "FR"}, map[string]interface{}{"hover": true})
+	if got := st.WithState("all", "", f); got.State["hover"] != true || f.State != nil {
+		t.Errorf("WithState = %v, original state = %v", got.State, f.State)
+	}
+	if got := st.WithState("plain", "poi", f); got != f {
+		t.Error("WithState copied a feature without state")
+	}
+}
+
+func TestNilFeatureStateStore(t *testing.T) {
+	var st *FeatureStateStore
+	ref := FeatureRef{Source: "s", ID: 1}
+	st.Set(ref, map[string]interface{}{"hover": true})
+	st.Remove(ref, "hover")
+	st.Restore([]FeatureState{{FeatureRef: ref, State: map[string]interface{}{"hover": true}}})
+	if got := st.Snapshot(); got != nil {
+		t.Errorf("Snapshot = %v", got)
+	}
+	if got := st.Get(ref); got != nil {
+		t.Errorf("Get = %v", got)
+	}
+	f := &Feature{ID: 1}
+	if got := st.FeatureID("s", "", f); got != 1 {
+		t.Errorf("FeatureID = %v", got)
+	}
+	if got := st.WithState("s", "", f); got != f {
+		t.Error("WithState copied a feature")
+	}
+}
+
+func TestEvaluateFeatureState(t *testing.T) {
+	e := mustExpr(t, `["case", ["boolean", ["feature-state", "hover"], false], 1, 0.5]`)
+	st := NewFeatureStateStore(nil)
+	f := &Feature{ID: 3}
+
+	eval := func() interface{} {
+		t.Helper()
+		v, err := e.Evaluate(EvalContext{Feature: st.WithState("v", "", f)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if got := eval(); got != 0.5 {
+		t.Errorf("without state = %v, want 0.5", got)
+	}
+	st.Set(FeatureRef{Source: "v", ID: 3}, map[string]interface{}{"hover": true})
+	if got := eval(); got != float64(1) {
+		t.Errorf("hovered = %v, want 1", got)
+	}
+
+	var p Paint
+	if err := json.Unmarshal([]byte(`{"fill-opacity": ["case", ["boolean", ["feature-state", "hover"], false], 1, 0.5]}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.FillOpacityAt(0, st.WithState("v", "", f)); err != nil || got != 1 {
+		t.Errorf("FillOpacityAt = %v, %v, want 1", got, err)
+	}
+}
@@ -2,6 +2,7 @@ package style
 
 import (
 	"encoding/json"
+	"sync"
 )
 
 const (
@@ -37,6 +38,13 @@ const (
 // A filter is simply an expression that evaluates to a boolean.
 type FilterContainer struct {
 	Expr *Expression
+
+	// compiled caches Expr converted to an expression filter, see
+	// FilterContainer.compile.
+	mu       sync.Mutex
+	source   *Expression
+	compiled *Expression
+	err      error
 }
 
 func (f *FilterContainer) UnmarshalJSON(data []byte) error {
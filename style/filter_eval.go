@@ -0,0 +1,106 @@
+package style
+
+import "github.com/pkg/errors"
+
+const (
+	filterKeyType = "$type"
+	filterKeyID   = "$id"
+
+	filterOperatorHas    = "has"
+	filterOperatorNotHas = "!has"
+	filterOperatorNone   = "none"
+)
+
+// Evaluate runs the filter against the feature in ctx. Both expression
+// filters and legacy filters such as ["==", "class", "park"] are supported.
+// A nil filter matches every feature. As in GL, a filter that fails to
+// evaluate for a feature, such as a comparison with a missing property, or
+// that does not produce a boolean does not match; only malformed filters
+// return an error.
+func (f *FilterContainer) Evaluate(ctx EvalContext) (bool, error) {
+	if f == nil || f.Expr == nil {
+		return true, nil
+	}
+	expr, err := f.compile()
+	if err != nil {
+		return false, err
+	}
+	v, err := expr.Evaluate(ctx)
+	if err != nil {
+		return false, nil
+	}
+	b, _ := v.(bool)
+	return b, nil
+}
+
+// compile converts the filter to an expression filter. The result is kept until Expr is replaced, so legacy filters
+// are converted once rather than per feature.
+func (f *FilterContainer) compile() (*Expression, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.source == f.Expr && (f.compiled != nil || f.err != nil) {
+		return f.compiled, f.err
+	}
+	f.source = f.Expr
+	f.compiled, f.err = ConvertLegacyFilter(f.Expr)
+	if f.err != nil {
+		f.err = errors.Wrap(f.err, "invalid filter")
+	}
+	return f.compiled, f.err
+}
+
+// isExpressionFilter reports whether a filter uses expression syntax rather
+// than the legacy filter syntax. It follows the same heuristics as GL.
+func isExpressionFilter(e *Expression) bool {
+	if e == nil {
+		return false
+	}
+	if e.IsLiteral {
+		_, ok := e.Value.(bool)
+		return ok
+	}
+	switch e.Operator {
+	case filterOperatorHas:
+		if len(e.Args) < 1 {
+			return true
+		}
+		key, ok := literalString(e.Args, 0)
+		return !ok || (key != filterKeyID && key != filterKeyType)
+	case FilterOperatorIn:
+		if len(e.Args) < 2 {
+			return false
+		}
+		_, keyIsString := literalString(e.Args, 0)
+		return !keyIsString || isArrayArg(e.Args[1])
+	case FilterOperatorNotIn, filterOperatorNotHas, filterOperatorNone:
+		return false
+	case ExpEQ, ExpNEq, ExpLT, ExpLTE, ExpGT, ExpGTE:
+		return len(e.Args) != 2 || isArrayArg(e.Args[0]) || isArrayArg(e.Args[1])
+	case ExpAll, ExpAny:
+		for _, arg := range e.Args {
+			if arg != nil && arg.IsLiteral {
+				if _, ok := arg.Value.(bool); ok {
+					continue
+				}
+			}
+			if !isExpressionFilter(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// isArrayArg reports whether a filter argument was written as a JSON array,
+// either as a nested expression or as a literal array.
+func isArrayArg(e *Expression) bool {
+	if e == nil {
+		return false
+	}
+	if !e.IsLiteral {
+		return true
+	}
+	_, ok := e.Value.([]interface{})
+	return ok
+}
@@ -0,0 +1,108 @@
+package style
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLayerMatches(t *testing.T) {
+	park := &Feature{ID: uint64(7), Type: "Polygon", Properties: map[string]interface{}{"class": "park", "rank": int64(2)}}
+	road := &Feature{ID: uint64(8), Type: "MultiLineString", Properties: map[string]interface{}{"class": "primary"}}
+
+	tests := []struct {
+		name    string
+		filter  string
+		feature *Feature
+		want    bool
+	}{
+		{"legacy_eq", `["==","class","park"]`, park, true},
+		{"legacy_neq", `["!=","class","park"]`, park, false},
+		{"legacy_type", `["==","$type","Polygon"]`, park, true},
+		{"legacy_type_multi", `["==","$type","LineString"]`, road, true},
+		{"legacy_id", `["==","$id",7]`, park, true},
+		{"legacy_in", `["in","class","forest","park"]`, park, true},
+		{"legacy_not_in", `["!in","class","forest","park"]`, road, true},
+		{"legacy_has", `["has","class"]`, park, true},
+		{"legacy_not_has", `["!has","name"]`, park, true},
+		{"legacy_lt", `["<","rank",3]`, park, true},
+		{"legacy_lt_mismatch", `["<","rank","3"]`, park, false},
+		{"legacy_all", `["all",["==","$type","Polygon"],["==","class","park"]]`, park, true},
+		{"legacy_none", `["none",["==","class","park"]]`, park, false},
+		{"expr_get", `["==",["get","class"],"park"]`, park, true},
+		{"expr_geometry_type", `["==",["geometry-type"],"MultiLineString"]`, road, true},
+		{"expr_id", `["==",["id"],8]`, road, true},
+		{"expr_match", `["match",["get","class"],["park","forest"],true,false]`, road, false},
+		{"expr_non_boolean", `["get","class"]`, park, false},
+		{"expr_missing_property", `["<",["get","x"],5]`, park, false},
+		{"expr_type_error", `["all",["==",["get","class"],"park"],[">",["get","name"],"a"]]`, park, false},
+		{"literal_true", `true`, road, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			layer := &Layer{ID: "test", Type: LayerTypeFill, Filter: &FilterContainer{}}
+			if err := json.Unmarshal([]byte(tc.filter), layer.Filter); err != nil {
+				t.Fatal(err)
+			}
+			got, err := layer.Matches(tc.feature, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterMalformed(t *testing.T) {
+	layer := &Layer{ID: "test", Type: LayerTypeFill, Filter: &FilterContainer{}}
+	if err := json.Unmarshal([]byte(`"park"`), layer.Filter); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := layer.Matches(&Feature{Type: "Polygon"}, 10); err == nil {
+		t.Fatal("expected error for a malformed filter")
+	}
+}
+
+func TestFilterCompiledOnce(t *testing.T) {
+	f := &FilterContainer{}
+	if err := json.Unmarshal([]byte(`["==","class","park"]`), f); err != nil {
+		t.Fatal(err)
+	}
+	park := &Feature{Properties: map[string]interface{}{"class": "park"}}
+	if ok, err := f.Evaluate(EvalContext{Feature: park}); err != nil || !ok {
+		t.Fatalf("first evaluation = %v, %v", ok, err)
+	}
+	compiled := f.compiled
+	if ok, _ := f.Evaluate(EvalContext{Feature: park}); !ok || f.compiled != compiled {
+		t.Fatal("filter was converted again")
+	}
+	if err := json.Unmarshal([]byte(`["==","class","forest"]`), f); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := f.Evaluate(EvalContext{Feature: park}); ok {
+		t.Fatal("replaced filter still uses the old conversion")
+	}
+}
+
+func TestLayerMatchesZoomRange(t *testing.T) {
+	layer := &Layer{ID: "test", Type: LayerTypeFill, MinZoom: ptr(5.0), MaxZoom: ptr(10.0)}
+	f := &Feature{Type: "Polygon"}
+	for zoom, want := range map[float64]bool{4.9: false, 5: true, 9.9: true, 10: false} {
+		got, err := layer.Matches(f, zoom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("zoom %v: expected %v, got %v", zoom, want, got)
+		}
+	}
+}
+
+func TestLayerMatchesNoFilter(t *testing.T) {
+	layer := &Layer{ID: "test", Type: LayerTypeFill}
+	ok, err := layer.Matches(&Feature{Type: "Point"}, 0)
+	if err != nil || !ok {
+		t.Fatalf("expected match without filter, got %v, %v", ok, err)
+	}
+}
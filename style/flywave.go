@@ -1,5 +1,10 @@
 package style
 
+import (
+	"encoding/json"
+	"image/color"
+)
+
 // FlywavePostEffects holds post-processing effects configuration (flywave extension).
 type FlywavePostEffects struct {
 	Bloom    *FlywaveBloom    `json:"bloom,omitempty"`
@@ -78,6 +83,141 @@ type FlywavePriority struct {
 	Category *string `json:"category,omitempty"`
 }
 
-type FlywaveDefinitions map[string]interface{}
+// Techniques a layer can be drawn with, as in flywave:technique.
+const (
+	FlywaveTechniqueNone                 = "none"
+	FlywaveTechniqueSquares              = "squares"
+	FlywaveTechniqueCircles              = "circles"
+	FlywaveTechniqueLabeledIcon          = "labeled-icon"
+	FlywaveTechniqueLineMarker           = "line-marker"
+	FlywaveTechniqueLine                 = "line"
+	FlywaveTechniqueSegments             = "segments"
+	FlywaveTechniqueSolidLine            = "solid-line"
+	FlywaveTechniqueDashedLine           = "dashed-line"
+	FlywaveTechniqueFill                 = "fill"
+	FlywaveTechniqueStandard             = "standard"
+	FlywaveTechniqueTerrain              = "terrain"
+	FlywaveTechniqueBasicExtrudedLine    = "basic-extruded-line"
+	FlywaveTechniqueStandardExtrudedLine = "standard-extruded-line"
+	FlywaveTechniqueExtrudedLine         = "extruded-line"
+	FlywaveTechniqueExtrudedPolygon      = "extruded-polygon"
+	FlywaveTechniqueShader               = "shader"
+	FlywaveTechniqueText                 = "text"
+)
+
+var flywaveTechniques = []string{
+	FlywaveTechniqueNone, FlywaveTechniqueSquares, FlywaveTechniqueCircles,
+	FlywaveTechniqueLabeledIcon, FlywaveTechniqueLineMarker, FlywaveTechniqueLine,
+	FlywaveTechniqueSegments, FlywaveTechniqueSolidLine, FlywaveTechniqueDashedLine,
+	FlywaveTechniqueFill, FlywaveTechniqueStandard, FlywaveTechniqueTerrain,
+	FlywaveTechniqueBasicExtrudedLine, FlywaveTechniqueStandardExtrudedLine,
+	FlywaveTechniqueExtrudedLine, FlywaveTechniqueExtrudedPolygon,
+	FlywaveTechniqueShader, FlywaveTechniqueText,
+}
+
+// Value types of typed definitions.
+const (
+	FlywaveTypeBoolean = "boolean"
+	FlywaveTypeNumber  = "number"
+	FlywaveTypeString  = "string"
+	FlywaveTypeColor   = "color"
+	FlywaveTypeVector2 = "vector2"
+	FlywaveTypeVector3 = "vector3"
+	FlywaveTypeVector4 = "vector4"
+)
+
+// FlywaveRef is the operator of a ["ref", name] lookup into
+// flywave:definitions.
+const FlywaveRef = "ref"
+
+// FlywaveDefinitions are the named values of flywave:definitions.
+type FlywaveDefinitions map[string]FlywaveDefinition
+
+// FlywaveDefinition is a named value that layers use through ["ref", name].
+// It is written either as a bare value or as an object with a value and an
+// optional type; the value may itself be a reference.
+type FlywaveDefinition struct {
+	Type        string      `json:"type,omitempty"`
+	Value       interface{} `json:"value"`
+	Description string      `json:"description,omitempty"`
+	// bare records that the definition was written as a plain value, so it
+	// is encoded the same way.
+	bare bool
+}
+
+func (d *FlywaveDefinition) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if obj, ok := v.(map[string]interface{}); ok {
+		if _, typed := obj["value"]; typed {
+			type plain FlywaveDefinition
+			var p plain
+			if err := json.Unmarshal(data, &p); err != nil {
+				return err
+			}
+			*d = FlywaveDefinition(p)
+			return nil
+		}
+	}
+	*d = FlywaveDefinition{Value: v, bare: true}
+	return nil
+}
+
+func (d FlywaveDefinition) MarshalJSON() ([]byte, error) {
+	if d.bare && d.Type == "" && d.Description == "" {
+		return json.Marshal(d.Value)
+	}
+	type plain FlywaveDefinition
+	return json.Marshal(plain(d))
+}
+
+// FlywaveShaderParams are the uniforms of flywave:shaderParams. Values are
+// numbers, booleans, color strings or numeric vectors.
+type FlywaveShaderParams map[string]interface{}
+
+// Number returns a numeric parameter.
+func (p FlywaveShaderParams) Number(name string) (float64, bool) {
+	v, ok := p[name].(float64)
+	return v, ok
+}
+
+// Bool returns a boolean parameter.
+func (p FlywaveShaderParams) Bool(name string) (bool, bool) {
+	v, ok := p[name].(bool)
+	return v, ok
+}
+
+// Color returns a color parameter.
+func (p FlywaveShaderParams) Color(name string) (color.Color, bool) {
+	s, ok := p[name].(string)
+	if !ok {
+		return nil, false
+	}
+	c, err := strToColor(s, defaultColorAlpha)
+	return c, err == nil
+}
+
+// Vector returns a numeric vector parameter.
+func (p FlywaveShaderParams) Vector(name string) ([]float64, bool) {
+	return numberSlice(p[name])
+}
+
+func numberSlice(v interface{}) ([]float64, bool) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	out := make([]float64, len(arr))
+	for i, item := range arr {
+		n, ok := item.(float64)
+		if !ok {
+			return nil, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
 
 
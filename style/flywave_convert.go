@@ -0,0 +1,442 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/flywave/go-mapbox/sprite"
+	"github.com/pkg/errors"
+)
+
+// FlywaveGlyphsCatalog names the font catalog ToFlywave creates from the
+// style's glyphs URL.
+const FlywaveGlyphsCatalog = "glyphs"
+
+// Text style defaults used when a symbol layer leaves text-font or
+// text-size unset.
+const (
+	flywaveDefaultFont = "Open Sans Regular"
+	flywaveDefaultSize = 16
+)
+
+// layerTechniques maps layer types to the technique drawing them. Types
+// missing here have no flywave equivalent.
+var layerTechniques = map[LayerType]string{
+	LayerTypeBackground:    FlywaveTechniqueNone,
+	LayerTypeFill:          FlywaveTechniqueFill,
+	LayerTypeLine:          FlywaveTechniqueSolidLine,
+	LayerTypeCircle:        FlywaveTechniqueCircles,
+	LayerTypeSymbol:        FlywaveTechniqueText,
+	LayerTypeFillExtrusion: FlywaveTechniqueExtrudedPolygon,
+	LayerTypeBuilding:      FlywaveTechniqueExtrudedPolygon,
+	LayerTypeHillshade:     FlywaveTechniqueTerrain,
+}
+
+// techniqueLayerTypes maps techniques to the layer type FromFlywave gives
+// layers without one.
+var techniqueLayerTypes = map[string]LayerType{
+	FlywaveTechniqueSquares:              LayerTypeCircle,
+	FlywaveTechniqueCircles:              LayerTypeCircle,
+	FlywaveTechniqueLabeledIcon:          LayerTypeSymbol,
+	FlywaveTechniqueLineMarker:           LayerTypeSymbol,
+	FlywaveTechniqueText:                 LayerTypeSymbol,
+	FlywaveTechniqueLine:                 LayerTypeLine,
+	FlywaveTechniqueSegments:             LayerTypeLine,
+	FlywaveTechniqueSolidLine:            LayerTypeLine,
+	FlywaveTechniqueDashedLine:           LayerTypeLine,
+	FlywaveTechniqueBasicExtrudedLine:    LayerTypeLine,
+	FlywaveTechniqueStandardExtrudedLine: LayerTypeLine,
+	FlywaveTechniqueExtrudedLine:         LayerTypeLine,
+	FlywaveTechniqueFill:                 LayerTypeFill,
+	FlywaveTechniqueStandard:             LayerTypeFill,
+	FlywaveTechniqueExtrudedPolygon:      LayerTypeFillExtrusion,
+	FlywaveTechniqueTerrain:              LayerTypeHillshade,
+}
+
+// UnmappedProperty is a property a conversion could not carry over.
+type UnmappedProperty struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// FlywaveReport lists what ToFlywave or FromFlywave could not map.
+type FlywaveReport struct {
+	Unmapped []UnmappedProperty `json:"unmapped,omitempty"`
+}
+
+func (r *FlywaveReport) add(path, format string, args ...interface{}) {
+	r.Unmapped = append(r.Unmapped, UnmappedProperty{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// FlywaveOptions control ToFlywave.
+type FlywaveOptions struct {
+	// StyleSet is the style set of every layer. By default a layer's style
+	// set is its source id.
+	StyleSet string
+	// Zoom is where zoom dependent text sizes and colors are sampled for
+	// text styles.
+	Zoom float64
+	// Sprite is the sprite index image textures are built from, as returned
+	// by sprite.GenerateSprite. Nil skips image textures.
+	Sprite map[string]*sprite.TextureSprite
+	// SpriteImage is the image the textures point into, by default the
+	// style's sprite URL with ".png".
+	SpriteImage string
+}
+
+// ToFlywave returns a copy of s as a flywave theme: every layer gets a
+// technique for its type, a style set and its render order, symbol layers
+// get a text style derived from text-font, text-size and the text paint
+// properties, and the sprite becomes image textures. The first background
+// layer sets the clear color.
+func (s *Style) ToFlywave(opts FlywaveOptions) (*Style, *FlywaveReport, error) {
+	out, err := cloneStyle(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &FlywaveReport{}
+	if out.Glyphs != "" && !out.hasFontCatalog(FlywaveGlyphsCatalog) {
+		out.FlywaveFontCatalogs = append(out.FlywaveFontCatalogs, FlywaveFontCatalog{Name: FlywaveGlyphsCatalog, URL: out.Glyphs})
+	}
+
+	clearSet := out.ClearColor != nil
+	for i, l := range out.Layers {
+		if l == nil {
+			continue
+		}
+		path := fmt.Sprintf("layers[%d]", i)
+		technique, ok := layerTechniques[l.Type]
+		if !ok {
+			technique = FlywaveTechniqueNone
+			report.add(path, "%s layers have no flywave technique", l.Type)
+		}
+		switch {
+		case l.Type == LayerTypeLine && l.Paint != nil && l.Paint.LineDashArray != nil:
+			technique = FlywaveTechniqueDashedLine
+		case l.Type == LayerTypeSymbol && l.Layout != nil && l.Layout.IconImage != nil:
+			technique = FlywaveTechniqueLabeledIcon
+			if placement, _ := l.Layout.SymbolPlacementAt(opts.Zoom, nil); placement != "point" {
+				technique = FlywaveTechniqueLineMarker
+			}
+		}
+		l.FlywaveTechnique = &technique
+		order := i
+		l.FlywaveRenderOrder = &order
+		if styleSet := opts.StyleSet; styleSet != "" {
+			l.FlywaveStyleSet = &styleSet
+		} else if l.Source != nil {
+			styleSet := *l.Source
+			l.FlywaveStyleSet = &styleSet
+		}
+
+		if l.Type == LayerTypeBackground && !clearSet && l.Paint != nil && l.Paint.BackgroundColor != nil {
+			c, constant := constantColor(l.Paint.BackgroundColor, opts.Zoom)
+			if !constant {
+				report.add(path+".paint.background-color", "clear color uses the value at zoom %g", opts.Zoom)
+			}
+			out.ClearColor = &c
+			clearSet = true
+		}
+		if l.Type == LayerTypeSymbol && l.Layout != nil && l.Layout.TextField != nil {
+			ts := out.textStyleFor(l, path, opts.Zoom, report)
+			out.FlywaveTextStyles = append(out.FlywaveTextStyles, ts)
+			l.FlywaveTextStyle = ts.Name
+		}
+	}
+
+	if opts.Sprite != nil {
+		image := opts.SpriteImage
+		if image == "" {
+			image = "sprite.png"
+			if out.Sprite != "" {
+				image = out.Sprite + ".png"
+			}
+		}
+		names := make([]string, 0, len(opts.Sprite))
+		for name := range opts.Sprite {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			icon := opts.Sprite[name]
+			if icon == nil || icon.TextureMeta == nil {
+				continue
+			}
+			x, y, w, h := icon.X, icon.Y, icon.Width, icon.Height
+			out.FlywaveImageTextures = append(out.FlywaveImageTextures, FlywaveImageTexture{
+				Name: name, Image: image, XOffset: &x, YOffset: &y, Width: &w, Height: &h,
+			})
+		}
+	}
+	return out, report, nil
+}
+
+// textStyleFor derives the text style of a symbol layer, named after it.
+func (s *Style) textStyleFor(l *Layer, path string, zoom float64, report *FlywaveReport) FlywaveTextStyle {
+	name := l.ID
+	font := flywaveDefaultFont
+	if len(l.Layout.TextFont) > 0 {
+		font = l.Layout.TextFont[0]
+		if len(l.Layout.TextFont) > 1 {
+			report.add(path+".layout.text-font", "only the first font %q of the fontstack is used", font)
+		}
+	}
+	size := flywaveDefaultSize
+	if l.Layout.TextSize != nil {
+		if _, constant := l.Layout.TextSize.(float64); !constant {
+			report.add(path+".layout.text-size", "text style uses the value at zoom %g", zoom)
+		}
+		if v, err := l.Layout.TextSizeAt(zoom, nil); err == nil {
+			size = int(math.Round(v))
+		}
+	}
+	ts := FlywaveTextStyle{Name: &name, FontName: &font, Size: &size}
+	if s.hasFontCatalog(FlywaveGlyphsCatalog) {
+		catalog := FlywaveGlyphsCatalog
+		ts.FontCatalogName = &catalog
+	}
+	if l.Paint == nil {
+		return ts
+	}
+	colors := []struct {
+		property string
+		value    *ColorType
+		field    **string
+	}{
+		{"text-color", l.Paint.TextColor, &ts.Color},
+		{"text-halo-color", l.Paint.TextHaloColor, &ts.BackgroundColor},
+	}
+	for _, c := range colors {
+		if c.value == nil {
+			continue
+		}
+		css, constant := constantColor(c.value, zoom)
+		if !constant {
+			report.add(path+".paint."+c.property, "text style uses the value at zoom %g", zoom)
+		}
+		*c.field = &css
+	}
+	if l.Paint.TextOpacity != nil {
+		if _, constant := l.Paint.TextOpacity.(float64); !constant {
+			report.add(path+".paint.text-opacity", "text style uses the value at zoom %g", zoom)
+		}
+		if v, err := l.Paint.TextOpacityAt(zoom, nil); err == nil {
+			ts.Opacity = &v
+		}
+	}
+	return ts
+}
+
+// constantColor returns the CSS form of a color property and whether it
+// is constant; other colors are sampled at zoom.
+func constantColor(c *ColorType, zoom float64) (string, bool) {
+	if plain, ok := c.internalType.(plainColorType); ok {
+		return plain.raw, true
+	}
+	return ColorToCSS(c.GetColorAtZoomLevel(ZoomLevel(zoom))), false
+}
+
+func (s *Style) hasFontCatalog(name string) bool {
+	for _, c := range s.FlywaveFontCatalogs {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Style) textStyle(name string) (FlywaveTextStyle, bool) {
+	for _, ts := range s.FlywaveTextStyles {
+		if ts.Name != nil && *ts.Name == name {
+			return ts, true
+		}
+	}
+	return FlywaveTextStyle{}, false
+}
+
+// FromFlywave returns a copy of the flywave theme s as a plain GL style.
+// Definitions are expanded, layers are ordered by their render order,
+// layers without a type get one from their technique, text styles are
+// written back to text-font, text-size and the text paint properties where
+// the layer does not set them, and the clear color becomes a background
+// layer. Every flywave property is removed; those without a GL equivalent
+// are listed in the report.
+func (s *Style) FromFlywave() (*Style, *FlywaveReport, error) {
+	out, err := cloneStyle(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := out.ExpandDefinitions(); err != nil {
+		return nil, nil, err
+	}
+	report := &FlywaveReport{}
+
+	// Paths in the report use the layer indices of s.
+	index := make(map[*Layer]int, len(out.Layers))
+	order := make(map[*Layer]int, len(out.Layers))
+	for i, l := range out.Layers {
+		index[l], order[l] = i, i
+		if l != nil && l.FlywaveRenderOrder != nil {
+			order[l] = *l.FlywaveRenderOrder
+		}
+	}
+	sort.SliceStable(out.Layers, func(i, j int) bool {
+		return order[out.Layers[i]] < order[out.Layers[j]]
+	})
+
+	var layers []*Layer
+	for _, l := range out.Layers {
+		if l == nil {
+			continue
+		}
+		path := fmt.Sprintf("layers[%d]", index[l])
+		if l.Type == "" {
+			technique := ""
+			if l.FlywaveTechnique != nil {
+				technique = *l.FlywaveTechnique
+			}
+			t, ok := techniqueLayerTypes[technique]
+			if !ok {
+				report.add(path, "layer %q has no type and technique %q has no GL equivalent; layer dropped", l.ID, technique)
+				continue
+			}
+			l.Type = t
+		}
+		if l.FlywaveTextStyle != nil {
+			if ts, ok := out.textStyle(*l.FlywaveTextStyle); ok {
+				if err := applyTextStyle(l, ts, path, report); err != nil {
+					return nil, nil, errors.Wrapf(err, "layer %q", l.ID)
+				}
+			} else {
+				report.add(path+".flywave:textStyle", "unknown text style %q", *l.FlywaveTextStyle)
+			}
+		}
+		reportFlywaveFields(reflect.ValueOf(l).Elem(), path, layerMappedKeys, report)
+		clearFlywaveFields(reflect.ValueOf(l).Elem())
+		layers = append(layers, l)
+	}
+	out.Layers = layers
+
+	if out.ClearColor != nil && (out.ClearAlpha == nil || *out.ClearAlpha != 0) {
+		if len(out.Layers) == 0 || out.Layers[0].Type != LayerTypeBackground {
+			c, err := parseColorType(*out.ClearColor)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "flywave:clearColor")
+			}
+			bg := &Layer{ID: backgroundLayerID, Type: LayerTypeBackground, Paint: &Paint{BackgroundColor: c}}
+			out.Layers = append([]*Layer{bg}, out.Layers...)
+		}
+	}
+	if out.Glyphs == "" {
+		for _, c := range out.FlywaveFontCatalogs {
+			if c.Name == FlywaveGlyphsCatalog {
+				out.Glyphs = c.URL
+			}
+		}
+	}
+	styleMapped := map[string]bool{
+		"flywave:clearColor":  true,
+		"flywave:clearAlpha":  true,
+		"flywave:definitions": true,
+		"flywave:textStyles":  true,
+	}
+	if len(out.FlywaveFontCatalogs) == 1 && out.FlywaveFontCatalogs[0].Name == FlywaveGlyphsCatalog {
+		styleMapped["flywave:fontCatalogs"] = true
+	}
+	reportFlywaveFields(reflect.ValueOf(out).Elem(), "", styleMapped, report)
+	clearFlywaveFields(reflect.ValueOf(out).Elem())
+	return out, report, nil
+}
+
+// layerMappedKeys are the layer properties FromFlywave maps to GL or that
+// carry no information GL lacks.
+var layerMappedKeys = map[string]bool{
+	"flywave:technique":   true,
+	"flywave:styleSet":    true,
+	"flywave:renderOrder": true,
+	"flywave:textStyle":   true,
+}
+
+// applyTextStyle writes a text style to the text properties the layer does
+// not set.
+func applyTextStyle(l *Layer, ts FlywaveTextStyle, path string, report *FlywaveReport) error {
+	if l.Layout == nil {
+		l.Layout = &Layout{}
+	}
+	if l.Paint == nil {
+		l.Paint = &Paint{}
+	}
+	if ts.FontName != nil && len(l.Layout.TextFont) == 0 {
+		l.Layout.TextFont = []string{*ts.FontName}
+	}
+	if ts.Size != nil && l.Layout.TextSize == nil {
+		l.Layout.TextSize = float64(*ts.Size)
+	}
+	if ts.Opacity != nil && l.Paint.TextOpacity == nil {
+		l.Paint.TextOpacity = *ts.Opacity
+	}
+	colors := []struct {
+		value *string
+		field **ColorType
+	}{
+		{ts.Color, &l.Paint.TextColor},
+		{ts.BackgroundColor, &l.Paint.TextHaloColor},
+	}
+	for _, c := range colors {
+		if c.value == nil || *c.field != nil {
+			continue
+		}
+		ct, err := parseColorType(*c.value)
+		if err != nil {
+			return err
+		}
+		*c.field = ct
+	}
+	if ts.BackgroundOpacity != nil {
+		report.add(path+".flywave:textStyle", "backgroundOpacity of text style %q has no GL equivalent", *ts.Name)
+	}
+	return nil
+}
+
+// parseColorType decodes a CSS color string, keeping its original form.
+func parseColorType(css string) (*ColorType, error) {
+	data, err := json.Marshal(css)
+	if err != nil {
+		return nil, err
+	}
+	var c ColorType
+	if err := c.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// flywaveFields calls fn for every flywave property of a Style or Layer
+// value.
+func flywaveFields(v reflect.Value, fn func(name string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if strings.HasPrefix(name, flywavePrefix) {
+			fn(name, v.Field(i))
+		}
+	}
+}
+
+func reportFlywaveFields(v reflect.Value, path string, mapped map[string]bool, report *FlywaveReport) {
+	flywaveFields(v, func(name string, field reflect.Value) {
+		if !field.IsZero() && !mapped[name] {
+			report.add(joinPath(path, name), "no GL equivalent")
+		}
+	})
+}
+
+func clearFlywaveFields(v reflect.Value) {
+	flywaveFields(v, func(_ string, field reflect.Value) {
+		field.Set(reflect.Zero(field.Type()))
+	})
+}
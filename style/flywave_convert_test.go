@@ -0,0 +1,154 @@
+package style
+
+import (
+	"testing"
+
+	"github.com/flywave/go-mapbox/sprite"
+)
+
+const glStyle = `{
+	"version": 8,
+	"glyphs": "https://example.com/fonts/{fontstack}/{range}.pbf",
+	"sprite": "https://example.com/sprite",
+	"sources": {"osm": {"type": "vector"}, "dem": {"type": "raster-dem"}},
+	"layers": [
+		{"id": "bg", "type": "background", "paint": {"background-color": "#f0f0f0"}},
+		{"id": "water", "type": "fill", "source": "osm", "source-layer": "water", "paint": {"fill-color": "#0000ff"}},
+		{"id": "paths", "type": "line", "source": "osm", "source-layer": "roads", "paint": {"line-dasharray": [2, 1]}},
+		{"id": "heat", "type": "heatmap", "source": "osm", "source-layer": "pois"},
+		{"id": "shops", "type": "symbol", "source": "osm", "source-layer": "pois",
+		 "layout": {"icon-image": "shop", "text-field": "{name}", "text-font": ["Noto Sans Bold"],
+			"text-size": ["interpolate", ["linear"], ["zoom"], 10, 12, 20, 24]},
+		 "paint": {"text-color": "#333333", "text-halo-color": "#ffffff", "text-opacity": 0.9}}
+	]
+}`
+
+func TestToFlywave(t *testing.T) {
+	s := parseStyle(t, glStyle)
+	index := map[string]*sprite.TextureSprite{
+		"shop": {TextureMeta: &sprite.TextureMeta{Width: 16, Height: 16, PixelRatio: 1}, X: 32, Y: 0},
+	}
+	fw, report, err := s.ToFlywave(FlywaveOptions{Zoom: 15, Sprite: index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTechniques := []string{"none", "fill", "dashed-line", "none", "labeled-icon"}
+	for i, l := range fw.Layers {
+		if l.FlywaveTechnique == nil || *l.FlywaveTechnique != wantTechniques[i] {
+			t.Errorf("layer %s technique = %v", l.ID, l.FlywaveTechnique)
+		}
+		if l.FlywaveRenderOrder == nil || *l.FlywaveRenderOrder != i {
+			t.Errorf("layer %s render order = %v", l.ID, l.FlywaveRenderOrder)
+		}
+	}
+	if fw.Layers[0].FlywaveStyleSet != nil || *fw.Layers[1].FlywaveStyleSet != "osm" {
+		t.Errorf("style sets = %v, %v", fw.Layers[0].FlywaveStyleSet, fw.Layers[1].FlywaveStyleSet)
+	}
+	if fw.ClearColor == nil || *fw.ClearColor != "#f0f0f0" {
+		t.Errorf("clear color = %v", fw.ClearColor)
+	}
+	if s.Layers[1].FlywaveTechnique != nil {
+		t.Error("ToFlywave modified its input")
+	}
+
+	if len(fw.FlywaveTextStyles) != 1 {
+		t.Fatalf("text styles = %+v", fw.FlywaveTextStyles)
+	}
+	ts := fw.FlywaveTextStyles[0]
+	if *ts.Name != "shops" || *ts.FontName != "Noto Sans Bold" || *ts.Size != 18 || *ts.Color != "#333333" ||
+		*ts.BackgroundColor != "#ffffff" || *ts.Opacity != 0.9 || *ts.FontCatalogName != FlywaveGlyphsCatalog {
+		t.Errorf("text style = %+v", ts)
+	}
+	if *fw.Layers[4].FlywaveTextStyle != "shops" {
+		t.Errorf("layer text style = %v", fw.Layers[4].FlywaveTextStyle)
+	}
+	if len(fw.FlywaveImageTextures) != 1 {
+		t.Fatalf("image textures = %+v", fw.FlywaveImageTextures)
+	}
+	tex := fw.FlywaveImageTextures[0]
+	if tex.Name != "shop" || tex.Image != "https://example.com/sprite.png" || *tex.XOffset != 32 || *tex.Width != 16 {
+		t.Errorf("image texture = %+v", tex)
+	}
+
+	want := []UnmappedProperty{
+		{"layers[3]", "heatmap layers have no flywave technique"},
+		{"layers[4].layout.text-size", "text style uses the value at zoom 15"},
+	}
+	if len(report.Unmapped) != len(want) {
+		t.Fatalf("report = %+v", report.Unmapped)
+	}
+	for i, u := range report.Unmapped {
+		if u != want[i] {
+			t.Errorf("unmapped %d = %+v, want %+v", i, u, want[i])
+		}
+	}
+	if errs := fw.ValidateFlywave(); len(errs) != 0 {
+		t.Errorf("converted theme is invalid: %v", errs)
+	}
+}
+
+func TestFromFlywave(t *testing.T) {
+	s := parseStyle(t, `{
+		"version": 8,
+		"sources": {"osm": {"type": "vector"}},
+		"flywave:clearColor": "#101010",
+		"flywave:enableShadows": true,
+		"flywave:definitions": {"roadColor": "#ff8800"},
+		"flywave:fontCatalogs": [{"name": "glyphs", "url": "https://example.com/{fontstack}/{range}.pbf"}],
+		"flywave:textStyles": [{"name": "labels", "fontName": "Noto Sans", "size": 14, "color": "#222222", "backgroundOpacity": 0.5}],
+		"layers": [
+			{"id": "labels", "type": "symbol", "source": "osm", "flywave:renderOrder": 2,
+			 "flywave:textStyle": "labels", "layout": {"text-field": "{name}", "text-size": 20}},
+			{"id": "roads", "source": "osm", "flywave:technique": "solid-line", "flywave:renderOrder": 1,
+			 "flywave:shaderParams": {"glow": 1}, "paint": {"line-color": ["ref", "roadColor"]}},
+			{"id": "custom", "source": "osm", "flywave:technique": "shader"}
+		]
+	}`)
+	gl, report, err := s.FromFlywave()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{"background", "roads", "labels"}
+	if len(gl.Layers) != len(ids) {
+		t.Fatalf("layers = %d", len(gl.Layers))
+	}
+	for i, l := range gl.Layers {
+		if l.ID != ids[i] {
+			t.Errorf("layer %d = %s, want %s", i, l.ID, ids[i])
+		}
+		if l.FlywaveTechnique != nil || l.FlywaveRenderOrder != nil || l.FlywaveShaderParams != nil || l.FlywaveTextStyle != nil {
+			t.Errorf("layer %s keeps flywave properties", l.ID)
+		}
+	}
+	if c := gl.Layers[0].Paint.BackgroundColor; ColorToCSS(c.GetColorAtZoomLevel(0)) != "#101010" {
+		t.Errorf("background = %v", c)
+	}
+	roads := gl.Layers[1]
+	if roads.Type != LayerTypeLine || ColorToCSS(roads.Paint.LineColor.GetColorAtZoomLevel(0)) != "#ff8800" {
+		t.Errorf("roads = %+v", roads)
+	}
+	labels := gl.Layers[2]
+	if len(labels.Layout.TextFont) != 1 || labels.Layout.TextFont[0] != "Noto Sans" || labels.Layout.TextSize != 20.0 ||
+		ColorToCSS(labels.Paint.TextColor.GetColorAtZoomLevel(0)) != "#222222" {
+		t.Errorf("labels = %+v %+v", labels.Layout, labels.Paint)
+	}
+	if gl.Glyphs != "https://example.com/{fontstack}/{range}.pbf" || gl.ClearColor != nil || gl.FlywaveTextStyles != nil ||
+		gl.EnableShadows != nil || gl.FlywaveDefinitions != nil || gl.FlywaveFontCatalogs != nil {
+		t.Errorf("style keeps flywave properties: %+v", gl)
+	}
+
+	want := []UnmappedProperty{
+		{"layers[1].flywave:shaderParams", "no GL equivalent"},
+		{"layers[0].flywave:textStyle", `backgroundOpacity of text style "labels" has no GL equivalent`},
+		{"layers[2]", `layer "custom" has no type and technique "shader" has no GL equivalent; layer dropped`},
+		{"flywave:enableShadows", "no GL equivalent"},
+	}
+	if len(report.Unmapped) != len(want) {
+		t.Fatalf("report = %+v", report.Unmapped)
+	}
+	for i, u := range report.Unmapped {
+		if u != want[i] {
+			t.Errorf("unmapped %d = %+v, want %+v", i, u, want[i])
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package style
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Resolve returns the value of the named definition with every reference
+// in it replaced by the value it refers to.
+func (d FlywaveDefinitions) Resolve(name string) (interface{}, error) {
+	return d.resolve(name, nil)
+}
+
+func (d FlywaveDefinitions) resolve(name string, stack []string) (interface{}, error) {
+	for _, n := range stack {
+		if n == name {
+			return nil, errors.Errorf("definition cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	def, ok := d[name]
+	if !ok {
+		return nil, errors.Errorf("unknown definition %q", name)
+	}
+	stack = append(stack, name)
+	return walkRefs(def.Value, "", func(ref, _ string) (interface{}, error) {
+		return d.resolve(ref, stack)
+	})
+}
+
+// flywaveRefName returns the definition name of a ["ref", name] lookup.
+func flywaveRefName(v []interface{}) (string, bool) {
+	if len(v) != 2 {
+		return "", false
+	}
+	if op, ok := v[0].(string); !ok || op != FlywaveRef {
+		return "", false
+	}
+	name, ok := v[1].(string)
+	return name, ok
+}
+
+// walkRefs returns a copy of the decoded JSON value v with every reference
+// replaced by the result of fn, which gets the definition name and the path
+// of the reference.
+func walkRefs(v interface{}, path string, fn func(name, path string) (interface{}, error)) (interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		if name, ok := flywaveRefName(t); ok {
+			return fn(name, path)
+		}
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			next, err := walkRefs(item, fmt.Sprintf("%s[%d]", path, i), fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = next
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for _, k := range sortedKeys(t) {
+			next, err := walkRefs(t[k], joinPath(path, k), fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = next
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// hasFlywaveRef reports whether a decoded JSON value contains a reference.
+func hasFlywaveRef(v interface{}) bool {
+	found := false
+	walkRefs(v, "", func(string, string) (interface{}, error) {
+		found = true
+		return nil, nil
+	})
+	return found
+}
+
+// layerRefMap returns the properties of a layer that may hold references,
+// which is all of them but its metadata.
+func layerRefMap(l *Layer) (map[string]interface{}, error) {
+	raw, err := propertyMap(l)
+	if err != nil {
+		return nil, err
+	}
+	delete(raw, "metadata")
+	return raw, nil
+}
+
+// ExpandDefinitions replaces every ["ref", name] in the layers by the value
+// of the named flywave definition, so renderers only see concrete values.
+// Parse expands definitions on load, before the layers are decoded, so
+// references may also stand for values of typed fields such as line-cap or
+// line-dasharray; styles built or decoded otherwise can be expanded with
+// this method. An unknown or cyclic reference is reported as a
+// ValidationError with the path of the reference.
+func (s *Style) ExpandDefinitions() error {
+	var defs FlywaveDefinitions
+	if s.FlywaveDefinitions != nil {
+		defs = *s.FlywaveDefinitions
+	}
+	for i, l := range s.Layers {
+		if l == nil {
+			continue
+		}
+		raw, err := propertyMap(l)
+		if err != nil {
+			return err
+		}
+		next, expanded, err := defs.expandLayer(raw, fmt.Sprintf("layers[%d]", i))
+		if err != nil {
+			return err
+		}
+		if !expanded {
+			continue
+		}
+		data, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+		var out Layer
+		if err := json.Unmarshal(data, &out); err != nil {
+			return errors.Wrapf(err, "layer %q", l.ID)
+		}
+		s.Layers[i] = &out
+	}
+	return nil
+}
+
+// expandLayer returns a copy of the decoded layer raw with its references
+// expanded, and whether it had any. Metadata is left as it is.
+func (d FlywaveDefinitions) expandLayer(raw map[string]interface{}, path string) (map[string]interface{}, bool, error) {
+	props := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if k != "metadata" {
+			props[k] = v
+		}
+	}
+	if !hasFlywaveRef(props) {
+		return raw, false, nil
+	}
+	expanded, err := walkRefs(props, path, func(name, path string) (interface{}, error) {
+		v, err := d.Resolve(name)
+		if err != nil {
+			return nil, &ValidationError{Path: path, Message: err.Error()}
+		}
+		return v, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	next := expanded.(map[string]interface{})
+	if metadata, ok := raw["metadata"]; ok {
+		next["metadata"] = metadata
+	}
+	return next, true, nil
+}
+
+// expandDocument expands the references in the layers of a style document
+// before it is decoded. Documents without references are returned as they
+// are.
+func expandDocument(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte(`"`+FlywaveRef+`"`)) {
+		return data, nil
+	}
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var defs FlywaveDefinitions
+	if raw, ok := doc["flywave:definitions"]; ok {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &defs); err != nil {
+			return nil, errors.Wrap(err, "flywave:definitions")
+		}
+	}
+	layers, _ := doc["layers"].([]interface{})
+	changed := false
+	for i, l := range layers {
+		raw, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		next, expanded, err := defs.expandLayer(raw, fmt.Sprintf("layers[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		if expanded {
+			layers[i] = next
+			changed = true
+		}
+	}
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(doc)
+}
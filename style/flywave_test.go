@@ -0,0 +1,176 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+const flywaveStyle = `{
+	"version": 8,
+	"sources": {"tilezen": {"type": "vector"}},
+	"flywave:definitions": {
+		"roadColor": {"type": "color", "value": "#ff0000"},
+		"roadWidth": 2,
+		"casingWidth": ["ref", "roadWidth"],
+		"sunDir": {"type": "vector3", "value": [0, 0, 1]}
+	},
+	"layers": [
+		{"id": "roads", "type": "line", "source": "tilezen", "source-layer": "roads",
+		 "flywave:styleSet": "tilezen", "flywave:technique": "solid-line",
+		 "flywave:shaderParams": {"sun": ["ref", "sunDir"], "glow": 0.5},
+		 "metadata": {"note": ["ref", "kept"]},
+		 "paint": {"line-color": ["ref", "roadColor"],
+			"line-width": ["interpolate", ["linear"], ["zoom"], 5, ["ref", "casingWidth"], 10, 8]}}
+	]
+}`
+
+func TestFlywaveDefinitionJSON(t *testing.T) {
+	var defs FlywaveDefinitions
+	raw := `{"a": "#fff", "b": {"type": "number", "value": 1, "description": "width"}, "c": {"x": 1}}`
+	if err := json.Unmarshal([]byte(raw), &defs); err != nil {
+		t.Fatal(err)
+	}
+	if defs["a"].Value != "#fff" || defs["b"].Type != FlywaveTypeNumber || defs["b"].Value != 1.0 {
+		t.Errorf("definitions = %+v", defs)
+	}
+	if m, ok := defs["c"].Value.(map[string]interface{}); !ok || m["x"] != 1.0 {
+		t.Errorf("object without value = %+v", defs["c"])
+	}
+	out, err := json.Marshal(defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(string(out), raw) {
+		t.Errorf("round trip = %s", out)
+	}
+}
+
+func TestParseExpandsDefinitions(t *testing.T) {
+	gl, err := Parse(strings.NewReader(flywaveStyle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := gl.style.Layers[0]
+	c, err := l.Paint.LineColor.Evaluate(0, nil)
+	if err != nil || c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("line-color = %v, %v", c, err)
+	}
+	if w, err := l.Paint.LineWidthAt(5, nil); err != nil || w != 2 {
+		t.Errorf("line-width = %v, %v", w, err)
+	}
+	if v, ok := l.FlywaveShaderParams.Vector("sun"); !ok || len(v) != 3 || v[2] != 1 {
+		t.Errorf("sun = %v", l.FlywaveShaderParams["sun"])
+	}
+	if n, ok := l.FlywaveShaderParams.Number("glow"); !ok || n != 0.5 {
+		t.Errorf("glow = %v", n)
+	}
+	if note, _ := json.Marshal(l.Metadata["note"]); string(note) != `["ref","kept"]` {
+		t.Errorf("metadata rewritten: %s", note)
+	}
+
+	_, err = Parse(strings.NewReader(`{"version": 8, "sources": {}, "layers": [
+		{"id": "a", "type": "fill", "paint": {"fill-color": ["ref", "missing"]}}]}`))
+	if err == nil || err.Error() != `layers[0].paint.fill-color: unknown definition "missing"` {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestParseExpandsTypedFieldDefinitions(t *testing.T) {
+	gl, err := Parse(strings.NewReader(`{"version": 8, "sources": {},
+		"flywave:definitions": {"cap": "round", "dash": [2, 1], "footprint": true},
+		"layers": [
+			{"id": "plain", "type": "background"},
+			{"id": "roads", "type": "line", "flywave:footprint": ["ref", "footprint"],
+			 "layout": {"line-cap": ["ref", "cap"]},
+			 "paint": {"line-dasharray": ["ref", "dash"]}}
+		]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := gl.style.Layers[1]
+	if l.Layout.LineCap != "round" {
+		t.Errorf("line-cap = %q", l.Layout.LineCap)
+	}
+	if len(l.Paint.LineDashArray) != 2 || l.Paint.LineDashArray[0] != 2 || l.Paint.LineDashArray[1] != 1 {
+		t.Errorf("line-dasharray = %v", l.Paint.LineDashArray)
+	}
+	if l.FlywaveFootprint == nil || !*l.FlywaveFootprint {
+		t.Errorf("flywave:footprint = %v", l.FlywaveFootprint)
+	}
+
+	_, err = Parse(strings.NewReader(`{"version": 8, "sources": {}, "layers": [
+		{"id": "a", "type": "line", "layout": {"line-cap": ["ref", "missing"]}}]}`))
+	if err == nil || err.Error() != `layers[0].layout.line-cap: unknown definition "missing"` {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFlywaveDefinitionsResolveCycle(t *testing.T) {
+	defs := FlywaveDefinitions{
+		"a": {Value: []interface{}{"ref", "b"}},
+		"b": {Value: []interface{}{"ref", "a"}},
+	}
+	if _, err := defs.Resolve("a"); err == nil || err.Error() != "definition cycle: a -> b -> a" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidateFlywave(t *testing.T) {
+	s := parseStyle(t, flywaveStyle)
+	if errs := s.ValidateFlywave(); len(errs) != 0 {
+		t.Fatalf("valid style: %v", errs)
+	}
+
+	s = parseStyle(t, `{
+		"version": 8,
+		"sources": {"tilezen": {"type": "vector"}},
+		"flywave:clearColor": "nope",
+		"flywave:definitions": {
+			"w": {"type": "number", "value": "wide"},
+			"v": {"type": "vector2", "value": [1, 2, 3]},
+			"loop": ["ref", "loop"]
+		},
+		"flywave:fontCatalogs": [{"name": "sans", "url": "sans.json"}, {"name": "sans", "url": ""}],
+		"flywave:textStyles": [{"name": "label", "fontCatalogName": "serif"}, {"name": "label", "color": "#zz"}],
+		"flywave:imageTextures": [{"name": "poi-shop", "image": "shop", "origin": "center"}],
+		"flywave:priorities": [{"group": "roads", "category": "highway"}],
+		"layers": [
+			{"id": "a", "type": "line", "source": "tilezen",
+			 "flywave:technique": "laser", "flywave:styleSet": "osm", "flywave:category": "path",
+			 "flywave:imageTexturePrefix": "icon-",
+			 "flywave:shaderParams": {"bad": {"x": 1}, "ok": [1, 2]},
+			 "paint": {"line-color": ["ref", "nope"]}},
+			{"id": "b", "type": "line", "source": "tilezen", "flywave:category": "highway",
+			 "flywave:imageTexturePrefix": "poi-"}
+		]
+	}`)
+	want := []string{
+		`flywave:definitions.loop: definition cycle: loop -> loop`,
+		`flywave:definitions.v: value [1,2,3] is not a vector2`,
+		`flywave:definitions.w: value "wide" is not a number`,
+		`flywave:clearColor: `,
+		`flywave:fontCatalogs[1].name: duplicate font catalog "sans", previously declared at flywave:fontCatalogs[0]`,
+		`flywave:fontCatalogs[1].url: url is required`,
+		`flywave:textStyles[0].fontCatalogName: unknown font catalog "serif"`,
+		`flywave:textStyles[1].name: duplicate text style "label", previously declared at flywave:textStyles[0]`,
+		`flywave:textStyles[1].color: `,
+		`flywave:imageTextures[0].origin: unknown origin "center", expected one of topleft, bottomleft`,
+		`layers[0].paint.line-color: unknown definition "nope"`,
+		`layers[0].flywave:technique: unknown technique "laser"`,
+		`layers[0].flywave:styleSet: style set "osm" does not name a source`,
+		`layers[0].flywave:category: category "path" is not listed in flywave:priorities or flywave:labelPriorities`,
+		`layers[0].flywave:imageTexturePrefix: no image texture matches prefix "icon-" and postfix ""`,
+		`layers[0].flywave:shaderParams.bad: shader parameter must be a number, boolean, string or numeric vector`,
+	}
+	errs := s.ValidateFlywave()
+	if len(errs) != len(want) {
+		t.Fatalf("errors:\n%v", errs)
+	}
+	for i, e := range errs {
+		if !strings.HasPrefix(e.Error(), want[i]) {
+			t.Errorf("error %d = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+}
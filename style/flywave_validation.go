@@ -0,0 +1,266 @@
+package style
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Image texture origins, as in flywave:imageTextures.
+var flywaveOrigins = []string{"topleft", "bottomleft"}
+
+// ValidateFlywave checks the flywave extensions of the style:
+//
+//   - definitions must have values of their declared type, and every
+//     ["ref", name] must name a definition without forming a cycle;
+//   - layer techniques must be known, style sets must name a source and
+//     categories must be listed in flywave:priorities or
+//     flywave:labelPriorities;
+//   - image texture prefixes and postfixes must match a declared image
+//     texture, layers must use declared text styles and text styles must
+//     use a declared font catalog;
+//   - text styles, font catalogs and image textures need unique names.
+func (s *Style) ValidateFlywave() ValidationErrors {
+	var errs ValidationErrors
+	var defs FlywaveDefinitions
+	if s.FlywaveDefinitions != nil {
+		defs = *s.FlywaveDefinitions
+	}
+	for _, name := range sortedDefinitionNames(defs) {
+		path := "flywave:definitions." + name
+		v, err := defs.Resolve(name)
+		if err != nil {
+			errs.add(path, "%v", err)
+			continue
+		}
+		if t := defs[name].Type; t != "" {
+			if err := checkFlywaveType(v, t); err != "" {
+				errs.add(path, "%s", err)
+			}
+		}
+	}
+	if s.ClearColor != nil {
+		if _, err := strToColor(*s.ClearColor, defaultColorAlpha); err != nil {
+			errs.add("flywave:clearColor", "%v", err)
+		}
+	}
+
+	catalogs := map[string]bool{}
+	seen := map[string]int{}
+	for i, c := range s.FlywaveFontCatalogs {
+		path := fmt.Sprintf("flywave:fontCatalogs[%d]", i)
+		if c.Name == "" {
+			errs.add(path+".name", "name is required")
+		} else if first, dup := seen[c.Name]; dup {
+			errs.add(path+".name", "duplicate font catalog %q, previously declared at flywave:fontCatalogs[%d]", c.Name, first)
+		} else {
+			seen[c.Name] = i
+		}
+		if c.URL == "" {
+			errs.add(path+".url", "url is required")
+		}
+		catalogs[c.Name] = true
+	}
+
+	seen = map[string]int{}
+	for i, ts := range s.FlywaveTextStyles {
+		path := fmt.Sprintf("flywave:textStyles[%d]", i)
+		if ts.Name != nil {
+			if first, dup := seen[*ts.Name]; dup {
+				errs.add(path+".name", "duplicate text style %q, previously declared at flywave:textStyles[%d]", *ts.Name, first)
+			} else {
+				seen[*ts.Name] = i
+			}
+		}
+		if ts.FontCatalogName != nil && !catalogs[*ts.FontCatalogName] {
+			errs.add(path+".fontCatalogName", "unknown font catalog %q", *ts.FontCatalogName)
+		}
+		colors := []struct {
+			key   string
+			value *string
+		}{{"color", ts.Color}, {"backgroundColor", ts.BackgroundColor}}
+		for _, c := range colors {
+			if c.value == nil {
+				continue
+			}
+			if _, err := strToColor(*c.value, defaultColorAlpha); err != nil {
+				errs.add(path+"."+c.key, "%v", err)
+			}
+		}
+	}
+
+	seen = map[string]int{}
+	for i, tex := range s.FlywaveImageTextures {
+		path := fmt.Sprintf("flywave:imageTextures[%d]", i)
+		if tex.Name == "" {
+			errs.add(path+".name", "name is required")
+		} else if first, dup := seen[tex.Name]; dup {
+			errs.add(path+".name", "duplicate image texture %q, previously declared at flywave:imageTextures[%d]", tex.Name, first)
+		} else {
+			seen[tex.Name] = i
+		}
+		if tex.Image == "" {
+			errs.add(path+".image", "image is required")
+		}
+		if tex.Origin != nil && !containsString(flywaveOrigins, *tex.Origin) {
+			errs.add(path+".origin", "unknown origin %q, expected one of %s", *tex.Origin, strings.Join(flywaveOrigins, ", "))
+		}
+	}
+
+	for i, p := range s.FlywavePriorities {
+		if p.Group == "" {
+			errs.add(fmt.Sprintf("flywave:priorities[%d].group", i), "group is required")
+		}
+	}
+
+	for i, l := range s.Layers {
+		if l == nil {
+			continue
+		}
+		for _, e := range s.validateFlywaveLayer(l, defs) {
+			errs = append(errs, &ValidationError{Path: joinPath(fmt.Sprintf("layers[%d]", i), e.Path), Message: e.Message})
+		}
+	}
+	return errs
+}
+
+// validateFlywaveLayer checks the flywave properties of a layer, with paths
+// relative to the layer.
+func (s *Style) validateFlywaveLayer(l *Layer, defs FlywaveDefinitions) ValidationErrors {
+	var errs ValidationErrors
+	if raw, err := layerRefMap(l); err != nil {
+		errs.add("", "%v", err)
+	} else {
+		walkRefs(raw, "", func(name, path string) (interface{}, error) {
+			if _, err := defs.Resolve(name); err != nil {
+				errs.add(path, "%v", err)
+			}
+			return nil, nil
+		})
+	}
+
+	if l.FlywaveTechnique != nil && !containsString(flywaveTechniques, *l.FlywaveTechnique) {
+		errs.add("flywave:technique", "unknown technique %q", *l.FlywaveTechnique)
+	}
+	if l.FlywaveStyleSet != nil {
+		if _, ok := s.Sources[*l.FlywaveStyleSet]; !ok {
+			errs.add("flywave:styleSet", "style set %q does not name a source", *l.FlywaveStyleSet)
+		}
+	}
+	if l.FlywaveCategory != nil && !s.hasFlywaveCategory(*l.FlywaveCategory) {
+		errs.add("flywave:category", "category %q is not listed in flywave:priorities or flywave:labelPriorities", *l.FlywaveCategory)
+	}
+
+	if l.FlywaveTextStyle != nil {
+		if _, ok := s.textStyle(*l.FlywaveTextStyle); !ok {
+			errs.add("flywave:textStyle", "unknown text style %q", *l.FlywaveTextStyle)
+		}
+	}
+
+	prefix, postfix := "", ""
+	if l.FlywaveImageTexturePrefix != nil {
+		prefix = *l.FlywaveImageTexturePrefix
+	}
+	if l.FlywaveImageTexturePostfix != nil {
+		postfix = *l.FlywaveImageTexturePostfix
+	}
+	if prefix != "" || postfix != "" {
+		matched := false
+		for _, tex := range s.FlywaveImageTextures {
+			if strings.HasPrefix(tex.Name, prefix) && strings.HasSuffix(tex.Name, postfix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			key := "flywave:imageTexturePrefix"
+			if prefix == "" {
+				key = "flywave:imageTexturePostfix"
+			}
+			errs.add(key, "no image texture matches prefix %q and postfix %q", prefix, postfix)
+		}
+	}
+
+	for _, name := range sortedKeys(l.FlywaveShaderParams) {
+		v := l.FlywaveShaderParams[name]
+		if arr, ok := v.([]interface{}); ok {
+			if _, ref := flywaveRefName(arr); ref {
+				continue
+			}
+		}
+		if !validShaderParam(v) {
+			errs.add("flywave:shaderParams."+name, "shader parameter must be a number, boolean, string or numeric vector")
+		}
+	}
+	return errs
+}
+
+func (s *Style) hasFlywaveCategory(category string) bool {
+	for _, p := range s.FlywavePriorities {
+		if p.Category != nil && *p.Category == category {
+			return true
+		}
+	}
+	return containsString(s.FlywaveLabelPriorities, category)
+}
+
+func validShaderParam(v interface{}) bool {
+	switch v.(type) {
+	case float64, bool, string:
+		return true
+	}
+	_, ok := numberSlice(v)
+	return ok
+}
+
+// checkFlywaveType describes how v does not match a definition type, or
+// returns "" if it does.
+func checkFlywaveType(v interface{}, t string) string {
+	switch t {
+	case FlywaveTypeBoolean:
+		if _, ok := v.(bool); ok {
+			return ""
+		}
+	case FlywaveTypeNumber:
+		if _, ok := v.(float64); ok {
+			return ""
+		}
+	case FlywaveTypeString:
+		if _, ok := v.(string); ok {
+			return ""
+		}
+	case FlywaveTypeColor:
+		if s, ok := v.(string); ok {
+			if _, err := strToColor(s, defaultColorAlpha); err != nil {
+				return err.Error()
+			}
+			return ""
+		}
+	case FlywaveTypeVector2, FlywaveTypeVector3, FlywaveTypeVector4:
+		n := int(t[len(t)-1] - '0')
+		if vec, ok := numberSlice(v); ok && len(vec) == n {
+			return ""
+		}
+	default:
+		return fmt.Sprintf("unknown definition type %q", t)
+	}
+	return fmt.Sprintf("value %s is not a %s", jsonString(v), t)
+}
+
+func sortedDefinitionNames(defs FlywaveDefinitions) []string {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package style
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Formatted is the value of a format expression: text made of sections
+// that may override the font, scale and color of the layer.
+type Formatted struct {
+	Sections []FormattedSection
+}
+
+// FormattedSection is a run of text, or an image, within a Formatted value.
+// Unset overrides are nil or empty and fall back to the layer's text-font,
+// text-size and text-color.
+type FormattedSection struct {
+	Text string
+	// Image is set for sections made from an image expression.
+	Image *ResolvedImage
+	// Scale multiplies text-size, from the font-scale option.
+	Scale *float64
+	// TextFont is the font stack, from the text-font option.
+	TextFont []string
+	// TextColor is the color, from the text-color option.
+	TextColor color.Color
+	// VerticalAlign is "bottom", "center" or "top", from the vertical-align
+	// option.
+	VerticalAlign string
+}
+
+// String returns the text of the sections, as labels and to-string show it.
+// Image sections contribute no text.
+func (f Formatted) String() string {
+	var sb strings.Builder
+	for _, s := range f.Sections {
+		sb.WriteString(s.Text)
+	}
+	return sb.String()
+}
+
+// ResolvedImage is the value of an image expression.
+type ResolvedImage struct {
+	Name string
+}
+
+func (ev *evaluator) evalFormat(e *Expression) (interface{}, error) {
+	if len(e.Args) == 0 {
+		return nil, errors.Errorf("%q requires at least 1 argument", e.Operator)
+	}
+	var out Formatted
+	for i := 0; i < len(e.Args); i++ {
+		v, err := ev.evalArg(e, i)
+		if err != nil {
+			return nil, err
+		}
+		var section FormattedSection
+		switch t := v.(type) {
+		case ResolvedImage:
+			section.Image = &t
+		default:
+			section.Text = valueToString(v)
+		}
+		if i+1 < len(e.Args) && e.Args[i+1] != nil && e.Args[i+1].IsLiteral {
+			if opts, ok := e.Args[i+1].Value.(map[string]interface{}); ok {
+				if err := ev.sectionOptions(&section, opts); err != nil {
+					return nil, errors.Wrapf(err, "%q: section %d", e.Operator, len(out.Sections))
+				}
+				i++
+			}
+		}
+		out.Sections = append(out.Sections, section)
+	}
+	return out, nil
+}
+
+func (ev *evaluator) sectionOptions(s *FormattedSection, opts map[string]interface{}) error {
+	for _, key := range sortedKeys(opts) {
+		v, err := ev.evalOption(opts[key])
+		if err != nil {
+			return errors.Wrap(err, key)
+		}
+		if v == nil {
+			continue
+		}
+		switch key {
+		case "font-scale":
+			n, ok := v.(float64)
+			if !ok {
+				return errors.Errorf("font-scale: expected number but found %s", typeOf(v))
+			}
+			s.Scale = &n
+		case "text-font":
+			fonts, err := convertPropertyValue[[]string](v)
+			if err != nil {
+				return errors.Wrap(err, "text-font")
+			}
+			s.TextFont = fonts
+		case "text-color":
+			c, ok := valueToColor(v)
+			if !ok {
+				return errors.Errorf("text-color: expected color but found %s", typeOf(v))
+			}
+			s.TextColor = c
+		case "vertical-align":
+			align, ok := v.(string)
+			if !ok || (align != "bottom" && align != "center" && align != "top") {
+				return errors.Errorf("vertical-align: expected bottom, center or top but found %s", jsonString(v))
+			}
+			s.VerticalAlign = align
+		}
+	}
+	return nil
+}
+
+// evalOption evaluates the value of an option object entry. Options may be
+// expressions; arrays that are not expressions, such as a bare font stack,
+// are taken literally.
+func (ev *evaluator) evalOption(raw interface{}) (interface{}, error) {
+	if arr, ok := raw.([]interface{}); ok && len(arr) > 0 {
+		if op, ok := arr[0].(string); ok && !IsKnownOperator(op) {
+			return normalizeValue(arr), nil
+		}
+	}
+	var e Expression
+	if err := e.decode(raw); err != nil {
+		return nil, err
+	}
+	return ev.eval(&e)
+}
+
+func (ev *evaluator) evalImage(e *Expression) (interface{}, error) {
+	v, err := ev.evalArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case string:
+		return ResolvedImage{Name: t}, nil
+	case ResolvedImage:
+		return t, nil
+	}
+	return nil, errors.Errorf("%q: expected string for argument 0 but found %s", e.Operator, typeOf(v))
+}
+
+// toFormatted converts the value of a formatted property: plain strings and
+// images become a single section.
+func toFormatted(v interface{}) (Formatted, bool) {
+	switch t := v.(type) {
+	case Formatted:
+		return t, true
+	case ResolvedImage:
+		return Formatted{Sections: []FormattedSection{{Image: &t}}}, true
+	case string:
+		return Formatted{Sections: []FormattedSection{{Text: t}}}, true
+	case nil:
+		return Formatted{}, true
+	}
+	return Formatted{}, false
+}
@@ -0,0 +1,70 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateFormat(t *testing.T) {
+	e := mustExpr(t, `["format",
+		["get", "name"], {"font-scale": 1.2, "text-font": ["literal", ["Open Sans Bold"]]},
+		"\n", {},
+		["number-format", ["get", "ele"], {}], {"text-color": ["get", "c"], "vertical-align": "top", "text-font": ["Noto Sans"]},
+		["image", "peak"]]`)
+	ctx := EvalContext{Feature: &Feature{Properties: map[string]interface{}{"name": "Mont Blanc", "ele": 4808.72, "c": "#ff0000"}}}
+	got, err := e.Evaluate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := got.(Formatted)
+	if !ok {
+		t.Fatalf("got %T, want Formatted", got)
+	}
+	scale := 1.2
+	want := []FormattedSection{
+		{Text: "Mont Blanc", Scale: &scale, TextFont: []string{"Open Sans Bold"}},
+		{Text: "\n"},
+		{Text: "4,808.72", TextFont: []string{"Noto Sans"}, TextColor: color.RGBA{255, 0, 0, 255}, VerticalAlign: "top"},
+		{Image: &ResolvedImage{Name: "peak"}},
+	}
+	if !reflect.DeepEqual(f.Sections, want) {
+		t.Errorf("sections = %+v, want %+v", f.Sections, want)
+	}
+	if s := f.String(); s != "Mont Blanc\n4,808.72" {
+		t.Errorf("String() = %q", s)
+	}
+
+	s, err := mustExpr(t, `["to-string", ["format", "a", {}, "b", {}]]`).Evaluate(EvalContext{})
+	if err != nil || s != "ab" {
+		t.Errorf("to-string = %v, %v, want ab", s, err)
+	}
+	if _, err := mustExpr(t, `["format", "a", {"vertical-align": "middle"}]`).Evaluate(EvalContext{}); err == nil {
+		t.Error("expected error for an invalid vertical-align")
+	}
+}
+
+func TestTextFieldFormatted(t *testing.T) {
+	var l Layout
+	if err := json.Unmarshal([]byte(`{"text-field": ["format", ["get", "ref"], {"font-scale": 0.8}, " ", {}, ["number-format", ["get", "len"], {"max-fraction-digits": 1}], {}]}`), &l); err != nil {
+		t.Fatal(err)
+	}
+	feature := &Feature{Properties: map[string]interface{}{"ref": "A7", "len": 1234.56}}
+	text, err := l.TextFieldAt(10, feature)
+	if err != nil || text != "A7 1,234.6" {
+		t.Errorf("TextFieldAt = %q, %v", text, err)
+	}
+	f, err := l.TextFieldFormattedAt(10, feature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Sections) != 3 || f.Sections[0].Scale == nil || *f.Sections[0].Scale != 0.8 {
+		t.Errorf("sections = %+v", f.Sections)
+	}
+
+	l = Layout{TextField: "{ref}"}
+	if f, err := l.TextFieldFormattedAt(10, feature); err != nil || f.String() != "A7" || len(f.Sections) != 1 {
+		t.Errorf("token text-field = %+v, %v", f, err)
+	}
+}
@@ -26,3 +26,74 @@ func getExponentialPercentage(zoomLevel, lowerStopVal, higherStopVal ZoomLevel,
 
 	return top / bottom
 }
+
+// unitBezier is a cubic bezier curve from (0, 0) to (1, 1) with control
+// points (x1, y1) and (x2, y2), as used by cubic-bezier interpolation.
+type unitBezier struct {
+	cx, bx, ax float64
+	cy, by, ay float64
+}
+
+func newUnitBezier(x1, y1, x2, y2 float64) unitBezier {
+	u := unitBezier{cx: 3 * x1, cy: 3 * y1}
+	u.bx = 3*(x2-x1) - u.cx
+	u.ax = 1 - u.cx - u.bx
+	u.by = 3*(y2-y1) - u.cy
+	u.ay = 1 - u.cy - u.by
+	return u
+}
+
+func (u unitBezier) sampleCurveX(t float64) float64 {
+	return ((u.ax*t+u.bx)*t + u.cx) * t
+}
+
+func (u unitBezier) sampleCurveY(t float64) float64 {
+	return ((u.ay*t+u.by)*t + u.cy) * t
+}
+
+func (u unitBezier) sampleCurveDerivativeX(t float64) float64 {
+	return (3*u.ax*t+2*u.bx)*t + u.cx
+}
+
+// solveCurveX finds the curve parameter for x, first with Newton's method
+// and then falling back to bisection.
+func (u unitBezier) solveCurveX(x, epsilon float64) float64 {
+	t := x
+	for i := 0; i < 8; i++ {
+		x2 := u.sampleCurveX(t) - x
+		if math.Abs(x2) < epsilon {
+			return t
+		}
+		d2 := u.sampleCurveDerivativeX(t)
+		if math.Abs(d2) < 1e-6 {
+			break
+		}
+		t -= x2 / d2
+	}
+
+	t0, t1 := 0.0, 1.0
+	t = x
+	if t < t0 {
+		return t0
+	}
+	if t > t1 {
+		return t1
+	}
+	for t0 < t1 {
+		x2 := u.sampleCurveX(t)
+		if math.Abs(x2-x) < epsilon {
+			return t
+		}
+		if x > x2 {
+			t0 = t
+		} else {
+			t1 = t
+		}
+		t = (t1-t0)*0.5 + t0
+	}
+	return t
+}
+
+func (u unitBezier) solve(x float64) float64 {
+	return u.sampleCurveY(u.solveCurveX(x, 1e-6))
+}
@@ -0,0 +1,376 @@
+package style
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ImportSeparator joins an import id and a source or layer id when the
+// import is flattened into its parent, e.g. "basemap/water".
+const ImportSeparator = "/"
+
+// StyleLoader fetches the style an import URL refers to.
+type StyleLoader interface {
+	LoadStyle(url string) (*Style, error)
+}
+
+// StyleLoaderFunc adapts a function to the StyleLoader interface.
+type StyleLoaderFunc func(url string) (*Style, error)
+
+func (f StyleLoaderFunc) LoadStyle(url string) (*Style, error) {
+	return f(url)
+}
+
+// MapStyleLoader serves styles from memory, keyed by URL.
+type MapStyleLoader map[string]*Style
+
+func (m MapStyleLoader) LoadStyle(url string) (*Style, error) {
+	s, ok := m[url]
+	if !ok {
+		return nil, errors.Errorf("style %q not found", url)
+	}
+	return s, nil
+}
+
+// FileStyleLoader reads styles from the file system. URLs may use the
+// file:// scheme; relative paths are resolved against Root.
+type FileStyleLoader struct {
+	Root string
+}
+
+func (f FileStyleLoader) LoadStyle(url string) (*Style, error) {
+	path := strings.TrimPrefix(url, "file://")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.Root, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading style %q", url)
+	}
+	var s Style
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrapf(err, "decoding style %q", url)
+	}
+	return &s, nil
+}
+
+// ResolveImports returns a copy of s with every import, and every import of
+// an import, flattened into one self-contained style.
+//
+// Imported sources and layers are prefixed with the import id. Imported
+// layers are placed below the parent's own layers, except that parent
+// layers with a slot are inserted at the matching slot layer of an import.
+// Import config values replace the imported style's schema defaults and
+// are substituted for its config expressions. The parent's sprite, glyphs,
+// terrain, fog, lights and projection take precedence over those of its
+// imports. Color themes stay scoped to the layers of the style that
+// declares them: an imported style's theme, or the color-theme of the
+// import that replaces it, applies to that import's layers only, see
+// ColorThemeFor.
+func ResolveImports(s *Style, loader StyleLoader) (*Style, error) {
+	r := &importResolver{loader: loader}
+	return r.resolve(s, nil)
+}
+
+type importResolver struct {
+	loader StyleLoader
+	stack  []string
+}
+
+func (r *importResolver) resolve(s *Style, config map[string]interface{}) (*Style, error) {
+	out, err := cloneStyle(s)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		if err := out.applyConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	imports := out.Imports
+	out.Imports = nil
+	if len(imports) == 0 {
+		return out, nil
+	}
+
+	// Layers of the imports, in order, with each import's slot layers
+	// remembered as insertion points for the parent's layers.
+	var layers []*Layer
+	slots := map[string]int{}
+	for _, imp := range imports {
+		child, err := r.resolveImport(imp)
+		if err != nil {
+			return nil, err
+		}
+		child.namespace(imp.ID)
+		for id, src := range child.Sources {
+			if _, ok := out.Sources[id]; ok {
+				return nil, errors.Errorf("import %q: source %q already exists", imp.ID, id)
+			}
+			if out.Sources == nil {
+				out.Sources = Sources{}
+			}
+			out.Sources[id] = src
+		}
+		for _, l := range child.Layers {
+			if l.Type == LayerTypeSlot {
+				name := strings.TrimPrefix(l.ID, imp.ID+ImportSeparator)
+				if _, ok := slots[name]; !ok {
+					slots[name] = len(layers)
+				}
+				continue
+			}
+			layers = append(layers, l)
+		}
+		out.inheritFrom(child)
+	}
+
+	// Insert the parent's slotted layers at their slot positions; layers
+	// sharing a slot keep their relative order.
+	bySlot := map[string][]*Layer{}
+	var top []*Layer
+	for _, l := range out.Layers {
+		if l.Slot != nil {
+			if _, ok := slots[*l.Slot]; ok {
+				bySlot[*l.Slot] = append(bySlot[*l.Slot], l)
+				l.Slot = nil
+				continue
+			}
+		}
+		top = append(top, l)
+	}
+	result := make([]*Layer, 0, len(layers)+len(out.Layers))
+	cursor := 0
+	for _, pos := range sortedSlotPositions(slots) {
+		result = append(result, layers[cursor:pos.index]...)
+		result = append(result, bySlot[pos.name]...)
+		cursor = pos.index
+	}
+	result = append(result, layers[cursor:]...)
+	out.Layers = append(result, top...)
+	return out, nil
+}
+
+func (r *importResolver) resolveImport(imp Import) (*Style, error) {
+	if imp.ID == "" {
+		return nil, errors.New("import requires an id")
+	}
+	src := imp.Data
+	if src == nil {
+		if imp.URL == "" {
+			return nil, errors.Errorf("import %q has neither url nor data", imp.ID)
+		}
+		for _, url := range r.stack {
+			if url == imp.URL {
+				return nil, errors.Errorf("import cycle: %s -> %s", strings.Join(r.stack, " -> "), imp.URL)
+			}
+		}
+		if r.loader == nil {
+			return nil, errors.Errorf("import %q: no loader for %q", imp.ID, imp.URL)
+		}
+		loaded, err := r.loader.LoadStyle(imp.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "import %q", imp.ID)
+		}
+		src = loaded
+		r.stack = append(r.stack, imp.URL)
+		defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+	}
+	// Imported styles lose their schema when flattened, so their config
+	// expressions are always replaced, by defaults if nothing is set.
+	config := imp.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	child, err := r.resolve(src, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "import %q", imp.ID)
+	}
+	if imp.ColorTheme != nil {
+		child.ColorTheme = imp.ColorTheme
+	}
+	// Layers of nested imports already carry the theme of their own import.
+	scope := &importTheme{theme: child.ColorTheme}
+	for _, l := range child.Layers {
+		if l.importTheme == nil {
+			l.importTheme = scope
+		}
+	}
+	return child, nil
+}
+
+// importTheme is the color theme of the import a flattened layer came from.
+type importTheme struct {
+	theme *ColorTheme
+}
+
+// ColorThemeFor returns the color theme that applies to l: the theme of the
+// import l came from if s was flattened by ResolveImports, which is nil
+// when that import has none, and the style's own color-theme otherwise.
+func (s *Style) ColorThemeFor(l *Layer) *ColorTheme {
+	if l != nil && l.importTheme != nil {
+		return l.importTheme.theme
+	}
+	return s.ColorTheme
+}
+
+type slotPosition struct {
+	name  string
+	index int
+}
+
+func sortedSlotPositions(slots map[string]int) []slotPosition {
+	out := make([]slotPosition, 0, len(slots))
+	for name, index := range slots {
+		out = append(out, slotPosition{name, index})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].index != out[j].index {
+			return out[i].index < out[j].index
+		}
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+// namespace prefixes source and layer ids, and the references to them.
+func (s *Style) namespace(prefix string) {
+	qualify := func(id string) string {
+		return prefix + ImportSeparator + id
+	}
+	sources := make(Sources, len(s.Sources))
+	for id, src := range s.Sources {
+		sources[qualify(id)] = src
+	}
+	s.Sources = sources
+	for _, l := range s.Layers {
+		l.ID = qualify(l.ID)
+		if l.Source != nil {
+			src := qualify(*l.Source)
+			l.Source = &src
+		}
+		if l.Slot != nil {
+			slot := qualify(*l.Slot)
+			l.Slot = &slot
+		}
+	}
+	if s.Terrain != nil && s.Terrain.Source != "" {
+		s.Terrain.Source = qualify(s.Terrain.Source)
+	}
+}
+
+// inheritFrom fills unset style-wide settings from an import.
+func (s *Style) inheritFrom(child *Style) {
+	if s.Sprite == "" {
+		s.Sprite = child.Sprite
+	}
+	if s.Glyphs == "" {
+		s.Glyphs = child.Glyphs
+	}
+	if s.Terrain == nil {
+		s.Terrain = child.Terrain
+	}
+	if s.Fog == nil {
+		s.Fog = child.Fog
+	}
+	if len(s.Lights) == 0 {
+		s.Lights = child.Lights
+	}
+	if s.Projection == nil {
+		s.Projection = child.Projection
+	}
+}
+
+// applyConfig overrides schema defaults with config values and replaces
+// config expressions in layers with the resulting constants.
+func (s *Style) applyConfig(config map[string]interface{}) error {
+	values := map[string]interface{}{}
+	for name, opt := range s.Schema {
+		values[name] = opt.Default
+	}
+	for name, v := range config {
+		opt, ok := s.Schema[name]
+		if !ok {
+			return errors.Errorf("config option %q is not in the style schema", name)
+		}
+		if err := opt.Check(v); err != nil {
+			return errors.Wrapf(err, "config option %q", name)
+		}
+		opt.Default = v
+		s.Schema[name] = opt
+		values[name] = v
+	}
+	for i, l := range s.Layers {
+		raw, err := propertyMap(l)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(substituteConfig(raw, values))
+		if err != nil {
+			return err
+		}
+		var next Layer
+		if err := json.Unmarshal(data, &next); err != nil {
+			return errors.Wrapf(err, "layer %q", l.ID)
+		}
+		s.Layers[i] = &next
+	}
+	return nil
+}
+
+// substituteConfig replaces ["config", name] expressions in a decoded JSON
+// value with the configured constants.
+func substituteConfig(v interface{}, values map[string]interface{}) interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		if len(t) == 2 || (len(t) == 3 && t[2] == "") {
+			if op, ok := t[0].(string); ok && op == ExpConfig {
+				if name, ok := t[1].(string); ok {
+					if value, ok := values[name]; ok {
+						return configLiteral(value)
+					}
+				}
+			}
+		}
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = substituteConfig(item, values)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = substituteConfig(item, values)
+		}
+		return out
+	}
+	return v
+}
+
+func configLiteral(v interface{}) interface{} {
+	if e, err := propertyExpression(v); err == nil && !e.IsLiteral {
+		return v
+	}
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		return []interface{}{ExpLiteral, v}
+	}
+	return v
+}
+
+func cloneStyle(s *Style) (*Style, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var out Style
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,128 @@
+package style
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const importBasemap = `{
+	"version": 8,
+	"sprite": "mapbox://sprites/basemap",
+	"schema": {"landColor": {"default": "#eee"}, "showRoads": {"default": true}},
+	"sources": {"composite": {"type": "vector", "url": "mapbox://mapbox.streets"}},
+	"terrain": {"source": "composite"},
+	"layers": [
+		{"id": "land", "type": "background", "paint": {"background-color": ["config", "landColor"]}},
+		{"id": "middle", "type": "slot"},
+		{"id": "roads", "type": "line", "source": "composite", "source-layer": "road",
+			"filter": ["config", "showRoads"]},
+		{"id": "top", "type": "slot"}
+	]
+}`
+
+func TestResolveImports(t *testing.T) {
+	loader := MapStyleLoader{"mapbox://styles/mapbox/basemap": parseStyle(t, importBasemap)}
+	root := parseStyle(t, `{
+		"version": 8,
+		"imports": [{"id": "basemap", "url": "mapbox://styles/mapbox/basemap", "config": {"landColor": "#abc"}}],
+		"sources": {"mine": {"type": "geojson", "data": {"type": "FeatureCollection", "features": []}}},
+		"layers": [
+			{"id": "points", "type": "circle", "source": "mine", "slot": "middle"},
+			{"id": "labels", "type": "symbol", "source": "mine"},
+			{"id": "pins", "type": "circle", "source": "mine", "slot": "top"}
+		]
+	}`)
+
+	out, err := ResolveImports(root, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, l := range out.Layers {
+		ids = append(ids, l.ID)
+	}
+	if got := strings.Join(ids, ","); got != "basemap/land,points,basemap/roads,pins,labels" {
+		t.Fatalf("unexpected layer order %s", got)
+	}
+	if _, ok := out.Sources["basemap/composite"]; !ok {
+		t.Fatalf("expected namespaced source, got %v", out.Sources)
+	}
+	if *out.Layers[2].Source != "basemap/composite" {
+		t.Fatalf("layer source not namespaced: %s", *out.Layers[2].Source)
+	}
+	if out.Layers[1].Slot != nil {
+		t.Fatal("slot should be cleared once the layer is placed")
+	}
+	if out.Sprite != "mapbox://sprites/basemap" || out.Terrain.Source != "basemap/composite" {
+		t.Fatalf("unexpected inherited settings: %q %+v", out.Sprite, out.Terrain)
+	}
+	if len(out.Imports) != 0 {
+		t.Fatal("imports should be removed")
+	}
+	if c := out.Layers[0].Paint.BackgroundColor.GetColorAtZoomLevel(0); ColorToCSS(c) != "#aabbcc" {
+		t.Fatalf("config override not applied, got %v", c)
+	}
+	if ok, err := out.Layers[2].Matches(&Feature{}, 0); err != nil || !ok {
+		t.Fatalf("default config not applied to filter: %v %v", ok, err)
+	}
+	if len(root.Imports) != 1 || root.Layers[0].Slot == nil {
+		t.Fatal("input style was modified")
+	}
+}
+
+func TestResolveImportsNested(t *testing.T) {
+	loader := MapStyleLoader{
+		"outer": parseStyle(t, `{"version": 8, "sources": {},
+			"imports": [{"id": "inner", "url": "inner"}],
+			"layers": [{"id": "a", "type": "background"}]}`),
+		"inner": parseStyle(t, `{"version": 8, "sources": {"s": {"type": "vector"}},
+			"layers": [{"id": "b", "type": "fill", "source": "s"}]}`),
+	}
+	root := parseStyle(t, `{"version": 8, "sources": {}, "imports": [{"id": "outer", "url": "outer"}], "layers": []}`)
+	out, err := ResolveImports(root, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Layers) != 2 || out.Layers[0].ID != "outer/inner/b" || out.Layers[1].ID != "outer/a" {
+		t.Fatalf("unexpected layers %v, %v", out.Layers[0].ID, out.Layers[1].ID)
+	}
+	if *out.Layers[0].Source != "outer/inner/s" {
+		t.Fatalf("unexpected source %s", *out.Layers[0].Source)
+	}
+}
+
+func TestResolveImportsErrors(t *testing.T) {
+	loader := MapStyleLoader{
+		"a": parseStyle(t, `{"version": 8, "sources": {}, "imports": [{"id": "b", "url": "b"}], "layers": []}`),
+		"b": parseStyle(t, `{"version": 8, "sources": {}, "imports": [{"id": "a", "url": "a"}], "layers": []}`),
+	}
+	tests := map[string]string{
+		"cycle":          `{"version": 8, "sources": {}, "imports": [{"id": "a", "url": "a"}], "layers": []}`,
+		"missing":        `{"version": 8, "sources": {}, "imports": [{"id": "x", "url": "nope"}], "layers": []}`,
+		"unknown_config": `{"version": 8, "sources": {}, "imports": [{"id": "x", "url": "", "data": {"version": 8, "sources": {}, "layers": []}, "config": {"foo": 1}}], "layers": []}`,
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ResolveImports(parseStyle(t, raw), loader); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFileStyleLoader(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "base.json"), []byte(importBasemap), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := parseStyle(t, `{"version": 8, "sources": {}, "imports": [{"id": "base", "url": "file://base.json"}], "layers": []}`)
+	out, err := ResolveImports(root, FileStyleLoader{Root: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Layers) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(out.Layers))
+	}
+}
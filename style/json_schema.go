@@ -0,0 +1,249 @@
+package style
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDialect is the JSON Schema draft the document of JSONSchema
+// conforms to.
+const JSONSchemaDialect = "http://json-schema.org/draft-07/schema#"
+
+const flywavePrefix = "flywave:"
+
+// JSONSchema returns a JSON Schema describing the whole style document,
+// flywave extension keys included, for editors to offer completion and
+// validation. It is derived from the style model, so it accepts exactly the
+// keys ParseStrict accepts; paint and layout properties additionally carry
+// the enum values and numeric ranges of the style specification.
+func JSONSchema() ([]byte, error) {
+	g := &schemaGenerator{definitions: map[string]interface{}{}}
+	g.schemaFor(reflect.TypeOf(Style{}))
+	doc := map[string]interface{}{
+		"$schema":     JSONSchemaDialect,
+		"title":       "Mapbox GL style",
+		"definitions": g.definitions,
+	}
+	for k, v := range g.definitions["Style"].(map[string]interface{}) {
+		doc[k] = v
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// schemaRequired lists the keys the style specification requires of each
+// object, and the flywave extension documentation of its own objects. Keys
+// of other objects are optional, whether or not their fields omit empty
+// values: Import.url, for one, may be left out when data is given.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(Style{}):               {"version", "sources", "layers"},
+	reflect.TypeOf(Layer{}):               {"id", "type"},
+	reflect.TypeOf(Source{}):              {"type"},
+	reflect.TypeOf(Import{}):              {"id"},
+	reflect.TypeOf(Light3D{}):             {"id", "type"},
+	reflect.TypeOf(Projection{}):          {"name"},
+	reflect.TypeOf(Terrain{}):             {"source"},
+	reflect.TypeOf(SchemaOption{}):        {"default"},
+	reflect.TypeOf(Selector{}):            {"layer"},
+	reflect.TypeOf(ModelSourceModel{}):    {"uri"},
+	reflect.TypeOf(FlywaveImageTexture{}): {"name", "image"},
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+// Schemas of types with their own JSON encoding.
+var (
+	expressionSchema = map[string]interface{}{"type": "array", "description": "Expression"}
+	functionSchema   = map[string]interface{}{"type": "object", "description": "Legacy function"}
+	colorSchema      = map[string]interface{}{
+		"anyOf": []interface{}{map[string]interface{}{"type": "string", "description": "CSS color"}, expressionSchema, functionSchema},
+	}
+	anySchema = map[string]interface{}{}
+)
+
+var layerTypes = []LayerType{
+	LayerTypeBackground, LayerTypeBuilding, LayerTypeCircle, LayerTypeClip,
+	LayerTypeFill, LayerTypeFillExtrusion, LayerTypeHeatmap, LayerTypeHillshade,
+	LayerTypeLine, LayerTypeModel, LayerTypeRaster, LayerTypeRasterParticle,
+	LayerTypeSky, LayerTypeSlot, LayerTypeSymbol,
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(ColorType{}):
+		return colorSchema
+	case reflect.TypeOf(Expression{}), reflect.TypeOf(FilterContainer{}):
+		return expressionSchema
+	case reflect.TypeOf(LayerType("")):
+		values := make([]interface{}, len(layerTypes))
+		for i, lt := range layerTypes {
+			values[i] = string(lt)
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	if customDecoded(t) {
+		return anySchema
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.structSchema(t)
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return anySchema
+}
+
+// structSchema adds a definition for a named struct type and returns a
+// reference to it. Anonymous structs are inlined.
+func (g *schemaGenerator) structSchema(t reflect.Type) interface{} {
+	name := t.Name()
+	if name != "" {
+		ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+		if _, ok := g.definitions[name]; ok {
+			return ref
+		}
+		// Reserve the name first: Import refers back to Style.
+		g.definitions[name] = nil
+		g.definitions[name] = g.objectSchema(t)
+		return ref
+	}
+	return g.objectSchema(t)
+}
+
+func (g *schemaGenerator) objectSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for _, f := range jsonFields(t) {
+		var s interface{}
+		switch t {
+		case reflect.TypeOf(Paint{}):
+			s = g.propertySchema(f, true)
+		case reflect.TypeOf(Layout{}):
+			s = g.propertySchema(f, false)
+		default:
+			s = g.schemaFor(f.Type)
+		}
+		if strings.HasPrefix(f.Name, flywavePrefix) {
+			s = describe(s, "Flywave extension")
+		}
+		props[f.Name] = s
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if required := schemaRequired[t]; len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// propertySchema describes a paint or layout property from the style
+// specification, falling back to the field type for properties it does not
+// list.
+func (g *schemaGenerator) propertySchema(f jsonField, paint bool) interface{} {
+	spec, ok := anyPropertySpec(f.Name, paint)
+	if !ok {
+		return g.schemaFor(f.Type)
+	}
+	value := spec.valueSchema()
+	if spec.Expression == exprConstant {
+		return value
+	}
+	return map[string]interface{}{"anyOf": []interface{}{value, expressionSchema, functionSchema}}
+}
+
+// anyPropertySpec looks a property up in every layer type, in a fixed order
+// so the result does not depend on map iteration.
+func anyPropertySpec(name string, paint bool) (propertySpec, bool) {
+	types := make([]string, 0, len(layerPropertySpecs))
+	for t := range layerPropertySpecs {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if spec, ok := propertySpecFor(LayerType(t), name, paint); ok {
+			return spec, true
+		}
+	}
+	return propertySpec{}, false
+}
+
+// valueSchema describes a constant value of the property.
+func (p propertySpec) valueSchema() map[string]interface{} {
+	switch p.Type {
+	case specNumber:
+		s := map[string]interface{}{"type": "number"}
+		if p.Min != nil {
+			s["minimum"] = *p.Min
+		}
+		if p.Max != nil {
+			s["maximum"] = *p.Max
+		}
+		return s
+	case specColor:
+		return map[string]interface{}{"type": "string", "description": "CSS color"}
+	case specBoolean:
+		return map[string]interface{}{"type": "boolean"}
+	case specString, specResolvedImage:
+		return map[string]interface{}{"type": "string"}
+	case specFormatted:
+		return map[string]interface{}{"type": []interface{}{"string", "array"}}
+	case specEnum:
+		values := make([]interface{}, len(p.Values))
+		for i, v := range p.Values {
+			values[i] = v
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	case specPadding:
+		return map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "number"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}, "minItems": 1, "maxItems": 4},
+			},
+		}
+	case specArray:
+		item := propertySpec{Type: p.Value, Values: p.Values}.valueSchema()
+		s := map[string]interface{}{"type": "array", "items": item}
+		if p.Length > 0 {
+			s["minItems"], s["maxItems"] = p.Length, p.Length
+		}
+		return s
+	}
+	return map[string]interface{}{}
+}
+
+// describe returns a copy of schema s with a description.
+func describe(s interface{}, description string) interface{} {
+	m, ok := s.(map[string]interface{})
+	if !ok {
+		return s
+	}
+	out := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	if _, ref := out["$ref"]; ref {
+		// Keywords next to $ref are ignored in draft-07.
+		return map[string]interface{}{"allOf": []interface{}{m}, "description": description}
+	}
+	out["description"] = description
+	return out
+}
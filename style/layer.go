@@ -34,9 +34,10 @@ type Layer struct {
 	FlywaveAnimateExtrusion *bool              `json:"flywave:animateExtrusion,omitempty"`
 	FlywaveBoundaryWalls    *bool              `json:"flywave:boundaryWalls,omitempty"`
 	FlywaveFootprint        *bool              `json:"flywave:footprint,omitempty"`
-	FlywaveShaderParams     map[string]interface{} `json:"flywave:shaderParams,omitempty"`
+	FlywaveShaderParams     FlywaveShaderParams    `json:"flywave:shaderParams,omitempty"`
 	FlywaveImageTexturePrefix    *string       `json:"flywave:imageTexturePrefix,omitempty"`
 	FlywaveImageTexturePostfix   *string       `json:"flywave:imageTexturePostfix,omitempty"`
+	FlywaveTextStyle             *string       `json:"flywave:textStyle,omitempty"`
 	ID                          string         `json:"id"`
 	Layout                      *Layout        `json:"layout,omitempty"`
 	MaxZoom                     *float64       `json:"maxzoom,omitempty"`
@@ -47,6 +48,10 @@ type Layer struct {
 	Source                      *string        `json:"source,omitempty"`
 	SourceLayer                 *string        `json:"source-layer,omitempty"`
 	Type                        LayerType      `json:"type"`
+
+	config *ConfigScope
+	// importTheme is set on layers flattened from an import.
+	importTheme *importTheme
 }
 
 func validLayerType(t LayerType) bool {
@@ -87,6 +92,33 @@ func (l *Layer) Validate() error {
 	return nil
 }
 
+// VisibleAtZoom reports whether zoom lies within the layer's
+// [minzoom, maxzoom) range.
+func (l *Layer) VisibleAtZoom(zoom float64) bool {
+	if l.MinZoom != nil && zoom < *l.MinZoom {
+		return false
+	}
+	if l.MaxZoom != nil && zoom >= *l.MaxZoom {
+		return false
+	}
+	return true
+}
+
+// Matches reports whether the feature would be drawn by this layer at the
+// given zoom, taking the layer zoom range and filter into account. A filter
+// that fails to evaluate for the feature does not match it; the error is
+// only returned for a malformed filter.
+func (l *Layer) Matches(feature *Feature, zoom float64) (bool, error) {
+	if !l.VisibleAtZoom(zoom) {
+		return false, nil
+	}
+	ok, err := l.Filter.Evaluate(EvalContext{Zoom: zoom, Feature: feature, Config: l.config})
+	if err != nil {
+		return false, errors.Wrapf(err, "layer %q filter", l.ID)
+	}
+	return ok, nil
+}
+
 type Light struct {
 	Anchor    string     `json:"anchor,omitempty"`
 	Color     *ColorType `json:"color,omitempty"`
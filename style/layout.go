@@ -79,4 +79,7 @@ type Layout struct {
 	TextTransform          string      `json:"text-transform,omitempty"`
 	TextVariableAnchor     []string    `json:"text-variable-anchor,omitempty"`
 	TextWritingMode        []string    `json:"text-writing-mode,omitempty"`
+
+	cache  propertyCache
+	config *ConfigScope
 }
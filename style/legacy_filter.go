@@ -0,0 +1,202 @@
+package style
+
+import (
+	"github.com/pkg/errors"
+)
+
+// IsLegacyFilter reports whether a filter is written in the legacy (v0)
+// filter syntax, e.g. ["==", "class", "park"] or ["in", "$type", "Polygon"].
+func IsLegacyFilter(e *Expression) bool {
+	return e != nil && !isExpressionFilter(e)
+}
+
+// ConvertLegacyFilter rewrites a legacy filter as an equivalent expression
+// filter. Filters that already use expression syntax are returned unchanged.
+func ConvertLegacyFilter(e *Expression) (*Expression, error) {
+	if e == nil || isExpressionFilter(e) {
+		return e, nil
+	}
+	return convertLegacyFilter(e)
+}
+
+func convertLegacyFilter(e *Expression) (*Expression, error) {
+	if e.IsLiteral {
+		if _, ok := e.Value.(bool); !ok {
+			return nil, errors.Errorf("invalid filter value: %v", e.Value)
+		}
+		return e, nil
+	}
+
+	switch e.Operator {
+	case ExpAll, ExpAny, filterOperatorNone:
+		args := make([]*Expression, len(e.Args))
+		for i, arg := range e.Args {
+			sub, err := ConvertLegacyFilter(arg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "arg %d of %q", i, e.Operator)
+			}
+			args[i] = sub
+		}
+		if e.Operator == filterOperatorNone {
+			return call(ExpNot, call(ExpAny, args...)), nil
+		}
+		return call(e.Operator, args...), nil
+	}
+
+	key, ok := literalString(e.Args, 0)
+	if !ok {
+		return nil, errors.Errorf("filter %q requires a property key", e.Operator)
+	}
+
+	switch e.Operator {
+	case filterOperatorHas:
+		return convertLegacyHas(key), nil
+	case filterOperatorNotHas:
+		return call(ExpNot, convertLegacyHas(key)), nil
+	case FilterOperatorIn:
+		return convertLegacyIn(key, e.Args[1:]), nil
+	case FilterOperatorNotIn:
+		return call(ExpNot, convertLegacyIn(key, e.Args[1:])), nil
+	case ExpEQ, ExpNEq, ExpLT, ExpLTE, ExpGT, ExpGTE:
+		if len(e.Args) != 2 {
+			return nil, errors.Errorf("filter %q requires a key and a value, got %d arguments", e.Operator, len(e.Args))
+		}
+		return convertLegacyComparison(e.Operator, key, expressionValue(e.Args[1])), nil
+	}
+	return nil, errors.Errorf("unknown filter operator: %q", e.Operator)
+}
+
+func call(op string, args ...*Expression) *Expression {
+	return &Expression{Operator: op, Args: args}
+}
+
+func literal(v interface{}) *Expression {
+	return &Expression{IsLiteral: true, Value: v}
+}
+
+func legacyGetter(key string) *Expression {
+	switch key {
+	case filterKeyType:
+		return call(ExpGeometryType)
+	case filterKeyID:
+		return call(ExpID)
+	}
+	return call(ExpGet, literal(key))
+}
+
+func convertLegacyHas(key string) *Expression {
+	switch key {
+	case filterKeyType:
+		return literal(true)
+	case filterKeyID:
+		return call(ExpNEq, call(ExpID), literal(nil))
+	}
+	return call(ExpHas, literal(key))
+}
+
+// geometryTypeLabels lists the geometry-type values a legacy $type value
+// stands for. Legacy filters treat multi-part geometries as their
+// single-part type.
+func geometryTypeLabels(values []interface{}) []interface{} {
+	out := make([]interface{}, 0, 2*len(values))
+	for _, v := range values {
+		out = append(out, v)
+		if s, ok := v.(string); ok {
+			out = append(out, "Multi"+s)
+		}
+	}
+	return out
+}
+
+func convertLegacyIn(key string, args []*Expression) *Expression {
+	if len(args) == 0 {
+		return literal(false)
+	}
+	values := make([]interface{}, 0, len(args))
+	seen := map[interface{}]bool{}
+	sameType := true
+	for _, arg := range args {
+		v := normalizeValue(expressionValue(arg))
+		switch v.(type) {
+		case string, float64:
+			if typeOf(v) != typeOf(normalizeValue(expressionValue(args[0]))) {
+				sameType = false
+			}
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+		default:
+			sameType = false
+		}
+		values = append(values, v)
+	}
+	if key == filterKeyType {
+		values = geometryTypeLabels(values)
+	}
+	if !sameType {
+		eqs := make([]*Expression, len(values))
+		for i, v := range values {
+			eqs[i] = call(ExpEQ, legacyGetter(key), literal(v))
+		}
+		if len(eqs) == 1 {
+			return eqs[0]
+		}
+		return call(ExpAny, eqs...)
+	}
+	return call(ExpMatch, legacyGetter(key), matchLabel(values), literal(true), literal(false))
+}
+
+func matchLabel(values []interface{}) *Expression {
+	if len(values) == 1 {
+		return literal(values[0])
+	}
+	return literal(values)
+}
+
+func convertLegacyComparison(op, key string, value interface{}) *Expression {
+	value = normalizeValue(value)
+	if key == filterKeyType && (op == ExpEQ || op == ExpNEq) {
+		in := convertLegacyIn(key, []*Expression{literal(value)})
+		if op == ExpNEq {
+			return call(ExpNot, in)
+		}
+		return in
+	}
+
+	get := legacyGetter(key)
+	switch op {
+	case ExpEQ, ExpNEq:
+		if value == nil && key != filterKeyID {
+			if op == ExpEQ {
+				return call(ExpAll, call(ExpHas, literal(key)), call(ExpEQ, get, literal(nil)))
+			}
+			return call(ExpAny, call(ExpNot, call(ExpHas, literal(key))), call(ExpNEq, get, literal(nil)))
+		}
+		return call(op, get, literal(value))
+	}
+
+	// Ordering comparisons never match values of a different type in legacy
+	// filters, so guard them with a runtime type check.
+	return call(ExpAll,
+		call(ExpEQ, call(ExpTypeOf, legacyGetter(key)), literal(typeOf(value))),
+		call(op, get, literal(value)))
+}
+
+// ConvertLegacyFilters rewrites every legacy layer filter in the style as an
+// expression filter and returns the number of filters converted.
+func (s *Style) ConvertLegacyFilters() (int, error) {
+	converted := 0
+	for i, l := range s.Layers {
+		if l == nil || l.Filter == nil || !IsLegacyFilter(l.Filter.Expr) {
+			continue
+		}
+		expr, err := ConvertLegacyFilter(l.Filter.Expr)
+		if err != nil {
+			return converted, errors.Wrapf(err, "layers[%d] (%q) filter", i, l.ID)
+		}
+		l.Filter.Expr = expr
+		converted++
+	}
+	return converted, nil
+}
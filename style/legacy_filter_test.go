@@ -0,0 +1,124 @@
+package style
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsLegacyFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`["==","class","park"]`, true},
+		{`["in","$type","Polygon"]`, true},
+		{`["!has","name"]`, true},
+		{`["has","$id"]`, true},
+		{`["all",["==","class","park"],true]`, true},
+		{`["has","name"]`, false},
+		{`["==",["get","class"],"park"]`, false},
+		{`["in",["get","class"],["literal",["a","b"]]]`, false},
+		{`["all",["==",["get","a"],1],["has","b"]]`, false},
+		{`true`, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.filter, func(t *testing.T) {
+			if got := IsLegacyFilter(mustExpr(t, tc.filter)); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertLegacyFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`["==","class","park"]`, `["==",["get","class"],"park"]`},
+		{`["!=","$id",3]`, `["!=",["id"],3]`},
+		{`["==","$type","Point"]`, `["match",["geometry-type"],["Point","MultiPoint"],true,false]`},
+		{`["in","$type","Polygon"]`, `["match",["geometry-type"],["Polygon","MultiPolygon"],true,false]`},
+		{`["in","class","park","forest","park"]`, `["match",["get","class"],["park","forest"],true,false]`},
+		{`["!in","class","park"]`, `["!",["match",["get","class"],"park",true,false]]`},
+		{`["in","rank",1,"1"]`, `["any",["==",["get","rank"],1],["==",["get","rank"],"1"]]`},
+		{`["!has","name"]`, `["!",["has","name"]]`},
+		{`["has","$id"]`, `["!=",["id"],null]`},
+		{`["<","rank",3]`, `["all",["==",["typeof",["get","rank"]],"number"],["<",["get","rank"],3]]`},
+		{`["none",["==","a",1]]`, `["!",["any",["==",["get","a"],1]]]`},
+		{`["all",["==","a",1],["has","b"]]`, `["all",["==",["get","a"],1],["has","b"]]`},
+		{`["==",["get","class"],"park"]`, `["==",["get","class"],"park"]`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.filter, func(t *testing.T) {
+			got, err := ConvertLegacyFilter(mustExpr(t, tc.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(string(data), tc.want) {
+				t.Fatalf("expected %s, got %s", tc.want, data)
+			}
+		})
+	}
+}
+
+func TestConvertLegacyFilterEquivalence(t *testing.T) {
+	features := []*Feature{
+		{ID: float64(1), Type: "Polygon", Properties: map[string]interface{}{"class": "park", "rank": float64(2)}},
+		{ID: float64(2), Type: "MultiPolygon", Properties: map[string]interface{}{"class": "forest", "rank": "high"}},
+		{ID: float64(3), Type: "LineString", Properties: map[string]interface{}{"name": "A1"}},
+	}
+	filters := map[string][]bool{
+		`["==","$type","Polygon"]`:              {true, true, false},
+		`["in","class","park","forest"]`:        {true, true, false},
+		`[">=","rank",2]`:                       {true, false, false},
+		`["!has","class"]`:                      {false, false, true},
+		`["any",["==","$id",3],["<","rank",1]]`: {false, false, true},
+	}
+	for raw, want := range filters {
+		expr, err := ConvertLegacyFilter(mustExpr(t, raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, f := range features {
+			v, err := expr.Evaluate(EvalContext{Feature: f})
+			if err != nil {
+				t.Fatalf("%s on feature %d: %v", raw, i, err)
+			}
+			if v != want[i] {
+				t.Fatalf("%s on feature %d: expected %v, got %v", raw, i, want[i], v)
+			}
+		}
+	}
+}
+
+func TestStyleConvertLegacyFilters(t *testing.T) {
+	raw := `{
+		"version": 8,
+		"sources": {},
+		"layers": [
+			{"id": "a", "type": "fill", "filter": ["==", "class", "park"]},
+			{"id": "b", "type": "fill", "filter": ["==", ["get", "class"], "park"]},
+			{"id": "c", "type": "background"}
+		]
+	}`
+	var s Style
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.ConvertLegacyFilters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 converted filter, got %d", n)
+	}
+	data, _ := json.Marshal(s.Layers[0].Filter)
+	if !jsonEqual(string(data), `["==",["get","class"],"park"]`) {
+		t.Fatalf("unexpected filter %s", data)
+	}
+}
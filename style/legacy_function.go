@@ -0,0 +1,391 @@
+package style
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// tokenProperties accept {token} strings that are replaced with feature
+// attributes.
+var tokenProperties = map[string]bool{"text-field": true, "icon-image": true}
+
+// interpolated reports whether legacy functions for the property default to
+// exponential rather than interval stops.
+func (p propertySpec) interpolated() bool {
+	switch p.Type {
+	case specNumber, specColor, specPadding:
+		return true
+	case specArray:
+		return p.Value == specNumber
+	}
+	return false
+}
+
+// ConvertLegacyFunction rewrites a legacy function object of a layer
+// property as the equivalent expression: zoom functions become interpolate
+// or step over ["zoom"], property functions interpolate, step, match or
+// case over ["get", property], and composite functions a zoom curve of
+// property curves.
+func ConvertLegacyFunction(t LayerType, property string, fn map[string]interface{}) (*Expression, error) {
+	spec, ok := propertySpecFor(t, property, true)
+	if !ok {
+		spec, ok = propertySpecFor(t, property, false)
+	}
+	if !ok {
+		return nil, errors.Errorf("unknown property %q for %s layers", property, t)
+	}
+	return convertLegacyFunction(fn, spec, property)
+}
+
+// convertLegacyFunction converts fn, a function of property, for a value
+// described by spec.
+func convertLegacyFunction(fn map[string]interface{}, spec propertySpec, property string) (*Expression, error) {
+	raw, err := convertFunction(normalizeValue(fn).(map[string]interface{}), spec, tokenProperties[property])
+	if err != nil {
+		return nil, wrapProperty(err, property)
+	}
+	e := &Expression{}
+	if err := e.decode(raw); err != nil {
+		return nil, wrapProperty(err, property)
+	}
+	return e, nil
+}
+
+func wrapProperty(err error, property string) error {
+	if property == "" {
+		return err
+	}
+	return errors.Wrap(err, property)
+}
+
+// ConvertLegacyFunctions rewrites the layer's legacy filter and every
+// function-valued or {token} string paint and layout property as an
+// expression. The converted properties stay bound to the layer's config
+// scope.
+func (l *Layer) ConvertLegacyFunctions() error {
+	if l.Filter != nil && l.Filter.Expr != nil {
+		filter, err := ConvertLegacyFilter(l.Filter.Expr)
+		if err != nil {
+			return errors.Wrapf(err, "layer %q: filter", l.ID)
+		}
+		l.Filter.Expr = filter
+	}
+	if l.Paint != nil {
+		var paint Paint
+		if err := l.convertPropertyGroup(l.Paint, &paint, true); err != nil {
+			return err
+		}
+		l.Paint = &paint
+	}
+	if l.Layout != nil {
+		var layout Layout
+		if err := l.convertPropertyGroup(l.Layout, &layout, false); err != nil {
+			return err
+		}
+		l.Layout = &layout
+	}
+	l.bindConfig(l.config)
+	return nil
+}
+
+// ConvertLegacyFunctions converts the legacy syntax of every layer, see
+// Layer.ConvertLegacyFunctions.
+func (s *Style) ConvertLegacyFunctions() error {
+	for _, l := range s.Layers {
+		if err := l.ConvertLegacyFunctions(); err != nil {
+			return err
+		}
+	}
+	_, err := s.Config()
+	return err
+}
+
+func (l *Layer) convertPropertyGroup(in, out interface{}, paint bool) error {
+	props, err := propertyMap(in)
+	if err != nil {
+		return err
+	}
+	for name, v := range props {
+		spec, ok := propertySpecFor(l.Type, name, paint)
+		if !ok {
+			continue
+		}
+		switch {
+		case isLegacyFunction(v):
+			converted, err := convertFunction(v.(map[string]interface{}), spec, tokenProperties[name])
+			if err != nil {
+				return errors.Wrapf(err, "layer %q: %s", l.ID, name)
+			}
+			props[name] = converted
+		case tokenProperties[name]:
+			if s, ok := v.(string); ok {
+				props[name] = convertTokenString(s)
+			}
+		}
+	}
+	data, err := json.Marshal(props)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return errors.Wrapf(err, "layer %q", l.ID)
+	}
+	return nil
+}
+
+func convertFunction(fn map[string]interface{}, spec propertySpec, tokens bool) (interface{}, error) {
+	stops, _ := fn["stops"].([]interface{})
+	if len(stops) == 0 {
+		return convertIdentityFunction(fn, spec)
+	}
+	pairs := make([][2]interface{}, len(stops))
+	for i, stop := range stops {
+		pair, ok := stop.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, errors.Errorf("stop %d: expected [input, output]", i)
+		}
+		pairs[i] = [2]interface{}{pair[0], pair[1]}
+	}
+
+	_, composite := pairs[0][0].(map[string]interface{})
+	_, hasProperty := fn["property"]
+	featureDependent := composite || hasProperty
+	for i := range pairs {
+		if s, ok := pairs[i][1].(string); ok && tokens && !featureDependent {
+			pairs[i][1] = convertTokenString(s)
+		} else {
+			pairs[i][1] = legacyLiteral(pairs[i][1])
+		}
+	}
+	switch {
+	case composite:
+		return convertCompositeFunction(fn, spec, pairs)
+	case featureDependent:
+		return convertPropertyFunction(fn, spec, pairs)
+	}
+	return convertZoomFunction(fn, spec, pairs)
+}
+
+func legacyFunctionType(fn map[string]interface{}, spec propertySpec) string {
+	if t, ok := fn["type"].(string); ok {
+		return t
+	}
+	if spec.interpolated() {
+		return FunctionExponential
+	}
+	return FunctionInterval
+}
+
+func interpolateOperator(fn map[string]interface{}) string {
+	switch fn["colorSpace"] {
+	case ColorSpaceHCL:
+		return ExpInterpolateHCL
+	case ColorSpaceLab:
+		return ExpInterpolateLab
+	}
+	return ExpInterpolate
+}
+
+func interpolation(fn map[string]interface{}) []interface{} {
+	base, ok := fn["base"].(float64)
+	if !ok || base == 1 {
+		return []interface{}{ExpLinear}
+	}
+	return []interface{}{ExpExponential, base}
+}
+
+func convertZoomFunction(fn map[string]interface{}, spec propertySpec, stops [][2]interface{}) (interface{}, error) {
+	input := []interface{}{ExpZoom}
+	var curve []interface{}
+	step := false
+	switch t := legacyFunctionType(fn, spec); t {
+	case FunctionInterval:
+		curve = []interface{}{ExpStep, input}
+		step = true
+	case FunctionExponential:
+		curve = []interface{}{interpolateOperator(fn), interpolation(fn), input}
+	default:
+		return nil, errors.Errorf("unsupported zoom function type %q", t)
+	}
+	for _, stop := range stops {
+		curve = appendStop(curve, stop[0], stop[1], step)
+	}
+	return fixupStepCurve(curve), nil
+}
+
+func convertPropertyFunction(fn map[string]interface{}, spec propertySpec, stops [][2]interface{}) (interface{}, error) {
+	get := []interface{}{ExpGet, fn["property"]}
+	def := legacyLiteral(fn["default"])
+	var curve []interface{}
+	switch t := legacyFunctionType(fn, spec); t {
+	case FunctionCategorical:
+		if _, ok := stops[0][0].(bool); ok {
+			curve = []interface{}{ExpCase}
+			for _, stop := range stops {
+				curve = append(curve, []interface{}{ExpEQ, get, stop[0]}, stop[1])
+			}
+			return append(curve, def), nil
+		}
+		curve = []interface{}{ExpMatch, get}
+		for _, stop := range stops {
+			curve = appendStop(curve, stop[0], stop[1], false)
+		}
+		return append(curve, def), nil
+	case FunctionInterval:
+		curve = []interface{}{ExpStep, []interface{}{ExpNumber, get}}
+		for _, stop := range stops {
+			curve = appendStop(curve, stop[0], stop[1], true)
+		}
+		curve = fixupStepCurve(curve)
+	case FunctionExponential:
+		curve = []interface{}{interpolateOperator(fn), interpolation(fn), []interface{}{ExpNumber, get}}
+		for _, stop := range stops {
+			curve = appendStop(curve, stop[0], stop[1], false)
+		}
+	default:
+		return nil, errors.Errorf("unsupported property function type %q", t)
+	}
+	if _, ok := fn["default"]; !ok {
+		return curve, nil
+	}
+	isNumber := []interface{}{ExpEQ, []interface{}{ExpTypeOf, get}, "number"}
+	return []interface{}{ExpCase, isNumber, curve, def}, nil
+}
+
+// convertCompositeFunction groups the {zoom, value} stops by zoom and
+// builds a zoom curve whose outputs are property curves.
+func convertCompositeFunction(fn map[string]interface{}, spec propertySpec, stops [][2]interface{}) (interface{}, error) {
+	var zooms []float64
+	byZoom := map[float64][][2]interface{}{}
+	for i, stop := range stops {
+		in, ok := stop[0].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("stop %d: expected {zoom, value} input", i)
+		}
+		zoom, ok := in["zoom"].(float64)
+		if !ok {
+			return nil, errors.Errorf("stop %d: zoom must be a number", i)
+		}
+		if _, seen := byZoom[zoom]; !seen {
+			zooms = append(zooms, zoom)
+		}
+		byZoom[zoom] = append(byZoom[zoom], [2]interface{}{in["value"], stop[1]})
+	}
+
+	var curve []interface{}
+	step := !spec.interpolated()
+	if step {
+		curve = []interface{}{ExpStep, []interface{}{ExpZoom}}
+	} else {
+		curve = []interface{}{interpolateOperator(fn), []interface{}{ExpLinear}, []interface{}{ExpZoom}}
+	}
+	for _, zoom := range zooms {
+		out, err := convertPropertyFunction(fn, spec, byZoom[zoom])
+		if err != nil {
+			return nil, err
+		}
+		curve = appendStop(curve, zoom, out, step)
+	}
+	return fixupStepCurve(curve), nil
+}
+
+// appendStop adds an input/output pair to a curve, skipping duplicate
+// inputs, which legacy functions allowed, and the redundant first input of
+// step curves.
+func appendStop(curve []interface{}, input, output interface{}, step bool) []interface{} {
+	if len(curve) > 3 && valuesEqual(input, curve[len(curve)-2]) {
+		return curve
+	}
+	if !(step && len(curve) == 2) {
+		curve = append(curve, input)
+	}
+	return append(curve, output)
+}
+
+// fixupStepCurve turns a step curve with a single output into a valid one
+// by adding a no-op stop.
+func fixupStepCurve(curve []interface{}) []interface{} {
+	if curve[0] == ExpStep && len(curve) == 3 {
+		curve = append(curve, 0.0, curve[2])
+	}
+	return curve
+}
+
+func convertIdentityFunction(fn map[string]interface{}, spec propertySpec) (interface{}, error) {
+	property, ok := fn["property"].(string)
+	if !ok {
+		return nil, errors.New("identity function requires a property")
+	}
+	get := []interface{}{ExpGet, property}
+	def, hasDefault := fn["default"]
+	if !hasDefault {
+		if spec.Type == specString {
+			return []interface{}{ExpString, get}, nil
+		}
+		return get, nil
+	}
+	switch spec.Type {
+	case specEnum:
+		values := make([]interface{}, len(spec.Values))
+		for i, v := range spec.Values {
+			values[i] = v
+		}
+		return []interface{}{ExpMatch, get, values, get, def}, nil
+	case specColor:
+		return []interface{}{ExpToColor, get, legacyLiteral(def)}, nil
+	case specNumber:
+		return []interface{}{ExpNumber, get, legacyLiteral(def)}, nil
+	case specBoolean:
+		return []interface{}{ExpBoolean, get, legacyLiteral(def)}, nil
+	case specString:
+		return []interface{}{ExpString, get, legacyLiteral(def)}, nil
+	case specArray:
+		var length interface{}
+		if spec.Length > 0 {
+			length = float64(spec.Length)
+		}
+		// Only the one argument form of "array" takes a value to assert;
+		// the default is applied when the assertion fails.
+		array := []interface{}{ExpArray, spec.Value}
+		if length != nil {
+			array = append(array, length)
+		}
+		return []interface{}{ExpCoalesce, append(array, get), legacyLiteral(def)}, nil
+	}
+	return []interface{}{ExpCoalesce, get, legacyLiteral(def)}, nil
+}
+
+// legacyLiteral wraps array and object values, which would otherwise be
+// read as expressions.
+func legacyLiteral(v interface{}) interface{} {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		return []interface{}{ExpLiteral, v}
+	}
+	return v
+}
+
+// convertTokenString rewrites "{name} ({ref})" as a concat of get
+// expressions. Strings without tokens are returned unchanged.
+func convertTokenString(s string) interface{} {
+	matches := tokenPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	out := []interface{}{ExpConcat}
+	pos := 0
+	for _, m := range matches {
+		if m[0] > pos {
+			out = append(out, s[pos:m[0]])
+		}
+		out = append(out, []interface{}{ExpGet, s[m[2]:m[3]]})
+		pos = m[1]
+	}
+	if pos < len(s) {
+		out = append(out, s[pos:])
+	} else if len(out) == 2 {
+		return []interface{}{ExpToString, out[1]}
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package style
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertLegacyFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		layer    LayerType
+		property string
+		fn       string
+		want     string
+	}{
+		{"zoom", LayerTypeLine, "line-width",
+			`{"base": 1.5, "stops": [[5, 1], [18, 20]]}`,
+			`["interpolate", ["exponential", 1.5], ["zoom"], 5, 1, 18, 20]`},
+		{"zoom_interval", LayerTypeLine, "line-cap",
+			`{"stops": [[10, "butt"]]}`,
+			`["step", ["zoom"], "butt", 0, "butt"]`},
+		{"zoom_tokens", LayerTypeSymbol, "text-field",
+			`{"stops": [[0, "{ref}"], [10, "{name} ({ref})"]]}`,
+			`["step", ["zoom"], ["to-string", ["get", "ref"]], 10, ["concat", ["get", "name"], " (", ["get", "ref"], ")"]]`},
+		{"categorical", LayerTypeFill, "fill-color",
+			`{"type": "categorical", "property": "kind", "stops": [["park", "green"], ["water", "blue"]], "default": "gray"}`,
+			`["match", ["get", "kind"], "park", "green", "water", "blue", "gray"]`},
+		{"categorical_boolean", LayerTypeFill, "fill-opacity",
+			`{"type": "categorical", "property": "hidden", "stops": [[true, 0]]}`,
+			`["case", ["==", ["get", "hidden"], true], 0, null]`},
+		{"interval_default", LayerTypeCircle, "circle-radius",
+			`{"type": "interval", "property": "rank", "stops": [[0, 2], [10, 4]], "default": 1}`,
+			`["case", ["==", ["typeof", ["get", "rank"]], "number"], ["step", ["number", ["get", "rank"]], 2, 10, 4], 1]`},
+		{"identity", LayerTypeSymbol, "text-offset",
+			`{"type": "identity", "property": "offset", "default": [0, 0]}`,
+			`["coalesce", ["array", "number", 2, ["get", "offset"]], ["literal", [0, 0]]]`},
+		{"composite", LayerTypeCircle, "circle-radius",
+			`{"property": "rank", "stops": [[{"zoom": 0, "value": 0}, 1], [{"zoom": 0, "value": 10}, 5], [{"zoom": 10, "value": 0}, 4]]}`,
+			`["interpolate", ["linear"], ["zoom"],
+				0, ["interpolate", ["linear"], ["number", ["get", "rank"]], 0, 1, 10, 5],
+				10, ["interpolate", ["linear"], ["number", ["get", "rank"]], 0, 4]]`},
+		{"hcl", LayerTypeFill, "fill-color",
+			`{"colorSpace": "hcl", "stops": [[0, "red"], [10, "blue"]]}`,
+			`["interpolate-hcl", ["linear"], ["zoom"], 0, "red", 10, "blue"]`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := ConvertLegacyFunction(tc.layer, tc.property, rawJSON(t, tc.fn).(map[string]interface{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(string(got), tc.want) {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// PropertyValue, which converts functions for a value type rather than a
+// named property, must agree with ConvertLegacyFunction.
+func TestConvertLegacyFunctionEquivalence(t *testing.T) {
+	fns := []string{
+		`{"base": 2, "stops": [[4, 1], [12, 8], [16, 30]]}`,
+		`{"type": "interval", "stops": [[4, 1], [12, 8]]}`,
+		`{"property": "rank", "stops": [[0, 1], [10, 6]]}`,
+		`{"property": "rank", "type": "interval", "stops": [[2, 1], [6, 3]], "default": 9}`,
+		`{"property": "rank", "stops": [[{"zoom": 4, "value": 0}, 1], [{"zoom": 4, "value": 10}, 2],
+			[{"zoom": 12, "value": 0}, 3], [{"zoom": 12, "value": 10}, 8]]}`,
+	}
+	for _, raw := range fns {
+		fn := rawJSON(t, raw).(map[string]interface{})
+		legacy, err := NewPropertyValue[float64](fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := ConvertLegacyFunction(LayerTypeCircle, "circle-radius", fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(e)
+		converted, err := NewPropertyValue[float64](rawJSON(t, string(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, zoom := range []float64{0, 4, 7, 12, 14, 20} {
+			for _, rank := range []float64{-1, 0, 3, 5, 10, 12} {
+				f := &Feature{Properties: map[string]interface{}{"rank": rank}}
+				want, err1 := legacy.Evaluate(zoom, f)
+				got, err2 := converted.Evaluate(zoom, f)
+				if err1 != nil || err2 != nil || math.Abs(want-got) > 1e-9 {
+					t.Fatalf("%s at zoom %v, rank %v: legacy %v (%v), expression %v (%v)", raw, zoom, rank, want, err1, got, err2)
+				}
+			}
+		}
+	}
+}
+
+func TestLegacyIdentityArray(t *testing.T) {
+	fn := rawJSON(t, `{"type": "identity", "property": "offset", "default": [1, 2]}`).(map[string]interface{})
+	e, err := ConvertLegacyFunction(LayerTypeSymbol, "text-offset", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := TypeCheckExpression(e, ArrayOf(TypeNumber, 2), "text-offset"); len(errs) > 0 {
+		t.Fatalf("converted identity function does not type check: %v", errs)
+	}
+	p, err := NewPropertyValue[[]float64](fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		want  []float64
+	}{
+		{"value", map[string]interface{}{"offset": []interface{}{3, 4}}, []float64{3, 4}},
+		{"missing", nil, []float64{1, 2}},
+		{"wrong_type", map[string]interface{}{"offset": "3"}, []float64{1, 2}},
+	}
+	for _, tc := range tests {
+		f := &Feature{Properties: tc.props}
+		v, err := e.Evaluate(EvalContext{Feature: f})
+		if err != nil || !valuesEqual(v, normalizeValue(tc.want)) {
+			t.Errorf("%s: expression = %v, %v, want %v", tc.name, v, err, tc.want)
+		}
+		got, err := p.Evaluate(0, f)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: property value = %v, %v, want %v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestStyleConvertLegacyFunctions(t *testing.T) {
+	s := parseStyle(t, `{"version": 8, "sources": {"s": {"type": "vector"}}, "layers": [
+		{"id": "a", "type": "symbol", "source": "s", "filter": ["==", "class", "city"],
+			"layout": {"text-field": "{name}", "text-size": {"stops": [[0, 10], [10, 20]]}},
+			"paint": {"text-color": {"property": "kind", "type": "categorical", "stops": [["a", "red"]], "default": "black"}}}
+	]}`)
+	if err := s.ConvertLegacyFunctions(); err != nil {
+		t.Fatal(err)
+	}
+	l := s.Layers[0]
+	if IsLegacyFilter(l.Filter.Expr) {
+		t.Fatal("filter not converted")
+	}
+	paint, _ := propertyMap(l.Paint)
+	layout, _ := propertyMap(l.Layout)
+	for name, v := range map[string]interface{}{"text-color": paint["text-color"], "text-size": layout["text-size"], "text-field": layout["text-field"]} {
+		if isLegacyFunction(v) {
+			t.Fatalf("%s not converted: %v", name, v)
+		}
+		if _, ok := v.([]interface{}); !ok {
+			t.Fatalf("%s: expected an expression, got %v", name, v)
+		}
+	}
+	size, err := l.Layout.TextSizeAt(5, nil)
+	if err != nil || size != 15 {
+		t.Fatalf("unexpected text-size %v %v", size, err)
+	}
+	c := l.Paint.TextColor.GetColorAtZoomLevel(0)
+	if ColorToCSS(c) != "#000000" {
+		t.Fatalf("unexpected text-color %v", c)
+	}
+	if errs := s.ValidateLayers(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+}
+
+func TestConvertLegacyFunctionsKeepsConfig(t *testing.T) {
+	s, err := Parse(strings.NewReader(`{"version": 8,
+		"schema": {"poiSize": {"default": 4, "type": "number"}},
+		"sources": {"s": {"type": "vector"}}, "layers": [
+		{"id": "a", "type": "circle", "source": "s",
+			"paint": {"circle-radius": ["config", "poiSize"], "circle-opacity": {"stops": [[0, 0.5], [10, 1]]}}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope, err := s.style.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.style.ConvertLegacyFunctions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Set("poiSize", 7); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.style.Layers[0].Paint.CircleRadiusAt(0, nil); err != nil || r != 7 {
+		t.Errorf("CircleRadiusAt after conversion = %v, %v, want 7", r, err)
+	}
+}
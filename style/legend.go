@@ -0,0 +1,458 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Layer metadata keys read by Legend.
+const (
+	// LegendLabelKey holds the human readable name of a layer.
+	LegendLabelKey = "legend:label"
+	// LegendClassLabelsKey holds an object mapping class values, such as
+	// match labels, to human readable names.
+	LegendClassLabelsKey = "legend:labels"
+	// LegendHiddenKey set to true leaves a layer out of the legend.
+	LegendHiddenKey = "legend:hidden"
+)
+
+// SwatchType is the shape a legend entry is drawn with.
+type SwatchType string
+
+const (
+	SwatchFill   SwatchType = "fill"
+	SwatchLine   SwatchType = "line"
+	SwatchCircle SwatchType = "circle"
+	SwatchIcon   SwatchType = "icon"
+	SwatchText   SwatchType = "text"
+	SwatchRaster SwatchType = "raster"
+)
+
+// LegendEntry is one class of a legend layer, or one stop of a ramp.
+type LegendEntry struct {
+	Label string
+	// Value is the attribute value the entry stands for: a match label, the
+	// lower bound of a step class or a ramp stop. It is nil for the match
+	// fallback, the step class below the first stop and layers that are not
+	// classified. Class labels in LegendClassLabelsKey use "" for these.
+	Value        interface{}
+	Color        color.Color
+	OutlineColor color.Color
+	Opacity      float64
+	LineWidth    float64
+	LineDash     []float64
+	Radius       float64
+	Icon         string
+}
+
+func (e *LegendEntry) MarshalJSON() ([]byte, error) {
+	css := func(c color.Color) string {
+		if c == nil {
+			return ""
+		}
+		return ColorToCSS(c)
+	}
+	return json.Marshal(struct {
+		Label        string      `json:"label"`
+		Value        interface{} `json:"value,omitempty"`
+		Color        string      `json:"color,omitempty"`
+		OutlineColor string      `json:"outline-color,omitempty"`
+		Opacity      float64     `json:"opacity"`
+		LineWidth    float64     `json:"line-width,omitempty"`
+		LineDash     []float64   `json:"line-dasharray,omitempty"`
+		Radius       float64     `json:"radius,omitempty"`
+		Icon         string      `json:"icon,omitempty"`
+	}{e.Label, e.Value, css(e.Color), css(e.OutlineColor), e.Opacity, e.LineWidth, e.LineDash, e.Radius, e.Icon})
+}
+
+// LegendLayer is the legend of one style layer.
+type LegendLayer struct {
+	ID     string     `json:"id"`
+	Label  string     `json:"label"`
+	Swatch SwatchType `json:"swatch"`
+	// Property is the feature attribute the layer is classified by, if any.
+	Property string `json:"property,omitempty"`
+	// Ramp is set when the entries are stops of a continuous ramp rather
+	// than discrete classes.
+	Ramp    bool           `json:"ramp,omitempty"`
+	Entries []*LegendEntry `json:"entries"`
+}
+
+// Legend describes how the layers of a style look at one zoom level.
+type Legend struct {
+	Zoom   float64        `json:"zoom"`
+	Layers []*LegendLayer `json:"layers"`
+}
+
+// Legend builds the legend of the layers visible at zoom, in style order.
+//
+// A layer whose main paint property (such as fill-color or line-width)
+// is a match, step or interpolate over feature data gets one entry per
+// match label, one per step class or, for interpolate, one per stop marked
+// as a ramp. Other layers get a single entry. Entries are evaluated with a
+// sample feature carrying the class value, so every paint property that
+// depends on the same attribute follows the class.
+func (s *Style) Legend(zoom float64) (*Legend, error) {
+	legend := &Legend{Zoom: zoom}
+	if _, err := s.Config(); err != nil {
+		return nil, err
+	}
+	for _, l := range s.Layers {
+		if l == nil || l.isHidden() || !l.VisibleAtZoom(zoom) {
+			continue
+		}
+		if hidden, _ := l.Metadata[LegendHiddenKey].(bool); hidden {
+			continue
+		}
+		ll, err := l.legend(zoom)
+		if err != nil {
+			return nil, err
+		}
+		if ll != nil {
+			legend.Layers = append(legend.Layers, ll)
+		}
+	}
+	return legend, nil
+}
+
+func (l *Layer) legend(zoom float64) (*LegendLayer, error) {
+	ll := &LegendLayer{ID: l.ID, Label: l.ID}
+	if label, ok := l.Metadata[LegendLabelKey].(string); ok && label != "" {
+		ll.Label = label
+	}
+	switch l.Type {
+	case LayerTypeFill, LayerTypeFillExtrusion, LayerTypeBackground:
+		ll.Swatch = SwatchFill
+	case LayerTypeLine:
+		ll.Swatch = SwatchLine
+	case LayerTypeCircle:
+		ll.Swatch = SwatchCircle
+	case LayerTypeSymbol:
+		ll.Swatch = SwatchText
+		if l.Layout != nil && l.Layout.IconImage != nil {
+			ll.Swatch = SwatchIcon
+		}
+	case LayerTypeRaster, LayerTypeHillshade, LayerTypeHeatmap:
+		ll.Swatch = SwatchRaster
+		ll.Entries = []*LegendEntry{{Label: ll.Label, Opacity: 1}}
+		return ll, nil
+	default:
+		return nil, nil
+	}
+
+	classes := l.legendClasses(ll)
+	for _, c := range classes {
+		entry, err := l.legendEntry(zoom, c.feature)
+		if err != nil {
+			return nil, err
+		}
+		entry.Label, entry.Value = c.label, c.value
+		ll.Entries = append(ll.Entries, entry)
+	}
+	return ll, nil
+}
+
+// legendClass is a legend entry before evaluation.
+type legendClass struct {
+	label   string
+	value   interface{}
+	feature *Feature
+}
+
+// legendClasses finds the first data driven match, step or interpolate in
+// the layer's main paint properties and returns a sample feature per class.
+func (l *Layer) legendClasses(ll *LegendLayer) []legendClass {
+	single := []legendClass{{label: ll.Label, feature: &Feature{Properties: map[string]interface{}{}}}}
+	var classifier *Expression
+	for _, e := range l.legendExpressions() {
+		if classifier = findClassifier(e); classifier != nil {
+			break
+		}
+	}
+	if classifier == nil {
+		return single
+	}
+	input := classifier.Args[0]
+	if classifier.Operator != ExpMatch && classifier.Operator != ExpStep {
+		input = classifier.Args[1]
+	}
+	if _, ok := sampleFeature(input, nil); !ok {
+		return single
+	}
+	ll.Property = inputProperty(input)
+	labels, _ := l.Metadata[LegendClassLabelsKey].(map[string]interface{})
+	label := func(v interface{}, def string) string {
+		if s, ok := labels[legendValueString(v)].(string); ok {
+			return s
+		}
+		return def
+	}
+	class := func(label string, v interface{}) legendClass {
+		f, _ := sampleFeature(input, v)
+		return legendClass{label: label, value: v, feature: f}
+	}
+	below := func(label string, sample float64) legendClass {
+		f, _ := sampleFeature(input, sample)
+		return legendClass{label: label, feature: f}
+	}
+
+	var classes []legendClass
+	args := classifier.Args
+	switch classifier.Operator {
+	case ExpMatch:
+		for i := 1; i+1 < len(args); i += 2 {
+			v := normalizeValue(expressionValue(args[i]))
+			first := v
+			if values, ok := v.([]interface{}); ok && len(values) > 0 {
+				first = values[0]
+			}
+			classes = append(classes, class(label(v, legendValueString(v)), first))
+		}
+		classes = append(classes, class(label("", "Other"), nil))
+	case ExpStep:
+		var stops []float64
+		for i := 2; i+1 < len(args); i += 2 {
+			if n, ok := expressionValue(args[i]).(float64); ok {
+				stops = append(stops, n)
+			}
+		}
+		if len(stops) == 0 {
+			return single
+		}
+		classes = append(classes, below(label("", "< "+formatNumber(stops[0])), stops[0]-1))
+		for i, stop := range stops {
+			def := "≥ " + formatNumber(stop)
+			if i+1 < len(stops) {
+				def = formatNumber(stop) + " – " + formatNumber(stops[i+1])
+			}
+			classes = append(classes, class(label(stop, def), stop))
+		}
+	default:
+		ll.Ramp = true
+		for i := 2; i+1 < len(args); i += 2 {
+			if n, ok := expressionValue(args[i]).(float64); ok {
+				classes = append(classes, class(label(n, formatNumber(n)), n))
+			}
+		}
+	}
+	return classes
+}
+
+// legendExpressions returns the main paint and layout properties of the
+// layer as expressions, in the order they are searched for a classifier.
+func (l *Layer) legendExpressions() []*Expression {
+	p, layout := l.Paint, l.Layout
+	if p == nil {
+		p = &Paint{}
+	}
+	if layout == nil {
+		layout = &Layout{}
+	}
+	var out []*Expression
+	color := func(c *ColorType) {
+		if c == nil {
+			return
+		}
+		if e, ok := c.internalType.(*expressionColorType); ok {
+			out = append(out, e.Expr)
+		}
+	}
+	value := func(name string, raw interface{}) {
+		if raw == nil {
+			return
+		}
+		if fn, ok := raw.(map[string]interface{}); ok && isLegacyFunction(fn) {
+			if e, err := ConvertLegacyFunction(l.Type, name, fn); err == nil {
+				out = append(out, e)
+			}
+			return
+		}
+		if e, err := propertyExpression(raw); err == nil {
+			out = append(out, e)
+		}
+	}
+	switch l.Type {
+	case LayerTypeFill:
+		color(p.FillColor)
+		color(p.FillOutlineColor)
+		value("fill-opacity", p.FillOpacity)
+	case LayerTypeFillExtrusion:
+		color(p.FillExtrusionColor)
+	case LayerTypeLine:
+		color(p.LineColor)
+		value("line-width", p.LineWidth)
+		value("line-opacity", p.LineOpacity)
+	case LayerTypeCircle:
+		color(p.CircleColor)
+		value("circle-radius", p.CircleRadius)
+		color(p.CircleStrokeColor)
+	case LayerTypeSymbol:
+		value("icon-image", tokenValue(layout.IconImage))
+		color(p.TextColor)
+		color(p.IconColor)
+	}
+	return out
+}
+
+// findClassifier returns the outermost match, step or interpolate whose
+// input depends on feature data.
+func findClassifier(e *Expression) *Expression {
+	if e == nil || e.IsLiteral {
+		return nil
+	}
+	switch e.Operator {
+	case ExpMatch, ExpStep:
+		if len(e.Args) > 2 && IsDataExpression(e.Args[0]) {
+			return e
+		}
+	case ExpInterpolate, ExpInterpolateHCL, ExpInterpolateLab:
+		if len(e.Args) > 3 && IsDataExpression(e.Args[1]) {
+			return e
+		}
+	}
+	for _, arg := range e.Args {
+		if c := findClassifier(arg); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// legendPassThrough are operators whose result follows their first argument
+// closely enough to sample it with the argument's value.
+var legendPassThrough = map[string]bool{
+	ExpToNumber: true, ExpNumber: true, ExpToString: true, ExpString: true,
+	ExpToBool: true, ExpBoolean: true, ExpDowncase: true, ExpUpcase: true,
+}
+
+// sampleFeature returns a feature for which input evaluates to v. ok is
+// false if input is not a plain attribute, id or geometry type lookup.
+func sampleFeature(input *Expression, v interface{}) (*Feature, bool) {
+	f := &Feature{Properties: map[string]interface{}{}}
+	for input != nil && !input.IsLiteral && legendPassThrough[input.Operator] && len(input.Args) > 0 {
+		input = input.Args[0]
+	}
+	if input == nil || input.IsLiteral {
+		return nil, false
+	}
+	switch input.Operator {
+	case ExpGet:
+		name, ok := literalString(input.Args, 0)
+		if !ok || len(input.Args) != 1 {
+			return nil, false
+		}
+		if v != nil {
+			f.Properties[name] = v
+		}
+	case ExpID:
+		f.ID = v
+	case ExpGeometryType:
+		f.Type, _ = v.(string)
+	default:
+		return nil, false
+	}
+	return f, true
+}
+
+// inputProperty returns the attribute a classifier input reads, or
+// "$id" and "$type" for the feature id and geometry type.
+func inputProperty(input *Expression) string {
+	for !input.IsLiteral && legendPassThrough[input.Operator] && len(input.Args) > 0 {
+		input = input.Args[0]
+	}
+	switch input.Operator {
+	case ExpGet:
+		name, _ := literalString(input.Args, 0)
+		return name
+	case ExpID:
+		return "$id"
+	case ExpGeometryType:
+		return FilterCategoryType
+	}
+	return ""
+}
+
+func legendValueString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return formatNumber(t)
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, item := range t {
+			parts[i] = legendValueString(item)
+		}
+		return strings.Join(parts, ", ")
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// legendEntry evaluates the paint properties of a swatch for a sample
+// feature. Properties that fail to evaluate keep their default.
+func (l *Layer) legendEntry(zoom float64, f *Feature) (*LegendEntry, error) {
+	p := l.Paint
+	if p == nil {
+		p = &Paint{}
+	}
+	e := &LegendEntry{Opacity: 1}
+	evalColor := func(c *ColorType) color.Color {
+		col, err := c.Evaluate(zoom, f)
+		if err != nil {
+			return nil
+		}
+		return col
+	}
+	switch l.Type {
+	case LayerTypeBackground:
+		e.Color = evalColor(p.BackgroundColor)
+		keep(&e.Opacity)(p.BackgroundOpacityAt(zoom, f))
+	case LayerTypeFill:
+		e.Color = evalColor(p.FillColor)
+		e.OutlineColor = evalColor(p.FillOutlineColor)
+		keep(&e.Opacity)(p.FillOpacityAt(zoom, f))
+	case LayerTypeFillExtrusion:
+		e.Color = evalColor(p.FillExtrusionColor)
+		keep(&e.Opacity)(p.FillExtrusionOpacityAt(zoom, f))
+	case LayerTypeLine:
+		e.Color = evalColor(p.LineColor)
+		e.LineWidth = 1
+		keep(&e.Opacity)(p.LineOpacityAt(zoom, f))
+		keep(&e.LineWidth)(p.LineWidthAt(zoom, f))
+		keep(&e.LineDash)(p.LineDashArrayAt(zoom, f))
+	case LayerTypeCircle:
+		e.Color = evalColor(p.CircleColor)
+		e.OutlineColor = evalColor(p.CircleStrokeColor)
+		e.Radius = 5
+		keep(&e.Opacity)(p.CircleOpacityAt(zoom, f))
+		keep(&e.Radius)(p.CircleRadiusAt(zoom, f))
+		keep(&e.LineWidth)(p.CircleStrokeWidthAt(zoom, f))
+	case LayerTypeSymbol:
+		e.Color = evalColor(p.TextColor)
+		keep(&e.Opacity)(p.TextOpacityAt(zoom, f))
+		if l.Layout != nil && l.Layout.IconImage != nil {
+			e.Color = evalColor(p.IconColor)
+			keep(&e.Opacity)(p.IconOpacityAt(zoom, f))
+			icon, err := l.Layout.IconImageAt(zoom, f)
+			if err != nil {
+				return nil, err
+			}
+			e.Icon = icon
+		}
+	}
+	return e, nil
+}
+
+// keep returns a function that stores an evaluated value in dst unless
+// evaluation failed, so dst keeps its default.
+func keep[T any](dst *T) func(T, error) {
+	return func(v T, err error) {
+		if err == nil {
+			*dst = v
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+)
+
+const legendStyle = `{
+	"version": 8,
+	"sources": {"s": {"type": "vector"}},
+	"layers": [
+		{"id": "bg", "type": "background", "paint": {"background-color": "#eeeeee"},
+		 "metadata": {"legend:hidden": true}},
+		{"id": "landuse", "type": "fill", "source": "s", "source-layer": "landuse",
+		 "metadata": {"legend:label": "Land use", "legend:labels": {"park": "Parks", "": "Other land"}},
+		 "paint": {
+			"fill-color": ["match", ["get", "class"], "park", "#00ff00", ["wood", "forest"], "#008000", "#cccccc"],
+			"fill-opacity": ["match", ["get", "class"], "park", 0.5, 1]
+		 }},
+		{"id": "roads", "type": "line", "source": "s", "source-layer": "roads",
+		 "paint": {"line-color": "#ff0000", "line-dasharray": [2, 1],
+			"line-width": ["step", ["get", "lanes"], 1, 2, 3, 4, 5]}},
+		{"id": "density", "type": "circle", "source": "s", "source-layer": "places",
+		 "paint": {"circle-radius": 4,
+			"circle-color": ["interpolate", ["linear"], ["get", "pop"], 0, "#000000", 100, "#ffffff"]}},
+		{"id": "zoomed", "type": "circle", "source": "s", "source-layer": "places", "minzoom": 12},
+		{"id": "pois", "type": "symbol", "source": "s", "source-layer": "pois",
+		 "layout": {"icon-image": "{maki}-11"}}
+	]
+}`
+
+func TestLegend(t *testing.T) {
+	s := parseStyle(t, legendStyle)
+	legend, err := s.Legend(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(legend.Layers) != 4 {
+		t.Fatalf("layers = %d", len(legend.Layers))
+	}
+
+	landuse := legend.Layers[0]
+	if landuse.Label != "Land use" || landuse.Swatch != SwatchFill || landuse.Property != "class" || landuse.Ramp {
+		t.Errorf("landuse = %+v", landuse)
+	}
+	wantFill := []struct {
+		label   string
+		value   interface{}
+		color   string
+		opacity float64
+	}{
+		{"Parks", "park", "#00ff00", 0.5},
+		{"wood, forest", "wood", "#008000", 1},
+		{"Other land", nil, "#cccccc", 1},
+	}
+	if len(landuse.Entries) != len(wantFill) {
+		t.Fatalf("landuse entries = %d", len(landuse.Entries))
+	}
+	for i, want := range wantFill {
+		e := landuse.Entries[i]
+		if e.Label != want.label || e.Value != want.value || ColorToCSS(e.Color) != want.color || e.Opacity != want.opacity {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+
+	roads := legend.Layers[1]
+	wantLabels := []string{"< 2", "2 – 4", "≥ 4"}
+	wantWidths := []float64{1, 3, 5}
+	if roads.Swatch != SwatchLine || len(roads.Entries) != 3 {
+		t.Fatalf("roads = %+v", roads)
+	}
+	for i, e := range roads.Entries {
+		if e.Label != wantLabels[i] || e.LineWidth != wantWidths[i] || ColorToCSS(e.Color) != "#ff0000" || len(e.LineDash) != 2 {
+			t.Errorf("roads entry %d = %+v", i, e)
+		}
+	}
+
+	density := legend.Layers[2]
+	if !density.Ramp || len(density.Entries) != 2 || density.Entries[1].Label != "100" ||
+		density.Entries[1].Color != (color.RGBA{255, 255, 255, 255}) || density.Entries[0].Radius != 4 {
+		t.Errorf("density = %+v", density)
+	}
+
+	pois := legend.Layers[3]
+	if pois.Swatch != SwatchIcon || len(pois.Entries) != 1 || pois.Entries[0].Label != "pois" {
+		t.Errorf("pois = %+v", pois)
+	}
+}
+
+func TestLegendEvaluationErrorKeepsDefault(t *testing.T) {
+	s := parseStyle(t, `{
+		"version": 8,
+		"sources": {"s": {"type": "vector"}},
+		"layers": [{"id": "water", "type": "fill", "source": "s", "source-layer": "water",
+			"paint": {"fill-color": "blue", "fill-opacity": ["number", ["get", "missing"]]}}]
+	}`)
+	legend, err := s.Legend(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := legend.Layers[0].Entries[0].Opacity; got != 1 {
+		t.Errorf("opacity = %v, want the default 1", got)
+	}
+}
+
+func TestLegendJSON(t *testing.T) {
+	s := parseStyle(t, `{
+		"version": 8,
+		"sources": {},
+		"layers": [{"id": "bg", "type": "background", "paint": {"background-color": "blue"}}]
+	}`)
+	legend, err := s.Legend(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(legend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"zoom":0,"layers":[{"id":"bg","label":"bg","swatch":"fill","entries":[
+		{"label":"bg","color":"#0000ff","opacity":1}]}]}`
+	if !jsonEqual(string(data), want) {
+		t.Errorf("json = %s", data)
+	}
+}
@@ -0,0 +1,226 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/flywave/go-mapbox/tilejson"
+	"github.com/pkg/errors"
+)
+
+// Severity ranks lint findings.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses "info", "warning" or "error".
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown severity %q", name)
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	v, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// LintFinding is one problem reported by a lint rule. Path locates the
+// offending value like ValidationError.Path does.
+type LintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"`
+	Layer    string   `json:"layer,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %s [%s]", f.Severity, f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", f.Severity, f.Path, f.Message, f.Rule)
+}
+
+// LintSource describes the data behind a style source. Both fields are
+// optional; rules that need missing data skip the source.
+type LintSource struct {
+	// TileJSON is the source's TileJSON, used for its vector_layers.
+	TileJSON *tilejson.TileJSON
+	// Attributes lists the attribute names of each source layer, as found
+	// in the tileset's tilestats (see mbtiles.TileStats.Attributes).
+	Attributes map[string][]string
+}
+
+// LintContext is the input of a lint run.
+type LintContext struct {
+	Style *Style
+	// Sources holds tileset metadata keyed by style source id.
+	Sources map[string]*LintSource
+	// Document optionally holds the style JSON Style was decoded from, for
+	// rules about keys the style model drops, such as removed properties.
+	Document []byte
+}
+
+func (c *LintContext) source(id string) *LintSource {
+	if c.Sources == nil {
+		return nil
+	}
+	return c.Sources[id]
+}
+
+// LintRule checks a style for one kind of problem. Rules report findings
+// with an empty Rule and Severity; the linter fills them in.
+type LintRule interface {
+	Name() string
+	Description() string
+	DefaultSeverity() Severity
+	Check(ctx *LintContext) []LintFinding
+}
+
+var (
+	lintRulesMu sync.RWMutex
+	lintRules   = map[string]LintRule{}
+)
+
+// RegisterLintRule makes a rule available to NewLinter. It panics if a rule
+// with the same name is already registered.
+func RegisterLintRule(r LintRule) {
+	lintRulesMu.Lock()
+	defer lintRulesMu.Unlock()
+	if _, dup := lintRules[r.Name()]; dup {
+		panic("style: RegisterLintRule called twice for rule " + r.Name())
+	}
+	lintRules[r.Name()] = r
+}
+
+// LintRules returns the registered rules sorted by name.
+func LintRules() []LintRule {
+	lintRulesMu.RLock()
+	defer lintRulesMu.RUnlock()
+	rules := make([]LintRule, 0, len(lintRules))
+	for _, r := range lintRules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name() < rules[j].Name() })
+	return rules
+}
+
+// Linter runs a set of rules. Severities overrides the default severity of
+// a rule by name and Disabled switches rules off.
+type Linter struct {
+	Rules      []LintRule
+	Severities map[string]Severity
+	Disabled   map[string]bool
+}
+
+// NewLinter returns a linter running every registered rule.
+func NewLinter() *Linter {
+	return &Linter{
+		Rules:      LintRules(),
+		Severities: map[string]Severity{},
+		Disabled:   map[string]bool{},
+	}
+}
+
+// Lint runs the enabled rules and returns their findings ordered by
+// severity, most severe first, then by path.
+func (l *Linter) Lint(ctx *LintContext) *LintReport {
+	report := &LintReport{Findings: []LintFinding{}}
+	if ctx == nil || ctx.Style == nil {
+		return report
+	}
+	for _, r := range l.Rules {
+		if l.Disabled[r.Name()] {
+			continue
+		}
+		severity, ok := l.Severities[r.Name()]
+		if !ok {
+			severity = r.DefaultSeverity()
+		}
+		for _, f := range r.Check(ctx) {
+			f.Rule = r.Name()
+			f.Severity = severity
+			report.Findings = append(report.Findings, f)
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		return a.Path < b.Path
+	})
+	return report
+}
+
+// Lint runs every registered rule over the style.
+func (s *Style) Lint(sources map[string]*LintSource) *LintReport {
+	return NewLinter().Lint(&LintContext{Style: s, Sources: sources})
+}
+
+// LintJSON decodes the style document data and runs every registered rule
+// over it, including those that inspect the document as written.
+func LintJSON(data []byte, sources map[string]*LintSource) (*LintReport, error) {
+	var s Style
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrap(err, "decoding style")
+	}
+	return NewLinter().Lint(&LintContext{Style: &s, Sources: sources, Document: data}), nil
+}
+
+// LintReport is the result of a lint run. It marshals to JSON for CI.
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+}
+
+// Count returns the number of findings at or above the given severity.
+func (r *LintReport) Count(min Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors reports whether any finding has error severity.
+func (r *LintReport) HasErrors() bool {
+	return r.Count(SeverityError) > 0
+}
+
+// JSON returns the report as indented JSON.
+func (r *LintReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
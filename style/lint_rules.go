@@ -0,0 +1,443 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterLintRule(duplicateLayerIDRule{})
+	RegisterLintRule(unknownSourceLayerRule{})
+	RegisterLintRule(unknownTextFieldRule{})
+	RegisterLintRule(hiddenLayerRule{})
+	RegisterLintRule(deprecatedSyntaxRule{})
+	RegisterLintRule(deprecatedPropertyRule{})
+	RegisterLintRule(featureStateRule{})
+}
+
+var tokenPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+type duplicateLayerIDRule struct{}
+
+func (duplicateLayerIDRule) Name() string              { return "duplicate-layer-id" }
+func (duplicateLayerIDRule) Description() string       { return "layer ids must be unique" }
+func (duplicateLayerIDRule) DefaultSeverity() Severity { return SeverityError }
+
+func (duplicateLayerIDRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	seen := map[string]int{}
+	for i, l := range ctx.Style.Layers {
+		if l == nil || l.ID == "" {
+			continue
+		}
+		if first, ok := seen[l.ID]; ok {
+			out = append(out, LintFinding{
+				Path:    fmt.Sprintf("layers[%d].id", i),
+				Layer:   l.ID,
+				Message: fmt.Sprintf("duplicate layer id %q, previously used at layers[%d]", l.ID, first),
+			})
+			continue
+		}
+		seen[l.ID] = i
+	}
+	return out
+}
+
+type unknownSourceLayerRule struct{}
+
+func (unknownSourceLayerRule) Name() string { return "unknown-source-layer" }
+func (unknownSourceLayerRule) Description() string {
+	return "source-layer must be listed in the vector_layers of the source's TileJSON"
+}
+func (unknownSourceLayerRule) DefaultSeverity() Severity { return SeverityError }
+
+func (unknownSourceLayerRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	for i, l := range ctx.Style.Layers {
+		if l == nil || l.Source == nil || l.SourceLayer == nil {
+			continue
+		}
+		src := ctx.source(*l.Source)
+		if src == nil || src.TileJSON == nil || len(src.TileJSON.VectorLayers) == 0 {
+			continue
+		}
+		found := false
+		for _, vl := range src.TileJSON.VectorLayers {
+			if vl.ID == *l.SourceLayer {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, LintFinding{
+				Path:    fmt.Sprintf("layers[%d].source-layer", i),
+				Layer:   l.ID,
+				Message: fmt.Sprintf("source layer %q is not in the vector_layers of source %q", *l.SourceLayer, *l.Source),
+			})
+		}
+	}
+	return out
+}
+
+type unknownTextFieldRule struct{}
+
+func (unknownTextFieldRule) Name() string { return "unknown-text-field-attribute" }
+func (unknownTextFieldRule) Description() string {
+	return "text-field must only reference attributes present in the tileset's tilestats"
+}
+func (unknownTextFieldRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (unknownTextFieldRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	for i, l := range ctx.Style.Layers {
+		if l == nil || l.Layout == nil || l.Layout.TextField == nil || l.Source == nil || l.SourceLayer == nil {
+			continue
+		}
+		src := ctx.source(*l.Source)
+		if src == nil || src.Attributes == nil {
+			continue
+		}
+		attrs, ok := src.Attributes[*l.SourceLayer]
+		if !ok {
+			continue
+		}
+		known := map[string]bool{}
+		for _, a := range attrs {
+			known[a] = true
+		}
+		for _, name := range attributeReferences(normalizeValue(l.Layout.TextField)) {
+			if !known[name] {
+				out = append(out, LintFinding{
+					Path:    fmt.Sprintf("layers[%d].layout.text-field", i),
+					Layer:   l.ID,
+					Message: fmt.Sprintf("attribute %q is not present in source layer %q", name, *l.SourceLayer),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// attributeReferences returns the feature attributes a property value
+// reads, through {token} strings, legacy function properties or get
+// expressions, without duplicates.
+func attributeReferences(raw interface{}) []string {
+	seen := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var tokens func(v interface{})
+	tokens = func(v interface{}) {
+		if s, ok := v.(string); ok {
+			for _, m := range tokenPattern.FindAllStringSubmatch(s, -1) {
+				add(m[1])
+			}
+		}
+	}
+	if isLegacyFunction(raw) {
+		fn := raw.(map[string]interface{})
+		if p, ok := fn["property"].(string); ok {
+			add(p)
+		}
+		stops, _ := fn["stops"].([]interface{})
+		for _, stop := range stops {
+			if pair, ok := stop.([]interface{}); ok && len(pair) == 2 {
+				tokens(pair[1])
+			}
+		}
+		return names
+	}
+	e, err := propertyExpression(raw)
+	if err != nil {
+		return nil
+	}
+	if e.IsLiteral {
+		tokens(e.Value)
+		return names
+	}
+	walkExpression(e, func(e *Expression) {
+		if (e.Operator == ExpGet || e.Operator == ExpHas) && len(e.Args) == 1 {
+			if name, ok := literalString(e.Args, 0); ok {
+				add(name)
+			}
+		}
+	})
+	return names
+}
+
+// walkExpression calls fn for e and each of its sub-expressions.
+func walkExpression(e *Expression, fn func(*Expression)) {
+	if e == nil || e.IsLiteral {
+		return
+	}
+	fn(e)
+	for _, arg := range e.Args {
+		walkExpression(arg, fn)
+	}
+}
+
+func usesOperator(e *Expression, op string) bool {
+	found := false
+	walkExpression(e, func(e *Expression) {
+		if e.Operator == op {
+			found = true
+		}
+	})
+	return found
+}
+
+type hiddenLayerRule struct{}
+
+func (hiddenLayerRule) Name() string { return "hidden-layer" }
+func (hiddenLayerRule) Description() string {
+	return "layers must not be covered by an opaque background or fill over their zoom range"
+}
+func (hiddenLayerRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (hiddenLayerRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	layers := ctx.Style.Layers
+	for i, below := range layers {
+		if below == nil || below.isHidden() {
+			continue
+		}
+		for _, above := range layers[i+1:] {
+			if above == nil || !above.covers(below) {
+				continue
+			}
+			minA, maxA := above.zoomRange()
+			minB, maxB := below.zoomRange()
+			lo, hi := max(minA, minB), min(maxA, maxB)
+			if lo >= hi {
+				continue
+			}
+			out = append(out, LintFinding{
+				Path:    fmt.Sprintf("layers[%d]", i),
+				Layer:   below.ID,
+				Message: fmt.Sprintf("layer is hidden by opaque layer %q at zoom %s to %s", above.ID, formatNumber(lo), formatNumber(hi)),
+			})
+			break
+		}
+	}
+	return out
+}
+
+func (l *Layer) isHidden() bool {
+	return l.Layout != nil && l.Layout.Visibility == "none"
+}
+
+// zoomRange returns the layer's [minzoom, maxzoom) range.
+func (l *Layer) zoomRange() (float64, float64) {
+	lo, hi := 0.0, 24.0
+	if l.MinZoom != nil {
+		lo = *l.MinZoom
+	}
+	if l.MaxZoom != nil {
+		hi = *l.MaxZoom
+	}
+	return lo, hi
+}
+
+// covers reports whether l, drawn above other, hides it completely: l is an
+// opaque background, or an opaque unfiltered fill of the same source layer
+// as the fill other.
+func (l *Layer) covers(other *Layer) bool {
+	if l.isHidden() {
+		return false
+	}
+	p := l.Paint
+	if p == nil {
+		p = &Paint{}
+	}
+	switch l.Type {
+	case LayerTypeBackground:
+		return p.BackgroundPattern == nil && isOpaqueOpacity(p.BackgroundOpacity) && isOpaqueColor(p.BackgroundColor)
+	case LayerTypeFill:
+		return other.Type == LayerTypeFill && l.Filter == nil &&
+			sameString(l.Source, other.Source) && sameString(l.SourceLayer, other.SourceLayer) &&
+			p.FillPattern == nil && isOpaqueOpacity(p.FillOpacity) && isOpaqueColor(p.FillColor)
+	}
+	return false
+}
+
+func isOpaqueOpacity(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	n, ok := normalizeValue(v).(float64)
+	return ok && n >= 1
+}
+
+// isOpaqueColor reports whether c is a constant opaque color. An unset
+// color defaults to opaque black.
+func isOpaqueColor(c *ColorType) bool {
+	if c == nil || c.internalType == nil {
+		return true
+	}
+	plain, ok := c.internalType.(plainColorType)
+	if !ok || plain.Color == nil {
+		return false
+	}
+	_, _, _, a := plain.Color.RGBA()
+	return a == 0xffff
+}
+
+func sameString(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+type deprecatedSyntaxRule struct{}
+
+func (deprecatedSyntaxRule) Name() string { return "deprecated-syntax" }
+func (deprecatedSyntaxRule) Description() string {
+	return "legacy functions and filters are deprecated in favour of expressions"
+}
+func (deprecatedSyntaxRule) DefaultSeverity() Severity { return SeverityInfo }
+
+func (deprecatedSyntaxRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	for i, l := range ctx.Style.Layers {
+		if l == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("layers[%d]", i)
+		if l.Filter != nil && IsLegacyFilter(l.Filter.Expr) {
+			out = append(out, LintFinding{
+				Path:    prefix + ".filter",
+				Layer:   l.ID,
+				Message: "legacy filter syntax is deprecated, use an expression",
+			})
+		}
+		for _, group := range []struct {
+			name  string
+			value interface{}
+		}{{"paint", l.Paint}, {"layout", l.Layout}} {
+			props, err := propertyMap(group.value)
+			if err != nil {
+				continue
+			}
+			for _, name := range sortedKeys(props) {
+				if isLegacyFunction(props[name]) {
+					out = append(out, LintFinding{
+						Path:    prefix + "." + group.name + "." + name,
+						Layer:   l.ID,
+						Message: "function syntax is deprecated, use an expression",
+					})
+				}
+			}
+		}
+	}
+	return out
+}
+
+type deprecatedPropertyRule struct{}
+
+func (deprecatedPropertyRule) Name() string { return "deprecated-property" }
+func (deprecatedPropertyRule) Description() string {
+	return "paint and layout properties removed from the style specification are ignored"
+}
+func (deprecatedPropertyRule) DefaultSeverity() Severity { return SeverityWarning }
+
+// Check inspects LintContext.Document, since the style model drops removed
+// properties when decoding. Without a document it reports nothing.
+func (deprecatedPropertyRule) Check(ctx *LintContext) []LintFinding {
+	if len(ctx.Document) == 0 {
+		return nil
+	}
+	var doc struct {
+		Layers []map[string]interface{} `json:"layers"`
+	}
+	if err := json.Unmarshal(ctx.Document, &doc); err != nil {
+		return nil
+	}
+	var out []LintFinding
+	for i, l := range doc.Layers {
+		id, _ := l["id"].(string)
+		prefix := fmt.Sprintf("layers[%d]", i)
+		add := func(path, message string) {
+			out = append(out, LintFinding{Path: prefix + path, Layer: id, Message: message})
+		}
+		for _, key := range sortedKeys(l) {
+			if class, ok := strings.CutPrefix(key, "paint."); ok {
+				add("."+key, fmt.Sprintf("paint class %q was removed, use expressions or a separate layer", class))
+			}
+		}
+		paint, _ := l["paint"].(map[string]interface{})
+		for _, key := range sortedKeys(paint) {
+			if to, ok := renamedPaintV7[key]; ok {
+				add(".paint."+key, fmt.Sprintf("%q was removed, use %q", key, to))
+			} else if slices.Contains(movedToLayoutV7, key) {
+				add(".paint."+key, fmt.Sprintf("%q is a layout property", key))
+			}
+		}
+		layout, _ := l["layout"].(map[string]interface{})
+		for _, key := range sortedKeys(layout) {
+			if to, ok := renamedLayoutV7[key]; ok {
+				add(".layout."+key, fmt.Sprintf("%q was removed, use %q", key, to))
+			} else if slices.Contains(removedLayoutV7, key) {
+				add(".layout."+key, fmt.Sprintf("%q was removed", key))
+			}
+		}
+	}
+	return out
+}
+
+type featureStateRule struct{}
+
+func (featureStateRule) Name() string { return "feature-state-without-promote-id" }
+func (featureStateRule) Description() string {
+	return "feature-state needs feature ids, so the source should set promoteId"
+}
+func (featureStateRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (featureStateRule) Check(ctx *LintContext) []LintFinding {
+	var out []LintFinding
+	for i, l := range ctx.Style.Layers {
+		if l == nil || l.Source == nil || l.Paint == nil {
+			continue
+		}
+		src, ok := ctx.Style.Sources[*l.Source]
+		if !ok || src == nil || src.PromoteID != nil {
+			continue
+		}
+		if src.Type != "vector" && src.Type != "geojson" {
+			continue
+		}
+		if src.Type == "geojson" && src.GenerateID != nil && *src.GenerateID {
+			continue
+		}
+		props, err := propertyMap(l.Paint)
+		if err != nil {
+			continue
+		}
+		for _, name := range sortedKeys(props) {
+			e, err := propertyExpression(props[name])
+			if err != nil || !usesOperator(e, ExpFeatureState) {
+				continue
+			}
+			out = append(out, LintFinding{
+				Path:    fmt.Sprintf("layers[%d].paint.%s", i, name),
+				Layer:   l.ID,
+				Message: fmt.Sprintf("feature-state is used but source %q has no promoteId", *l.Source),
+			})
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
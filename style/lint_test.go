@@ -0,0 +1,132 @@
+package style
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/flywave/go-mapbox/tilejson"
+)
+
+const lintStyle = `{
+	"version": 8,
+	"sources": {
+		"streets": {"type": "vector", "url": "mapbox://mapbox.streets"},
+		"states": {"type": "geojson", "data": "states.geojson"}
+	},
+	"layers": [
+		{"id": "land", "type": "fill", "source": "streets", "source-layer": "landuse"},
+		{"id": "bg", "type": "background", "minzoom": 12, "paint": {"background-color": "#fff"}},
+		{"id": "water", "type": "fill", "source": "streets", "source-layer": "waterway",
+			"filter": ["==", "class", "river"], "paint": {"fill-opacity": {"stops": [[0, 0.5], [10, 1]]}}},
+		{"id": "state-hover", "type": "fill", "source": "states",
+			"paint": {"fill-color": ["case", ["boolean", ["feature-state", "hover"], false], "red", "blue"]}},
+		{"id": "labels", "type": "symbol", "source": "streets", "source-layer": "road",
+			"layout": {"text-field": ["coalesce", ["get", "name_en"], ["get", "name"]]}},
+		{"id": "shields", "type": "symbol", "source": "streets", "source-layer": "road",
+			"layout": {"text-field": "{ref} {shield}"}},
+		{"id": "labels", "type": "circle", "source": "streets", "source-layer": "poi"}
+	]
+}`
+
+func TestLint(t *testing.T) {
+	tj := tilejson.New(nil)
+	for _, id := range []string{"landuse", "water", "road", "poi"} {
+		tj.AddVectorLayer(tilejson.NewVectorLayer(id, nil))
+	}
+	sources := map[string]*LintSource{
+		"streets": {TileJSON: tj, Attributes: map[string][]string{"road": {"name", "ref", "class"}}},
+	}
+	report := parseStyle(t, lintStyle).Lint(sources)
+
+	want := []LintFinding{
+		{Rule: "unknown-source-layer", Severity: SeverityError, Path: "layers[2].source-layer", Layer: "water"},
+		{Rule: "duplicate-layer-id", Severity: SeverityError, Path: "layers[6].id", Layer: "labels"},
+		{Rule: "hidden-layer", Severity: SeverityWarning, Path: "layers[0]", Layer: "land"},
+		{Rule: "feature-state-without-promote-id", Severity: SeverityWarning, Path: "layers[3].paint.fill-color", Layer: "state-hover"},
+		{Rule: "unknown-text-field-attribute", Severity: SeverityWarning, Path: "layers[4].layout.text-field", Layer: "labels"},
+		{Rule: "unknown-text-field-attribute", Severity: SeverityWarning, Path: "layers[5].layout.text-field", Layer: "shields"},
+		{Rule: "deprecated-syntax", Severity: SeverityInfo, Path: "layers[2].filter", Layer: "water"},
+		{Rule: "deprecated-syntax", Severity: SeverityInfo, Path: "layers[2].paint.fill-opacity", Layer: "water"},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("expected %d findings, got %d: %v", len(want), len(report.Findings), report.Findings)
+	}
+	for i, f := range report.Findings {
+		f.Message = ""
+		if f != want[i] {
+			t.Errorf("finding %d: expected %+v, got %+v", i, want[i], f)
+		}
+	}
+	if !report.HasErrors() || report.Count(SeverityWarning) != 6 {
+		t.Fatalf("unexpected counts: %d errors, %d warnings or worse", report.Count(SeverityError), report.Count(SeverityWarning))
+	}
+}
+
+func TestLinterOverrides(t *testing.T) {
+	l := NewLinter()
+	l.Severities["duplicate-layer-id"] = SeverityWarning
+	for _, r := range l.Rules {
+		if r.Name() != "duplicate-layer-id" {
+			l.Disabled[r.Name()] = true
+		}
+	}
+	report := l.Lint(&LintContext{Style: parseStyle(t, lintStyle)})
+	if len(report.Findings) != 1 || report.HasErrors() {
+		t.Fatalf("unexpected findings %v", report.Findings)
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded LintReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Findings[0] != report.Findings[0] {
+		t.Fatalf("round trip mismatch: %s", data)
+	}
+	if !jsonEqual(string(data), `{"findings": [{"rule": "duplicate-layer-id", "severity": "warning", "path": "layers[6].id",
+		"layer": "labels", "message": "duplicate layer id \"labels\", previously used at layers[4]"}]}`) {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+}
+
+func TestLintDeprecatedProperties(t *testing.T) {
+	report, err := LintJSON([]byte(`{
+		"version": 8,
+		"sources": {"streets": {"type": "vector"}},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-image": "paper", "background-color": "#fff"}},
+			{"id": "labels", "type": "symbol", "source": "streets", "source-layer": "poi",
+				"paint": {"text-size": 12}, "paint.night": {"text-color": "#fff"},
+				"layout": {"symbol-min-distance": 100, "text-max-size": 20, "text-field": "{name}"}}
+		]
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range report.Findings {
+		if f.Rule == "deprecated-property" {
+			got = append(got, f.Path+": "+f.Message)
+		}
+	}
+	want := []string{
+		`layers[0].paint.background-image: "background-image" was removed, use "background-pattern"`,
+		`layers[1].layout.symbol-min-distance: "symbol-min-distance" was removed, use "symbol-spacing"`,
+		`layers[1].layout.text-max-size: "text-max-size" was removed`,
+		`layers[1].paint.night: paint class "night" was removed, use expressions or a separate layer`,
+		`layers[1].paint.text-size: "text-size" is a layout property`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, f := range parseStyle(t, lintStyle).Lint(nil).Findings {
+		if f.Rule == "deprecated-property" {
+			t.Errorf("finding without a document: %v", f)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Migrate decodes a style document of version 7 or 8 and returns it as a
+// version 8 style. Version 7 styles are migrated first, see MigrateV7; the
+// warnings list what the migration could only approximate.
+func Migrate(data []byte, classes ...string) (*Style, ValidationErrors, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	var warnings ValidationErrors
+	switch version, _ := doc["version"].(float64); version {
+	case 7:
+		var err error
+		if warnings, err = MigrateV7(doc, classes...); err != nil {
+			return nil, nil, err
+		}
+	case 8:
+	default:
+		return nil, nil, errors.Errorf("version: cannot migrate style version %v", doc["version"])
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	var s Style
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, nil, errors.Wrap(err, "decoding migrated style")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &s, warnings, nil
+}
+
+// MigrateV7 rewrites a decoded version 7 style document in place as
+// version 8. It
+//
+//   - resolves layer refs and inlines @constants,
+//   - merges the paint.<class> properties of the given classes, in order,
+//     over the layer paint and drops all other classes,
+//   - renames properties that changed name and moves text-size and
+//     icon-size from paint to layout,
+//   - splits comma separated text-font strings into font stacks,
+//   - rewrites mapbox:// sprite and glyphs URLs to their v8 form.
+//
+// Zoom functions keep their v7 form, which version 8 reads as legacy
+// function syntax. Layout holds a single font stack, so a text-font
+// function is replaced by the stack of its first stop and reported in the
+// returned warnings.
+func MigrateV7(doc map[string]interface{}, classes ...string) (ValidationErrors, error) {
+	constants, _ := doc["constants"].(map[string]interface{})
+	layers, _ := doc["layers"].([]interface{})
+
+	if err := derefLayers(layers); err != nil {
+		return nil, err
+	}
+	var warnings ValidationErrors
+	for i, raw := range layers {
+		layer, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("layers[%d]: expected object", i)
+		}
+		if err := migrateLayerV7(layer, constants, classes, fmt.Sprintf("layers[%d]", i), &warnings); err != nil {
+			return nil, errors.Wrapf(err, "layers[%d]", i)
+		}
+	}
+
+	sources, _ := doc["sources"].(map[string]interface{})
+	for _, raw := range sources {
+		src, ok := raw.(map[string]interface{})
+		if !ok || src["type"] != "video" {
+			continue
+		}
+		if u, ok := src["url"]; ok {
+			src["urls"] = u
+			delete(src, "url")
+		}
+		// v7 video coordinates were [lat, lng].
+		coords, _ := src["coordinates"].([]interface{})
+		for _, c := range coords {
+			if pair, ok := c.([]interface{}); ok && len(pair) == 2 {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+		}
+	}
+
+	if glyphs, ok := doc["glyphs"].(string); ok {
+		migrated, err := migrateGlyphsURL(glyphs)
+		if err != nil {
+			return nil, err
+		}
+		doc["glyphs"] = migrated
+	}
+	if sprite, ok := doc["sprite"].(string); ok {
+		doc["sprite"] = migrateSpriteURL(sprite)
+	}
+	delete(doc, "constants")
+	doc["version"] = 8
+	return warnings, nil
+}
+
+// derefLayers copies the shared properties of the layer a ref names into
+// the referring layer.
+func derefLayers(layers []interface{}) error {
+	byID := map[string]map[string]interface{}{}
+	for _, raw := range layers {
+		if layer, ok := raw.(map[string]interface{}); ok {
+			if id, ok := layer["id"].(string); ok {
+				byID[id] = layer
+			}
+		}
+	}
+	for _, raw := range layers {
+		layer, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, ok := layer["ref"].(string)
+		if !ok {
+			continue
+		}
+		parent, ok := byID[ref]
+		if !ok {
+			return errors.Errorf("layer %q refers to unknown layer %q", layer["id"], ref)
+		}
+		for _, key := range []string{"type", "source", "source-layer", "minzoom", "maxzoom", "filter", "layout"} {
+			v, ok := parent[key]
+			if !ok {
+				continue
+			}
+			if m, ok := v.(map[string]interface{}); ok {
+				copied := make(map[string]interface{}, len(m))
+				for k, item := range m {
+					copied[k] = item
+				}
+				v = copied
+			}
+			layer[key] = v
+		}
+		delete(layer, "ref")
+	}
+	return nil
+}
+
+var (
+	renamedLayoutV7 = map[string]string{"symbol-min-distance": "symbol-spacing"}
+	renamedPaintV7  = map[string]string{
+		"background-image": "background-pattern",
+		"fill-image":       "fill-pattern",
+		"line-image":       "line-pattern",
+	}
+	// movedToLayoutV7 are paint properties in v7 and layout properties in
+	// v8; removedLayoutV7 have no v8 counterpart.
+	movedToLayoutV7 = []string{"text-size", "icon-size"}
+	removedLayoutV7 = []string{"text-max-size", "icon-max-size"}
+)
+
+func migrateLayerV7(layer, constants map[string]interface{}, classes []string, path string, warnings *ValidationErrors) error {
+	paint, _ := layer["paint"].(map[string]interface{})
+	if paint == nil {
+		paint = map[string]interface{}{}
+	}
+	for _, class := range classes {
+		overrides, _ := layer["paint."+class].(map[string]interface{})
+		for k, v := range overrides {
+			paint[k] = v
+		}
+	}
+	for key := range layer {
+		if strings.HasPrefix(key, "paint.") {
+			delete(layer, key)
+		}
+	}
+	layout, _ := layer["layout"].(map[string]interface{})
+	if layout == nil {
+		layout = map[string]interface{}{}
+	}
+
+	for _, props := range []map[string]interface{}{paint, layout} {
+		for k, v := range props {
+			resolved, err := resolveConstants(v, constants)
+			if err != nil {
+				return errors.Wrap(err, k)
+			}
+			props[k] = resolved
+		}
+	}
+	renameKeys(paint, renamedPaintV7)
+	renameKeys(layout, renamedLayoutV7)
+	for _, key := range movedToLayoutV7 {
+		if v, ok := paint[key]; ok {
+			layout[key] = v
+			delete(paint, key)
+		}
+	}
+	for _, key := range removedLayoutV7 {
+		delete(layout, key)
+	}
+	if font, ok := layout["text-font"]; ok {
+		stack, err := migrateFontStack(font, path+".layout.text-font", warnings)
+		if err != nil {
+			return err
+		}
+		layout["text-font"] = stack
+	}
+
+	if len(paint) > 0 {
+		layer["paint"] = paint
+	} else {
+		delete(layer, "paint")
+	}
+	if len(layout) > 0 {
+		layer["layout"] = layout
+	} else {
+		delete(layer, "layout")
+	}
+	return nil
+}
+
+// resolveConstants replaces "@name" strings in a property value, including
+// function stop outputs, with the constant's value.
+func resolveConstants(v interface{}, constants map[string]interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if !strings.HasPrefix(t, "@") {
+			return t, nil
+		}
+		c, ok := constants[t]
+		if !ok {
+			return nil, errors.Errorf("unknown constant %q", t)
+		}
+		return resolveConstants(c, constants)
+	case map[string]interface{}:
+		stops, _ := t["stops"].([]interface{})
+		for _, stop := range stops {
+			pair, ok := stop.([]interface{})
+			if !ok || len(pair) != 2 {
+				continue
+			}
+			out, err := resolveConstants(pair[1], constants)
+			if err != nil {
+				return nil, err
+			}
+			pair[1] = out
+		}
+	}
+	return v, nil
+}
+
+func renameKeys(props map[string]interface{}, names map[string]string) {
+	for from, to := range names {
+		if v, ok := props[from]; ok {
+			props[to] = v
+			delete(props, from)
+		}
+	}
+}
+
+func migrateFontStack(font interface{}, path string, warnings *ValidationErrors) ([]interface{}, error) {
+	switch t := font.(type) {
+	case []interface{}:
+		return t, nil
+	case string:
+		var stack []interface{}
+		for _, name := range strings.Split(t, ",") {
+			stack = append(stack, strings.TrimSpace(name))
+		}
+		return stack, nil
+	case map[string]interface{}:
+		stops, _ := t["stops"].([]interface{})
+		if len(stops) == 0 {
+			return nil, errors.New("text-font: function has no stops")
+		}
+		first, ok := stops[0].([]interface{})
+		if !ok || len(first) != 2 {
+			return nil, errors.Errorf("text-font: invalid stop %v", stops[0])
+		}
+		if _, ok := first[1].(map[string]interface{}); ok {
+			return nil, errors.Errorf("text-font: invalid stop %v", stops[0])
+		}
+		stack, err := migrateFontStack(first[1], path, warnings)
+		if err != nil {
+			return nil, err
+		}
+		if len(stops) > 1 {
+			warnings.add(path, "zoom function replaced by the font stack of its first stop, %s", jsonString(stack))
+		}
+		return stack, nil
+	}
+	return nil, errors.Errorf("text-font: unexpected value %v", font)
+}
+
+// migrateGlyphsURL rewrites the v7 mapbox://fontstack and mapbox://fonts/v1
+// glyph URLs to mapbox://fonts/<owner>/{fontstack}/{range}.pbf.
+func migrateGlyphsURL(glyphs string) (string, error) {
+	u, err := url.Parse(glyphs)
+	if err != nil || u.Scheme != "mapbox" {
+		return glyphs, nil
+	}
+	path, err := url.PathUnescape(u.EscapedPath())
+	if err != nil {
+		return "", errors.Wrap(err, "glyphs")
+	}
+	parts := strings.Split(path, "/")
+	switch {
+	case u.Host == "fontstack" && path == "/{fontstack}/{range}.pbf":
+		return "mapbox://fonts/mapbox/{fontstack}/{range}.pbf", nil
+	case u.Host == "fonts" && len(parts) == 5 && parts[1] == "v1" &&
+		parts[3] == "{fontstack}" && parts[4] == "{range}.pbf":
+		return fmt.Sprintf("mapbox://fonts/%s/{fontstack}/{range}.pbf", parts[2]), nil
+	case u.Host == "fonts" && len(parts) == 4 && parts[2] == "{fontstack}":
+		return glyphs, nil
+	}
+	return "", errors.Errorf("glyphs: cannot migrate %q", glyphs)
+}
+
+// migrateSpriteURL rewrites mapbox://sprite/<owner>/<id> and
+// mapbox://sprite/<owner>.<id> to mapbox://sprites/<owner>/<id>.
+func migrateSpriteURL(sprite string) string {
+	rest, ok := strings.CutPrefix(sprite, "mapbox://sprite/")
+	if !ok {
+		return sprite
+	}
+	if !strings.Contains(rest, "/") {
+		rest = strings.Replace(rest, ".", "/", 1)
+	}
+	return "mapbox://sprites/" + rest
+}
@@ -0,0 +1,116 @@
+package style
+
+import (
+	"testing"
+)
+
+const v7Style = `{
+	"version": 7,
+	"sprite": "mapbox://sprite/mapbox.bright",
+	"glyphs": "mapbox://fontstack/{fontstack}/{range}.pbf",
+	"constants": {
+		"@water": "#a0c8f0",
+		"@sans": "Open Sans Regular, Arial Unicode MS Regular",
+		"@road-width": {"base": 1.5, "stops": [[5, 0.5], [18, "@wide"]]},
+		"@wide": 20
+	},
+	"sources": {"streets": {"type": "vector", "url": "mapbox://mapbox.mapbox-streets-v6"}},
+	"layers": [
+		{"id": "background", "type": "background", "paint": {"background-image": "paper"}},
+		{"id": "water", "type": "fill", "source": "streets", "source-layer": "water",
+			"paint": {"fill-color": "@water"}, "paint.night": {"fill-color": "#036"}},
+		{"id": "road", "type": "line", "source": "streets", "source-layer": "road",
+			"layout": {"line-cap": "round"}, "paint": {"line-width": "@road-width"}},
+		{"id": "road-casing", "ref": "road", "paint": {"line-width": 1, "line-color": "#fff"}},
+		{"id": "labels", "type": "symbol", "source": "streets", "source-layer": "place_label",
+			"layout": {"text-font": "@sans", "text-max-size": 18, "symbol-min-distance": 250, "text-field": "{name}"},
+			"paint": {"text-size": 14}}
+	]
+}`
+
+func TestMigrateV7(t *testing.T) {
+	s, warnings, err := Migrate([]byte(v7Style), "night")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if s.Version != 8 {
+		t.Fatalf("expected version 8, got %d", s.Version)
+	}
+	if s.Sprite != "mapbox://sprites/mapbox/bright" || s.Glyphs != "mapbox://fonts/mapbox/{fontstack}/{range}.pbf" {
+		t.Fatalf("unexpected urls %q %q", s.Sprite, s.Glyphs)
+	}
+	if errs := s.ValidateLayers(); len(errs) > 0 {
+		t.Fatalf("migrated style does not validate: %v", errs)
+	}
+
+	if s.Layers[0].Paint.BackgroundPattern != "paper" {
+		t.Fatalf("background-image not renamed: %v", s.Layers[0].Paint.BackgroundPattern)
+	}
+	if c := ColorToCSS(s.Layers[1].Paint.FillColor.GetColorAtZoomLevel(0)); c != "#003366" {
+		t.Fatalf("night class not merged, fill-color %s", c)
+	}
+	width, err := s.Layers[2].Paint.LineWidthAt(18, nil)
+	if err != nil || width != 20 {
+		t.Fatalf("constant not resolved in stops: %v %v", width, err)
+	}
+	casing := s.Layers[3]
+	if casing.Type != LayerTypeLine || *casing.SourceLayer != "road" || casing.Layout.LineCap != "round" {
+		t.Fatalf("ref not resolved: %+v", casing)
+	}
+	labels := s.Layers[4]
+	if len(labels.Layout.TextFont) != 2 || labels.Layout.TextFont[1] != "Arial Unicode MS Regular" {
+		t.Fatalf("unexpected text-font %q", labels.Layout.TextFont)
+	}
+	if labels.Layout.TextSize != 14.0 || labels.Layout.SymbolSpacing != 250.0 {
+		t.Fatalf("layout not migrated: %v %v", labels.Layout.TextSize, labels.Layout.SymbolSpacing)
+	}
+}
+
+func TestMigrateTextFontFunction(t *testing.T) {
+	s, warnings, err := Migrate([]byte(`{
+		"version": 7,
+		"constants": {"@bold": "Open Sans Bold, Arial Unicode MS Bold"},
+		"sources": {"streets": {"type": "vector"}},
+		"layers": [
+			{"id": "labels", "type": "symbol", "source": "streets", "source-layer": "place_label",
+				"layout": {"text-font": {"stops": [[4, "@bold"], [10, "Open Sans Regular"]]}, "text-field": "{name}"}},
+			{"id": "single", "type": "symbol", "source": "streets", "source-layer": "place_label",
+				"layout": {"text-font": {"stops": [[0, ["Open Sans Italic"]]]}}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.ValidateLayers(); len(errs) > 0 {
+		t.Fatalf("migrated style does not validate: %v", errs)
+	}
+	if font := s.Layers[0].Layout.TextFont; len(font) != 2 || font[0] != "Open Sans Bold" {
+		t.Errorf("text-font = %q, want the first stop's stack", font)
+	}
+	if font := s.Layers[1].Layout.TextFont; len(font) != 1 || font[0] != "Open Sans Italic" {
+		t.Errorf("single stop text-font = %q", font)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "layers[0].layout.text-font" {
+		t.Errorf("warnings = %v, want one for layers[0].layout.text-font", warnings)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	tests := map[string]string{
+		"version":  `{"version": 6, "sources": {}, "layers": []}`,
+		"constant": `{"version": 7, "sources": {}, "layers": [{"id": "bg", "type": "background", "paint": {"background-color": "@missing"}}]}`,
+		"ref":      `{"version": 7, "sources": {}, "layers": [{"id": "a", "ref": "b"}]}`,
+		"glyphs":   `{"version": 7, "glyphs": "mapbox://fonts/{fontstack}", "sources": {}, "layers": []}`,
+		"font":     `{"version": 7, "sources": {}, "layers": [{"id": "l", "type": "symbol", "layout": {"text-font": {"stops": []}}}]}`,
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Migrate([]byte(raw)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
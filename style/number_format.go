@@ -0,0 +1,248 @@
+package style
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// NumberFormat holds the options of a number-format expression. Numbers
+// are formatted like Intl.NumberFormat does in the browser, so labels
+// exported from a style read the same as on the map.
+type NumberFormat struct {
+	// Locale is a BCP 47 locale, DefaultLocale when empty. It decides the
+	// decimal separator, digit grouping, currency symbol and its placement.
+	Locale string
+	// Currency is an ISO 4217 code. When set, the number is formatted as
+	// an amount of that currency.
+	Currency string
+	// MinFractionDigits and MaxFractionDigits bound the digits after the
+	// decimal separator. They default to 0 and 3, or to the digits of the
+	// currency.
+	MinFractionDigits *int
+	MaxFractionDigits *int
+}
+
+// currencyPatterns holds the CLDR standard currency patterns, which x/text
+// does not carry, keyed by locale. Only the locales listed here and their
+// children place the currency symbol as Intl.NumberFormat does; every other
+// locale, Arabic, Hebrew, Persian and Hindi among them, uses the root
+// pattern, with the symbol before the amount. The minus sign of a pattern
+// stands for the locale's minus sign, and a pattern without a negative part
+// negates with a leading one.
+var currencyPatterns = map[string]string{
+	"und":    "¤#,##0.00",
+	"bg":     "#,##0.00\u00a0¤",
+	"ca":     "#,##0.00\u00a0¤",
+	"cs":     "#,##0.00\u00a0¤",
+	"da":     "#,##0.00\u00a0¤",
+	"de":     "#,##0.00\u00a0¤",
+	"de-AT":  "¤\u00a0#,##0.00",
+	"de-CH":  "¤\u00a0#,##0.00;¤-#,##0.00",
+	"de-LI":  "¤\u00a0#,##0.00;¤-#,##0.00",
+	"el":     "#,##0.00\u00a0¤",
+	"en-CH":  "¤\u00a0#,##0.00;¤-#,##0.00",
+	"es":     "#,##0.00\u00a0¤",
+	"es-419": "¤#,##0.00",
+	"et":     "#,##0.00\u00a0¤",
+	"fi":     "#,##0.00\u00a0¤",
+	"fr":     "#,##0.00\u00a0¤",
+	"hr":     "#,##0.00\u00a0¤",
+	"hu":     "#,##0.00\u00a0¤",
+	"is":     "#,##0.00\u00a0¤",
+	"it":     "#,##0.00\u00a0¤",
+	"it-CH":  "¤\u00a0#,##0.00;¤-#,##0.00",
+	"lt":     "#,##0.00\u00a0¤",
+	"lv":     "#,##0.00\u00a0¤",
+	"nb":     "#,##0.00\u00a0¤",
+	"nl":     "¤\u00a0#,##0.00;¤\u00a0-#,##0.00",
+	"pl":     "#,##0.00\u00a0¤",
+	"pt":     "¤\u00a0#,##0.00",
+	"pt-PT":  "#,##0.00\u00a0¤",
+	"ro":     "#,##0.00\u00a0¤",
+	"ru":     "#,##0.00\u00a0¤",
+	"sk":     "#,##0.00\u00a0¤",
+	"sl":     "#,##0.00\u00a0¤",
+	"sv":     "#,##0.00\u00a0¤",
+	"uk":     "#,##0.00\u00a0¤",
+}
+
+// nbsp separates currency symbols from amounts, as in Intl.NumberFormat.
+const nbsp = "\u00a0"
+
+// Format formats v.
+func (nf NumberFormat) Format(v float64) (string, error) {
+	locale := nf.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		tag = language.Make(DefaultLocale)
+	}
+	// x/text has no data for the Norwegian macrolanguage; CLDR aliases
+	// it to Bokmål.
+	if base, _ := tag.Base(); base.String() == "no" {
+		tag, _ = language.Compose(tag, language.MustParseBase("nb"))
+	}
+	minDigits, maxDigits := 0, 3
+	var unit currency.Unit
+	if nf.Currency != "" {
+		if unit, err = currency.ParseISO(nf.Currency); err != nil {
+			return "", errors.Errorf("invalid currency code %q", nf.Currency)
+		}
+		minDigits, _ = currency.Standard.Rounding(unit)
+		maxDigits = minDigits
+	}
+	switch {
+	case nf.MinFractionDigits != nil && nf.MaxFractionDigits != nil:
+		minDigits, maxDigits = *nf.MinFractionDigits, *nf.MaxFractionDigits
+	case nf.MinFractionDigits != nil:
+		minDigits = *nf.MinFractionDigits
+		maxDigits = max(maxDigits, minDigits)
+	case nf.MaxFractionDigits != nil:
+		maxDigits = *nf.MaxFractionDigits
+		minDigits = min(minDigits, maxDigits)
+	}
+	if minDigits < 0 || maxDigits > 20 || minDigits > maxDigits {
+		return "", errors.Errorf("fraction digits out of range: min %d, max %d", minDigits, maxDigits)
+	}
+
+	printer := message.NewPrinter(tag)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return printer.Sprint(number.Decimal(v)), nil
+	}
+	rounded := roundHalfAway(math.Abs(v), maxDigits)
+	opts := []number.Option{number.MinFractionDigits(minDigits), number.MaxFractionDigits(maxDigits)}
+	digits := printer.Sprint(number.Decimal(rounded, opts...))
+	// The minus sign, such as U+2212 in Swedish or one led by a bidi mark
+	// in Arabic, is whatever x/text puts before the digits.
+	sign := ""
+	if v < 0 && rounded != 0 {
+		sign = strings.TrimSuffix(printer.Sprint(number.Decimal(-rounded, opts...)), digits)
+	}
+	if nf.Currency == "" {
+		return sign + digits, nil
+	}
+
+	symbol := printer.Sprint(currency.Symbol(unit))
+	positive, negativePattern, ok := strings.Cut(currencyPattern(tag), ";")
+	pattern := positive
+	if sign != "" {
+		pattern = "-" + positive
+		if ok {
+			pattern = negativePattern
+		}
+	}
+	start := strings.IndexAny(pattern, "#0")
+	end := strings.LastIndexAny(pattern, "#0") + 1
+	prefix, suffix := pattern[:start], pattern[end:]
+	// Symbols that touch the digits with a letter, such as "CHF", are
+	// spaced from them, following the CLDR currency spacing rules.
+	if last, _ := utf8.DecodeLastRuneInString(symbol); strings.HasSuffix(prefix, "¤") && unicode.IsLetter(last) {
+		prefix += nbsp
+	}
+	if first, _ := utf8.DecodeRuneInString(symbol); strings.HasPrefix(suffix, "¤") && unicode.IsLetter(first) {
+		suffix = nbsp + suffix
+	}
+	return strings.NewReplacer("¤", symbol, "-", sign).Replace(prefix) + digits + strings.ReplaceAll(suffix, "¤", symbol), nil
+}
+
+// currencyPattern returns the currency pattern of the closest locale to tag
+// that has one.
+func currencyPattern(tag language.Tag) string {
+	for t := tag; ; t = t.Parent() {
+		if p, ok := currencyPatterns[t.String()]; ok {
+			return p
+		}
+		if t == language.Und {
+			return currencyPatterns["und"]
+		}
+	}
+}
+
+// roundHalfAway rounds v, which is not negative, to digits fraction digits,
+// half away from zero like Intl.NumberFormat. It rounds the shortest
+// decimal representation of v rather than its binary value, so 1.005
+// rounds to 1.01 as written; x/text rounds half to even.
+func roundHalfAway(v float64, digits int) float64 {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) <= digits {
+		return v
+	}
+	kept := []byte(whole + frac[:digits])
+	if frac[digits] >= '5' {
+		i := len(kept) - 1
+		for ; i >= 0 && kept[i] == '9'; i-- {
+			kept[i] = '0'
+		}
+		if i < 0 {
+			kept = append([]byte{'1'}, kept...)
+		} else {
+			kept[i]++
+		}
+	}
+	point := len(kept) - digits
+	r, _ := strconv.ParseFloat(string(kept[:point])+"."+string(kept[point:]), 64)
+	return r
+}
+
+func (ev *evaluator) evalNumberFormat(e *Expression) (interface{}, error) {
+	n, err := ev.evalNumberArg(e, 0)
+	if err != nil {
+		return nil, err
+	}
+	nf := NumberFormat{Locale: ev.ctx.Locale}
+	if len(e.Args) > 1 {
+		opts, ok := expressionValue(e.Args[1]).(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("%q expects an options object", e.Operator)
+		}
+		for _, key := range sortedKeys(opts) {
+			v, err := ev.evalOption(opts[key])
+			if err != nil {
+				return nil, errors.Wrapf(err, "%q: %s", e.Operator, key)
+			}
+			if v == nil {
+				continue
+			}
+			switch key {
+			case "locale", "currency":
+				s, ok := v.(string)
+				if !ok {
+					return nil, errors.Errorf("%q: %s: expected string but found %s", e.Operator, key, typeOf(v))
+				}
+				if key == "locale" {
+					nf.Locale = s
+				} else {
+					nf.Currency = s
+				}
+			case "min-fraction-digits", "max-fraction-digits":
+				f, ok := v.(float64)
+				if !ok {
+					return nil, errors.Errorf("%q: %s: expected number but found %s", e.Operator, key, typeOf(v))
+				}
+				digits := int(f)
+				if key == "min-fraction-digits" {
+					nf.MinFractionDigits = &digits
+				} else {
+					nf.MaxFractionDigits = &digits
+				}
+			}
+		}
+	}
+	s, err := nf.Format(n)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%q", e.Operator)
+	}
+	return s, nil
+}
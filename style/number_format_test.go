@@ -0,0 +1,78 @@
+package style
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestEvaluateNumberFormat(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`["number-format", 1234.5678, {}]`, "1,234.568"},
+		{`["number-format", 0.125, {"max-fraction-digits": 2}]`, "0.13"},
+		{`["number-format", 3, {"min-fraction-digits": 2}]`, "3.00"},
+		{`["number-format", 1234567.891, {"locale": "de-DE", "max-fraction-digits": 1}]`, "1.234.567,9"},
+		{`["number-format", 1234567, {"locale": "hi-IN"}]`, "12,34,567"},
+		{`["number-format", -1234.5, {"locale": "en-US", "currency": "USD"}]`, "-$1,234.50"},
+		{`["number-format", 1234.5, {"locale": "de-DE", "currency": "EUR"}]`, "1.234,50\u00a0€"},
+		{`["number-format", 1234.5, {"locale": "en-US", "currency": "JPY"}]`, "¥1,235"},
+		{`["number-format", 12, {"locale": "en-US", "currency": "CHF", "min-fraction-digits": 0}]`, "CHF\u00a012"},
+		{`["number-format", ["get", "n"], {"locale": ["get", "locale"]}]`, "0,5"},
+		{`["number-format", 1234567.891, {"locale": "de-CH", "currency": "CHF"}]`, "CHF\u00a01’234’567.89"},
+		{`["number-format", -1234.5, {"locale": "de-CH", "currency": "CHF"}]`, "CHF-1’234.50"},
+		{`["number-format", -1234.5, {"locale": "de-DE", "currency": "EUR"}]`, "-1.234,50\u00a0€"},
+		{`["number-format", 1234.5, {"locale": "nl-NL", "currency": "EUR"}]`, "€\u00a01.234,50"},
+		{`["number-format", 1.005, {"locale": "en-US", "currency": "USD"}]`, "$1.01"},
+		{`["number-format", 1.005, {"max-fraction-digits": 2}]`, "1.01"},
+		{`["number-format", 9.995, {"max-fraction-digits": 2}]`, "10"},
+		{`["number-format", -1234.5, {"locale": "sv"}]`, "\u22121\u00a0234,5"},
+		{`["number-format", -1234.5, {"locale": "fi", "currency": "EUR"}]`, "\u22121\u00a0234,50\u00a0€"},
+		{`["number-format", -1234.5, {"locale": "no", "currency": "NOK"}]`, "\u22121\u00a0234,50\u00a0kr"},
+		{`["number-format", -1234.5, {"locale": "nl-NL", "currency": "EUR"}]`, "€\u00a0-1.234,50"},
+		{`["number-format", -0.001, {"locale": "sv", "max-fraction-digits": 2}]`, "0"},
+		// Locales without a currency pattern use the root one.
+		{`["number-format", -1234.5, {"locale": "ar", "currency": "USD"}]`, "\u061c-US$١٬٢٣٤٫٥٠"},
+	}
+	ctx := EvalContext{Feature: &Feature{Properties: map[string]interface{}{"n": 0.5, "locale": "fr"}}}
+	for _, tt := range tests {
+		got, err := mustExpr(t, tt.expr).Evaluate(ctx)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, err := mustExpr(t, `["number-format", 1, {"currency": "XYZW"}]`).Evaluate(EvalContext{}); err == nil {
+		t.Error("expected error for an invalid currency")
+	}
+	got, err := mustExpr(t, `["number-format", 1.5, {}]`).Evaluate(EvalContext{Locale: "de"})
+	if err != nil || got != "1,5" {
+		t.Errorf("with context locale = %v, %v, want 1,5", got, err)
+	}
+}
+
+func TestCurrencyPatterns(t *testing.T) {
+	for locale, pattern := range currencyPatterns {
+		tag, err := language.Parse(locale)
+		if err != nil || tag.String() != locale {
+			t.Errorf("%s: not a canonical locale: %v, %v", locale, tag, err)
+		}
+		if base, _ := tag.Base(); base.String() == "no" {
+			t.Errorf("%s: Norwegian is formatted as nb", locale)
+		}
+		positive, negative, _ := strings.Cut(pattern, ";")
+		if strings.Count(positive, "¤") != 1 || strings.Contains(positive, "-") {
+			t.Errorf("%s: invalid positive pattern %q", locale, positive)
+		}
+		if negative != "" && (strings.Count(negative, "¤") != 1 || strings.Count(negative, "-") != 1) {
+			t.Errorf("%s: invalid negative pattern %q", locale, negative)
+		}
+	}
+}
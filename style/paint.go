@@ -3,6 +3,7 @@ package style
 type Paint struct {
 	// Background
 	BackgroundColor          *ColorType `json:"background-color,omitempty"`
+	BackgroundColorUseTheme  interface{} `json:"background-color-use-theme,omitempty"`
 	BackgroundEmissiveStrength interface{} `json:"background-emissive-strength,omitempty"`
 	BackgroundOpacity        interface{} `json:"background-opacity,omitempty"`
 	BackgroundPattern        interface{} `json:"background-pattern,omitempty"`
@@ -11,12 +12,14 @@ type Paint struct {
 	// Circle
 	CircleBlur             interface{} `json:"circle-blur,omitempty"`
 	CircleColor            *ColorType  `json:"circle-color,omitempty"`
+	CircleColorUseTheme    interface{} `json:"circle-color-use-theme,omitempty"`
 	CircleEmissiveStrength interface{} `json:"circle-emissive-strength,omitempty"`
 	CircleOpacity          interface{} `json:"circle-opacity,omitempty"`
 	CirclePitchAlignment   string      `json:"circle-pitch-alignment,omitempty"`
 	CirclePitchScale       string      `json:"circle-pitch-scale,omitempty"`
 	CircleRadius           interface{} `json:"circle-radius,omitempty"`
 	CircleStrokeColor      *ColorType  `json:"circle-stroke-color,omitempty"`
+	CircleStrokeColorUseTheme interface{} `json:"circle-stroke-color-use-theme,omitempty"`
 	CircleStrokeOpacity    interface{} `json:"circle-stroke-opacity,omitempty"`
 	CircleStrokeWidth      interface{} `json:"circle-stroke-width,omitempty"`
 	CircleTranslate        []float64   `json:"circle-translate,omitempty"`
@@ -25,9 +28,11 @@ type Paint struct {
 	// Fill
 	FillAntialias    interface{} `json:"fill-antialias,omitempty"`
 	FillColor        *ColorType  `json:"fill-color,omitempty"`
+	FillColorUseTheme interface{} `json:"fill-color-use-theme,omitempty"`
 	FillEmissiveStrength interface{} `json:"fill-emissive-strength,omitempty"`
 	FillOpacity      interface{} `json:"fill-opacity,omitempty"`
 	FillOutlineColor *ColorType  `json:"fill-outline-color,omitempty"`
+	FillOutlineColorUseTheme interface{} `json:"fill-outline-color-use-theme,omitempty"`
 	FillPattern      interface{} `json:"fill-pattern,omitempty"`
 	FillPatternCrossFade interface{} `json:"fill-pattern-cross-fade,omitempty"`
 	FillTranslate    []float64   `json:"fill-translate,omitempty"`
@@ -44,9 +49,11 @@ type Paint struct {
 	FillExtrusionBaseAlignment                     string      `json:"fill-extrusion-base-alignment,omitempty"`
 	FillExtrusionCastShadows                       *bool       `json:"fill-extrusion-cast-shadows,omitempty"`
 	FillExtrusionColor                             *ColorType  `json:"fill-extrusion-color,omitempty"`
+	FillExtrusionColorUseTheme                     interface{} `json:"fill-extrusion-color-use-theme,omitempty"`
 	FillExtrusionCutoffFadeRange                   interface{} `json:"fill-extrusion-cutoff-fade-range,omitempty"`
 	FillExtrusionEmissiveStrength                  interface{} `json:"fill-extrusion-emissive-strength,omitempty"`
 	FillExtrusionFloodLightColor                   *ColorType  `json:"fill-extrusion-flood-light-color,omitempty"`
+	FillExtrusionFloodLightColorUseTheme           interface{} `json:"fill-extrusion-flood-light-color-use-theme,omitempty"`
 	FillExtrusionFloodLightGroundAttenuation       interface{} `json:"fill-extrusion-flood-light-ground-attenuation,omitempty"`
 	FillExtrusionFloodLightGroundRadius            interface{} `json:"fill-extrusion-flood-light-ground-radius,omitempty"`
 	FillExtrusionFloodLightIntensity               interface{} `json:"fill-extrusion-flood-light-intensity,omitempty"`
@@ -65,6 +72,7 @@ type Paint struct {
 
 	// Heatmap
 	HeatmapColor    *ColorType  `json:"heatmap-color,omitempty"`
+	HeatmapColorUseTheme interface{} `json:"heatmap-color-use-theme,omitempty"`
 	HeatmapIntensity interface{} `json:"heatmap-intensity,omitempty"`
 	HeatmapOpacity  interface{} `json:"heatmap-opacity,omitempty"`
 	HeatmapRadius   interface{} `json:"heatmap-radius,omitempty"`
@@ -72,20 +80,25 @@ type Paint struct {
 
 	// Hillshade
 	HillshadeAccentColor          *ColorType `json:"hillshade-accent-color,omitempty"`
+	HillshadeAccentColorUseTheme  interface{} `json:"hillshade-accent-color-use-theme,omitempty"`
 	HillshadeEmissiveStrength     interface{} `json:"hillshade-emissive-strength,omitempty"`
 	HillshadeExaggeration         interface{} `json:"hillshade-exaggeration,omitempty"`
 	HillshadeHighlightColor       *ColorType `json:"hillshade-highlight-color,omitempty"`
+	HillshadeHighlightColorUseTheme interface{} `json:"hillshade-highlight-color-use-theme,omitempty"`
 	HillshadeIlluminationAnchor   string      `json:"hillshade-illumination-anchor,omitempty"`
 	HillshadeIlluminationDirection interface{} `json:"hillshade-illumination-direction,omitempty"`
 	HillshadeShadowColor          *ColorType `json:"hillshade-shadow-color,omitempty"`
+	HillshadeShadowColorUseTheme  interface{} `json:"hillshade-shadow-color-use-theme,omitempty"`
 
 	// Line
 	LineBlur              interface{} `json:"line-blur,omitempty"`
 	LineColor             *ColorType  `json:"line-color,omitempty"`
+	LineColorUseTheme     interface{} `json:"line-color-use-theme,omitempty"`
 	LineDashArray         []float64   `json:"line-dasharray,omitempty"`
 	LineEmissiveStrength  interface{} `json:"line-emissive-strength,omitempty"`
 	LineGapWidth          interface{} `json:"line-gap-width,omitempty"`
 	LineGradient          *ColorType  `json:"line-gradient,omitempty"`
+	LineGradientUseTheme  interface{} `json:"line-gradient-use-theme,omitempty"`
 	LineOcclusionOpacity  interface{} `json:"line-occlusion-opacity,omitempty"`
 	LineOffset            interface{} `json:"line-offset,omitempty"`
 	LineOpacity           interface{} `json:"line-opacity,omitempty"`
@@ -94,6 +107,7 @@ type Paint struct {
 	LineTranslate         []float64   `json:"line-translate,omitempty"`
 	LineTranslateAnchor   string      `json:"line-translate-anchor,omitempty"`
 	LineTrimColor         *ColorType  `json:"line-trim-color,omitempty"`
+	LineTrimColorUseTheme interface{} `json:"line-trim-color-use-theme,omitempty"`
 	LineTrimFadeRange     []float64   `json:"line-trim-fade-range,omitempty"`
 	LineTrimOffset        []float64   `json:"line-trim-offset,omitempty"`
 	LineWidth             interface{} `json:"line-width,omitempty"`
@@ -102,6 +116,7 @@ type Paint struct {
 	ModelAmbientOcclusionIntensity          interface{} `json:"model-ambient-occlusion-intensity,omitempty"`
 	ModelCastShadows                        *bool       `json:"model-cast-shadows,omitempty"`
 	ModelColor                              *ColorType  `json:"model-color,omitempty"`
+	ModelColorUseTheme                      interface{} `json:"model-color-use-theme,omitempty"`
 	ModelColorMixIntensity                  interface{} `json:"model-color-mix-intensity,omitempty"`
 	ModelCutoffFadeRange                    interface{} `json:"model-cutoff-fade-range,omitempty"`
 	ModelElevationReference                 string      `json:"model-elevation-reference,omitempty"`
@@ -134,7 +149,9 @@ type Paint struct {
 
 	// Sky
 	SkyAtmosphereColor       *ColorType `json:"sky-atmosphere-color,omitempty"`
+	SkyAtmosphereColorUseTheme interface{} `json:"sky-atmosphere-color-use-theme,omitempty"`
 	SkyAtmosphereHaloColor   *ColorType `json:"sky-atmosphere-halo-color,omitempty"`
+	SkyAtmosphereHaloColorUseTheme interface{} `json:"sky-atmosphere-halo-color-use-theme,omitempty"`
 	SkyAtmosphereSun         []float64  `json:"sky-atmosphere-sun,omitempty"`
 	SkyAtmosphereSunIntensity interface{} `json:"sky-atmosphere-sun-intensity,omitempty"`
 	SkyGradientCenter        []float64  `json:"sky-gradient-center,omitempty"`
@@ -144,10 +161,12 @@ type Paint struct {
 
 	// Symbol - Icon
 	IconColor              *ColorType `json:"icon-color,omitempty"`
+	IconColorUseTheme      interface{} `json:"icon-color-use-theme,omitempty"`
 	IconCrossFade          interface{} `json:"icon-cross-fade,omitempty"`
 	IconEmissiveStrength   interface{} `json:"icon-emissive-strength,omitempty"`
 	IconHaloBlur           interface{} `json:"icon-halo-blur,omitempty"`
 	IconHaloColor          *ColorType `json:"icon-halo-color,omitempty"`
+	IconHaloColorUseTheme  interface{} `json:"icon-halo-color-use-theme,omitempty"`
 	IconHaloWidth          interface{} `json:"icon-halo-width,omitempty"`
 	IconOcclusionOpacity   interface{} `json:"icon-occlusion-opacity,omitempty"`
 	IconOpacity            interface{} `json:"icon-opacity,omitempty"`
@@ -156,9 +175,11 @@ type Paint struct {
 
 	// Symbol - Text
 	TextColor            *ColorType `json:"text-color,omitempty"`
+	TextColorUseTheme    interface{} `json:"text-color-use-theme,omitempty"`
 	TextEmissiveStrength interface{} `json:"text-emissive-strength,omitempty"`
 	TextHaloBlur         interface{} `json:"text-halo-blur,omitempty"`
 	TextHaloColor        *ColorType `json:"text-halo-color,omitempty"`
+	TextHaloColorUseTheme interface{} `json:"text-halo-color-use-theme,omitempty"`
 	TextHaloWidth        interface{} `json:"text-halo-width,omitempty"`
 	TextOcclusionOpacity interface{} `json:"text-occlusion-opacity,omitempty"`
 	TextOpacity          interface{} `json:"text-opacity,omitempty"`
@@ -167,4 +188,7 @@ type Paint struct {
 
 	// Symbol - combined
 	SymbolZOffset interface{} `json:"symbol-z-offset,omitempty"`
+
+	cache  propertyCache
+	config *ConfigScope
 }
@@ -0,0 +1,384 @@
+package style
+
+// propertyAt evaluates a Paint or Layout field, returning def when it is
+// unset or fails to evaluate. The parsed value is cached under the property
+// name.
+func propertyAt[T any](c *propertyCache, config *ConfigScope, name string, raw interface{}, zoom float64, feature *Feature, def T) (T, error) {
+	p, err := cachedPropertyValue[T](c, name, raw, nil)
+	if err != nil {
+		return def, err
+	}
+	return evaluateOr(p, EvalContext{Zoom: zoom, Feature: feature, Config: config}, def)
+}
+
+// tokenPropertyAt is propertyAt for properties that accept {token} strings.
+func tokenPropertyAt[T any](c *propertyCache, config *ConfigScope, name string, raw interface{}, zoom float64, feature *Feature, def T) (T, error) {
+	p, err := cachedPropertyValue[T](c, name, raw, tokenValue)
+	if err != nil {
+		return def, err
+	}
+	return evaluateOr(p, EvalContext{Zoom: zoom, Feature: feature, Config: config}, def)
+}
+
+// evaluateOr is PropertyValue.EvaluateOr with the whole evaluation context.
+func evaluateOr[T any](p *PropertyValue[T], ctx EvalContext, def T) (T, error) {
+	if p == nil {
+		return def, nil
+	}
+	v, err := p.EvaluateContext(ctx)
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// tokenValue rewrites a {token} string as the equivalent expression.
+func tokenValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return convertTokenString(s)
+	}
+	return v
+}
+
+// numberArray turns a nil slice into a nil interface so it reads as unset.
+func numberArray(v []float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
+func (p *Paint) BackgroundOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "background-opacity", p.BackgroundOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) CircleBlurAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "circle-blur", p.CircleBlur, zoom, feature, 0)
+}
+
+func (p *Paint) CircleOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "circle-opacity", p.CircleOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) CircleRadiusAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 5, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "circle-radius", p.CircleRadius, zoom, feature, 5)
+}
+
+func (p *Paint) CircleStrokeOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "circle-stroke-opacity", p.CircleStrokeOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) CircleStrokeWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "circle-stroke-width", p.CircleStrokeWidth, zoom, feature, 0)
+}
+
+func (p *Paint) FillOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "fill-opacity", p.FillOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) FillExtrusionBaseAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "fill-extrusion-base", p.FillExtrusionBase, zoom, feature, 0)
+}
+
+func (p *Paint) FillExtrusionHeightAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "fill-extrusion-height", p.FillExtrusionHeight, zoom, feature, 0)
+}
+
+func (p *Paint) FillExtrusionOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "fill-extrusion-opacity", p.FillExtrusionOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) HeatmapIntensityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "heatmap-intensity", p.HeatmapIntensity, zoom, feature, 1)
+}
+
+func (p *Paint) HeatmapOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "heatmap-opacity", p.HeatmapOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) HeatmapRadiusAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 30, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "heatmap-radius", p.HeatmapRadius, zoom, feature, 30)
+}
+
+func (p *Paint) HeatmapWeightAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "heatmap-weight", p.HeatmapWeight, zoom, feature, 1)
+}
+
+func (p *Paint) IconHaloBlurAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "icon-halo-blur", p.IconHaloBlur, zoom, feature, 0)
+}
+
+func (p *Paint) IconHaloWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "icon-halo-width", p.IconHaloWidth, zoom, feature, 0)
+}
+
+func (p *Paint) IconOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "icon-opacity", p.IconOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) LineBlurAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "line-blur", p.LineBlur, zoom, feature, 0)
+}
+
+func (p *Paint) LineDashArrayAt(zoom float64, feature *Feature) ([]float64, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return propertyAt[[]float64](&p.cache, p.config, "line-dasharray", numberArray(p.LineDashArray), zoom, feature, nil)
+}
+
+func (p *Paint) LineGapWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "line-gap-width", p.LineGapWidth, zoom, feature, 0)
+}
+
+func (p *Paint) LineOffsetAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "line-offset", p.LineOffset, zoom, feature, 0)
+}
+
+func (p *Paint) LineOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "line-opacity", p.LineOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) LineWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "line-width", p.LineWidth, zoom, feature, 1)
+}
+
+func (p *Paint) RasterOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "raster-opacity", p.RasterOpacity, zoom, feature, 1)
+}
+
+func (p *Paint) TextHaloBlurAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "text-halo-blur", p.TextHaloBlur, zoom, feature, 0)
+}
+
+func (p *Paint) TextHaloWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "text-halo-width", p.TextHaloWidth, zoom, feature, 0)
+}
+
+func (p *Paint) TextOpacityAt(zoom float64, feature *Feature) (float64, error) {
+	if p == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&p.cache, p.config, "text-opacity", p.TextOpacity, zoom, feature, 1)
+}
+
+// IconImageAt evaluates icon-image, expanding {token} strings with feature
+// attributes.
+func (l *Layout) IconImageAt(zoom float64, feature *Feature) (string, error) {
+	if l == nil {
+		return "", nil
+	}
+	return tokenPropertyAt[string](&l.cache, l.config, "icon-image", l.IconImage, zoom, feature, "")
+}
+
+func (l *Layout) IconOffsetAt(zoom float64, feature *Feature) ([]float64, error) {
+	if l == nil {
+		return []float64{0, 0}, nil
+	}
+	return propertyAt[[]float64](&l.cache, l.config, "icon-offset", numberArray(l.IconOffset), zoom, feature, []float64{0, 0})
+}
+
+func (l *Layout) IconPaddingAt(zoom float64, feature *Feature) (Padding, error) {
+	if l == nil {
+		return Padding{2, 2, 2, 2}, nil
+	}
+	return propertyAt[Padding](&l.cache, l.config, "icon-padding", l.IconPadding, zoom, feature, Padding{2, 2, 2, 2})
+}
+
+func (l *Layout) IconRotateAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "icon-rotate", l.IconRotate, zoom, feature, 0)
+}
+
+func (l *Layout) IconSizeAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 1, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "icon-size", l.IconSize, zoom, feature, 1)
+}
+
+func (l *Layout) LineMiterLimitAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 2, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "line-miter-limit", l.LineMiterLimit, zoom, feature, 2)
+}
+
+func (l *Layout) LineRoundLimitAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 1.05, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "line-round-limit", l.LineRoundLimit, zoom, feature, 1.05)
+}
+
+func (l *Layout) SymbolPlacementAt(zoom float64, feature *Feature) (string, error) {
+	if l == nil {
+		return "point", nil
+	}
+	return propertyAt[string](&l.cache, l.config, "symbol-placement", l.SymbolPlacement, zoom, feature, "point")
+}
+
+func (l *Layout) SymbolSpacingAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 250, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "symbol-spacing", l.SymbolSpacing, zoom, feature, 250)
+}
+
+// TextFieldAt evaluates text-field as plain text, expanding {token} strings
+// with feature attributes.
+func (l *Layout) TextFieldAt(zoom float64, feature *Feature) (string, error) {
+	if l == nil {
+		return "", nil
+	}
+	return tokenPropertyAt[string](&l.cache, l.config, "text-field", l.TextField, zoom, feature, "")
+}
+
+// TextFieldFormattedAt evaluates text-field with the sections of format
+// expressions, for output that styles each section.
+func (l *Layout) TextFieldFormattedAt(zoom float64, feature *Feature) (Formatted, error) {
+	if l == nil {
+		return Formatted{}, nil
+	}
+	return tokenPropertyAt[Formatted](&l.cache, l.config, "text-field", l.TextField, zoom, feature, Formatted{})
+}
+
+func (l *Layout) TextLetterSpacingAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-letter-spacing", l.TextLetterSpacing, zoom, feature, 0)
+}
+
+func (l *Layout) TextLineHeightAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 1.2, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-line-height", l.TextLineHeight, zoom, feature, 1.2)
+}
+
+func (l *Layout) TextMaxAngleAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 45, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-max-angle", l.TextMaxAngle, zoom, feature, 45)
+}
+
+func (l *Layout) TextMaxWidthAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 10, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-max-width", l.TextMaxWidth, zoom, feature, 10)
+}
+
+func (l *Layout) TextOffsetAt(zoom float64, feature *Feature) ([]float64, error) {
+	if l == nil {
+		return []float64{0, 0}, nil
+	}
+	return propertyAt[[]float64](&l.cache, l.config, "text-offset", numberArray(l.TextOffset), zoom, feature, []float64{0, 0})
+}
+
+func (l *Layout) TextPaddingAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 2, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-padding", l.TextPadding, zoom, feature, 2)
+}
+
+func (l *Layout) TextRadialOffsetAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-radial-offset", l.TextRadialOffset, zoom, feature, 0)
+}
+
+func (l *Layout) TextRotateAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-rotate", l.TextRotate, zoom, feature, 0)
+}
+
+func (l *Layout) TextSizeAt(zoom float64, feature *Feature) (float64, error) {
+	if l == nil {
+		return 16, nil
+	}
+	return propertyAt[float64](&l.cache, l.config, "text-size", l.TextSize, zoom, feature, 16)
+}
@@ -0,0 +1,69 @@
+package style
+
+import (
+	"reflect"
+	"sync"
+)
+
+// propertyCache keeps the parsed values of the properties of a Paint or
+// Layout, so the *At accessors parse a property once rather than for every
+// feature. An entry is reused while the field holds the same value: the
+// same constant, or the same map or slice. Changing a map or slice in place
+// is not noticed; assign a new value instead.
+type propertyCache struct {
+	mu     sync.Mutex
+	values map[propertyCacheKey]cachedProperty
+}
+
+type propertyCacheKey struct {
+	name string
+	typ  reflect.Type
+}
+
+type cachedProperty struct {
+	raw   interface{}
+	value interface{}
+	err   error
+}
+
+// cachedPropertyValue returns the PropertyValue of raw, the value of the
+// named property, parsing it on first use. prepare, if set, rewrites raw
+// before parsing.
+func cachedPropertyValue[T any](c *propertyCache, name string, raw interface{}, prepare func(interface{}) interface{}) (*PropertyValue[T], error) {
+	key := propertyCacheKey{name, reflect.TypeOf((*T)(nil))}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.values[key]; ok && sameRaw(entry.raw, raw) {
+		p, _ := entry.value.(*PropertyValue[T])
+		return p, entry.err
+	}
+	value := raw
+	if prepare != nil {
+		value = prepare(raw)
+	}
+	p, err := newPropertyValue[T](value, name)
+	if c.values == nil {
+		c.values = map[propertyCacheKey]cachedProperty{}
+	}
+	c.values[key] = cachedProperty{raw: raw, value: p, err: err}
+	return p, err
+}
+
+// sameRaw reports whether two decoded property values are the same value:
+// equal scalars, or the same map, slice or pointer.
+func sameRaw(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	return va.Type().Comparable() && a == b
+}
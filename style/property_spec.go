@@ -0,0 +1,427 @@
+package style
+
+// Property value types used by the style specification.
+const (
+	specNumber        = "number"
+	specColor         = "color"
+	specBoolean       = "boolean"
+	specString        = "string"
+	specEnum          = "enum"
+	specArray         = "array"
+	specFormatted     = "formatted"
+	specResolvedImage = "resolvedImage"
+	specPadding       = "padding"
+)
+
+// How a property may be driven by expressions.
+const (
+	// exprConstant properties only accept constant values.
+	exprConstant = iota
+	// exprZoom properties accept camera expressions but not feature data.
+	exprZoom
+	// exprDataDriven properties accept camera and feature data expressions.
+	exprDataDriven
+	// exprColorRamp properties are driven by heatmap-density or
+	// line-progress.
+	exprColorRamp
+)
+
+// propertySpec describes the value of a single paint or layout property.
+type propertySpec struct {
+	Type       string
+	Value      string   // item type of array properties
+	Length     int      // fixed length of array properties, 0 if variable
+	Values     []string // allowed values of enum properties and enum arrays
+	Min        *float64
+	Max        *float64
+	Expression int
+}
+
+var enumAnchors = []string{
+	"center", "left", "right", "top", "bottom",
+	"top-left", "top-right", "bottom-left", "bottom-right",
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// layerSpec lists the paint and layout properties a layer type accepts.
+type layerSpec struct {
+	Paint  map[string]propertySpec
+	Layout map[string]propertySpec
+}
+
+// expressionType returns the type an expression for this property must
+// produce.
+func (p propertySpec) expressionType() ExpressionType {
+	switch p.Type {
+	case specNumber:
+		return TypeNumber
+	case specColor:
+		return TypeColor
+	case specBoolean:
+		return TypeBoolean
+	case specString, specEnum:
+		return TypeString
+	case specFormatted:
+		return TypeFormatted
+	case specResolvedImage:
+		return TypeResolvedImage
+	case specPadding:
+		return TypePadding
+	case specArray:
+		item := propertySpec{Type: p.Value}.expressionType()
+		return ArrayOf(item, p.Length)
+	}
+	return TypeValue
+}
+
+// propertySpecFor looks up a paint (paint == true) or layout property of a
+// layer type.
+func propertySpecFor(t LayerType, name string, paint bool) (propertySpec, bool) {
+	ls, ok := layerPropertySpecs[t]
+	if !ok {
+		return propertySpec{}, false
+	}
+	props := ls.Layout
+	if paint {
+		props = ls.Paint
+	}
+	spec, ok := props[name]
+	return spec, ok
+}
+
+// propertySpecNamed looks up a paint or layout property by name alone;
+// property names are not shared between layer types.
+func propertySpecNamed(name string) (propertySpec, bool) {
+	if name == "" {
+		return propertySpec{}, false
+	}
+	for _, ls := range layerPropertySpecs {
+		if spec, ok := ls.Paint[name]; ok {
+			return spec, true
+		}
+		if spec, ok := ls.Layout[name]; ok {
+			return spec, true
+		}
+	}
+	return propertySpec{}, false
+}
+
+var layerPropertySpecs = map[LayerType]layerSpec{
+	LayerTypeBackground: {
+		Paint: map[string]propertySpec{
+			"background-color":             {Type: specColor, Expression: exprZoom},
+			"background-color-use-theme":   {Type: specString, Expression: exprDataDriven},
+			"background-emissive-strength": {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"background-opacity":           {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"background-pattern":           {Type: specResolvedImage, Expression: exprZoom},
+			"background-pitch-alignment":   {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeCircle: {
+		Paint: map[string]propertySpec{
+			"circle-blur":                   {Type: specNumber, Expression: exprDataDriven},
+			"circle-color":                  {Type: specColor, Expression: exprDataDriven},
+			"circle-color-use-theme":        {Type: specString, Expression: exprDataDriven},
+			"circle-emissive-strength":      {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"circle-opacity":                {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"circle-pitch-alignment":        {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"circle-pitch-scale":            {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"circle-radius":                 {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"circle-stroke-color":           {Type: specColor, Expression: exprDataDriven},
+			"circle-stroke-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"circle-stroke-opacity":         {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"circle-stroke-width":           {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"circle-translate":              {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"circle-translate-anchor":       {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"circle-elevation-reference": {Type: specEnum, Values: []string{"none", "hd-road-markup"}, Expression: exprConstant},
+			"circle-sort-key":            {Type: specNumber, Expression: exprDataDriven},
+			"visibility":                 {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeFill: {
+		Paint: map[string]propertySpec{
+			"fill-antialias":               {Type: specBoolean, Expression: exprZoom},
+			"fill-color":                   {Type: specColor, Expression: exprDataDriven},
+			"fill-color-use-theme":         {Type: specString, Expression: exprDataDriven},
+			"fill-emissive-strength":       {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"fill-opacity":                 {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"fill-outline-color":           {Type: specColor, Expression: exprDataDriven},
+			"fill-outline-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"fill-pattern":                 {Type: specResolvedImage, Expression: exprDataDriven},
+			"fill-pattern-cross-fade":      {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-translate":               {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"fill-translate-anchor":        {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"fill-z-offset":                {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+		},
+		Layout: map[string]propertySpec{
+			"fill-sort-key": {Type: specNumber, Expression: exprDataDriven},
+			"visibility":    {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeFillExtrusion: {
+		Paint: map[string]propertySpec{
+			"fill-extrusion-ambient-occlusion-ground-attenuation": {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-ambient-occlusion-ground-radius":      {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"fill-extrusion-ambient-occlusion-intensity":          {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-ambient-occlusion-radius":             {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"fill-extrusion-ambient-occlusion-wall-radius":        {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"fill-extrusion-base":                                 {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"fill-extrusion-base-alignment":                       {Type: specEnum, Values: []string{"terrain", "flat"}, Expression: exprZoom},
+			"fill-extrusion-cast-shadows":                         {Type: specBoolean, Expression: exprZoom},
+			"fill-extrusion-color":                                {Type: specColor, Expression: exprDataDriven},
+			"fill-extrusion-color-use-theme":                      {Type: specString, Expression: exprDataDriven},
+			"fill-extrusion-cutoff-fade-range":                    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-emissive-strength":                    {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"fill-extrusion-flood-light-color":                    {Type: specColor, Expression: exprZoom},
+			"fill-extrusion-flood-light-color-use-theme":          {Type: specString, Expression: exprDataDriven},
+			"fill-extrusion-flood-light-ground-attenuation":       {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-flood-light-ground-radius":            {Type: specNumber, Expression: exprDataDriven},
+			"fill-extrusion-flood-light-intensity":                {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-flood-light-wall-radius":              {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"fill-extrusion-height":                               {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"fill-extrusion-height-alignment":                     {Type: specEnum, Values: []string{"terrain", "flat"}, Expression: exprZoom},
+			"fill-extrusion-line-width":                           {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"fill-extrusion-opacity":                              {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-pattern":                              {Type: specResolvedImage, Expression: exprDataDriven},
+			"fill-extrusion-pattern-cross-fade":                   {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"fill-extrusion-rounded-roof":                         {Type: specBoolean, Expression: exprZoom},
+			"fill-extrusion-translate":                            {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"fill-extrusion-translate-anchor":                     {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"fill-extrusion-vertical-gradient":                    {Type: specBoolean, Expression: exprZoom},
+			"fill-extrusion-vertical-scale":                       {Type: specNumber, Min: bound(0), Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"fill-extrusion-edge-radius": {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprConstant},
+			"visibility":                 {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeHeatmap: {
+		Paint: map[string]propertySpec{
+			"heatmap-color":           {Type: specColor, Expression: exprColorRamp},
+			"heatmap-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"heatmap-intensity":       {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"heatmap-opacity":         {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"heatmap-radius":          {Type: specNumber, Min: bound(1), Expression: exprDataDriven},
+			"heatmap-weight":          {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeHillshade: {
+		Paint: map[string]propertySpec{
+			"hillshade-accent-color":              {Type: specColor, Expression: exprZoom},
+			"hillshade-accent-color-use-theme":    {Type: specString, Expression: exprDataDriven},
+			"hillshade-emissive-strength":         {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"hillshade-exaggeration":              {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"hillshade-highlight-color":           {Type: specColor, Expression: exprZoom},
+			"hillshade-highlight-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"hillshade-illumination-anchor":       {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"hillshade-illumination-direction":    {Type: specNumber, Min: bound(0), Max: bound(359), Expression: exprZoom},
+			"hillshade-shadow-color":              {Type: specColor, Expression: exprZoom},
+			"hillshade-shadow-color-use-theme":    {Type: specString, Expression: exprDataDriven},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeLine: {
+		Paint: map[string]propertySpec{
+			"line-blur":                 {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"line-color":                {Type: specColor, Expression: exprDataDriven},
+			"line-color-use-theme":      {Type: specString, Expression: exprDataDriven},
+			"line-dasharray":            {Type: specArray, Value: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"line-emissive-strength":    {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"line-gap-width":            {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"line-gradient":             {Type: specColor, Expression: exprColorRamp},
+			"line-gradient-use-theme":   {Type: specString, Expression: exprDataDriven},
+			"line-occlusion-opacity":    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"line-offset":               {Type: specNumber, Expression: exprDataDriven},
+			"line-opacity":              {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"line-pattern":              {Type: specResolvedImage, Expression: exprDataDriven},
+			"line-pattern-cross-fade":   {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"line-translate":            {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"line-translate-anchor":     {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"line-trim-color":           {Type: specColor, Expression: exprZoom},
+			"line-trim-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"line-trim-fade-range":      {Type: specArray, Value: specNumber, Length: 2, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"line-trim-offset":          {Type: specArray, Value: specNumber, Length: 2, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"line-width":                {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+		},
+		Layout: map[string]propertySpec{
+			"line-cap":                    {Type: specEnum, Values: []string{"butt", "round", "square"}, Expression: exprDataDriven},
+			"line-cross-slope":            {Type: specNumber, Expression: exprZoom},
+			"line-elevation-ground-scale": {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"line-elevation-reference":    {Type: specEnum, Values: []string{"none", "sea", "ground", "hd-road-markup"}, Expression: exprConstant},
+			"line-join":                   {Type: specEnum, Values: []string{"bevel", "round", "miter", "none"}, Expression: exprDataDriven},
+			"line-miter-limit":            {Type: specNumber, Expression: exprZoom},
+			"line-round-limit":            {Type: specNumber, Expression: exprZoom},
+			"line-sort-key":               {Type: specNumber, Expression: exprDataDriven},
+			"line-z-offset":               {Type: specNumber, Expression: exprDataDriven},
+			"visibility":                  {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeModel: {
+		Paint: map[string]propertySpec{
+			"model-ambient-occlusion-intensity":               {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"model-cast-shadows":                              {Type: specBoolean, Expression: exprZoom},
+			"model-color":                                     {Type: specColor, Expression: exprDataDriven},
+			"model-color-use-theme":                           {Type: specString, Expression: exprDataDriven},
+			"model-color-mix-intensity":                       {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"model-cutoff-fade-range":                         {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"model-elevation-reference":                       {Type: specEnum, Values: []string{"sea", "ground", "hd-road-markup"}, Expression: exprConstant},
+			"model-emissive-strength":                         {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"model-height-based-emissive-strength-multiplier": {Type: specArray, Value: specNumber, Length: 5, Expression: exprDataDriven},
+			"model-opacity":                                   {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"model-receive-shadows":                           {Type: specBoolean, Expression: exprZoom},
+			"model-rotation":                                  {Type: specArray, Value: specNumber, Length: 3, Expression: exprDataDriven},
+			"model-roughness":                                 {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"model-scale":                                     {Type: specArray, Value: specNumber, Length: 3, Expression: exprDataDriven},
+			"model-translation":                               {Type: specArray, Value: specNumber, Length: 3, Expression: exprDataDriven},
+			"model-type":                                      {Type: specEnum, Values: []string{"common-3d", "location-indicator"}, Expression: exprConstant},
+		},
+		Layout: map[string]propertySpec{
+			"model-allow-density-reduction": {Type: specBoolean, Expression: exprConstant},
+			"model-id":                      {Type: specString, Expression: exprDataDriven},
+			"visibility":                    {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeRaster: {
+		Paint: map[string]propertySpec{
+			"raster-array-band":        {Type: specString, Expression: exprConstant},
+			"raster-brightness-max":    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"raster-brightness-min":    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"raster-color-mix":         {Type: specArray, Value: specNumber, Length: 4, Expression: exprZoom},
+			"raster-color-range":       {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"raster-contrast":          {Type: specNumber, Min: bound(-1), Max: bound(1), Expression: exprZoom},
+			"raster-elevation":         {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"raster-emissive-strength": {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"raster-hue-rotate":        {Type: specNumber, Expression: exprZoom},
+			"raster-opacity":           {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"raster-resampling":        {Type: specEnum, Values: []string{"linear", "nearest"}, Expression: exprZoom},
+			"raster-saturation":        {Type: specNumber, Min: bound(-1), Max: bound(1), Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeRasterParticle: {
+		Paint: map[string]propertySpec{
+			"raster-particle-elevation":           {Type: specNumber, Min: bound(0), Expression: exprZoom},
+			"raster-particle-fade-opacity-factor": {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"raster-particle-speed-factor":        {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeSky: {
+		Paint: map[string]propertySpec{
+			"sky-atmosphere-color":                {Type: specColor, Expression: exprZoom},
+			"sky-atmosphere-color-use-theme":      {Type: specString, Expression: exprDataDriven},
+			"sky-atmosphere-halo-color":           {Type: specColor, Expression: exprZoom},
+			"sky-atmosphere-halo-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"sky-atmosphere-sun":                  {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"sky-atmosphere-sun-intensity":        {Type: specNumber, Min: bound(0), Max: bound(100), Expression: exprZoom},
+			"sky-gradient-center":                 {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"sky-gradient-radius":                 {Type: specNumber, Min: bound(0), Max: bound(180), Expression: exprZoom},
+			"sky-opacity":                         {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"sky-type":                            {Type: specEnum, Values: []string{"gradient", "atmosphere"}, Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeSymbol: {
+		Paint: map[string]propertySpec{
+			"icon-color":                {Type: specColor, Expression: exprDataDriven},
+			"icon-color-use-theme":      {Type: specString, Expression: exprDataDriven},
+			"icon-cross-fade":           {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprZoom},
+			"icon-emissive-strength":    {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"icon-halo-blur":            {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"icon-halo-color":           {Type: specColor, Expression: exprDataDriven},
+			"icon-halo-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"icon-halo-width":           {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"icon-occlusion-opacity":    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"icon-opacity":              {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"icon-translate":            {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"icon-translate-anchor":     {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+			"symbol-z-offset":           {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-color":                {Type: specColor, Expression: exprDataDriven},
+			"text-color-use-theme":      {Type: specString, Expression: exprDataDriven},
+			"text-emissive-strength":    {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-halo-blur":            {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-halo-color":           {Type: specColor, Expression: exprDataDriven},
+			"text-halo-color-use-theme": {Type: specString, Expression: exprDataDriven},
+			"text-halo-width":           {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-occlusion-opacity":    {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"text-opacity":              {Type: specNumber, Min: bound(0), Max: bound(1), Expression: exprDataDriven},
+			"text-translate":            {Type: specArray, Value: specNumber, Length: 2, Expression: exprZoom},
+			"text-translate-anchor":     {Type: specEnum, Values: []string{"map", "viewport"}, Expression: exprZoom},
+		},
+		Layout: map[string]propertySpec{
+			"icon-allow-overlap":      {Type: specBoolean, Expression: exprZoom},
+			"icon-anchor":             {Type: specEnum, Values: enumAnchors, Expression: exprDataDriven},
+			"icon-ignore-placement":   {Type: specBoolean, Expression: exprZoom},
+			"icon-image":              {Type: specResolvedImage, Expression: exprDataDriven},
+			"icon-keep-upright":       {Type: specBoolean, Expression: exprZoom},
+			"icon-offset":             {Type: specArray, Value: specNumber, Length: 2, Expression: exprDataDriven},
+			"icon-optional":           {Type: specBoolean, Expression: exprZoom},
+			"icon-padding":            {Type: specPadding, Min: bound(0), Expression: exprDataDriven},
+			"icon-pitch-alignment":    {Type: specEnum, Values: []string{"map", "viewport", "auto"}, Expression: exprZoom},
+			"icon-rotate":             {Type: specNumber, Expression: exprDataDriven},
+			"icon-rotation-alignment": {Type: specEnum, Values: []string{"map", "viewport", "auto"}, Expression: exprZoom},
+			"icon-size":               {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"icon-text-fit":           {Type: specEnum, Values: []string{"none", "width", "height", "both"}, Expression: exprDataDriven},
+			"icon-text-fit-padding":   {Type: specArray, Value: specNumber, Length: 4, Expression: exprDataDriven},
+			"symbol-avoid-edges":      {Type: specBoolean, Expression: exprZoom},
+			"symbol-placement":        {Type: specEnum, Values: []string{"point", "line", "line-center"}, Expression: exprZoom},
+			"symbol-spacing":          {Type: specNumber, Min: bound(1), Expression: exprZoom},
+			"symbol-z-elevate":        {Type: specBoolean, Expression: exprZoom},
+			"symbol-z-order":          {Type: specEnum, Values: []string{"auto", "viewport-y", "source"}, Expression: exprZoom},
+			"text-allow-overlap":      {Type: specBoolean, Expression: exprZoom},
+			"text-anchor":             {Type: specEnum, Values: enumAnchors, Expression: exprDataDriven},
+			"text-field":              {Type: specFormatted, Expression: exprDataDriven},
+			"text-font":               {Type: specArray, Value: specString, Expression: exprDataDriven},
+			"text-ignore-placement":   {Type: specBoolean, Expression: exprZoom},
+			"text-justify":            {Type: specEnum, Values: []string{"auto", "left", "center", "right"}, Expression: exprDataDriven},
+			"text-keep-upright":       {Type: specBoolean, Expression: exprZoom},
+			"text-letter-spacing":     {Type: specNumber, Expression: exprDataDriven},
+			"text-line-height":        {Type: specNumber, Expression: exprDataDriven},
+			"text-max-angle":          {Type: specNumber, Expression: exprZoom},
+			"text-max-width":          {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-offset":             {Type: specArray, Value: specNumber, Length: 2, Expression: exprDataDriven},
+			"text-optional":           {Type: specBoolean, Expression: exprZoom},
+			"text-padding":            {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-pitch-alignment":    {Type: specEnum, Values: []string{"map", "viewport", "auto"}, Expression: exprZoom},
+			"text-radial-offset":      {Type: specNumber, Expression: exprDataDriven},
+			"text-rotate":             {Type: specNumber, Expression: exprDataDriven},
+			"text-rotation-alignment": {Type: specEnum, Values: []string{"map", "viewport", "viewport-glyph", "auto"}, Expression: exprZoom},
+			"text-size":               {Type: specNumber, Min: bound(0), Expression: exprDataDriven},
+			"text-transform":          {Type: specEnum, Values: []string{"none", "uppercase", "lowercase"}, Expression: exprDataDriven},
+			"text-variable-anchor":    {Type: specArray, Value: specEnum, Values: enumAnchors, Expression: exprZoom},
+			"text-writing-mode":       {Type: specArray, Value: specEnum, Values: []string{"horizontal", "vertical"}, Expression: exprZoom},
+			"visibility":              {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeClip: {
+		Layout: map[string]propertySpec{
+			"clip-layer-scope": {Type: specArray, Value: specString, Expression: exprConstant},
+			"clip-layer-types": {Type: specArray, Value: specEnum, Values: []string{"model", "symbol"}, Expression: exprConstant},
+			"visibility":       {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+	LayerTypeSlot: {
+		Layout: map[string]propertySpec{
+			"visibility": {Type: specEnum, Values: []string{"visible", "none"}, Expression: exprConstant},
+		},
+	},
+}
@@ -0,0 +1,247 @@
+package style
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateProperties checks the layer's paint and layout properties against
+// the style specification: properties must belong to the layer type, values
+// must have the right type, enum values and numeric ranges must be valid, and
+// only data-driven properties may depend on feature data. Every problem
+// found is reported, with paths relative to the layer.
+func (l *Layer) ValidateProperties() ValidationErrors {
+	var errs ValidationErrors
+	if _, ok := layerPropertySpecs[l.Type]; !ok {
+		return errs
+	}
+	errs = append(errs, l.validatePropertyGroup(l.Paint, true, "paint")...)
+	errs = append(errs, l.validatePropertyGroup(l.Layout, false, "layout")...)
+	return errs
+}
+
+// ValidateLayers validates every layer, collecting structural errors from
+// Layer.Validate as well as property errors from Layer.ValidateProperties.
+// Duplicate layer ids are reported too.
+func (s *Style) ValidateLayers() ValidationErrors {
+	var errs ValidationErrors
+	seen := map[string]int{}
+	for i, l := range s.Layers {
+		prefix := fmt.Sprintf("layers[%d]", i)
+		if l == nil {
+			errs.add(prefix, "layer is null")
+			continue
+		}
+		if err := l.Validate(); err != nil {
+			errs.add(prefix, "%v", err)
+		}
+		if first, ok := seen[l.ID]; ok && l.ID != "" {
+			errs.add(prefix+".id", "duplicate layer id %q, previously used at layers[%d]", l.ID, first)
+		} else {
+			seen[l.ID] = i
+		}
+		for _, e := range l.ValidateProperties() {
+			errs = append(errs, &ValidationError{Path: prefix + "." + e.Path, Message: e.Message})
+		}
+	}
+	return errs
+}
+
+func (l *Layer) validatePropertyGroup(v interface{}, paint bool, path string) ValidationErrors {
+	tc := &typeChecker{}
+	props, err := propertyMap(v)
+	if err != nil {
+		tc.errs.add(path, "%v", err)
+		return tc.errs
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propPath := path + "." + name
+		spec, ok := propertySpecFor(l.Type, name, paint)
+		if !ok {
+			if owner, found := propertyOwner(name, paint); found {
+				tc.errs.add(propPath, "property is not supported by %s layers, only by %s layers", l.Type, owner)
+			} else {
+				tc.errs.add(propPath, "unknown property")
+			}
+			continue
+		}
+		raw := props[name]
+		errCount := len(tc.errs)
+		tc.checkProperty(raw, spec.expressionType(), propPath)
+		if len(tc.errs) > errCount {
+			continue
+		}
+		spec.validateDrivenBy(raw, propPath, &tc.errs)
+		spec.validateLiterals(raw, propPath, &tc.errs)
+	}
+	return tc.errs
+}
+
+// propertyOwner returns the layer types that do accept a property.
+func propertyOwner(name string, paint bool) (string, bool) {
+	var owners []string
+	for t := range layerPropertySpecs {
+		if _, ok := propertySpecFor(t, name, paint); ok {
+			owners = append(owners, string(t))
+		}
+	}
+	sort.Strings(owners)
+	return strings.Join(owners, ", "), len(owners) > 0
+}
+
+// validateDrivenBy checks that expressions and functions only use inputs the
+// property supports.
+func (p propertySpec) validateDrivenBy(raw interface{}, path string, errs *ValidationErrors) {
+	if isLegacyFunction(raw) {
+		fn := raw.(map[string]interface{})
+		switch {
+		case p.Expression == exprConstant:
+			errs.add(path, "functions are not supported")
+		case p.Expression != exprDataDriven && fn["property"] != nil:
+			errs.add(path, "property functions are not supported")
+		}
+		return
+	}
+	e, err := propertyExpression(raw)
+	if err != nil || e.IsLiteral {
+		return
+	}
+	switch p.Expression {
+	case exprConstant:
+		errs.add(path, "expressions are not supported")
+		return
+	case exprZoom:
+		if IsDataExpression(e) {
+			errs.add(path, "data expressions not supported")
+			return
+		}
+	}
+	if IsCameraExpression(e) && !isTopLevelZoomCurve(e) {
+		errs.add(path, "\"zoom\" expression may only be used as input to a top-level \"step\" or \"interpolate\" expression")
+	}
+}
+
+// isTopLevelZoomCurve reports whether every use of zoom in e is the input of
+// a step or interpolate that is not nested inside another expression,
+// looking through let and coalesce wrappers as GL does.
+func isTopLevelZoomCurve(e *Expression) bool {
+	switch e.Operator {
+	case ExpStep, ExpInterpolate, ExpInterpolateHCL, ExpInterpolateLab:
+		input := 0
+		if e.Operator != ExpStep {
+			input = 1
+		}
+		for i, arg := range e.Args {
+			if i != input && IsCameraExpression(arg) {
+				return false
+			}
+		}
+		return len(e.Args) > input
+	case ExpLet:
+		for i, arg := range e.Args {
+			if i != len(e.Args)-1 && IsCameraExpression(arg) {
+				return false
+			}
+		}
+		return len(e.Args) > 0 && isTopLevelZoomCurve(e.Args[len(e.Args)-1])
+	case ExpCoalesce:
+		for _, arg := range e.Args {
+			if IsCameraExpression(arg) && !isTopLevelZoomCurve(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// validateLiterals checks enum values and numeric ranges of constant values,
+// including the outputs of legacy functions.
+func (p propertySpec) validateLiterals(raw interface{}, path string, errs *ValidationErrors) {
+	if isLegacyFunction(raw) {
+		stops, _ := raw.(map[string]interface{})["stops"].([]interface{})
+		for i, stop := range stops {
+			if pair, ok := stop.([]interface{}); ok && len(pair) == 2 {
+				p.validateLiterals(pair[1], fmt.Sprintf("%s.stops[%d][1]", path, i), errs)
+			}
+		}
+		return
+	}
+	e, err := propertyExpression(raw)
+	if err != nil || !e.IsLiteral {
+		return
+	}
+	value := normalizeValue(e.Value)
+
+	switch p.Type {
+	case specEnum:
+		p.validateEnum(value, path, errs)
+	case specNumber:
+		p.validateRange(value, path, errs)
+	case specPadding:
+		if arr, ok := value.([]interface{}); ok {
+			if len(arr) < 1 || len(arr) > 4 {
+				errs.add(path, "padding requires 1 to 4 values, found %d", len(arr))
+			}
+			for i, item := range arr {
+				p.validateRange(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		} else {
+			p.validateRange(value, path, errs)
+		}
+	case specArray:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		if p.Length > 0 && len(arr) != p.Length {
+			errs.add(path, "array length %d expected, length %d found", p.Length, len(arr))
+		}
+		for i, item := range arr {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch p.Value {
+			case specEnum:
+				p.validateEnum(item, itemPath, errs)
+			case specNumber:
+				p.validateRange(item, itemPath, errs)
+			}
+		}
+	}
+}
+
+func (p propertySpec) validateEnum(value interface{}, path string, errs *ValidationErrors) {
+	s, ok := value.(string)
+	if !ok {
+		return
+	}
+	for _, v := range p.Values {
+		if v == s {
+			return
+		}
+	}
+	quoted := make([]string, len(p.Values))
+	for i, v := range p.Values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	errs.add(path, "expected one of [%s], %q found", strings.Join(quoted, ", "), s)
+}
+
+func (p propertySpec) validateRange(value interface{}, path string, errs *ValidationErrors) {
+	n, ok := value.(float64)
+	if !ok {
+		return
+	}
+	if p.Min != nil && n < *p.Min {
+		errs.add(path, "%s is less than the minimum value %s", formatNumber(n), formatNumber(*p.Min))
+	}
+	if p.Max != nil && n > *p.Max {
+		errs.add(path, "%s is greater than the maximum value %s", formatNumber(n), formatNumber(*p.Max))
+	}
+}
@@ -0,0 +1,88 @@
+package style
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLayerValidateProperties(t *testing.T) {
+	tests := []struct {
+		name  string
+		layer string
+		want  []string
+	}{
+		{"valid", `{"id":"l","type":"line","paint":{"line-width":["interpolate",["linear"],["zoom"],5,1,10,["get","w"]]},"layout":{"line-cap":"round"}}`, nil},
+		{"wrong_layer_type", `{"id":"l","type":"fill","paint":{"line-width":2}}`,
+			[]string{"paint.line-width: property is not supported by fill layers, only by line layers"}},
+		{"enum", `{"id":"l","type":"line","layout":{"line-cap":"rounded"}}`,
+			[]string{`layout.line-cap: expected one of ["butt", "round", "square"], "rounded" found`}},
+		{"minimum", `{"id":"l","type":"line","paint":{"line-width":-1}}`,
+			[]string{"paint.line-width: -1 is less than the minimum value 0"}},
+		{"maximum_in_stops", `{"id":"l","type":"fill","paint":{"fill-opacity":{"stops":[[0,0.5],[10,2]]}}}`,
+			[]string{"paint.fill-opacity.stops[1][1]: 2 is greater than the maximum value 1"}},
+		{"zoom_only", `{"id":"l","type":"symbol","layout":{"symbol-placement":["get","placement"]}}`,
+			[]string{"layout.symbol-placement: data expressions not supported"}},
+		{"property_function", `{"id":"l","type":"symbol","layout":{"symbol-placement":{"property":"p","type":"categorical","stops":[["a","line"]]}}}`,
+			[]string{"layout.symbol-placement: property functions are not supported"}},
+		{"constant_only", `{"id":"l","type":"fill-extrusion","layout":{"fill-extrusion-edge-radius":["step",["zoom"],0,5,1]}}`,
+			[]string{"layout.fill-extrusion-edge-radius: expressions are not supported"}},
+		{"nested_zoom", `{"id":"l","type":"line","paint":{"line-width":["*",2,["zoom"]]}}`,
+			[]string{`paint.line-width: "zoom" expression may only be used as input to a top-level "step" or "interpolate" expression`}},
+		{"type_error", `{"id":"l","type":"fill","paint":{"fill-color":["+",1,2]}}`,
+			[]string{"paint.fill-color: expected color but found number"}},
+		{"array_length", `{"id":"l","type":"symbol","layout":{"text-offset":[0,1,2]}}`,
+			[]string{"layout.text-offset: expected array<number, 2> but found array<number, 3>"}},
+		{"enum_array_item", `{"id":"l","type":"clip","layout":{"clip-layer-types":["model","fill"]}}`,
+			[]string{`layout.clip-layer-types[1]: expected one of ["model", "symbol"], "fill" found`}},
+		{"collects_all", `{"id":"l","type":"line","paint":{"line-opacity":5,"line-width":-2}}`,
+			[]string{
+				"paint.line-opacity: 5 is greater than the maximum value 1",
+				"paint.line-width: -2 is less than the minimum value 0",
+			}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var l Layer
+			if err := json.Unmarshal([]byte(tc.layer), &l); err != nil {
+				t.Fatal(err)
+			}
+			errs := l.ValidateProperties()
+			if len(errs) != len(tc.want) {
+				t.Fatalf("expected %d errors, got %d: %v", len(tc.want), len(errs), errs)
+			}
+			for i, w := range tc.want {
+				if errs[i].Error() != w {
+					t.Fatalf("error %d: expected %q, got %q", i, w, errs[i].Error())
+				}
+			}
+		})
+	}
+}
+
+func TestStyleValidateLayers(t *testing.T) {
+	raw := `{
+		"version": 8,
+		"sources": {},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-opacity": 2}},
+			{"id": "bg", "type": "fill", "paint": {"fill-color": "red"}}
+		]
+	}`
+	var s Style
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatal(err)
+	}
+	errs := s.ValidateLayers()
+	want := []string{
+		"layers[0].paint.background-opacity: 2 is greater than the maximum value 1",
+		`layers[1].id: duplicate layer id "bg", previously used at layers[0]`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Fatalf("error %d: expected %q, got %q", i, w, errs[i].Error())
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package style
+
+import (
+	"encoding/json"
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// Legacy function types.
+const (
+	FunctionExponential = "exponential"
+	FunctionInterval    = "interval"
+	FunctionCategorical = "categorical"
+	FunctionIdentity    = "identity"
+)
+
+// Padding holds top, right, bottom and left values. A single number or a
+// list of one to four numbers is expanded following CSS rules.
+type Padding [4]float64
+
+// PropertyValue is a paint or layout property value of type T, given as a
+// constant, a legacy stops function or an expression. Legacy functions are
+// converted to expressions, see ConvertLegacyFunction.
+//
+// T may be float64, string, bool, []float64, []string, Padding,
+// color.Color or Formatted. Enum properties use string; formatted and image
+// values read as string give their text and image name.
+type PropertyValue[T any] struct {
+	raw      interface{}
+	constant interface{}
+	expr     *Expression
+}
+
+// NewPropertyValue builds a PropertyValue from a decoded JSON value, as
+// found in the interface{} fields of Paint and Layout. A nil value yields a
+// nil PropertyValue.
+func NewPropertyValue[T any](raw interface{}) (*PropertyValue[T], error) {
+	return newPropertyValue[T](raw, "")
+}
+
+// newPropertyValue is NewPropertyValue for the named property, whose spec
+// guides the conversion of legacy functions. Without a known name the spec
+// is derived from T.
+func newPropertyValue[T any](raw interface{}, name string) (*PropertyValue[T], error) {
+	if raw == nil {
+		return nil, nil
+	}
+	p := &PropertyValue[T]{raw: raw}
+	if isLegacyFunction(raw) {
+		spec, ok := propertySpecNamed(name)
+		if !ok {
+			spec = specOf[T]()
+		}
+		e, err := convertLegacyFunction(raw.(map[string]interface{}), spec, name)
+		if err != nil {
+			return nil, err
+		}
+		p.expr = e
+		return p, nil
+	}
+	e, err := propertyExpression(raw)
+	if err != nil {
+		return nil, err
+	}
+	if e.IsLiteral {
+		p.constant = normalizeValue(e.Value)
+		if _, err := convertPropertyValue[T](p.constant); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	p.expr = e
+	return p, nil
+}
+
+func (p *PropertyValue[T]) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	v, err := NewPropertyValue[T](raw)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		*p = PropertyValue[T]{}
+		return nil
+	}
+	*p = *v
+	return nil
+}
+
+func (p *PropertyValue[T]) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return json.Marshal(nil)
+	}
+	if p.expr != nil && !isLegacyFunction(p.raw) {
+		return p.expr.MarshalJSON()
+	}
+	return json.Marshal(p.raw)
+}
+
+// IsConstant reports whether the value is the same at every zoom level and
+// for every feature.
+func (p *PropertyValue[T]) IsConstant() bool {
+	return p == nil || p.expr == nil
+}
+
+// IsZoomDependent reports whether the value changes with zoom.
+func (p *PropertyValue[T]) IsZoomDependent() bool {
+	switch {
+	case p == nil:
+		return false
+	case p.expr != nil:
+		return IsCameraExpression(p.expr)
+	}
+	return false
+}
+
+// IsFeatureDependent reports whether the value depends on feature data.
+func (p *PropertyValue[T]) IsFeatureDependent() bool {
+	switch {
+	case p == nil:
+		return false
+	case p.expr != nil:
+		return IsDataExpression(p.expr)
+	}
+	return false
+}
+
+// Evaluate returns the value at the given zoom level for feature, which may
+// be nil for zoom-only values.
+func (p *PropertyValue[T]) Evaluate(zoom float64, feature *Feature) (T, error) {
+	return p.EvaluateContext(EvalContext{Zoom: zoom, Feature: feature})
+}
+
+// EvaluateContext is like Evaluate with every input of ctx, such as the
+// config scope, available to expressions.
+func (p *PropertyValue[T]) EvaluateContext(ctx EvalContext) (T, error) {
+	var zero T
+	if p == nil {
+		return zero, errors.New("property value is not set")
+	}
+	var v interface{}
+	var err error
+	if p.expr != nil {
+		v, err = p.expr.Evaluate(ctx)
+	} else {
+		v = p.constant
+	}
+	if err != nil {
+		return zero, err
+	}
+	return convertPropertyValue[T](v)
+}
+
+// EvaluateOr is like Evaluate but returns def when p is nil, and def along
+// with the error when the value fails to evaluate.
+func (p *PropertyValue[T]) EvaluateOr(zoom float64, feature *Feature, def T) (T, error) {
+	if p == nil {
+		return def, nil
+	}
+	v, err := p.Evaluate(zoom, feature)
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// specOf describes the values of T, for properties without a known spec.
+func specOf[T any]() propertySpec {
+	var out T
+	switch any(&out).(type) {
+	case *float64:
+		return propertySpec{Type: specNumber}
+	case *bool:
+		return propertySpec{Type: specBoolean}
+	case *color.Color:
+		return propertySpec{Type: specColor}
+	case *Padding:
+		return propertySpec{Type: specPadding}
+	case *[]float64:
+		return propertySpec{Type: specArray, Value: specNumber}
+	case *[]string:
+		return propertySpec{Type: specArray, Value: specString}
+	case *Formatted:
+		return propertySpec{Type: specFormatted}
+	}
+	return propertySpec{Type: specString}
+}
+
+func convertPropertyValue[T any](v interface{}) (T, error) {
+	var out T
+	v = normalizeValue(v)
+	switch dst := any(&out).(type) {
+	case *float64:
+		n, ok := v.(float64)
+		if !ok {
+			return out, errors.Errorf("expected number but found %s", typeOf(v))
+		}
+		*dst = n
+	case *string:
+		switch t := v.(type) {
+		case string:
+			*dst = t
+		case Formatted:
+			*dst = t.String()
+		case ResolvedImage:
+			*dst = t.Name
+		default:
+			return out, errors.Errorf("expected string but found %s", typeOf(v))
+		}
+	case *Formatted:
+		f, ok := toFormatted(v)
+		if !ok {
+			return out, errors.Errorf("expected formatted but found %s", typeOf(v))
+		}
+		*dst = f
+	case *bool:
+		b, ok := v.(bool)
+		if !ok {
+			return out, errors.Errorf("expected boolean but found %s", typeOf(v))
+		}
+		*dst = b
+	case *[]float64:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return out, errors.Errorf("expected array<number> but found %s", typeOf(v))
+		}
+		nums := make([]float64, len(arr))
+		for i, item := range arr {
+			n, ok := item.(float64)
+			if !ok {
+				return out, errors.Errorf("expected array<number> but found %s", typeOf(v))
+			}
+			nums[i] = n
+		}
+		*dst = nums
+	case *[]string:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return out, errors.Errorf("expected array<string> but found %s", typeOf(v))
+		}
+		strs := make([]string, len(arr))
+		for i, item := range arr {
+			s, ok := item.(string)
+			if !ok {
+				return out, errors.Errorf("expected array<string> but found %s", typeOf(v))
+			}
+			strs[i] = s
+		}
+		*dst = strs
+	case *Padding:
+		pad, err := toPadding(v)
+		if err != nil {
+			return out, err
+		}
+		*dst = pad
+	case *color.Color:
+		c, ok := valueToColor(v)
+		if !ok {
+			return out, errors.Errorf("expected color but found %s", typeOf(v))
+		}
+		*dst = c
+	case *interface{}:
+		*dst = v
+	default:
+		return out, errors.Errorf("unsupported property value type %T", out)
+	}
+	return out, nil
+}
+
+func toPadding(v interface{}) (Padding, error) {
+	if n, ok := v.(float64); ok {
+		return Padding{n, n, n, n}, nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 1 || len(arr) > 4 {
+		return Padding{}, errors.Errorf("expected padding but found %s", typeOf(v))
+	}
+	nums := make([]float64, len(arr))
+	for i, item := range arr {
+		n, ok := item.(float64)
+		if !ok {
+			return Padding{}, errors.Errorf("expected padding but found %s", typeOf(v))
+		}
+		nums[i] = n
+	}
+	switch len(nums) {
+	case 1:
+		return Padding{nums[0], nums[0], nums[0], nums[0]}, nil
+	case 2:
+		return Padding{nums[0], nums[1], nums[0], nums[1]}, nil
+	case 3:
+		return Padding{nums[0], nums[1], nums[2], nums[1]}, nil
+	}
+	return Padding{nums[0], nums[1], nums[2], nums[3]}, nil
+}
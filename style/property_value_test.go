@@ -0,0 +1,152 @@
+package style
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func rawJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPropertyValueNumber(t *testing.T) {
+	feature := &Feature{Properties: map[string]interface{}{"rank": 3, "class": "minor"}}
+	tests := []struct {
+		name string
+		raw  string
+		zoom float64
+		want float64
+	}{
+		{"constant", `2`, 5, 2},
+		{"zoom_exponential", `{"stops":[[0,0],[10,10]]}`, 5, 5},
+		{"zoom_base", `{"base":2,"stops":[[0,0],[2,3]]}`, 1, 1},
+		{"zoom_below", `{"stops":[[5,1],[10,2]]}`, 0, 1},
+		{"zoom_above", `{"stops":[[5,1],[10,2]]}`, 12, 2},
+		{"interval", `{"type":"interval","stops":[[0,1],[5,2],[10,3]]}`, 7, 2},
+		{"property_exponential", `{"property":"rank","stops":[[0,0],[10,100]]}`, 0, 30},
+		{"categorical", `{"property":"class","type":"categorical","stops":[["major",4],["minor",2]]}`, 0, 2},
+		{"categorical_default", `{"property":"class","type":"categorical","stops":[["major",4]],"default":1}`, 0, 1},
+		{"identity", `{"property":"rank","type":"identity"}`, 0, 3},
+		{"composite", `{"property":"rank","stops":[[{"zoom":0,"value":0},0],[{"zoom":0,"value":10},10],[{"zoom":10,"value":0},0],[{"zoom":10,"value":10},20]]}`, 5, 4.5},
+		{"expression", `["interpolate",["linear"],["zoom"],0,1,10,["get","rank"]]`, 5, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewPropertyValue[float64](rawJSON(t, tc.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Evaluate(tc.zoom, feature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPropertyValueTypes(t *testing.T) {
+	dash, err := NewPropertyValue[[]float64](rawJSON(t, `{"stops":[[0,[2,2]],[10,[4,1]]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := dash.Evaluate(5, nil); !reflect.DeepEqual(got, []float64{3, 1.5}) {
+		t.Fatalf("unexpected dasharray %v", got)
+	}
+
+	lineCap, err := NewPropertyValue[string](rawJSON(t, `{"stops":[[0,"butt"],[10,"round"]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lineCap.Evaluate(9.9, nil); got != "butt" {
+		t.Fatalf("enum functions should default to interval, got %q", got)
+	}
+
+	pad, err := NewPropertyValue[Padding](rawJSON(t, `[1,2,3]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pad.Evaluate(0, nil); got != (Padding{1, 2, 3, 2}) {
+		t.Fatalf("unexpected padding %v", got)
+	}
+
+	if _, err := NewPropertyValue[float64]("wide"); err == nil {
+		t.Fatal("expected a type error for a string constant")
+	}
+	if !dash.IsZoomDependent() || dash.IsFeatureDependent() || dash.IsConstant() {
+		t.Fatal("unexpected dependency flags for a zoom function")
+	}
+	if data, err := json.Marshal(dash); err != nil || !jsonEqual(string(data), `{"stops":[[0,[2,2]],[10,[4,1]]]}`) {
+		t.Fatalf("function not marshalled as written: %s (%v)", data, err)
+	}
+}
+
+func TestPaintLayoutAccessors(t *testing.T) {
+	var l Layer
+	raw := `{"id":"roads","type":"line",
+		"paint":{"line-width":["interpolate",["linear"],["zoom"],5,1,10,["get","w"]],"line-dasharray":[2,1]},
+		"layout":{"line-miter-limit":{"stops":[[0,1],[10,3]]}}}`
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		t.Fatal(err)
+	}
+	feature := &Feature{Properties: map[string]interface{}{"w": 9}}
+	if w, err := l.Paint.LineWidthAt(7.5, feature); err != nil || w != 5 {
+		t.Fatalf("expected width 5, got %v (%v)", w, err)
+	}
+	if o, err := l.Paint.LineOpacityAt(7.5, feature); err != nil || o != 1 {
+		t.Fatalf("expected default opacity 1, got %v (%v)", o, err)
+	}
+	if d, err := l.Paint.LineDashArrayAt(0, nil); err != nil || !reflect.DeepEqual(d, []float64{2, 1}) {
+		t.Fatalf("unexpected dasharray %v (%v)", d, err)
+	}
+	if m, err := l.Layout.LineMiterLimitAt(5, nil); err != nil || m != 2 {
+		t.Fatalf("expected miter limit 2, got %v (%v)", m, err)
+	}
+	var empty *Layout
+	if s, err := empty.TextSizeAt(0, nil); err != nil || s != 16 {
+		t.Fatalf("expected default text size 16, got %v (%v)", s, err)
+	}
+}
+
+func TestAccessorErrorReturnsDefault(t *testing.T) {
+	var p Paint
+	if err := json.Unmarshal([]byte(`{"fill-opacity": ["number", ["get", "missing"]]}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	o, err := p.FillOpacityAt(0, &Feature{})
+	if err == nil {
+		t.Fatal("expected evaluation error")
+	}
+	if o != 1 {
+		t.Fatalf("expected default opacity 1 on error, got %v", o)
+	}
+}
+
+func TestAccessorCache(t *testing.T) {
+	var p Paint
+	if err := json.Unmarshal([]byte(`{"line-width": ["get", "w"]}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	feature := &Feature{Properties: map[string]interface{}{"w": 3}}
+	if w, err := p.LineWidthAt(0, feature); err != nil || w != 3 {
+		t.Fatalf("expected width 3, got %v (%v)", w, err)
+	}
+	key := propertyCacheKey{"line-width", reflect.TypeOf((*float64)(nil))}
+	parsed := p.cache.values[key].value
+	if _, err := p.LineWidthAt(0, feature); err != nil || p.cache.values[key].value != parsed {
+		t.Fatal("line-width was parsed again")
+	}
+	p.LineWidth = 7.0
+	if w, err := p.LineWidthAt(0, feature); err != nil || w != 7 {
+		t.Fatalf("expected width 7 after assignment, got %v (%v)", w, err)
+	}
+}
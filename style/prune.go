@@ -0,0 +1,257 @@
+package style
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/flywave/go-mapbox/sprite"
+)
+
+// imageProperties are the paint and layout properties that name sprite
+// images.
+var imageProperties = []string{
+	"icon-image", "background-pattern", "fill-pattern", "fill-extrusion-pattern", "line-pattern",
+}
+
+// PruneReport lists what Style.Prune removed and the mismatches it found
+// between the style and its sprite.
+type PruneReport struct {
+	RemovedLayers  []string `json:"removedLayers,omitempty"`
+	RemovedSources []string `json:"removedSources,omitempty"`
+	// MissingImages are referenced by the style but absent from the sprite.
+	MissingImages []string `json:"missingImages,omitempty"`
+	// UnusedImages are in the sprite but never referenced. Layers listed in
+	// DynamicImageLayers compute image names from data, so images they use
+	// may appear here.
+	UnusedImages       []string `json:"unusedImages,omitempty"`
+	DynamicImageLayers []string `json:"dynamicImageLayers,omitempty"`
+}
+
+// Prune removes layers that can never render and sources that no layer
+// uses, and compares the image names the remaining layers reference with
+// the sprite index built by sprite.GenerateSprite. A nil index skips the
+// sprite comparison.
+func (s *Style) Prune(index map[string]*sprite.TextureSprite) (*PruneReport, error) {
+	report := &PruneReport{}
+
+	var kept []*Layer
+	for _, l := range s.Layers {
+		if l != nil && l.neverRenders() {
+			report.RemovedLayers = append(report.RemovedLayers, l.ID)
+			continue
+		}
+		kept = append(kept, l)
+	}
+	s.Layers = kept
+
+	used := map[string]bool{}
+	for _, l := range s.Layers {
+		if l != nil && l.Source != nil {
+			used[*l.Source] = true
+		}
+	}
+	if s.Terrain != nil {
+		used[s.Terrain.Source] = true
+	}
+	for _, iconset := range s.Iconsets {
+		used[iconset.Source] = true
+	}
+	for id := range s.Sources {
+		if !used[id] {
+			report.RemovedSources = append(report.RemovedSources, id)
+			delete(s.Sources, id)
+		}
+	}
+	sort.Strings(report.RemovedSources)
+
+	if index == nil {
+		return report, nil
+	}
+	referenced := map[string]bool{}
+	for _, l := range s.Layers {
+		if l == nil {
+			continue
+		}
+		names, dynamic, err := l.imageReferences()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			referenced[name] = true
+		}
+		if dynamic {
+			report.DynamicImageLayers = append(report.DynamicImageLayers, l.ID)
+		}
+	}
+	for name := range referenced {
+		if _, ok := index[name]; !ok {
+			report.MissingImages = append(report.MissingImages, name)
+		}
+	}
+	for name := range index {
+		if !referenced[name] {
+			report.UnusedImages = append(report.UnusedImages, name)
+		}
+	}
+	sort.Strings(report.MissingImages)
+	sort.Strings(report.UnusedImages)
+	return report, nil
+}
+
+// neverRenders reports whether the layer is hidden, has an empty zoom
+// range or a filter that is false for every feature.
+func (l *Layer) neverRenders() bool {
+	if l.Layout != nil && l.Layout.Visibility == "none" {
+		return true
+	}
+	if l.MinZoom != nil && l.MaxZoom != nil && *l.MinZoom >= *l.MaxZoom {
+		return true
+	}
+	if l.Filter == nil || l.Filter.Expr == nil {
+		return false
+	}
+	filter, err := ConvertLegacyFilter(l.Filter.Expr)
+	if err != nil || !isStaticExpression(filter) {
+		return false
+	}
+	v, err := filter.Evaluate(EvalContext{})
+	return err == nil && v != true
+}
+
+// isStaticExpression reports whether e evaluates to the same value for
+// every feature, camera and config.
+func isStaticExpression(e *Expression) bool {
+	if e == nil || e.IsLiteral {
+		return true
+	}
+	switch e.Operator {
+	case ExpGet, ExpHas, ExpID, ExpGeometryType, ExpProperties, ExpFeatureState,
+		ExpAccumulated, ExpLineProgress, ExpHeatmapDensity, ExpWithin, ExpDist,
+		ExpZoom, ExpPitch, ExpDistFromCenter, ExpConfig, ExpMeasureLight,
+		ExpWorldview, ExpResolvedLocale, ExpRand, ExpVar:
+		return false
+	}
+	for _, arg := range e.Args {
+		if !isStaticExpression(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// imageReferences returns the image names the layer can use. dynamic is set
+// when a name is computed from data or contains a {token}.
+func (l *Layer) imageReferences() (names []string, dynamic bool, err error) {
+	paint, err := propertyMap(l.Paint)
+	if err != nil {
+		return nil, false, err
+	}
+	layout, err := propertyMap(l.Layout)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, prop := range imageProperties {
+		raw, ok := paint[prop]
+		if !ok {
+			raw, ok = layout[prop]
+		}
+		if !ok {
+			continue
+		}
+		n, d := imageNames(raw)
+		names = append(names, n...)
+		dynamic = dynamic || d
+	}
+	return names, dynamic, nil
+}
+
+func imageNames(raw interface{}) ([]string, bool) {
+	if isLegacyFunction(raw) {
+		fn := raw.(map[string]interface{})
+		if fn["type"] == FunctionIdentity {
+			return nil, true
+		}
+		var names []string
+		dynamic := false
+		stops, _ := fn["stops"].([]interface{})
+		for _, stop := range stops {
+			if pair, ok := stop.([]interface{}); ok && len(pair) == 2 {
+				n, d := imageNames(pair[1])
+				names = append(names, n...)
+				dynamic = dynamic || d
+			}
+		}
+		return names, dynamic
+	}
+	e, err := propertyExpression(raw)
+	if err != nil {
+		return nil, true
+	}
+	var names []string
+	dynamic := expressionImageNames(e, &names)
+	return names, dynamic
+}
+
+// expressionImageNames collects the string outputs of e and reports whether
+// any output is computed.
+func expressionImageNames(e *Expression, names *[]string) bool {
+	if e == nil {
+		return false
+	}
+	if e.IsLiteral {
+		s, ok := e.Value.(string)
+		if !ok {
+			return false
+		}
+		if strings.Contains(s, "{") {
+			return true
+		}
+		if s != "" {
+			*names = append(*names, s)
+		}
+		return false
+	}
+
+	var outputs []*Expression
+	switch e.Operator {
+	case ExpLiteral:
+		if len(e.Args) == 1 {
+			return expressionImageNames(e.Args[0], names)
+		}
+	case ExpImage, ExpString, ExpToString:
+		outputs = e.Args[:min(1, len(e.Args))]
+	case ExpCoalesce:
+		outputs = e.Args
+	case ExpCase:
+		for i := 1; i < len(e.Args); i += 2 {
+			outputs = append(outputs, e.Args[i])
+		}
+		if len(e.Args) > 0 {
+			outputs = append(outputs, e.Args[len(e.Args)-1])
+		}
+	case ExpMatch:
+		for i := 2; i < len(e.Args); i += 2 {
+			outputs = append(outputs, e.Args[i])
+		}
+		if len(e.Args) > 1 {
+			outputs = append(outputs, e.Args[len(e.Args)-1])
+		}
+	case ExpStep:
+		for i := 1; i < len(e.Args); i += 2 {
+			outputs = append(outputs, e.Args[i])
+		}
+	case ExpLet:
+		if len(e.Args) > 0 {
+			outputs = e.Args[len(e.Args)-1:]
+		}
+	default:
+		return true
+	}
+	dynamic := false
+	for _, out := range outputs {
+		if expressionImageNames(out, names) {
+			dynamic = true
+		}
+	}
+	return dynamic
+}
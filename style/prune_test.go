@@ -0,0 +1,79 @@
+package style
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flywave/go-mapbox/sprite"
+)
+
+func TestPrune(t *testing.T) {
+	s := parseStyle(t, `{
+		"version": 8,
+		"sources": {
+			"streets": {"type": "vector", "url": "mapbox://mapbox.streets"},
+			"unused": {"type": "vector", "url": "mapbox://mapbox.unused"},
+			"hidden": {"type": "geojson", "data": {"type": "FeatureCollection", "features": []}}
+		},
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-pattern": "paper"}},
+			{"id": "hidden", "type": "fill", "source": "hidden", "layout": {"visibility": "none"}},
+			{"id": "empty_range", "type": "line", "source": "streets", "minzoom": 10, "maxzoom": 10},
+			{"id": "false_filter", "type": "line", "source": "streets", "filter": ["all", true, ["<", ["+", 1, 1], 1]]},
+			{"id": "legacy_false", "type": "line", "source": "streets", "filter": ["any"]},
+			{"id": "data_filter", "type": "line", "source": "streets", "filter": ["==", ["get", "class"], "x"]},
+			{"id": "pois", "type": "symbol", "source": "streets",
+				"layout": {"icon-image": ["match", ["get", "kind"], "cafe", "cafe-15", ["image", "marker-15"]]}},
+			{"id": "shields", "type": "symbol", "source": "streets", "layout": {"icon-image": "{shield}-{len}"}}
+		]
+	}`)
+	index := map[string]*sprite.TextureSprite{"paper": {}, "cafe-15": {}, "bus-15": {}}
+
+	report, err := s.Prune(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PruneReport{
+		RemovedLayers:      []string{"hidden", "empty_range", "false_filter", "legacy_false"},
+		RemovedSources:     []string{"hidden", "unused"},
+		MissingImages:      []string{"marker-15"},
+		UnusedImages:       []string{"bus-15"},
+		DynamicImageLayers: []string{"shields"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("unexpected report:\n  got:  %+v\n  want: %+v", report, want)
+	}
+	if len(s.Layers) != 4 || len(s.Sources) != 1 {
+		t.Fatalf("expected 4 layers and 1 source, got %d and %d", len(s.Layers), len(s.Sources))
+	}
+}
+
+func TestPruneLegacyStops(t *testing.T) {
+	s := parseStyle(t, `{"version": 8, "sources": {"s": {"type": "vector"}}, "layers": [
+		{"id": "a", "type": "symbol", "source": "s", "layout": {"icon-image": {"stops": [[0, "dot"], [10, "pin"]]}}}
+	]}`)
+	report, err := s.Prune(map[string]*sprite.TextureSprite{"dot": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.MissingImages, []string{"pin"}) || len(report.UnusedImages) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestPruneNilLayers(t *testing.T) {
+	s := parseStyle(t, `{"version": 8, "sources": {"s": {"type": "vector"}}, "layers": [
+		null,
+		{"id": "a", "type": "symbol", "source": "s", "layout": {"icon-image": "dot"}}
+	]}`)
+	report, err := s.Prune(map[string]*sprite.TextureSprite{"dot": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RemovedLayers) != 0 || len(report.RemovedSources) != 0 || len(report.UnusedImages) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(s.Layers) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(s.Layers))
+	}
+}
@@ -0,0 +1,185 @@
+package style
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseStrict is like Parse but rejects documents containing keys the style
+// model does not know, such as a misspelled "fill-colour" or an unsupported
+// "flywave:" extension. The error is a ValidationErrors listing every unknown
+// key with its path.
+func ParseStrict(reader io.Reader) (*MapboxGLStyle, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	unknown, err := UnknownProperties(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := unknown.Err(); err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(data))
+}
+
+// UnknownProperties reports every object key in the style document data that
+// does not map to a field of the style model. Keys are matched exactly, so a
+// key differing only in case is reported too, although encoding/json would
+// accept it. Values decoded by custom unmarshalers (colors, expressions,
+// filters) and free-form maps like metadata are not inspected.
+func UnknownProperties(data []byte) (ValidationErrors, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "decoding style")
+	}
+	var errs ValidationErrors
+	checkKnownKeys(doc, reflect.TypeOf(Style{}), "", &errs)
+	return errs, nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// customDecoded reports whether values of t are decoded by their own
+// UnmarshalJSON rather than by field.
+func customDecoded(t reflect.Type) bool {
+	return t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+func checkKnownKeys(v interface{}, t reflect.Type, path string, errs *ValidationErrors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if customDecoded(t) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		byName := make(map[string]reflect.Type, len(fields))
+		for _, f := range fields {
+			byName[f.Name] = f.Type
+		}
+		for _, k := range sortedKeys(obj) {
+			ft, ok := byName[k]
+			if !ok {
+				if s := closestField(k, fields); s != "" {
+					errs.add(joinPath(path, k), "unknown property, did you mean %q?", s)
+				} else {
+					errs.add(joinPath(path, k), "unknown property")
+				}
+				continue
+			}
+			checkKnownKeys(obj[k], ft, joinPath(path, k), errs)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, k := range sortedKeys(obj) {
+			checkKnownKeys(obj[k], t.Elem(), joinPath(path, k), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range arr {
+			checkKnownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonField is a struct field as seen by encoding/json.
+type jsonField struct {
+	Name string
+	Type reflect.Type
+}
+
+// jsonFields lists the JSON keys of a struct type in declaration order,
+// following the encoding/json rules for tags and embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{Name: name, Type: ft})
+	}
+	return fields
+}
+
+// closestField returns the field name nearest to key when it is close enough
+// to be a likely typo, or "" otherwise.
+func closestField(key string, fields []jsonField) string {
+	best, bestDist := "", min(3, len(key)/3+1)
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, key) {
+			return f.Name
+		}
+		if d := editDistance(key, f.Name); d < bestDist {
+			best, bestDist = f.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
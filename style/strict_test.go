@@ -0,0 +1,120 @@
+package style
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseStrict(t *testing.T) {
+	raw := `{
+		"version": 8,
+		"sources": {"osm": {"type": "vector", "urll": "x"}},
+		"flywave:clearColour": "#000",
+		"flywave:enableShadows": true,
+		"layers": [
+			{"id": "bg", "type": "background", "paint": {"background-color": "#fff"}},
+			{"id": "water", "type": "fill", "source": "osm",
+			 "flywave:technique": "fill", "flywave:bogus": 1,
+			 "paint": {"fill-colour": "#00f", "fill-color": ["get", "c"]},
+			 "metadata": {"anything": {"goes": true}}}
+		]
+	}`
+	_, err := ParseStrict(strings.NewReader(raw))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	want := []string{
+		`flywave:clearColour: unknown property, did you mean "flywave:clearColor"?`,
+		`layers[1].flywave:bogus: unknown property`,
+		`layers[1].paint.fill-colour: unknown property, did you mean "fill-color"?`,
+		`sources.osm.urll: unknown property, did you mean "url"?`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v", errs)
+	}
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+
+	s, err := ParseStrict(strings.NewReader(`{"version": 8, "sources": {}, "layers": [
+		{"id": "x", "type": "line", "layout": {"line-cap": "round"}, "flywave:renderOrder": 2}]}`))
+	if err != nil || s.style.Layers[0].FlywaveRenderOrder == nil {
+		t.Fatalf("strict parse of valid style: %v", err)
+	}
+}
+
+func TestUnknownPropertiesCase(t *testing.T) {
+	errs, err := UnknownProperties([]byte(`{"Version": 8, "layers": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Message != `unknown property, did you mean "version"?` {
+		t.Errorf("errors = %v", errs)
+	}
+	if _, err := UnknownProperties([]byte(`{`)); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["$schema"] != JSONSchemaDialect || doc["additionalProperties"] != false {
+		t.Fatalf("root = %v", doc["$schema"])
+	}
+	props := doc["properties"].(map[string]interface{})
+	if _, ok := props["flywave:postEffects"]; !ok {
+		t.Error("missing flywave:postEffects")
+	}
+	defs := doc["definitions"].(map[string]interface{})
+	for _, name := range []string{"Layer", "Paint", "Layout", "Source", "FlywaveBloom", "Import"} {
+		if _, ok := defs[name]; !ok {
+			t.Errorf("missing definition %s", name)
+		}
+	}
+	layer := defs["Layer"].(map[string]interface{})
+	if req, _ := json.Marshal(layer["required"]); string(req) != `["id","type"]` {
+		t.Errorf("layer required = %s", req)
+	}
+	required := map[string]string{
+		"Import":             `["id"]`,
+		"FlywaveOutline":     `null`,
+		"FlywaveVignette":    `null`,
+		"FlywaveSepia":       `null`,
+		"FlywaveFontCatalog": `null`,
+		"FlywavePriority":    `null`,
+		"Source":             `["type"]`,
+	}
+	for name, want := range required {
+		if req, _ := json.Marshal(defs[name].(map[string]interface{})["required"]); string(req) != want {
+			t.Errorf("%s required = %s, want %s", name, req, want)
+		}
+	}
+	layerProps := layer["properties"].(map[string]interface{})
+	if tech := layerProps["flywave:technique"].(map[string]interface{}); tech["description"] != "Flywave extension" {
+		t.Errorf("flywave:technique = %v", tech)
+	}
+	paint := defs["Paint"].(map[string]interface{})["properties"].(map[string]interface{})
+	opacity, _ := json.Marshal(paint["fill-opacity"])
+	if !jsonEqual(string(opacity), `{"anyOf": [
+		{"type": "number", "minimum": 0, "maximum": 1},
+		{"type": "array", "description": "Expression"},
+		{"type": "object", "description": "Legacy function"}]}`) {
+		t.Errorf("fill-opacity = %s", opacity)
+	}
+	layout := defs["Layout"].(map[string]interface{})["properties"].(map[string]interface{})
+	visibility, _ := json.Marshal(layout["visibility"])
+	if !jsonEqual(string(visibility), `{"type": "string", "enum": ["visible", "none"]}`) {
+		t.Errorf("visibility = %s", visibility)
+	}
+}
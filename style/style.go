@@ -79,6 +79,9 @@ type Style struct {
 	Transition           *Transition            `json:"transition,omitempty"`
 	Version              int                    `json:"version"`
 	Zoom                 float64                `json:"zoom,omitempty"`
+
+	// config is the scope bound to the layers, see Style.BindConfig.
+	config *configBinding
 }
 
 type Featureset struct {
@@ -124,12 +127,23 @@ type Snow struct {
 }
 
 func Parse(reader io.Reader) (*MapboxGLStyle, error) {
-	s := new(Style)
-	dec := json.NewDecoder(reader)
-	err := dec.Decode(s)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	// References may stand for values of typed fields, so they are
+	// expanded before the document is decoded into a Style.
+	data, err = expandDocument(data)
 	if err != nil {
 		return nil, err
 	}
+	s := new(Style)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if _, err := s.Config(); err != nil {
+		return nil, err
+	}
 
 	bgColor, err := s.calculateBackgroundColor()
 	if err != nil {
@@ -2014,8 +2014,8 @@ func TestStyleFlywaveExtensionsRoundTrip(t *testing.T) {
 	if s.FlywaveDefinitions == nil {
 		t.Fatal("expected Definitions")
 	}
-	if (*s.FlywaveDefinitions)["roadColor"] != "#ff0000" {
-		t.Fatalf("definitions.roadColor = %v", (*s.FlywaveDefinitions)["roadColor"])
+	if (*s.FlywaveDefinitions)["roadColor"].Value != "#ff0000" {
+		t.Fatalf("definitions.roadColor = %v", (*s.FlywaveDefinitions)["roadColor"].Value)
 	}
 	if s.FlywavePostEffects == nil || s.FlywavePostEffects.Bloom == nil || !s.FlywavePostEffects.Bloom.Enabled {
 		t.Fatal("expected bloom post effect")
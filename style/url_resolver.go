@@ -0,0 +1,302 @@
+package style
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// URLKind is the kind of resource a style URL points to.
+type URLKind string
+
+const (
+	URLKindStyle  URLKind = "style"
+	URLKindSource URLKind = "source"
+	URLKindSprite URLKind = "sprite"
+	URLKindGlyphs URLKind = "glyphs"
+	URLKindTiles  URLKind = "tiles"
+)
+
+// DefaultBaseURL is the Mapbox API the default templates point to.
+const DefaultBaseURL = "https://api.mapbox.com"
+
+const mapboxScheme = "mapbox://"
+
+// DefaultURLTemplates map mapbox:// URLs to the Mapbox API. Placeholders
+// are {base} plus, per kind:
+//
+//	style:  {owner}, {id}        mapbox://styles/{owner}/{id}
+//	source: {tileset}            mapbox://{tileset}
+//	sprite: {owner}, {id}        mapbox://sprites/{owner}/{id}
+//	glyphs: {owner}              mapbox://fonts/{owner}/{fontstack}/{range}.pbf
+//	tiles:  {path}               mapbox://tiles/{path}
+//
+// The glyphs {fontstack} and {range} tokens are kept for the renderer.
+var DefaultURLTemplates = map[URLKind]string{
+	URLKindStyle:  "{base}/styles/v1/{owner}/{id}",
+	URLKindSource: "{base}/v4/{tileset}.json?secure",
+	URLKindSprite: "{base}/styles/v1/{owner}/{id}/sprite",
+	URLKindGlyphs: "{base}/fonts/v1/{owner}/{fontstack}/{range}.pbf",
+	URLKindTiles:  "{base}/v4/{path}",
+}
+
+var urlPlaceholders = map[URLKind][]string{
+	URLKindStyle:  {"owner", "id"},
+	URLKindSource: {"tileset"},
+	URLKindSprite: {"owner", "id"},
+	URLKindGlyphs: {"owner"},
+	URLKindTiles:  {"path"},
+}
+
+// URLResolver turns mapbox:// URLs into HTTP URLs and back. Templates
+// replaces DefaultURLTemplates per kind, which lets styles authored against
+// Mapbox be served from a self-hosted tile server.
+type URLResolver struct {
+	BaseURL     string
+	AccessToken string
+	Templates   map[URLKind]string
+}
+
+// NewURLResolver returns a resolver for the Mapbox API at baseURL, or at
+// DefaultBaseURL if baseURL is empty.
+func NewURLResolver(baseURL, accessToken string) *URLResolver {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &URLResolver{BaseURL: strings.TrimSuffix(baseURL, "/"), AccessToken: accessToken}
+}
+
+func (r *URLResolver) template(kind URLKind) (string, error) {
+	if t, ok := r.Templates[kind]; ok {
+		return t, nil
+	}
+	if t, ok := DefaultURLTemplates[kind]; ok {
+		return t, nil
+	}
+	return "", errors.Errorf("unknown url kind %q", kind)
+}
+
+// Resolve returns the HTTP URL of a mapbox:// URL. Other URLs are returned
+// unchanged. The access token, if any, is added as a query parameter.
+func (r *URLResolver) Resolve(kind URLKind, u string) (string, error) {
+	if !strings.HasPrefix(u, mapboxScheme) {
+		return u, nil
+	}
+	params, err := parseMapboxURL(kind, u)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := r.template(kind)
+	if err != nil {
+		return "", err
+	}
+	out := strings.ReplaceAll(tmpl, "{base}", strings.TrimSuffix(r.BaseURL, "/"))
+	for _, name := range urlPlaceholders[kind] {
+		out = strings.ReplaceAll(out, "{"+name+"}", params[name])
+	}
+	if params["draft"] != "" {
+		out = insertPath(out, "/draft")
+	}
+	return r.withToken(out), nil
+}
+
+// Reverse returns the mapbox:// URL an HTTP URL produced by Resolve stands
+// for. ok is false if the URL does not match the kind's template.
+// Placeholders in the query of a template are not matched.
+func (r *URLResolver) Reverse(kind URLKind, u string) (string, bool) {
+	tmpl, err := r.template(kind)
+	if err != nil {
+		return "", false
+	}
+	re, names := templatePattern(strings.ReplaceAll(stripQuery(tmpl), "{base}", strings.TrimSuffix(r.BaseURL, "/")), urlPlaceholders[kind])
+	path := stripQuery(u)
+	draft := false
+	m := re.FindStringSubmatch(path)
+	if m == nil && kind == URLKindStyle {
+		if trimmed, ok := strings.CutSuffix(path, "/draft"); ok {
+			m, draft = re.FindStringSubmatch(trimmed), true
+		}
+	}
+	if m == nil {
+		return "", false
+	}
+	params := map[string]string{}
+	for i, name := range names {
+		params[name] = m[i+1]
+	}
+	switch kind {
+	case URLKindStyle:
+		out := mapboxScheme + "styles/" + params["owner"] + "/" + params["id"]
+		if draft {
+			out += "/draft"
+		}
+		return out, true
+	case URLKindSource:
+		return mapboxScheme + params["tileset"], true
+	case URLKindSprite:
+		return mapboxScheme + "sprites/" + params["owner"] + "/" + params["id"], true
+	case URLKindGlyphs:
+		return mapboxScheme + "fonts/" + params["owner"] + "/{fontstack}/{range}.pbf", true
+	case URLKindTiles:
+		return mapboxScheme + "tiles/" + params["path"], true
+	}
+	return "", false
+}
+
+// ResolveSprite returns the URL of a sprite file, such as "@2x.png" or
+// ".json", for a mapbox:// or HTTP sprite URL.
+func (r *URLResolver) ResolveSprite(u, suffix string) (string, error) {
+	if !strings.HasPrefix(u, mapboxScheme) {
+		return insertPath(u, suffix), nil
+	}
+	resolved, err := r.Resolve(URLKindSprite, u)
+	if err != nil {
+		return "", err
+	}
+	return insertPath(resolved, suffix), nil
+}
+
+// ResolveStyle rewrites the mapbox:// URLs of the style's sprite, glyphs,
+// sources and imports in place. The sprite keeps its access token:
+// renderers insert the file suffix before the query, as ResolveSprite does.
+func (r *URLResolver) ResolveStyle(s *Style) error {
+	return r.rewriteStyle(s, r.Resolve)
+}
+
+// ReverseStyle rewrites the HTTP URLs of the style that match the resolver
+// templates back to mapbox:// URLs.
+func (r *URLResolver) ReverseStyle(s *Style) error {
+	return r.rewriteStyle(s, func(kind URLKind, u string) (string, error) {
+		if out, ok := r.Reverse(kind, u); ok {
+			return out, nil
+		}
+		return u, nil
+	})
+}
+
+func (r *URLResolver) rewriteStyle(s *Style, rewrite func(URLKind, string) (string, error)) error {
+	var err error
+	apply := func(kind URLKind, u *string) {
+		if err != nil || *u == "" {
+			return
+		}
+		var out string
+		if out, err = rewrite(kind, *u); err == nil {
+			*u = out
+		}
+	}
+	apply(URLKindSprite, &s.Sprite)
+	apply(URLKindGlyphs, &s.Glyphs)
+	for _, src := range s.Sources {
+		if src == nil {
+			continue
+		}
+		apply(URLKindSource, &src.URL)
+		for i := range src.Tiles {
+			apply(URLKindTiles, &src.Tiles[i])
+		}
+	}
+	for i := range s.Imports {
+		apply(URLKindStyle, &s.Imports[i].URL)
+	}
+	return err
+}
+
+func parseMapboxURL(kind URLKind, u string) (map[string]string, error) {
+	rest := stripQuery(strings.TrimPrefix(u, mapboxScheme))
+	parts := strings.Split(rest, "/")
+	invalid := errors.Errorf("invalid %s url %q", kind, u)
+	switch kind {
+	case URLKindStyle, URLKindSprite:
+		host := "styles"
+		if kind == URLKindSprite {
+			host = "sprites"
+		}
+		if len(parts) < 3 || parts[0] != host || parts[1] == "" || parts[2] == "" {
+			return nil, invalid
+		}
+		params := map[string]string{"owner": parts[1], "id": parts[2]}
+		if kind == URLKindStyle && len(parts) > 3 && parts[3] == "draft" {
+			params["draft"] = "true"
+		}
+		return params, nil
+	case URLKindSource:
+		if rest == "" || strings.Contains(rest, "/") {
+			return nil, invalid
+		}
+		return map[string]string{"tileset": rest}, nil
+	case URLKindGlyphs:
+		if len(parts) < 2 || parts[0] != "fonts" || parts[1] == "" {
+			return nil, invalid
+		}
+		return map[string]string{"owner": parts[1]}, nil
+	case URLKindTiles:
+		if len(parts) < 2 || parts[0] != "tiles" {
+			return nil, invalid
+		}
+		return map[string]string{"path": strings.Join(parts[1:], "/")}, nil
+	}
+	return nil, errors.Errorf("unknown url kind %q", kind)
+}
+
+// templatePattern compiles a URL template into a regular expression that
+// captures the named placeholders, in the order they appear.
+func templatePattern(tmpl string, placeholders []string) (*regexp.Regexp, []string) {
+	known := map[string]bool{}
+	for _, p := range placeholders {
+		known[p] = true
+	}
+	var names []string
+	var b strings.Builder
+	b.WriteString("^")
+	for len(tmpl) > 0 {
+		start := strings.Index(tmpl, "{")
+		if start < 0 {
+			b.WriteString(regexp.QuoteMeta(tmpl))
+			break
+		}
+		end := strings.Index(tmpl[start:], "}")
+		if end < 0 {
+			b.WriteString(regexp.QuoteMeta(tmpl))
+			break
+		}
+		name := tmpl[start+1 : start+end]
+		b.WriteString(regexp.QuoteMeta(tmpl[:start]))
+		if known[name] {
+			b.WriteString("(.+?)")
+			names = append(names, name)
+		} else {
+			b.WriteString(regexp.QuoteMeta(tmpl[start : start+end+1]))
+		}
+		tmpl = tmpl[start+end+1:]
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), names
+}
+
+func (r *URLResolver) withToken(u string) string {
+	if r.AccessToken == "" || strings.Contains(u, "access_token=") {
+		return u
+	}
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + "access_token=" + r.AccessToken
+}
+
+// insertPath appends s to the path of u, before any query.
+func insertPath(u, s string) string {
+	if i := strings.Index(u, "?"); i >= 0 {
+		return u[:i] + s + u[i:]
+	}
+	return u + s
+}
+
+func stripQuery(u string) string {
+	if i := strings.Index(u, "?"); i >= 0 {
+		return u[:i]
+	}
+	return u
+}
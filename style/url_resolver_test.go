@@ -0,0 +1,88 @@
+package style
+
+import "testing"
+
+func TestURLResolver(t *testing.T) {
+	r := NewURLResolver("", "pk.test")
+	tests := []struct {
+		kind URLKind
+		in   string
+		want string
+	}{
+		{URLKindStyle, "mapbox://styles/mapbox/streets-v12", "https://api.mapbox.com/styles/v1/mapbox/streets-v12?access_token=pk.test"},
+		{URLKindStyle, "mapbox://styles/me/abc/draft", "https://api.mapbox.com/styles/v1/me/abc/draft?access_token=pk.test"},
+		{URLKindSource, "mapbox://mapbox.streets-v8,mapbox.terrain-v2", "https://api.mapbox.com/v4/mapbox.streets-v8,mapbox.terrain-v2.json?secure&access_token=pk.test"},
+		{URLKindSprite, "mapbox://sprites/mapbox/bright-v9", "https://api.mapbox.com/styles/v1/mapbox/bright-v9/sprite?access_token=pk.test"},
+		{URLKindGlyphs, "mapbox://fonts/mapbox/{fontstack}/{range}.pbf", "https://api.mapbox.com/fonts/v1/mapbox/{fontstack}/{range}.pbf?access_token=pk.test"},
+		{URLKindTiles, "mapbox://tiles/mapbox.satellite/{z}/{x}/{y}.png", "https://api.mapbox.com/v4/mapbox.satellite/{z}/{x}/{y}.png?access_token=pk.test"},
+		{URLKindSource, "https://example.com/tiles.json", "https://example.com/tiles.json"},
+	}
+	for _, tc := range tests {
+		got, err := r.Resolve(tc.kind, tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.in, tc.want, got)
+		}
+		if tc.in == tc.want {
+			continue
+		}
+		if back, ok := r.Reverse(tc.kind, got); !ok || back != tc.in {
+			t.Errorf("reverse of %s: expected %s, got %s (%v)", got, tc.in, back, ok)
+		}
+	}
+
+	if got, _ := r.ResolveSprite("mapbox://sprites/mapbox/bright-v9", "@2x.json"); got != "https://api.mapbox.com/styles/v1/mapbox/bright-v9/sprite@2x.json?access_token=pk.test" {
+		t.Fatalf("unexpected sprite file url %s", got)
+	}
+	s := parseStyle(t, `{"version": 8, "sprite": "mapbox://sprites/mapbox/bright-v9", "sources": {}, "layers": []}`)
+	if err := r.ResolveStyle(s); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.ResolveSprite(s.Sprite, ".png"); got != "https://api.mapbox.com/styles/v1/mapbox/bright-v9/sprite.png?access_token=pk.test" {
+		t.Fatalf("unexpected resolved style sprite file url %s", got)
+	}
+	for _, bad := range []string{"mapbox://styles/mapbox", "mapbox://sprites//x"} {
+		if _, err := r.Resolve(URLKindStyle, bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestURLResolverSelfHosted(t *testing.T) {
+	r := &URLResolver{
+		BaseURL: "https://tiles.example.com",
+		Templates: map[URLKind]string{
+			URLKindSource: "{base}/data/{tileset}/tile.json",
+			URLKindSprite: "{base}/sprites/{owner}/{id}",
+			URLKindGlyphs: "{base}/fonts/{fontstack}/{range}.pbf?owner={owner}",
+		},
+	}
+	s := parseStyle(t, `{
+		"version": 8,
+		"sprite": "mapbox://sprites/mapbox/streets-v12",
+		"glyphs": "mapbox://fonts/mapbox/{fontstack}/{range}.pbf",
+		"sources": {"composite": {"type": "vector", "url": "mapbox://mapbox.streets-v8"}},
+		"imports": [{"id": "basemap", "url": "mapbox://styles/mapbox/standard"}],
+		"layers": []
+	}`)
+	if err := r.ResolveStyle(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Sprite != "https://tiles.example.com/sprites/mapbox/streets-v12" ||
+		s.Glyphs != "https://tiles.example.com/fonts/{fontstack}/{range}.pbf?owner=mapbox" ||
+		s.Sources["composite"].URL != "https://tiles.example.com/data/mapbox.streets-v8/tile.json" ||
+		s.Imports[0].URL != "https://tiles.example.com/styles/v1/mapbox/standard" {
+		t.Fatalf("unexpected urls %q %q %q %q", s.Sprite, s.Glyphs, s.Sources["composite"].URL, s.Imports[0].URL)
+	}
+
+	if err := r.ReverseStyle(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Sprite != "mapbox://sprites/mapbox/streets-v12" ||
+		s.Sources["composite"].URL != "mapbox://mapbox.streets-v8" ||
+		s.Imports[0].URL != "mapbox://styles/mapbox/standard" {
+		t.Fatalf("unexpected reversed urls %q %q %q", s.Sprite, s.Sources["composite"].URL, s.Imports[0].URL)
+	}
+}
@@ -0,0 +1,44 @@
+package style
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is a single problem found while validating a style. Path
+// locates the offending value, e.g. "layers[3].paint.fill-color".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors collects every problem found by a validation pass.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns nil when no errors were collected, so a ValidationErrors can be
+// returned as an error without producing a non-nil empty value.
+func (errs ValidationErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (errs *ValidationErrors) add(path, format string, args ...interface{}) {
+	*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,93 @@
+package symbol
+
+import "math"
+
+const collisionCellSize = 64
+
+// CollisionIndex records the boxes of placed symbols in a grid so later
+// symbols can be tested against them.
+type CollisionIndex struct {
+	boxes []Box
+	grid  map[[2]int][]int
+}
+
+// NewCollisionIndex returns an empty index.
+func NewCollisionIndex() *CollisionIndex {
+	return &CollisionIndex{grid: map[[2]int][]int{}}
+}
+
+func (c *CollisionIndex) cells(b Box, fn func(cell [2]int) bool) {
+	x0, x1 := int(math.Floor(b.MinX/collisionCellSize)), int(math.Floor(b.MaxX/collisionCellSize))
+	y0, y1 := int(math.Floor(b.MinY/collisionCellSize)), int(math.Floor(b.MaxY/collisionCellSize))
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			if !fn([2]int{x, y}) {
+				return
+			}
+		}
+	}
+}
+
+// Collides reports whether b overlaps a box in the index.
+func (c *CollisionIndex) Collides(b Box) bool {
+	hit := false
+	c.cells(b, func(cell [2]int) bool {
+		for _, i := range c.grid[cell] {
+			if c.boxes[i].Intersects(b) {
+				hit = true
+				return false
+			}
+		}
+		return true
+	})
+	return hit
+}
+
+// Insert adds b to the index.
+func (c *CollisionIndex) Insert(b Box) {
+	i := len(c.boxes)
+	c.boxes = append(c.boxes, b)
+	c.cells(b, func(cell [2]int) bool {
+		c.grid[cell] = append(c.grid[cell], i)
+		return true
+	})
+}
+
+// fits reports whether none of boxes collide, or whether overlap is
+// allowed.
+func (c *CollisionIndex) fits(boxes []Box, allowOverlap bool) bool {
+	if allowOverlap {
+		return true
+	}
+	for _, b := range boxes {
+		if c.Collides(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// Place returns the symbols that can be placed, in order, and adds their
+// boxes to the index. A symbol is placed only if both its text and its icon
+// fit, following text-allow-overlap, icon-allow-overlap and the
+// *-ignore-placement properties.
+func (c *CollisionIndex) Place(symbols []*Symbol) []*Symbol {
+	var placed []*Symbol
+	for _, s := range symbols {
+		if !c.fits(s.TextBoxes, s.TextAllowOverlap) || !c.fits(s.IconBoxes, s.IconAllowOverlap) {
+			continue
+		}
+		if !s.TextIgnorePlacement {
+			for _, b := range s.TextBoxes {
+				c.Insert(b)
+			}
+		}
+		if !s.IconIgnorePlacement {
+			for _, b := range s.IconBoxes {
+				c.Insert(b)
+			}
+		}
+		placed = append(placed, s)
+	}
+	return placed
+}
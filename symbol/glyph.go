@@ -0,0 +1,46 @@
+// Package symbol lays out symbol layers for server-side labelling: it
+// shapes text-field into positioned glyphs using glyph PBF metrics, places
+// icons and labels at points or along lines and resolves collisions.
+//
+// All positions are in pixels with y pointing down.
+package symbol
+
+import "strings"
+
+// OneEm is the font size glyph PBF metrics are generated at.
+const OneEm = 24
+
+// GlyphBorder is the SDF buffer around glyph PBF bitmaps.
+const GlyphBorder = 3
+
+// GlyphMetrics are the metrics of a glyph PBF glyph, in pixels at OneEm.
+type GlyphMetrics struct {
+	Width   uint32
+	Height  uint32
+	Left    int32
+	Top     int32
+	Advance uint32
+}
+
+// GlyphSource looks up the metrics of a code point in a font stack.
+type GlyphSource interface {
+	Glyph(fontstack []string, r rune) (GlyphMetrics, bool)
+}
+
+// GlyphSet holds glyph metrics by font stack name and code point. A font
+// stack is looked up by its comma joined name first and then font by font.
+type GlyphSet map[string]map[rune]GlyphMetrics
+
+func (s GlyphSet) Glyph(fontstack []string, r rune) (GlyphMetrics, bool) {
+	if glyphs, ok := s[strings.Join(fontstack, ",")]; ok {
+		if g, ok := glyphs[r]; ok {
+			return g, true
+		}
+	}
+	for _, font := range fontstack {
+		if g, ok := s[font][r]; ok {
+			return g, true
+		}
+	}
+	return GlyphMetrics{}, false
+}
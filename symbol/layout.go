@@ -0,0 +1,297 @@
+package symbol
+
+import (
+	"github.com/flywave/go-mapbox/sprite"
+	"github.com/flywave/go-mapbox/style"
+	"github.com/pkg/errors"
+)
+
+// Symbol placements, as in symbol-placement.
+const (
+	PlacementPoint      = "point"
+	PlacementLine       = "line"
+	PlacementLineCenter = "line-center"
+)
+
+// DefaultFontstack is the text-font default.
+var DefaultFontstack = []string{"Open Sans Regular", "Arial Unicode MS Regular"}
+
+// Symbol is a laid out label and icon at one anchor. Quads and boxes are in
+// pixels; boxes include text-padding and icon-padding.
+type Symbol struct {
+	Layer  string
+	Anchor Point
+	Text   string
+
+	TextQuads []Quad
+	Icon      *Quad
+	TextBoxes []Box
+	IconBoxes []Box
+
+	TextAllowOverlap    bool
+	TextIgnorePlacement bool
+	IconAllowOverlap    bool
+	IconIgnorePlacement bool
+}
+
+// Engine lays out symbol layers with glyph metrics and sprite icons.
+type Engine struct {
+	Glyphs GlyphSource
+	Icons  map[string]*sprite.TextureSprite
+}
+
+// NewEngine returns an engine using glyphs and the icons of a sprite index.
+func NewEngine(glyphs GlyphSource, icons map[string]*sprite.TextureSprite) *Engine {
+	return &Engine{Glyphs: glyphs, Icons: icons}
+}
+
+// Layout evaluates the layout properties of a symbol layer for a feature
+// and returns its symbols. geometry is the feature geometry in screen
+// pixels. Point placement puts a symbol on every point, the start of every
+// line and the centroid of a polygon's first ring; line placement bends the
+// text along every line and ring. Glyphs and icons missing from the engine
+// are skipped.
+func (e *Engine) Layout(l *style.Layer, f *style.Feature, zoom float64, geometry [][]Point) ([]*Symbol, error) {
+	if l.Type != style.LayerTypeSymbol {
+		return nil, errors.Errorf("layer %q: not a symbol layer", l.ID)
+	}
+	p, err := e.properties(l.Layout, f, zoom)
+	if err != nil {
+		return nil, errors.Wrapf(err, "layer %q", l.ID)
+	}
+	if p.shaping == nil && p.icon == nil {
+		return nil, nil
+	}
+
+	var symbols []*Symbol
+	newSymbol := func(anchor Point) *Symbol {
+		s := &Symbol{
+			Layer:               l.ID,
+			Anchor:              anchor,
+			Text:                p.text,
+			TextAllowOverlap:    p.textAllowOverlap,
+			TextIgnorePlacement: p.textIgnorePlacement,
+			IconAllowOverlap:    p.iconAllowOverlap,
+			IconIgnorePlacement: p.iconIgnorePlacement,
+		}
+		symbols = append(symbols, s)
+		return s
+	}
+
+	if p.placement == PlacementPoint {
+		for _, anchor := range pointAnchors(f.Type, geometry) {
+			s := newSymbol(anchor)
+			if p.shaping != nil {
+				for _, q := range GlyphQuads(p.shaping) {
+					s.TextQuads = append(s.TextQuads, q.Translate(anchor))
+				}
+				s.TextBoxes = []Box{p.shaping.Box().Translate(anchor).Pad(p.textPadding, p.textPadding, p.textPadding, p.textPadding)}
+			}
+			if p.icon != nil {
+				q := p.icon.Quad().Translate(anchor)
+				s.Icon = &q
+				s.IconBoxes = []Box{p.icon.Box.Translate(anchor).Pad(p.iconPadding[0], p.iconPadding[1], p.iconPadding[2], p.iconPadding[3])}
+			}
+		}
+		return symbols, nil
+	}
+
+	if f.Type == style.FilterTypePoint {
+		return nil, nil
+	}
+	labelLength := 0.0
+	if p.shaping != nil {
+		labelLength = p.shaping.Right - p.shaping.Left
+	} else {
+		labelLength = p.icon.Box.MaxX - p.icon.Box.MinX
+	}
+	spacing := p.spacing
+	if p.placement == PlacementLineCenter {
+		spacing = 0
+	}
+	for _, line := range geometry {
+		for _, anchor := range LineAnchors(line, spacing, labelLength, p.maxAngle) {
+			var quads []Quad
+			if p.shaping != nil {
+				var ok bool
+				if quads, ok = PlaceOnLine(p.shaping, line, anchor); !ok {
+					continue
+				}
+			}
+			s := newSymbol(anchor.Point)
+			s.TextQuads = quads
+			for _, q := range quads {
+				s.TextBoxes = append(s.TextBoxes, q.Bounds().Pad(p.textPadding, p.textPadding, p.textPadding, p.textPadding))
+			}
+			if p.icon != nil {
+				q := p.icon.Quad().Rotate(anchor.Angle).Translate(anchor.Point)
+				s.Icon = &q
+				s.IconBoxes = []Box{q.Bounds().Pad(p.iconPadding[0], p.iconPadding[1], p.iconPadding[2], p.iconPadding[3])}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// symbolProperties are the evaluated layout properties of one feature.
+type symbolProperties struct {
+	placement           string
+	spacing, maxAngle   float64
+	text                string
+	shaping             *Shaping
+	icon                *ShapedIcon
+	textPadding         float64
+	iconPadding         style.Padding
+	textAllowOverlap    bool
+	textIgnorePlacement bool
+	iconAllowOverlap    bool
+	iconIgnorePlacement bool
+}
+
+func (e *Engine) properties(layout *style.Layout, f *style.Feature, zoom float64) (*symbolProperties, error) {
+	p := &symbolProperties{}
+	var err error
+	if p.placement, err = layout.SymbolPlacementAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "symbol-placement")
+	}
+	if p.spacing, err = layout.SymbolSpacingAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "symbol-spacing")
+	}
+	if p.maxAngle, err = layout.TextMaxAngleAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "text-max-angle")
+	}
+	if p.textPadding, err = layout.TextPaddingAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "text-padding")
+	}
+	if p.iconPadding, err = layout.IconPaddingAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "icon-padding")
+	}
+	if layout != nil {
+		p.textAllowOverlap = layout.TextAllowOverlap != nil && *layout.TextAllowOverlap
+		p.textIgnorePlacement = layout.TextIgnorePlacement != nil && *layout.TextIgnorePlacement
+		p.iconAllowOverlap = layout.IconAllowOverlap != nil && *layout.IconAllowOverlap
+		p.iconIgnorePlacement = layout.IconIgnorePlacement != nil && *layout.IconIgnorePlacement
+	}
+
+	if p.text, err = layout.TextFieldAt(zoom, f); err != nil {
+		return nil, errors.Wrap(err, "text-field")
+	}
+	if p.text != "" && e.Glyphs != nil {
+		opts, err := shapeOptions(layout, f, zoom, p.placement != PlacementPoint)
+		if err != nil {
+			return nil, err
+		}
+		fontstack := DefaultFontstack
+		if layout != nil && len(layout.TextFont) > 0 {
+			fontstack = layout.TextFont
+		}
+		if s := Shape(p.text, fontstack, e.Glyphs, opts); len(s.Glyphs) > 0 {
+			p.shaping = s
+		}
+	}
+
+	name, err := layout.IconImageAt(zoom, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "icon-image")
+	}
+	if icon, ok := e.Icons[name]; ok && name != "" {
+		size, err := layout.IconSizeAt(zoom, f)
+		if err != nil {
+			return nil, errors.Wrap(err, "icon-size")
+		}
+		offset, err := layout.IconOffsetAt(zoom, f)
+		if err != nil {
+			return nil, errors.Wrap(err, "icon-offset")
+		}
+		anchor := AnchorCenter
+		if layout != nil && layout.IconAnchor != "" {
+			anchor = layout.IconAnchor
+		}
+		p.icon = ShapeIcon(name, icon, anchor, pair(offset), size)
+		if p.shaping != nil && layout != nil {
+			var padding [4]float64
+			copy(padding[:], layout.IconTextFitPadding)
+			p.icon = FitIcon(p.icon, p.shaping, layout.IconTextFit, padding)
+		}
+	}
+	return p, nil
+}
+
+func shapeOptions(layout *style.Layout, f *style.Feature, zoom float64, onLine bool) (ShapeOptions, error) {
+	opts := DefaultShapeOptions()
+	var err error
+	if opts.Size, err = layout.TextSizeAt(zoom, f); err != nil {
+		return opts, errors.Wrap(err, "text-size")
+	}
+	if opts.MaxWidth, err = layout.TextMaxWidthAt(zoom, f); err != nil {
+		return opts, errors.Wrap(err, "text-max-width")
+	}
+	if opts.LineHeight, err = layout.TextLineHeightAt(zoom, f); err != nil {
+		return opts, errors.Wrap(err, "text-line-height")
+	}
+	if opts.LetterSpacing, err = layout.TextLetterSpacingAt(zoom, f); err != nil {
+		return opts, errors.Wrap(err, "text-letter-spacing")
+	}
+	offset, err := layout.TextOffsetAt(zoom, f)
+	if err != nil {
+		return opts, errors.Wrap(err, "text-offset")
+	}
+	opts.Offset = pair(offset)
+	if layout != nil {
+		if layout.TextJustify != "" {
+			opts.Justify = layout.TextJustify
+		}
+		if layout.TextAnchor != "" {
+			opts.Anchor = layout.TextAnchor
+		}
+	}
+	if onLine {
+		// Line labels are single lines centered on their anchor.
+		opts.MaxWidth, opts.Justify, opts.Anchor = 0, JustifyCenter, AnchorCenter
+	}
+	return opts, nil
+}
+
+func pair(v []float64) [2]float64 {
+	var p [2]float64
+	copy(p[:], v)
+	return p
+}
+
+// pointAnchors returns the anchors of point placement.
+func pointAnchors(geomType string, geometry [][]Point) []Point {
+	var anchors []Point
+	switch geomType {
+	case style.FilterTypePolygon:
+		if len(geometry) > 0 && len(geometry[0]) > 0 {
+			anchors = append(anchors, centroid(geometry[0]))
+		}
+	default:
+		for _, part := range geometry {
+			if len(part) > 0 {
+				anchors = append(anchors, part[0])
+			}
+			if geomType == style.FilterTypePoint {
+				anchors = append(anchors, part[min(1, len(part)):]...)
+			}
+		}
+	}
+	return anchors
+}
+
+// centroid returns the area centroid of a ring, or its first point if the
+// ring has no area.
+func centroid(ring []Point) Point {
+	var a, cx, cy float64
+	for i := range ring {
+		p, q := ring[i], ring[(i+1)%len(ring)]
+		cross := p.X*q.Y - q.X*p.Y
+		a += cross
+		cx += (p.X + q.X) * cross
+		cy += (p.Y + q.Y) * cross
+	}
+	if a == 0 {
+		return ring[0]
+	}
+	return Point{cx / (3 * a), cy / (3 * a)}
+}
@@ -0,0 +1,157 @@
+package symbol
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/flywave/go-mapbox/sprite"
+	"github.com/flywave/go-mapbox/style"
+)
+
+func decodeLayer(t *testing.T, raw string) *style.Layer {
+	t.Helper()
+	var l style.Layer
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		t.Fatal(err)
+	}
+	return &l
+}
+
+func testEngine() *Engine {
+	icons := map[string]*sprite.TextureSprite{
+		"dot": {TextureMeta: &sprite.TextureMeta{Width: 10, Height: 10, PixelRatio: 1}},
+	}
+	return NewEngine(monospace(), icons)
+}
+
+func TestLayoutPoint(t *testing.T) {
+	l := decodeLayer(t, `{"id": "poi", "type": "symbol", "source": "s",
+		"layout": {"text-field": "{name}", "text-font": ["Test Regular"], "text-size": 24,
+			"text-anchor": "left", "icon-image": "dot", "text-padding": 0}}`)
+	f := &style.Feature{Type: "Point", Properties: map[string]interface{}{"name": "ab"}}
+	symbols, err := testEngine().Layout(l, f, 10, [][]Point{{{100, 100}}, {{300, 300}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 2 {
+		t.Fatalf("symbols = %d", len(symbols))
+	}
+	s := symbols[0]
+	if s.Text != "ab" || len(s.TextQuads) != 2 || s.Icon == nil {
+		t.Fatalf("symbol = %+v", s)
+	}
+	if b := s.TextBoxes[0]; b.MinX != 100 || b.MaxX != 124 {
+		t.Errorf("text box = %+v", b)
+	}
+	// The default icon-padding is 2.
+	if b := s.IconBoxes[0]; b != (Box{93, 93, 107, 107}) {
+		t.Errorf("icon box = %+v", b)
+	}
+
+	missing := &style.Feature{Type: "Point", Properties: map[string]interface{}{}}
+	l.Layout.IconImage = nil
+	symbols, err = testEngine().Layout(l, missing, 10, [][]Point{{{0, 0}}})
+	if err != nil || len(symbols) != 0 {
+		t.Errorf("symbols without text or icon = %v, %v", symbols, err)
+	}
+}
+
+func TestLayoutIconTextFit(t *testing.T) {
+	l := decodeLayer(t, `{"id": "shield", "type": "symbol", "source": "s",
+		"layout": {"text-field": "abcd", "text-font": ["Test Regular"], "text-size": 24,
+			"icon-image": "dot", "icon-text-fit": "both", "icon-text-fit-padding": [1, 1, 1, 1]}}`)
+	symbols, err := testEngine().Layout(l, &style.Feature{Type: "Point"}, 10, [][]Point{{{0, 0}}})
+	if err != nil || len(symbols) != 1 {
+		t.Fatalf("symbols = %v, %v", symbols, err)
+	}
+	if q := symbols[0].Icon; q.TL.X != -25 || q.BR.X != 25 {
+		t.Errorf("icon quad = %+v", q)
+	}
+}
+
+func TestLayoutLine(t *testing.T) {
+	l := decodeLayer(t, `{"id": "road", "type": "symbol", "source": "s",
+		"layout": {"text-field": "abcd", "text-font": ["Test Regular"], "text-size": 24,
+			"symbol-placement": "line", "symbol-spacing": 200}}`)
+	f := &style.Feature{Type: "LineString"}
+	// A 400px line gets two anchors, at 100 and 300.
+	symbols, err := testEngine().Layout(l, f, 10, [][]Point{{{0, 50}, {400, 50}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 2 || symbols[0].Anchor != (Point{100, 50}) || symbols[1].Anchor != (Point{300, 50}) {
+		t.Fatalf("anchors = %v", symbols)
+	}
+	if len(symbols[0].TextQuads) != 4 || len(symbols[0].TextBoxes) != 4 {
+		t.Errorf("quads = %v", symbols[0].TextQuads)
+	}
+
+	// Drawn right to left, the text still reads left to right.
+	symbols, err = testEngine().Layout(l, f, 10, [][]Point{{{400, 50}, {0, 50}}})
+	if err != nil || len(symbols) != 2 {
+		t.Fatalf("reversed = %v, %v", symbols, err)
+	}
+	q := symbols[0].TextQuads
+	if q[0].TL.X > q[1].TL.X || q[0].TL.Y > q[0].BL.Y {
+		t.Errorf("reversed line quads upside down: %+v", q[:2])
+	}
+
+	// Too short for the label.
+	symbols, _ = testEngine().Layout(l, f, 10, [][]Point{{{0, 0}, {30, 0}}})
+	if len(symbols) != 0 {
+		t.Errorf("short line symbols = %v", symbols)
+	}
+}
+
+func TestPlaceOnLineBends(t *testing.T) {
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	s := Shape("abcd", testFont, monospace(), opts)
+	line := []Point{{0, 0}, {100, 0}, {100, 100}}
+	quads, ok := PlaceOnLine(s, line, Anchor{Point: Point{100, 0}, Distance: 100})
+	if !ok || len(quads) != 4 {
+		t.Fatalf("quads = %v, %v", quads, ok)
+	}
+	// The first glyphs follow the horizontal segment, the last ones turn
+	// down the vertical one.
+	if quads[0].TR.Y != quads[0].TL.Y || quads[3].TR.X != quads[3].TL.X {
+		t.Errorf("quads = %+v", quads)
+	}
+	if _, ok := PlaceOnLine(s, line, Anchor{Point: Point{0, 0}}); ok {
+		t.Error("placed text running off the line")
+	}
+}
+
+func TestLineAnchorsMaxAngle(t *testing.T) {
+	zigzag := []Point{{0, 0}, {50, 0}, {50, 50}, {100, 50}}
+	if a := LineAnchors(zigzag, 0, 80, 45); len(a) != 0 {
+		t.Errorf("anchors over sharp turns = %v", a)
+	}
+	if a := LineAnchors(zigzag, 0, 80, 180); len(a) != 1 || math.Abs(a[0].Angle-math.Pi/2) > 1e-9 {
+		t.Errorf("anchors = %v", a)
+	}
+}
+
+func TestCollisionIndex(t *testing.T) {
+	a := &Symbol{TextBoxes: []Box{{0, 0, 100, 20}}}
+	b := &Symbol{TextBoxes: []Box{{50, 10, 150, 30}}}
+	c := &Symbol{TextBoxes: []Box{{200, 0, 300, 20}}}
+	placed := NewCollisionIndex().Place([]*Symbol{a, b, c})
+	if len(placed) != 2 || placed[0] != a || placed[1] != c {
+		t.Errorf("placed = %v", placed)
+	}
+
+	b.TextAllowOverlap = true
+	b.TextIgnorePlacement = true
+	d := &Symbol{TextBoxes: []Box{{120, 25, 140, 35}}}
+	placed = NewCollisionIndex().Place([]*Symbol{a, b, d})
+	if len(placed) != 3 {
+		t.Errorf("placed with overlap = %v", placed)
+	}
+
+	icon := &Symbol{IconBoxes: []Box{{90, 0, 110, 20}}}
+	if placed := NewCollisionIndex().Place([]*Symbol{a, icon}); len(placed) != 1 {
+		t.Errorf("icon collided with nothing: %v", placed)
+	}
+}
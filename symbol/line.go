@@ -0,0 +1,125 @@
+package symbol
+
+import "math"
+
+// Anchor is a label position on a line.
+type Anchor struct {
+	Point
+	// Angle is the direction of the line at the anchor, in radians.
+	Angle float64
+	// Distance is the distance of the anchor from the start of the line.
+	Distance float64
+}
+
+func lineLength(line []Point) float64 {
+	l := 0.0
+	for i := 1; i < len(line); i++ {
+		l += math.Hypot(line[i].X-line[i-1].X, line[i].Y-line[i-1].Y)
+	}
+	return l
+}
+
+// pointAt returns the point at distance d along the line and the direction
+// of the segment it lies on. ok is false if d is off the line.
+func pointAt(line []Point, d float64) (Point, float64, bool) {
+	if d < 0 {
+		return Point{}, 0, false
+	}
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		seg := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if seg == 0 {
+			continue
+		}
+		if d <= seg {
+			t := d / seg
+			return Point{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}, math.Atan2(b.Y-a.Y, b.X-a.X), true
+		}
+		d -= seg
+	}
+	return Point{}, 0, false
+}
+
+// LineAnchors returns label anchors spaced along a line, as for
+// symbol-placement: line. Anchors leave room for labelLength pixels of text
+// centered on them and are dropped where the line turns by more than
+// maxAngle degrees in total under the label. A line shorter than spacing
+// gets a single anchor at its middle.
+func LineAnchors(line []Point, spacing, labelLength, maxAngle float64) []Anchor {
+	total := lineLength(line)
+	if total < labelLength || total == 0 {
+		return nil
+	}
+	var distances []float64
+	if spacing <= 0 || total < spacing {
+		distances = []float64{total / 2}
+	} else {
+		n := math.Floor(total / spacing)
+		start := (total - (n-1)*spacing) / 2
+		for i := 0.0; i < n; i++ {
+			distances = append(distances, start+i*spacing)
+		}
+	}
+	var anchors []Anchor
+	for _, d := range distances {
+		if d < labelLength/2 || d > total-labelLength/2 {
+			continue
+		}
+		p, angle, ok := pointAt(line, d)
+		if !ok || turning(line, d-labelLength/2, d+labelLength/2) > maxAngle*math.Pi/180 {
+			continue
+		}
+		anchors = append(anchors, Anchor{Point: p, Angle: angle, Distance: d})
+	}
+	return anchors
+}
+
+// turning returns the total absolute turn of the line, in radians, at the
+// vertices between distances from and to.
+func turning(line []Point, from, to float64) float64 {
+	sum, d := 0.0, 0.0
+	for i := 1; i+1 < len(line); i++ {
+		d += math.Hypot(line[i].X-line[i-1].X, line[i].Y-line[i-1].Y)
+		if d <= from || d >= to {
+			continue
+		}
+		a := math.Atan2(line[i].Y-line[i-1].Y, line[i].X-line[i-1].X)
+		b := math.Atan2(line[i+1].Y-line[i].Y, line[i+1].X-line[i].X)
+		delta := math.Abs(b - a)
+		if delta > math.Pi {
+			delta = 2*math.Pi - delta
+		}
+		sum += delta
+	}
+	return sum
+}
+
+// PlaceOnLine bends shaped text along a line, centered on the anchor. Each
+// glyph is rotated to the direction of the line under its center. Text that
+// would read upside down follows the line backwards. ok is false if the
+// text runs off the line.
+func PlaceOnLine(s *Shaping, line []Point, anchor Anchor) ([]Quad, bool) {
+	d := anchor.Distance
+	if math.Cos(anchor.Angle) < 0 {
+		reversed := make([]Point, len(line))
+		for i, p := range line {
+			reversed[len(line)-1-i] = p
+		}
+		line, d = reversed, lineLength(line)-d
+	}
+	var quads []Quad
+	for _, g := range s.Glyphs {
+		if g.Metrics.Width == 0 || g.Metrics.Height == 0 {
+			continue
+		}
+		center := g.X + float64(g.Metrics.Advance)*s.Scale/2
+		p, angle, ok := pointAt(line, d+center)
+		if !ok {
+			return nil, false
+		}
+		q := newQuad(glyphBox(g, s.Scale)).Translate(Point{-center, 0}).Rotate(angle).Translate(p)
+		q.Rune = g.Rune
+		quads = append(quads, q)
+	}
+	return quads, true
+}
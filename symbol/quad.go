@@ -0,0 +1,169 @@
+package symbol
+
+import (
+	"image"
+	"math"
+
+	"github.com/flywave/go-mapbox/sprite"
+)
+
+// Icon text fit modes, as in icon-text-fit.
+const (
+	TextFitNone   = "none"
+	TextFitWidth  = "width"
+	TextFitHeight = "height"
+	TextFitBoth   = "both"
+)
+
+// Point is a position in pixels.
+type Point struct {
+	X, Y float64
+}
+
+// Box is an axis aligned box in pixels.
+type Box struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Translate returns b moved by p.
+func (b Box) Translate(p Point) Box {
+	return Box{b.MinX + p.X, b.MinY + p.Y, b.MaxX + p.X, b.MaxY + p.Y}
+}
+
+// Pad returns b grown by the top, right, bottom and left padding.
+func (b Box) Pad(top, right, bottom, left float64) Box {
+	return Box{b.MinX - left, b.MinY - top, b.MaxX + right, b.MaxY + bottom}
+}
+
+// Intersects reports whether b and o overlap.
+func (b Box) Intersects(o Box) bool {
+	return b.MinX < o.MaxX && o.MinX < b.MaxX && b.MinY < o.MaxY && o.MinY < b.MaxY
+}
+
+// Quad is a textured quad of a glyph or an icon. The corners are clockwise
+// from the top left of the texture.
+type Quad struct {
+	TL, TR, BR, BL Point
+	// Rune is the glyph drawn, 0 for icons.
+	Rune rune
+	// Image is the icon drawn, empty for glyphs.
+	Image string
+	// Tex is the icon's rectangle in its sprite sheet.
+	Tex image.Rectangle
+}
+
+func newQuad(b Box) Quad {
+	return Quad{
+		TL: Point{b.MinX, b.MinY}, TR: Point{b.MaxX, b.MinY},
+		BR: Point{b.MaxX, b.MaxY}, BL: Point{b.MinX, b.MaxY},
+	}
+}
+
+func (q *Quad) corners() []*Point {
+	return []*Point{&q.TL, &q.TR, &q.BR, &q.BL}
+}
+
+// Bounds returns the axis aligned bounds of the quad.
+func (q Quad) Bounds() Box {
+	b := Box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range q.corners() {
+		b.MinX, b.MinY = math.Min(b.MinX, p.X), math.Min(b.MinY, p.Y)
+		b.MaxX, b.MaxY = math.Max(b.MaxX, p.X), math.Max(b.MaxY, p.Y)
+	}
+	return b
+}
+
+// Rotate returns q rotated by angle radians clockwise around the origin.
+func (q Quad) Rotate(angle float64) Quad {
+	sin, cos := math.Sincos(angle)
+	for _, p := range q.corners() {
+		p.X, p.Y = p.X*cos-p.Y*sin, p.X*sin+p.Y*cos
+	}
+	return q
+}
+
+// Translate returns q moved by d.
+func (q Quad) Translate(d Point) Quad {
+	for _, p := range q.corners() {
+		p.X, p.Y = p.X+d.X, p.Y+d.Y
+	}
+	return q
+}
+
+// glyphBox returns the box of a glyph's SDF bitmap, including its border.
+func glyphBox(g PositionedGlyph, scale float64) Box {
+	m := g.Metrics
+	x := g.X + (float64(m.Left)-GlyphBorder)*scale
+	y := g.Y + (-float64(m.Top)-GlyphBorder)*scale
+	return Box{x, y, x + float64(m.Width+2*GlyphBorder)*scale, y + float64(m.Height+2*GlyphBorder)*scale}
+}
+
+// GlyphQuads returns a quad per visible glyph of the shaping, relative to
+// its anchor.
+func GlyphQuads(s *Shaping) []Quad {
+	var quads []Quad
+	for _, g := range s.Glyphs {
+		if g.Metrics.Width == 0 || g.Metrics.Height == 0 {
+			continue
+		}
+		q := newQuad(glyphBox(g, s.Scale))
+		q.Rune = g.Rune
+		quads = append(quads, q)
+	}
+	return quads
+}
+
+// ShapedIcon is an icon positioned relative to its anchor.
+type ShapedIcon struct {
+	Image string
+	Tex   image.Rectangle
+	Box   Box
+}
+
+// ShapeIcon positions a sprite image for icon-anchor, icon-offset in pixels
+// and icon-size.
+func ShapeIcon(name string, icon *sprite.TextureSprite, anchor string, offset [2]float64, size float64) *ShapedIcon {
+	ratio := float64(icon.PixelRatio)
+	if ratio <= 0 {
+		ratio = 1
+	}
+	w, h := float64(icon.Width)/ratio*size, float64(icon.Height)/ratio*size
+	hAlign, vAlign := anchorAlign(anchor)
+	left, top := offset[0]*size-w*hAlign, offset[1]*size-h*vAlign
+	return &ShapedIcon{
+		Image: name,
+		Tex:   image.Rect(icon.X, icon.Y, icon.X+icon.Width, icon.Y+icon.Height),
+		Box:   Box{left, top, left + w, top + h},
+	}
+}
+
+// Quad returns the icon's quad relative to its anchor.
+func (i *ShapedIcon) Quad() Quad {
+	q := newQuad(i.Box)
+	q.Image, q.Tex = i.Image, i.Tex
+	return q
+}
+
+// FitIcon stretches the icon around the text for icon-text-fit. padding
+// is icon-text-fit-padding: top, right, bottom and left.
+func FitIcon(icon *ShapedIcon, text *Shaping, fit string, padding [4]float64) *ShapedIcon {
+	if fit == "" || fit == TextFitNone {
+		return icon
+	}
+	b, t := icon.Box, text.Box()
+	w, h := b.MaxX-b.MinX, b.MaxY-b.MinY
+	out := *icon
+	if fit == TextFitWidth || fit == TextFitBoth {
+		out.Box.MinX, out.Box.MaxX = t.MinX-padding[3], t.MaxX+padding[1]
+	} else {
+		cx := (t.MinX + t.MaxX) / 2
+		out.Box.MinX, out.Box.MaxX = cx-w/2, cx+w/2
+	}
+	if fit == TextFitHeight || fit == TextFitBoth {
+		out.Box.MinY, out.Box.MaxY = t.MinY-padding[0], t.MaxY+padding[2]
+	} else {
+		cy := (t.MinY + t.MaxY) / 2
+		out.Box.MinY, out.Box.MaxY = cy-h/2, cy+h/2
+	}
+	return &out
+}
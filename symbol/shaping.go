@@ -0,0 +1,238 @@
+package symbol
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Text justifications and anchors, as in text-justify and text-anchor.
+const (
+	JustifyAuto   = "auto"
+	JustifyLeft   = "left"
+	JustifyCenter = "center"
+	JustifyRight  = "right"
+
+	AnchorCenter      = "center"
+	AnchorLeft        = "left"
+	AnchorRight       = "right"
+	AnchorTop         = "top"
+	AnchorBottom      = "bottom"
+	AnchorTopLeft     = "top-left"
+	AnchorTopRight    = "top-right"
+	AnchorBottomLeft  = "bottom-left"
+	AnchorBottomRight = "bottom-right"
+)
+
+// baselineOffset is the vertical pen position of the first line, which
+// roughly centers a line of text on its anchor.
+const baselineOffset = -17
+
+// ShapeOptions control text shaping. Lengths are in ems, as in the style
+// spec.
+type ShapeOptions struct {
+	// Size is the text size in pixels; 0 means the default of 16.
+	Size float64
+	// MaxWidth is the width lines are broken at; 0 disables breaking.
+	MaxWidth      float64
+	LineHeight    float64
+	LetterSpacing float64
+	Justify       string
+	Anchor        string
+	Offset        [2]float64
+}
+
+// DefaultShapeOptions returns the style spec defaults.
+func DefaultShapeOptions() ShapeOptions {
+	return ShapeOptions{Size: 16, MaxWidth: 10, LineHeight: 1.2, Justify: JustifyCenter, Anchor: AnchorCenter}
+}
+
+// PositionedGlyph is a glyph with the pen position of its origin.
+type PositionedGlyph struct {
+	Rune    rune
+	X, Y    float64
+	Metrics GlyphMetrics
+}
+
+// Shaping is shaped text relative to its anchor. Glyphs missing from the
+// glyph source are dropped.
+type Shaping struct {
+	Glyphs []PositionedGlyph
+	Lines  int
+	// Scale converts glyph metrics to pixels.
+	Scale                    float64
+	Top, Bottom, Left, Right float64
+}
+
+// Box returns the bounds of the shaped text.
+func (s *Shaping) Box() Box {
+	return Box{MinX: s.Left, MinY: s.Top, MaxX: s.Right, MaxY: s.Bottom}
+}
+
+// Shape lays out text with the font stack. Lines are broken at explicit
+// newlines and, when MaxWidth is set, at whitespace, hyphens and between
+// ideographs so that lines are about the same width.
+func Shape(text string, fontstack []string, glyphs GlyphSource, opts ShapeOptions) *Shaping {
+	if opts.Size <= 0 {
+		opts.Size = 16
+	}
+	scale := opts.Size / OneEm
+	spacing := opts.LetterSpacing * OneEm
+	lineHeight := opts.LineHeight * OneEm
+
+	advance := func(r rune) float64 {
+		g, ok := glyphs.Glyph(fontstack, r)
+		if !ok {
+			return 0
+		}
+		return float64(g.Advance) + spacing
+	}
+	var lines [][]rune
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		lines = append(lines, breakLines([]rune(paragraph), advance, opts.MaxWidth*OneEm)...)
+	}
+
+	hAlign, vAlign := anchorAlign(opts.Anchor)
+	justify := hAlign
+	switch opts.Justify {
+	case JustifyLeft:
+		justify = 0
+	case JustifyRight:
+		justify = 1
+	case JustifyCenter, "":
+		justify = 0.5
+	}
+
+	s := &Shaping{Lines: len(lines), Scale: scale}
+	maxLineLength := 0.0
+	y := float64(baselineOffset)
+	for _, line := range lines {
+		start := len(s.Glyphs)
+		x := 0.0
+		for _, r := range trimSpace(line) {
+			g, ok := glyphs.Glyph(fontstack, r)
+			if !ok {
+				continue
+			}
+			s.Glyphs = append(s.Glyphs, PositionedGlyph{Rune: r, X: x, Y: y, Metrics: g})
+			x += float64(g.Advance) + spacing
+		}
+		if len(s.Glyphs) > start {
+			lineLength := x - spacing
+			maxLineLength = math.Max(maxLineLength, lineLength)
+			for i := start; i < len(s.Glyphs); i++ {
+				s.Glyphs[i].X -= lineLength * justify
+			}
+		}
+		y += lineHeight
+	}
+
+	height := float64(len(lines)) * lineHeight
+	shiftX := (justify - hAlign) * maxLineLength
+	shiftY := (-vAlign*float64(len(lines)) + 0.5) * lineHeight
+	offsetX, offsetY := opts.Offset[0]*OneEm, opts.Offset[1]*OneEm
+	for i := range s.Glyphs {
+		g := &s.Glyphs[i]
+		g.X = (g.X + shiftX + offsetX) * scale
+		g.Y = (g.Y + shiftY + offsetY) * scale
+	}
+	s.Top = (offsetY - vAlign*height) * scale
+	s.Bottom = s.Top + height*scale
+	s.Left = (offsetX - hAlign*maxLineLength) * scale
+	s.Right = s.Left + maxLineLength*scale
+	return s
+}
+
+// anchorAlign returns the horizontal and vertical position of the anchor
+// within the text box, from 0 (left, top) to 1 (right, bottom).
+func anchorAlign(anchor string) (float64, float64) {
+	h, v := 0.5, 0.5
+	switch anchor {
+	case AnchorLeft, AnchorTopLeft, AnchorBottomLeft:
+		h = 0
+	case AnchorRight, AnchorTopRight, AnchorBottomRight:
+		h = 1
+	}
+	switch anchor {
+	case AnchorTop, AnchorTopLeft, AnchorTopRight:
+		v = 0
+	case AnchorBottom, AnchorBottomLeft, AnchorBottomRight:
+		v = 1
+	}
+	return h, v
+}
+
+// breakLines splits a paragraph into lines no wider than about maxWidth.
+// Like Mapbox GL it aims for lines of equal width rather than filling
+// lines greedily, choosing the breaks with the least squared deviation from
+// the target width.
+func breakLines(text []rune, advance func(rune) float64, maxWidth float64) [][]rune {
+	width := func(from, to int) float64 {
+		w := 0.0
+		for _, r := range trimSpace(text[from:to]) {
+			w += advance(r)
+		}
+		return w
+	}
+	total := width(0, len(text))
+	if maxWidth <= 0 || total <= maxWidth {
+		return [][]rune{text}
+	}
+	target := total / math.Ceil(total/maxWidth)
+
+	// Break positions: a line may end before index i.
+	breaks := []int{0}
+	for i := 1; i < len(text); i++ {
+		if breakable(text[i-1], text[i]) {
+			breaks = append(breaks, i)
+		}
+	}
+	breaks = append(breaks, len(text))
+
+	cost := make([]float64, len(breaks))
+	prev := make([]int, len(breaks))
+	for i := 1; i < len(breaks); i++ {
+		cost[i] = math.Inf(1)
+		last := i == len(breaks)-1
+		for j := 0; j < i; j++ {
+			w := width(breaks[j], breaks[i])
+			badness := (w - target) * (w - target)
+			if last && w < target {
+				badness /= 2
+			}
+			if c := cost[j] + badness; c < cost[i] {
+				cost[i], prev[i] = c, j
+			}
+		}
+	}
+	var lines [][]rune
+	for i := len(breaks) - 1; i > 0; i = prev[i] {
+		lines = append([][]rune{text[breaks[prev[i]]:breaks[i]]}, lines...)
+	}
+	return lines
+}
+
+// breakable reports whether a line may break between a and b.
+func breakable(a, b rune) bool {
+	switch {
+	case unicode.IsSpace(a) && !unicode.IsSpace(b):
+		return true
+	case a == '-' || a == '\u00ad' || a == '\u200b' || a == '/':
+		return !unicode.IsSpace(b)
+	}
+	return ideographic(a) || ideographic(b)
+}
+
+func ideographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Yi)
+}
+
+func trimSpace(line []rune) []rune {
+	for len(line) > 0 && unicode.IsSpace(line[0]) {
+		line = line[1:]
+	}
+	for len(line) > 0 && unicode.IsSpace(line[len(line)-1]) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,178 @@
+package symbol
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/flywave/go-mapbox/sprite"
+)
+
+// monospace returns a glyph set where every printable ASCII character is
+// 12 units wide, half an em.
+func monospace() GlyphSet {
+	glyphs := map[rune]GlyphMetrics{}
+	for r := rune(32); r < 127; r++ {
+		g := GlyphMetrics{Width: 10, Height: 14, Left: 1, Top: -4, Advance: 12}
+		if r == ' ' {
+			g.Width, g.Height = 0, 0
+		}
+		glyphs[r] = g
+	}
+	return GlyphSet{"Test Regular": glyphs}
+}
+
+var testFont = []string{"Test Regular"}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func lineTexts(s *Shaping) []string {
+	var lines []string
+	var b strings.Builder
+	y := math.NaN()
+	for _, g := range s.Glyphs {
+		if g.Y != y && b.Len() > 0 {
+			lines = append(lines, b.String())
+			b.Reset()
+		}
+		y = g.Y
+		b.WriteRune(g.Rune)
+	}
+	if b.Len() > 0 {
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func TestGlyphSetFallback(t *testing.T) {
+	set := GlyphSet{
+		"A":   {'a': {Advance: 1}},
+		"B":   {'b': {Advance: 2}},
+		"A,B": {'c': {Advance: 3}},
+	}
+	for r, want := range map[rune]uint32{'a': 1, 'b': 2, 'c': 3} {
+		g, ok := set.Glyph([]string{"A", "B"}, r)
+		if !ok || g.Advance != want {
+			t.Errorf("%q = %v, %v", r, g, ok)
+		}
+	}
+	if _, ok := set.Glyph([]string{"A", "B"}, 'd'); ok {
+		t.Error("found missing glyph")
+	}
+}
+
+func TestShapeSingleLine(t *testing.T) {
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	s := Shape("abcd", testFont, monospace(), opts)
+	if s.Lines != 1 || len(s.Glyphs) != 4 {
+		t.Fatalf("shaping = %+v", s)
+	}
+	// 4 glyphs of 12px centered on the anchor.
+	if s.Left != -24 || s.Right != 24 || s.Glyphs[0].X != -24 || s.Glyphs[3].X != 12 {
+		t.Errorf("left %v right %v first %v last %v", s.Left, s.Right, s.Glyphs[0].X, s.Glyphs[3].X)
+	}
+	if !approx(s.Top, -14.4) || !approx(s.Bottom, 14.4) {
+		t.Errorf("top %v bottom %v", s.Top, s.Bottom)
+	}
+
+	opts.LetterSpacing = 0.5
+	spaced := Shape("abcd", testFont, monospace(), opts)
+	if w := spaced.Right - spaced.Left; w != 4*12+3*12 {
+		t.Errorf("letter spaced width = %v", w)
+	}
+}
+
+func TestShapeLineBreaking(t *testing.T) {
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	opts.MaxWidth = 5 // 10 characters
+
+	s := Shape("aaa bbb ccc ddd", testFont, monospace(), opts)
+	if got := lineTexts(s); len(got) != 2 || got[0] != "aaa bbb" || got[1] != "ccc ddd" {
+		t.Errorf("lines = %q", got)
+	}
+	if s.Lines != 2 || !approx(s.Bottom-s.Top, 2*1.2*24) {
+		t.Errorf("lines %d height %v", s.Lines, s.Bottom-s.Top)
+	}
+
+	s = Shape("one\ntwo", testFont, monospace(), DefaultShapeOptions())
+	if got := lineTexts(s); len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("newline lines = %q", got)
+	}
+
+	opts.MaxWidth = 0
+	if got := lineTexts(Shape("aaa bbb ccc ddd", testFont, monospace(), opts)); len(got) != 1 {
+		t.Errorf("unbroken lines = %q", got)
+	}
+}
+
+func TestShapeJustifyAndAnchor(t *testing.T) {
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	opts.MaxWidth = 3
+
+	opts.Justify = JustifyLeft
+	s := Shape("aaaa bb", testFont, monospace(), opts)
+	if s.Glyphs[0].X != s.Glyphs[4].X {
+		t.Errorf("left justified lines start at %v and %v", s.Glyphs[0].X, s.Glyphs[4].X)
+	}
+	opts.Justify = JustifyRight
+	s = Shape("aaaa bb", testFont, monospace(), opts)
+	if s.Glyphs[3].X != s.Glyphs[5].X {
+		t.Errorf("right justified lines end at %v and %v", s.Glyphs[3].X, s.Glyphs[5].X)
+	}
+
+	opts.Justify = JustifyAuto
+	opts.Anchor = AnchorTopLeft
+	s = Shape("aaaa bb", testFont, monospace(), opts)
+	if s.Left != 0 || s.Top != 0 || s.Glyphs[0].X != 0 || s.Glyphs[4].X != 0 {
+		t.Errorf("top-left: box %+v glyph x %v, %v", s.Box(), s.Glyphs[0].X, s.Glyphs[4].X)
+	}
+	opts.Anchor = AnchorBottomRight
+	opts.Offset = [2]float64{1, 0}
+	s = Shape("aaaa bb", testFont, monospace(), opts)
+	if s.Right != 24 || s.Bottom != 0 {
+		t.Errorf("bottom-right with offset: box %+v", s.Box())
+	}
+}
+
+func TestGlyphQuads(t *testing.T) {
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	quads := GlyphQuads(Shape("a b", testFont, monospace(), opts))
+	if len(quads) != 2 {
+		t.Fatalf("quads = %v", quads)
+	}
+	q := quads[0]
+	// x = -18 + (1 - 3), y = -17 + (4 - 3) with the line centered.
+	if q.Rune != 'a' || q.TL != (Point{-20, -16}) || q.BR != (Point{-4, 4}) {
+		t.Errorf("quad = %+v", q)
+	}
+}
+
+func TestShapeIconAndFit(t *testing.T) {
+	meta := &sprite.TextureSprite{TextureMeta: &sprite.TextureMeta{Width: 40, Height: 20, PixelRatio: 2}, X: 10, Y: 5}
+	icon := ShapeIcon("shield", meta, AnchorBottom, [2]float64{0, 0}, 2)
+	if icon.Box != (Box{-20, -20, 20, 0}) || icon.Tex.Min.X != 10 || icon.Tex.Max.Y != 25 {
+		t.Errorf("icon = %+v", icon)
+	}
+
+	opts := DefaultShapeOptions()
+	opts.Size = 24
+	text := Shape("abcdef", testFont, monospace(), opts)
+	both := FitIcon(icon, text, TextFitBoth, [4]float64{1, 2, 3, 4})
+	want := Box{text.Left - 4, text.Top - 1, text.Right + 2, text.Bottom + 3}
+	if both.Box != want {
+		t.Errorf("both = %+v, want %+v", both.Box, want)
+	}
+	width := FitIcon(icon, text, TextFitWidth, [4]float64{})
+	if width.Box.MinX != text.Left || width.Box.MaxY-width.Box.MinY != 20 {
+		t.Errorf("width = %+v", width.Box)
+	}
+	if FitIcon(icon, text, TextFitNone, [4]float64{}) != icon {
+		t.Error("none changed the icon")
+	}
+}